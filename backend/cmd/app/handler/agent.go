@@ -10,6 +10,9 @@ import (
 	"github.com/google/uuid"
 	"github.com/xichan96/cortex-lab/internal/appdto"
 	"github.com/xichan96/cortex-lab/internal/di"
+	"github.com/xichan96/cortex-lab/internal/pkg/agentrun"
+	"github.com/xichan96/cortex-lab/internal/pkg/errcode"
+	"github.com/xichan96/cortex-lab/pkg/web/cctx"
 	"github.com/xichan96/cortex-lab/pkg/web/gx"
 	"github.com/xichan96/cortex/trigger/http"
 )
@@ -29,6 +32,8 @@ type agentChatRequest struct {
 // @Tags Agent管理
 // @Accept json
 // @Produce text/event-stream
+// @Description 运行可通过 POST /api/chat/session/{session_id}/cancel 取消, 会话ID与运行ID见响应头 X-Session-Id 与 X-Run-Id
+// @Description 角色中需审批的工具调用会等待决定, 可通过 GET /api/chat/approvals 查看并审批
 // @Router /api/agent/chat/stream [post]
 func AgentStreamChatAPI(c *gin.Context) {
 	bodyBytes, err := io.ReadAll(c.Request.Body)
@@ -72,28 +77,38 @@ func AgentStreamChatAPI(c *gin.Context) {
 		}
 	}
 
-	engine, err := di.AgentApp.Engine(reqBody.SessionID, reqBody.PromptContent, reqBody.PromptConfig, reqBody.PromptKey, roleToolConfig)
-	if err != nil {
-		gx.JSONErr(c, err)
+	run, ok := agentrun.Default.Start(reqBody.SessionID, cctx.GetUserID[string](c))
+	if !ok {
+		gx.JSONErr(c, errcode.ChatSessionBusy)
 		return
 	}
+	defer agentrun.Default.Finish(run)
 
-	httpHandler := http.NewHandler()
-	req, err := httpHandler.GetMessageRequest(c)
+	engine, err := di.AgentApp.Engine(c, run, reqBody.RoleID, reqBody.PromptContent, reqBody.PromptConfig, reqBody.PromptKey, roleToolConfig)
 	if err != nil {
 		gx.JSONErr(c, err)
 		return
 	}
+	run.SetEngine(engine)
 
-	req.SessionID = reqBody.SessionID
-	req.Message = reqBody.Message
+	stream, err := engine.ExecuteStream(reqBody.Message, nil)
+	if err != nil {
+		setSSEHeaders(c)
+		writeSSEvent(c, http.SSEvent{
+			Type:  "error",
+			Error: sseError(err),
+		})
+		return
+	}
 
-	httpHandler.StreamChatAPI(c, engine, req)
+	streamRun(c, run, run.Watch(stream))
 }
 
 // AgentChatAPI Agent聊天接口
 // @Summary Agent聊天接口
 // @Description 与Agent进行对话交互
+// @Description 运行可通过 POST /api/chat/session/{session_id}/cancel 取消, 会话ID与运行ID见响应头 X-Session-Id 与 X-Run-Id
+// @Description 角色中需审批的工具调用会等待决定, 可通过 GET /api/chat/approvals 查看并审批
 // @Tags Agent管理
 // @Accept json
 // @Produce json
@@ -146,7 +161,14 @@ func AgentChatAPI(c *gin.Context) {
 		}
 	}
 
-	engine, err := di.AgentApp.Engine(reqBody.SessionID, reqBody.PromptContent, reqBody.PromptConfig, reqBody.PromptKey, roleToolConfig)
+	run, ok := agentrun.Default.Start(reqBody.SessionID, cctx.GetUserID[string](c))
+	if !ok {
+		gx.JSONErr(c, errcode.ChatSessionBusy)
+		return
+	}
+	defer agentrun.Default.Finish(run)
+
+	engine, err := di.AgentApp.Engine(c, run, reqBody.RoleID, reqBody.PromptContent, reqBody.PromptConfig, reqBody.PromptKey, roleToolConfig)
 	if err != nil {
 		gx.JSONErr(c, err)
 		return
@@ -165,6 +187,10 @@ func AgentChatAPI(c *gin.Context) {
 	req.SessionID = reqBody.SessionID
 	req.Message = reqBody.Message
 
+	// a cancel stops the engine, which ends the request
+	run.SetEngine(engine)
+	setRunHeaders(c, run)
+
 	httpHandler.ChatAPI(c, engine, req)
 }
 
@@ -3,14 +3,14 @@ package handler
 import (
 	"encoding/json"
 	"errors"
-	"fmt"
 	"io"
+	"mime"
 	"net/http"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/xichan96/cortex-lab/internal/appdto"
 	"github.com/xichan96/cortex-lab/internal/di"
-	"github.com/xichan96/cortex-lab/pkg/ec"
 	"github.com/xichan96/cortex-lab/pkg/web/gx"
 	httptrigger "github.com/xichan96/cortex/trigger/http"
 )
@@ -22,6 +22,12 @@ import (
 // @Produce json
 // @Param page query int false "Page"
 // @Param page_size query int false "Page Size (max 100)"
+// @Param role_id query string false "Role ID"
+// @Param model_name query string false "Model Name"
+// @Param tag query string false "Tag"
+// @Param folder_id query string false "Folder ID"
+// @Param archived query bool false "List archived sessions"
+// @Param include_children query bool false "Also list the sessions created by calls between roles"
 // @Success 200 {object} gx.Response
 // @Router /chat/session [get]
 func GetChatSessionsAPI(c *gin.Context) {
@@ -55,6 +61,52 @@ func GetChatSessionsAPI(c *gin.Context) {
 	})
 }
 
+// 全文搜索聊天记录
+// @Summary Search Chat Messages
+// @Description Search message content across all sessions of the current user. Matches in snippets are wrapped in <mark>.
+// @Tags Chat
+// @Accept json
+// @Produce json
+// @Param keyword query string true "Keyword, every word must match"
+// @Param role_id query string false "Role ID"
+// @Param model_name query string false "Model name"
+// @Param start_date query string false "start date, 2006-01-02"
+// @Param end_date query string false "end date (inclusive), 2006-01-02"
+// @Param page query int false "Page"
+// @Param page_size query int false "Page Size (max 100)"
+// @Success 200 {object} gx.Response
+// @Router /chat/search [get]
+func SearchChatMessagesAPI(c *gin.Context) {
+	var req appdto.SearchChatMessagesReq
+	if err := c.ShouldBindQuery(&req); err != nil {
+		gx.JSONErr(c, gx.BErr(err))
+		return
+	}
+
+	if req.Page <= 0 {
+		req.Page = 1
+	}
+	if req.PageSize <= 0 {
+		req.PageSize = 20
+	}
+	if req.PageSize > 100 {
+		req.PageSize = 100
+	}
+
+	list, total, err := di.ChatApp.SearchMessages(c, &req)
+	if err != nil {
+		gx.JSONErr(c, gx.BErr(err))
+		return
+	}
+
+	gx.JSONSuccess(c, map[string]interface{}{
+		"list":      list,
+		"total":     total,
+		"page":      req.Page,
+		"page_size": req.PageSize,
+	})
+}
+
 // 获取会话详情
 // @Summary Get Chat Session Detail
 // @Tags Chat
@@ -77,6 +129,29 @@ func GetChatSessionAPI(c *gin.Context) {
 	gx.JSONSuccess(c, session)
 }
 
+// 获取会话调用树
+// @Summary Get Chat Session Call Tree
+// @Description The roles called with notify_role from the conversation the session belongs to, with their inputs, outputs and latency, starting from the session that started it.
+// @Tags Chat
+// @Accept json
+// @Produce json
+// @Param session_id path string true "Session ID"
+// @Success 200 {object} appdto.ChatCallNode
+// @Router /chat/session/{session_id}/calls [get]
+func GetChatCallTreeAPI(c *gin.Context) {
+	id := c.Param("session_id")
+	if id == "" {
+		gx.JSONErr(c, gx.BErr(errors.New("session_id is required")))
+		return
+	}
+	tree, err := di.ChatApp.GetCallTree(c, id)
+	if err != nil {
+		gx.JSONErr(c, err)
+		return
+	}
+	gx.JSONSuccess(c, tree)
+}
+
 // 更新会话标题
 // @Summary Update Chat Session Title
 // @Tags Chat
@@ -105,6 +180,274 @@ func UpdateChatSessionTitleAPI(c *gin.Context) {
 	gx.JSONSuccess(c, nil)
 }
 
+// 整理会话（置顶、归档、文件夹、标签）
+// @Summary Organize Chat Session
+// @Description Pin, archive, move to a folder or tag a session. Omitted fields are left unchanged, tags replace the current ones.
+// @Tags Chat
+// @Accept json
+// @Produce json
+// @Param session_id path string true "Session ID"
+// @Param body body appdto.UpdateChatSessionOrganizeReq true "Organize"
+// @Success 200 {object} gx.Response
+// @Router /chat/session/{session_id}/organize [put]
+func OrganizeChatSessionAPI(c *gin.Context) {
+	id := c.Param("session_id")
+	if id == "" {
+		gx.JSONErr(c, gx.BErr(errors.New("session_id is required")))
+		return
+	}
+	var req appdto.UpdateChatSessionOrganizeReq
+	if err := gx.BindJSON(c, &req); err != nil {
+		gx.JSONErr(c, gx.BErr(err))
+		return
+	}
+	if err := di.ChatApp.OrganizeSession(c, id, &req); err != nil {
+		gx.JSONErr(c, err)
+		return
+	}
+	gx.JSONSuccess(c, nil)
+}
+
+// 获取会话标签
+// @Summary Get Chat Tags
+// @Tags Chat
+// @Produce json
+// @Success 200 {object} []appdto.ChatTag
+// @Router /chat/tags [get]
+func GetChatTagsAPI(c *gin.Context) {
+	tags, err := di.ChatApp.GetTags(c)
+	if err != nil {
+		gx.JSONErr(c, err)
+		return
+	}
+	gx.JSONSuccess(c, tags)
+}
+
+// 获取会话文件夹
+// @Summary Get Chat Folders
+// @Tags Chat
+// @Produce json
+// @Success 200 {object} []appdto.ChatFolder
+// @Router /chat/folders [get]
+func GetChatFoldersAPI(c *gin.Context) {
+	folders, err := di.ChatApp.GetFolders(c)
+	if err != nil {
+		gx.JSONErr(c, err)
+		return
+	}
+	gx.JSONSuccess(c, folders)
+}
+
+// 创建会话文件夹
+// @Summary Create Chat Folder
+// @Tags Chat
+// @Accept json
+// @Produce json
+// @Param body body appdto.CreateChatFolderReq true "Folder"
+// @Success 200 {object} gx.Response
+// @Router /chat/folders [post]
+func CreateChatFolderAPI(c *gin.Context) {
+	var req appdto.CreateChatFolderReq
+	if err := gx.BindJSON(c, &req); err != nil {
+		gx.JSONErr(c, gx.BErr(err))
+		return
+	}
+	if strings.TrimSpace(req.Name) == "" {
+		gx.JSONErr(c, gx.BErr(errors.New("name is required")))
+		return
+	}
+	id, err := di.ChatApp.CreateFolder(c, &req)
+	if err != nil {
+		gx.JSONErr(c, err)
+		return
+	}
+	gx.JSONSuccess(c, map[string]string{"id": id})
+}
+
+// 重命名会话文件夹
+// @Summary Update Chat Folder
+// @Tags Chat
+// @Accept json
+// @Produce json
+// @Param folder_id path string true "Folder ID"
+// @Param body body appdto.UpdateChatFolderReq true "Folder"
+// @Success 200 {object} gx.Response
+// @Router /chat/folders/{folder_id} [put]
+func UpdateChatFolderAPI(c *gin.Context) {
+	var req appdto.UpdateChatFolderReq
+	if err := gx.BindJSON(c, &req); err != nil {
+		gx.JSONErr(c, gx.BErr(err))
+		return
+	}
+	req.ID = c.Param("folder_id")
+	if strings.TrimSpace(req.Name) == "" {
+		gx.JSONErr(c, gx.BErr(errors.New("name is required")))
+		return
+	}
+	if err := di.ChatApp.UpdateFolder(c, &req); err != nil {
+		gx.JSONErr(c, err)
+		return
+	}
+	gx.JSONSuccess(c, nil)
+}
+
+// 删除会话文件夹（会话保留）
+// @Summary Delete Chat Folder
+// @Description Delete a folder, its sessions are kept outside any folder.
+// @Tags Chat
+// @Produce json
+// @Param folder_id path string true "Folder ID"
+// @Success 200 {object} gx.Response
+// @Router /chat/folders/{folder_id} [delete]
+func DeleteChatFolderAPI(c *gin.Context) {
+	if err := di.ChatApp.DeleteFolder(c, c.Param("folder_id")); err != nil {
+		gx.JSONErr(c, err)
+		return
+	}
+	gx.JSONSuccess(c, nil)
+}
+
+// 导出会话
+// @Summary Export Chat Session
+// @Description Download a session as a JSON export with every message and its meta, or as a Markdown transcript of the active branch.
+// @Tags Chat
+// @Produce json
+// @Produce text/markdown
+// @Param session_id path string true "Session ID"
+// @Param format query string false "json (default) or markdown"
+// @Success 200 {object} appdto.ChatSessionBundle
+// @Router /chat/session/{session_id}/export [get]
+func ExportChatSessionAPI(c *gin.Context) {
+	id := c.Param("session_id")
+	if id == "" {
+		gx.JSONErr(c, gx.BErr(errors.New("session_id is required")))
+		return
+	}
+	var req appdto.ExportChatSessionReq
+	if err := c.ShouldBindQuery(&req); err != nil {
+		gx.JSONErr(c, gx.BErr(err))
+		return
+	}
+
+	var data []byte
+	var contentType, fileName string
+	switch req.Format {
+	case "", "json":
+		bundle, err := di.ChatApp.ExportSession(c, id)
+		if err != nil {
+			gx.JSONErr(c, err)
+			return
+		}
+		if data, err = json.MarshalIndent(bundle, "", "  "); err != nil {
+			gx.JSONErr(c, err)
+			return
+		}
+		contentType, fileName = "application/json; charset=utf-8", "session-"+id+".json"
+	case "markdown":
+		transcript, err := di.ChatApp.ExportSessionMarkdown(c, id)
+		if err != nil {
+			gx.JSONErr(c, err)
+			return
+		}
+		data = []byte(transcript)
+		contentType, fileName = "text/markdown; charset=utf-8", "session-"+id+".md"
+	default:
+		gx.JSONErr(c, gx.BErr(errors.New("format must be json or markdown")))
+		return
+	}
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": fileName}))
+	c.Data(http.StatusOK, contentType, data)
+}
+
+// 导入会话
+// @Summary Import Chat Session
+// @Description Create a session of the current user from a JSON export.
+// @Tags Chat
+// @Accept json
+// @Produce json
+// @Param body body appdto.ChatSessionBundle true "JSON export of a session"
+// @Success 200 {object} gx.Response
+// @Router /chat/session/import [post]
+func ImportChatSessionAPI(c *gin.Context) {
+	var bundle appdto.ChatSessionBundle
+	if err := gx.BindJSON(c, &bundle); err != nil {
+		gx.JSONErr(c, gx.BErr(err))
+		return
+	}
+	id, err := di.ChatApp.ImportSession(c, &bundle)
+	if err != nil {
+		gx.JSONErr(c, err)
+		return
+	}
+	gx.JSONSuccess(c, map[string]string{"session_id": id})
+}
+
+// 分享会话
+// @Summary Share Chat Session
+// @Description Create a read-only public link to the session, replacing the previous one.
+// @Tags Chat
+// @Accept json
+// @Produce json
+// @Param session_id path string true "Session ID"
+// @Param body body appdto.ShareChatSessionReq false "Share options"
+// @Success 200 {object} appdto.ChatShare
+// @Router /chat/session/{session_id}/share [post]
+func ShareChatSessionAPI(c *gin.Context) {
+	id := c.Param("session_id")
+	if id == "" {
+		gx.JSONErr(c, gx.BErr(errors.New("session_id is required")))
+		return
+	}
+	var req appdto.ShareChatSessionReq
+	if err := gx.BindJSON(c, &req); err != nil && !errors.Is(err, io.EOF) {
+		gx.JSONErr(c, gx.BErr(err))
+		return
+	}
+	share, err := di.ChatApp.ShareSession(c, id, &req)
+	if err != nil {
+		gx.JSONErr(c, err)
+		return
+	}
+	gx.JSONSuccess(c, share)
+}
+
+// 取消分享会话
+// @Summary Revoke Chat Session Share
+// @Tags Chat
+// @Produce json
+// @Param session_id path string true "Session ID"
+// @Success 200 {object} gx.Response
+// @Router /chat/session/{session_id}/share [delete]
+func RevokeChatSessionShareAPI(c *gin.Context) {
+	id := c.Param("session_id")
+	if id == "" {
+		gx.JSONErr(c, gx.BErr(errors.New("session_id is required")))
+		return
+	}
+	if err := di.ChatApp.RevokeShare(c, id); err != nil {
+		gx.JSONErr(c, err)
+		return
+	}
+	gx.JSONSuccess(c, nil)
+}
+
+// 查看分享的会话（无需登录）
+// @Summary Get Shared Chat Session
+// @Description Read-only view of a shared session, served without authentication.
+// @Tags Chat
+// @Produce json
+// @Param token path string true "Share token"
+// @Success 200 {object} appdto.SharedChatSession
+// @Router /share/{token} [get]
+func GetSharedChatSessionAPI(c *gin.Context) {
+	session, err := di.ChatApp.GetSharedSession(c, c.Param("token"))
+	if err != nil {
+		gx.JSONErr(c, err)
+		return
+	}
+	gx.JSONSuccess(c, session)
+}
+
 // 6.3.4 删除会话（含历史消息）
 // @Summary Delete Chat Session
 // @Tags Chat
@@ -131,21 +474,23 @@ func DeleteChatSessionAPI(c *gin.Context) {
 // @Tags Chat
 // @Accept json
 // @Produce json
-// @Param role_id path string true "Role ID"
-// @Param provider path string true "Provider"
-// @Param model_name path string true "Model Name"
+// @Description Without provider and model the session model is used, or the preferred model of the role for a new session.
+// @Param role_id path string true "Role ID, or Team ID to chat with a team"
+// @Param provider path string false "Provider"
+// @Param model_name path string false "Model Name"
 // @Param X-Chat-Session-Id header string false "Session ID"
 // @Param body body appdto.SendChatMessageReq true "Message Request"
 // @Success 200 {object} gx.Response
 // @Router /chat/:role_id/model/:provider/:model_name [post]
+// @Router /chat/:role_id [post]
 func SendChatMessageAPI(c *gin.Context) {
 	roleID := c.Param("role_id")
 	provider := c.Param("provider")
 	modelName := c.Param("model_name")
 	sessionID := c.GetHeader("X-Chat-Session-Id")
 
-	if roleID == "" || provider == "" || modelName == "" {
-		gx.JSONErr(c, gx.BErr(errors.New("role_id, provider, model_name are required")))
+	if roleID == "" {
+		gx.JSONErr(c, gx.BErr(errors.New("role_id is required")))
 		return
 	}
 
@@ -177,6 +522,7 @@ func SendChatMessageAPI(c *gin.Context) {
 // @Param page query int false "Page"
 // @Param page_size query int false "Page Size (max 100)"
 // @Param order query string false "Order (asc/desc)"
+// @Param branch query string false "active: only messages of the active branch"
 // @Success 200 {object} gx.Response
 // @Router /chat/session/:session_id/messages [get]
 func GetChatMessagesAPI(c *gin.Context) {
@@ -219,6 +565,91 @@ func GetChatMessagesAPI(c *gin.Context) {
 	})
 }
 
+// 编辑用户消息并从该处重新生成 (创建新分支)
+// @Summary Edit Chat Message
+// @Tags Chat
+// @Accept json
+// @Produce json
+// @Param session_id path string true "Session ID"
+// @Param message_id path string true "User Message ID"
+// @Param body body appdto.EditChatMessageReq true "Edited Content"
+// @Success 200 {object} gx.Response
+// @Router /chat/session/{session_id}/messages/{message_id} [put]
+func EditChatMessageAPI(c *gin.Context) {
+	sessionID := c.Param("session_id")
+	messageID := c.Param("message_id")
+	var req appdto.EditChatMessageReq
+	if err := gx.BindJSON(c, &req); err != nil {
+		gx.JSONErr(c, gx.BErr(err))
+		return
+	}
+	if req.Content == "" {
+		gx.JSONErr(c, gx.BErr(errors.New("content is required")))
+		return
+	}
+
+	messages, err := di.ChatApp.EditMessage(c, sessionID, messageID, &req)
+	if err != nil {
+		gx.JSONErr(c, err)
+		return
+	}
+	gx.JSONSuccess(c, map[string]interface{}{
+		"session_id": sessionID,
+		"messages":   messages,
+	})
+}
+
+// 重新生成回复 (创建兄弟分支)
+// @Summary Regenerate Chat Message
+// @Tags Chat
+// @Accept json
+// @Produce json
+// @Param session_id path string true "Session ID"
+// @Param message_id path string true "Assistant message to regenerate, or the user message to answer again"
+// @Success 200 {object} gx.Response
+// @Router /chat/session/{session_id}/messages/{message_id}/regenerate [post]
+func RegenerateChatMessageAPI(c *gin.Context) {
+	sessionID := c.Param("session_id")
+	messageID := c.Param("message_id")
+
+	messages, err := di.ChatApp.RegenerateMessage(c, sessionID, messageID)
+	if err != nil {
+		gx.JSONErr(c, err)
+		return
+	}
+	gx.JSONSuccess(c, map[string]interface{}{
+		"session_id": sessionID,
+		"messages":   messages,
+	})
+}
+
+// 切换当前分支
+// @Summary Switch Chat Branch
+// @Tags Chat
+// @Accept json
+// @Produce json
+// @Param session_id path string true "Session ID"
+// @Param body body appdto.SwitchChatBranchReq true "Message on the branch to activate"
+// @Success 200 {object} gx.Response
+// @Router /chat/session/{session_id}/branch [put]
+func SwitchChatBranchAPI(c *gin.Context) {
+	sessionID := c.Param("session_id")
+	var req appdto.SwitchChatBranchReq
+	if err := gx.BindJSON(c, &req); err != nil {
+		gx.JSONErr(c, gx.BErr(err))
+		return
+	}
+	if req.MessageID == "" {
+		gx.JSONErr(c, gx.BErr(errors.New("message_id is required")))
+		return
+	}
+	if err := di.ChatApp.SwitchBranch(c, sessionID, req.MessageID); err != nil {
+		gx.JSONErr(c, err)
+		return
+	}
+	gx.JSONSuccess(c, nil)
+}
+
 func SendChatMessageStreamAPI(c *gin.Context) {
 	roleID := c.Param("role_id")
 	provider := c.Param("provider")
@@ -228,8 +659,8 @@ func SendChatMessageStreamAPI(c *gin.Context) {
 		sessionID = ""
 	}
 
-	if roleID == "" || provider == "" || modelName == "" {
-		gx.JSONErr(c, gx.BErr(errors.New("role_id, provider, model_name are required")))
+	if roleID == "" {
+		gx.JSONErr(c, gx.BErr(errors.New("role_id is required")))
 		return
 	}
 
@@ -252,42 +683,212 @@ func SendChatMessageStreamAPI(c *gin.Context) {
 		return
 	}
 
-	userInput := req.Messages[len(req.Messages)-1].Content
-
-	httpHandler := httptrigger.NewHandler()
+	input := req.Messages[len(req.Messages)-1]
 
-	finalSessionID, engine, err := di.ChatApp.PrepareStreamMessage(c, roleID, provider, modelName, sessionID, userInput)
+	finalSessionID, run, stream, err := di.ChatApp.StreamMessage(c, roleID, provider, modelName, sessionID, &input, req.ModelParams)
 	if err != nil {
-		c.Header("Content-Type", "text/event-stream")
-		c.Header("Cache-Control", "no-cache")
-		c.Header("Connection", "keep-alive")
-
-		var errMsg string
-		var errCode *ec.ErrorCode
-		if errors.As(err, &errCode) {
-			errMsg = fmt.Sprintf("%d: %s", errCode.Code, errCode.Msg)
-		} else {
-			errMsg = err.Error()
+		setSSEHeaders(c)
+		writeSSEvent(c, httptrigger.SSEvent{
+			Type:  "error",
+			Error: sseError(err),
+		})
+		return
+	}
+
+	c.Header("X-Chat-Session-Id", finalSessionID)
+	streamRun(c, run, stream)
+}
+
+// 取消会话中正在运行的回复
+// @Summary Cancel Chat Run
+// @Description Stop the run in flight of a session. The partial reply is saved with meta.cancelled set.
+// @Tags Chat
+// @Accept json
+// @Produce json
+// @Param session_id path string true "Session ID"
+// @Param body body appdto.CancelChatRunReq false "Run to cancel, the current run when empty"
+// @Success 200 {object} gx.Response
+// @Router /chat/session/{session_id}/cancel [post]
+func CancelChatRunAPI(c *gin.Context) {
+	sessionID := c.Param("session_id")
+	var req appdto.CancelChatRunReq
+	if c.Request.ContentLength > 0 {
+		if err := gx.BindJSON(c, &req); err != nil {
+			gx.JSONErr(c, gx.BErr(err))
+			return
 		}
+	}
+	cancelled := di.ChatApp.CancelRun(c, sessionID, req.RunID)
+	gx.JSONSuccess(c, map[string]bool{"cancelled": cancelled})
+}
 
-		event := httptrigger.SSEvent{
-			Type:  "error",
-			Error: errMsg,
+// 获取等待审批的工具调用
+// @Summary Get Tool Call Approvals
+// @Description List the tool calls of the current user waiting for approval. A run streamed to the client also announces them with an "approval_required" event.
+// @Tags Chat
+// @Produce json
+// @Param session_id query string false "Session ID"
+// @Success 200 {object} []appdto.ToolApproval
+// @Router /chat/approvals [get]
+func GetToolApprovalsAPI(c *gin.Context) {
+	var req appdto.GetToolApprovalsReq
+	if err := c.ShouldBindQuery(&req); err != nil {
+		gx.JSONErr(c, gx.BErr(err))
+		return
+	}
+	gx.JSONSuccess(c, di.ChatApp.GetApprovals(c, &req))
+}
+
+// 批准工具调用
+// @Summary Approve Tool Call
+// @Description Run a tool call waiting for approval, with the arguments of the body when set
+// @Tags Chat
+// @Accept json
+// @Produce json
+// @Param approval_id path string true "Approval ID"
+// @Param body body appdto.ApproveToolCallReq false "Arguments replacing the ones of the call"
+// @Success 200 {object} gx.Response
+// @Router /chat/approvals/{approval_id}/approve [post]
+func ApproveToolCallAPI(c *gin.Context) {
+	var req appdto.ApproveToolCallReq
+	if c.Request.ContentLength > 0 {
+		if err := gx.BindJSON(c, &req); err != nil {
+			gx.JSONErr(c, gx.BErr(err))
+			return
 		}
-		eventData, _ := json.Marshal(event)
-		fmt.Fprintf(c.Writer, "data: %s\n\n", eventData)
-		if flusher, ok := c.Writer.(http.Flusher); ok {
-			flusher.Flush()
+	}
+	if err := di.ChatApp.ApproveToolCall(c, c.Param("approval_id"), &req); err != nil {
+		gx.JSONErr(c, err)
+		return
+	}
+	gx.JSONSuccess(c, nil)
+}
+
+// 拒绝工具调用
+// @Summary Reject Tool Call
+// @Description Answer a tool call waiting for approval with an error instead of running it, the reason is passed to the model
+// @Tags Chat
+// @Accept json
+// @Produce json
+// @Param approval_id path string true "Approval ID"
+// @Param body body appdto.RejectToolCallReq false "Reason"
+// @Success 200 {object} gx.Response
+// @Router /chat/approvals/{approval_id}/reject [post]
+func RejectToolCallAPI(c *gin.Context) {
+	var req appdto.RejectToolCallReq
+	if c.Request.ContentLength > 0 {
+		if err := gx.BindJSON(c, &req); err != nil {
+			gx.JSONErr(c, gx.BErr(err))
+			return
 		}
+	}
+	if err := di.ChatApp.RejectToolCall(c, c.Param("approval_id"), &req); err != nil {
+		gx.JSONErr(c, err)
 		return
 	}
+	gx.JSONSuccess(c, nil)
+}
 
-	c.Header("X-Chat-Session-Id", finalSessionID)
+// 修改等待审批的工具调用参数
+// @Summary Edit Tool Call Arguments
+// @Description Replace the arguments of a tool call waiting for approval, it keeps waiting for a decision
+// @Tags Chat
+// @Accept json
+// @Produce json
+// @Param approval_id path string true "Approval ID"
+// @Param body body appdto.EditToolCallReq true "Arguments"
+// @Success 200 {object} appdto.ToolApproval
+// @Router /chat/approvals/{approval_id}/arguments [put]
+func EditToolCallAPI(c *gin.Context) {
+	var req appdto.EditToolCallReq
+	if err := gx.BindJSON(c, &req); err != nil {
+		gx.JSONErr(c, gx.BErr(err))
+		return
+	}
+	if req.Arguments == nil {
+		gx.JSONErr(c, gx.BErr(errors.New("arguments are required")))
+		return
+	}
+	approval, err := di.ChatApp.EditToolCall(c, c.Param("approval_id"), &req)
+	if err != nil {
+		gx.JSONErr(c, err)
+		return
+	}
+	gx.JSONSuccess(c, approval)
+}
+
+// 上传聊天附件 (图片/文件)
+// @Summary Upload Chat Attachment
+// @Description Store a file to send with a chat message. Images are passed to vision models, text files are inlined in the prompt.
+// @Tags Chat
+// @Accept multipart/form-data
+// @Produce json
+// @Param file formData file true "File (max 20MB)"
+// @Success 200 {object} gx.Response
+// @Router /chat/attachments [post]
+func UploadChatAttachmentAPI(c *gin.Context) {
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		gx.JSONErr(c, gx.BErr(err))
+		return
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		gx.JSONErr(c, gx.BErr(err))
+		return
+	}
+	defer file.Close()
+
+	attachment, err := di.ChatApp.UploadAttachment(c, fileHeader.Filename, file)
+	if err != nil {
+		gx.JSONErr(c, err)
+		return
+	}
+	gx.JSONSuccess(c, attachment)
+}
+
+// inlineAttachmentTypes are the attachment types shown in the browser, the
+// others are downloaded so an uploaded page or SVG never runs on the app origin
+var inlineAttachmentTypes = map[string]bool{
+	"image/png":       true,
+	"image/jpeg":      true,
+	"image/gif":       true,
+	"image/webp":      true,
+	"application/pdf": true,
+}
 
-	reqMsg := &httptrigger.MessageRequest{
-		SessionID: finalSessionID,
-		Message:   userInput,
+// 下载聊天附件
+// @Summary Get Chat Attachment
+// @Description Raster images and PDFs are served inline, any other file is served as an application/octet-stream download.
+// @Tags Chat
+// @Produce octet-stream
+// @Param attachment_id path string true "Attachment ID"
+// @Success 200 {file} file
+// @Router /chat/attachments/{attachment_id} [get]
+func GetChatAttachmentAPI(c *gin.Context) {
+	id := c.Param("attachment_id")
+	if id == "" {
+		gx.JSONErr(c, gx.BErr(errors.New("attachment_id is required")))
+		return
 	}
+	attachment, file, err := di.ChatApp.OpenAttachment(c, id)
+	if err != nil {
+		gx.JSONErr(c, err)
+		return
+	}
+	defer file.Close()
 
-	httpHandler.StreamChatAPI(c, engine, reqMsg)
+	contentType, disposition := attachment.MimeType, "inline"
+	if !inlineAttachmentTypes[attachment.MimeType] {
+		contentType, disposition = "application/octet-stream", "attachment"
+	}
+	headers := map[string]string{
+		"Content-Disposition":    mime.FormatMediaType(disposition, map[string]string{"filename": attachment.FileName}),
+		"X-Content-Type-Options": "nosniff",
+	}
+	// browsers refuse to render a PDF in a sandboxed document
+	if contentType != "application/pdf" {
+		headers["Content-Security-Policy"] = "sandbox; default-src 'none'; img-src 'self'"
+	}
+	c.DataFromReader(http.StatusOK, attachment.Size, contentType, file, headers)
 }
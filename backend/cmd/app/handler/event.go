@@ -0,0 +1,204 @@
+package handler
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/xichan96/cortex-lab/internal/appdto"
+	"github.com/xichan96/cortex-lab/internal/di"
+	"github.com/xichan96/cortex-lab/pkg/web/gx"
+)
+
+// Get Event Subscription List
+// @Summary Get Event Subscription List
+// @Tags Event Subscription
+// @Accept json
+// @Produce json
+// @Param page query int false "Page"
+// @Param page_size query int false "Page Size"
+// @Success 200 {object} gx.Response
+// @Router /event-subscriptions [get]
+func GetEventSubscriptionsAPI(c *gin.Context) {
+	var req appdto.GetEventSubscriptionsReq
+	if err := c.ShouldBindQuery(&req); err != nil {
+		gx.JSONErr(c, gx.BErr(err))
+		return
+	}
+
+	// Default page/size
+	if req.Page <= 0 {
+		req.Page = 1
+	}
+	if req.PageSize <= 0 {
+		req.PageSize = 20
+	}
+
+	list, total, err := di.EventApp.GetSubscriptions(c, &req)
+	if err != nil {
+		gx.JSONErr(c, err)
+		return
+	}
+
+	gx.JSONSuccess(c, map[string]interface{}{
+		"list":      list,
+		"total":     total,
+		"page":      req.Page,
+		"page_size": req.PageSize,
+	})
+}
+
+// Create Event Subscription
+// @Summary Create Event Subscription
+// @Description Posts the chosen events of the current user to the URL: session.created, message.completed, tool.failed, experience.created, experience.updated (by an agent) and role.updated (of the roles created by the user). Each POST carries the event in X-Cortex-Event, the Unix time in seconds in X-Signature-Timestamp and the hex HMAC-SHA256 of "<timestamp>.<body>" with the secret in X-Signature-256, prefixed with "sha256=". A delivery is retried with an exponential backoff until a 2xx response, 6 attempts at most. The secret is only returned here and when it is rotated.
+// @Tags Event Subscription
+// @Accept json
+// @Produce json
+// @Param req body appdto.CreateEventSubscriptionReq true "req"
+// @Success 200 {object} gx.Response
+// @Router /event-subscriptions [post]
+func CreateEventSubscriptionAPI(c *gin.Context) {
+	var req appdto.CreateEventSubscriptionReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		gx.JSONErr(c, gx.BErr(err))
+		return
+	}
+	secret, err := di.EventApp.CreateSubscription(c, &req)
+	if err != nil {
+		gx.JSONErr(c, err)
+		return
+	}
+	gx.JSONSuccess(c, secret)
+}
+
+// Update Event Subscription
+// @Summary Update Event Subscription
+// @Tags Event Subscription
+// @Accept json
+// @Produce json
+// @Param subscription_id path string true "Subscription ID"
+// @Param req body appdto.UpdateEventSubscriptionReq true "req"
+// @Success 200 {object} gx.Response
+// @Router /event-subscriptions/{subscription_id} [put]
+func UpdateEventSubscriptionAPI(c *gin.Context) {
+	var req appdto.UpdateEventSubscriptionReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		gx.JSONErr(c, gx.BErr(err))
+		return
+	}
+	req.ID = c.Param("subscription_id")
+	if err := di.EventApp.UpdateSubscription(c, &req); err != nil {
+		gx.JSONErr(c, err)
+		return
+	}
+	gx.JSONSuccess(c, nil)
+}
+
+// Delete Event Subscription
+// @Summary Delete Event Subscription
+// @Tags Event Subscription
+// @Accept json
+// @Produce json
+// @Param subscription_id path string true "Subscription ID"
+// @Success 200 {object} gx.Response
+// @Router /event-subscriptions/{subscription_id} [delete]
+func DeleteEventSubscriptionAPI(c *gin.Context) {
+	id := c.Param("subscription_id")
+	if err := di.EventApp.DeleteSubscription(c, id); err != nil {
+		gx.JSONErr(c, err)
+		return
+	}
+	gx.JSONSuccess(c, nil)
+}
+
+// Get Event Subscription
+// @Summary Get Event Subscription
+// @Tags Event Subscription
+// @Accept json
+// @Produce json
+// @Param subscription_id path string true "Subscription ID"
+// @Success 200 {object} gx.Response
+// @Router /event-subscriptions/{subscription_id} [get]
+func GetEventSubscriptionAPI(c *gin.Context) {
+	id := c.Param("subscription_id")
+	subscription, err := di.EventApp.GetSubscription(c, id)
+	if err != nil {
+		gx.JSONErr(c, err)
+		return
+	}
+	gx.JSONSuccess(c, subscription)
+}
+
+// Rotate Event Subscription Secret
+// @Summary Rotate Event Subscription Secret
+// @Description Replaces the secret of the subscription, the deliveries attempted from now on are signed with the new secret.
+// @Tags Event Subscription
+// @Accept json
+// @Produce json
+// @Param subscription_id path string true "Subscription ID"
+// @Success 200 {object} gx.Response
+// @Router /event-subscriptions/{subscription_id}/secret [post]
+func RotateEventSubscriptionSecretAPI(c *gin.Context) {
+	secret, err := di.EventApp.RotateSecret(c, c.Param("subscription_id"))
+	if err != nil {
+		gx.JSONErr(c, err)
+		return
+	}
+	gx.JSONSuccess(c, secret)
+}
+
+// Get Event Delivery List
+// @Summary Get Event Delivery List
+// @Tags Event Subscription
+// @Accept json
+// @Produce json
+// @Param subscription_id path string true "Subscription ID"
+// @Param page query int false "Page"
+// @Param page_size query int false "Page Size"
+// @Param status query string false "pending, succeeded or failed"
+// @Success 200 {object} gx.Response
+// @Router /event-subscriptions/{subscription_id}/deliveries [get]
+func GetEventDeliveriesAPI(c *gin.Context) {
+	var req appdto.GetEventDeliveriesReq
+	if err := c.ShouldBindQuery(&req); err != nil {
+		gx.JSONErr(c, gx.BErr(err))
+		return
+	}
+
+	// Default page/size
+	if req.Page <= 0 {
+		req.Page = 1
+	}
+	if req.PageSize <= 0 {
+		req.PageSize = 20
+	}
+
+	list, total, err := di.EventApp.GetDeliveries(c, c.Param("subscription_id"), &req)
+	if err != nil {
+		gx.JSONErr(c, err)
+		return
+	}
+
+	gx.JSONSuccess(c, map[string]interface{}{
+		"list":      list,
+		"total":     total,
+		"page":      req.Page,
+		"page_size": req.PageSize,
+	})
+}
+
+// Redeliver Event
+// @Summary Redeliver Event
+// @Description Posts the event of a delivery again as a new delivery, with the same event ID.
+// @Tags Event Subscription
+// @Accept json
+// @Produce json
+// @Param subscription_id path string true "Subscription ID"
+// @Param delivery_id path string true "Delivery ID"
+// @Success 200 {object} gx.Response
+// @Router /event-subscriptions/{subscription_id}/deliveries/{delivery_id}/redeliver [post]
+func RedeliverEventAPI(c *gin.Context) {
+	delivery, err := di.EventApp.Redeliver(c, c.Param("subscription_id"), c.Param("delivery_id"))
+	if err != nil {
+		gx.JSONErr(c, err)
+		return
+	}
+	gx.JSONSuccess(c, delivery)
+}
@@ -2,6 +2,7 @@ package handler
 
 import (
 	"errors"
+	"io"
 
 	"github.com/gin-gonic/gin"
 	"github.com/xichan96/cortex-lab/internal/appdto"
@@ -155,3 +156,180 @@ func DeleteExperienceAPI(c *gin.Context) {
 
 	gx.JSONSuccess(c, nil)
 }
+
+// readDocumentUpload binds the form fields of a document upload and reads its file
+func readDocumentUpload(c *gin.Context) (*appdto.IngestDocumentReq, string, []byte, error) {
+	var req appdto.IngestDocumentReq
+	if err := c.ShouldBind(&req); err != nil {
+		return nil, "", nil, err
+	}
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		return nil, "", nil, err
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		return nil, "", nil, err
+	}
+	defer file.Close()
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	return &req, fileHeader.Filename, data, nil
+}
+
+// Upload Document
+// @Summary Upload Document
+// @Description Split a Markdown, plain text, HTML or PDF file into document_fragment experiences sharing the document ID as source_id.
+// @Tags Experience
+// @Accept multipart/form-data
+// @Produce json
+// @Param file formData file true "Document (max 10MB)"
+// @Param role_id formData string false "Role the fragments belong to"
+// @Param title formData string false "Title, the file name by default"
+// @Param tags formData string false "Tags (JSON array) copied to every fragment"
+// @Param chunk_size formData int false "Max characters per fragment (default 1000)"
+// @Param chunk_overlap formData int false "Characters repeated between fragments (default 100)"
+// @Success 200 {object} gx.Response
+// @Router /experiences/documents [post]
+func UploadExperienceDocumentAPI(c *gin.Context) {
+	req, fileName, data, err := readDocumentUpload(c)
+	if err != nil {
+		gx.JSONErr(c, gx.BErr(err))
+		return
+	}
+
+	userID := cctx.GetUserID[string](c)
+	doc, err := di.ExperienceApp.IngestDocument(c, userID, req, fileName, data)
+	if err != nil {
+		gx.JSONErr(c, err)
+		return
+	}
+
+	gx.JSONSuccess(c, doc)
+}
+
+// Replace Document
+// @Summary Replace Document
+// @Description Re-ingest a document from a new file, its fragments are rebuilt.
+// @Tags Experience
+// @Accept multipart/form-data
+// @Produce json
+// @Param id path string true "Document ID"
+// @Param file formData file true "Document (max 10MB)"
+// @Param role_id formData string false "Role the fragments belong to"
+// @Param title formData string false "Title"
+// @Param tags formData string false "Tags (JSON array)"
+// @Param chunk_size formData int false "Max characters per fragment (default 1000)"
+// @Param chunk_overlap formData int false "Characters repeated between fragments (default 100)"
+// @Success 200 {object} gx.Response
+// @Router /experiences/documents/{id} [put]
+func ReplaceExperienceDocumentAPI(c *gin.Context) {
+	id := c.Param("id")
+	if id == "" {
+		gx.JSONErr(c, gx.BErr(errors.New("id is required")))
+		return
+	}
+	req, fileName, data, err := readDocumentUpload(c)
+	if err != nil {
+		gx.JSONErr(c, gx.BErr(err))
+		return
+	}
+
+	doc, err := di.ExperienceApp.ReingestDocument(c, id, req, fileName, data)
+	if err != nil {
+		gx.JSONErr(c, err)
+		return
+	}
+
+	gx.JSONSuccess(c, doc)
+}
+
+// Get Documents
+// @Summary Get Documents
+// @Tags Experience
+// @Accept json
+// @Produce json
+// @Param page query int false "Page"
+// @Param page_size query int false "Page Size"
+// @Param role_id query string false "Role ID"
+// @Param keyword query string false "Keyword in title or file name"
+// @Success 200 {object} gx.Response
+// @Router /experiences/documents [get]
+func GetExperienceDocumentsAPI(c *gin.Context) {
+	var req appdto.GetExperienceDocumentsReq
+	if err := c.ShouldBindQuery(&req); err != nil {
+		gx.JSONErr(c, gx.BErr(err))
+		return
+	}
+
+	if req.Page <= 0 {
+		req.Page = 1
+	}
+	if req.PageSize <= 0 {
+		req.PageSize = 20
+	}
+
+	list, total, err := di.ExperienceApp.GetDocuments(c, &req)
+	if err != nil {
+		gx.JSONErr(c, err)
+		return
+	}
+
+	gx.JSONSuccess(c, map[string]interface{}{
+		"list":      list,
+		"total":     total,
+		"page":      req.Page,
+		"page_size": req.PageSize,
+	})
+}
+
+// Get Document Detail
+// @Summary Get Document Detail
+// @Description The document with its fragments in document order.
+// @Tags Experience
+// @Accept json
+// @Produce json
+// @Param id path string true "Document ID"
+// @Success 200 {object} gx.Response
+// @Router /experiences/documents/{id} [get]
+func GetExperienceDocumentAPI(c *gin.Context) {
+	id := c.Param("id")
+	if id == "" {
+		gx.JSONErr(c, gx.BErr(errors.New("id is required")))
+		return
+	}
+
+	doc, err := di.ExperienceApp.GetDocument(c, id)
+	if err != nil {
+		gx.JSONErr(c, err)
+		return
+	}
+
+	gx.JSONSuccess(c, doc)
+}
+
+// Delete Document
+// @Summary Delete Document
+// @Description Delete a document together with all its fragments.
+// @Tags Experience
+// @Accept json
+// @Produce json
+// @Param id path string true "Document ID"
+// @Success 200 {object} gx.Response
+// @Router /experiences/documents/{id} [delete]
+func DeleteExperienceDocumentAPI(c *gin.Context) {
+	id := c.Param("id")
+	if id == "" {
+		gx.JSONErr(c, gx.BErr(errors.New("id is required")))
+		return
+	}
+
+	if err := di.ExperienceApp.DeleteDocument(c, id); err != nil {
+		gx.JSONErr(c, err)
+		return
+	}
+
+	gx.JSONSuccess(c, nil)
+}
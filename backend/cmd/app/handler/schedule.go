@@ -0,0 +1,166 @@
+package handler
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/xichan96/cortex-lab/internal/appdto"
+	"github.com/xichan96/cortex-lab/internal/di"
+	"github.com/xichan96/cortex-lab/pkg/web/gx"
+)
+
+// Get Schedule List
+// @Summary Get Schedule List
+// @Tags Schedule
+// @Accept json
+// @Produce json
+// @Param page query int false "Page"
+// @Param page_size query int false "Page Size"
+// @Success 200 {object} gx.Response
+// @Router /schedules [get]
+func GetSchedulesAPI(c *gin.Context) {
+	var req appdto.GetSchedulesReq
+	if err := c.ShouldBindQuery(&req); err != nil {
+		gx.JSONErr(c, gx.BErr(err))
+		return
+	}
+
+	// Default page/size
+	if req.Page <= 0 {
+		req.Page = 1
+	}
+	if req.PageSize <= 0 {
+		req.PageSize = 20
+	}
+
+	list, total, err := di.ScheduleApp.GetSchedules(c, &req)
+	if err != nil {
+		gx.JSONErr(c, err)
+		return
+	}
+
+	gx.JSONSuccess(c, map[string]interface{}{
+		"list":      list,
+		"total":     total,
+		"page":      req.Page,
+		"page_size": req.PageSize,
+	})
+}
+
+// Create Schedule
+// @Summary Create Schedule
+// @Description Sends the input to the role on the cron schedule, in the given session or in a new session per run. The cron expression has 5 fields and is evaluated in the time zone of the schedule.
+// @Tags Schedule
+// @Accept json
+// @Produce json
+// @Param req body appdto.CreateScheduleReq true "req"
+// @Success 200 {object} gx.Response
+// @Router /schedules [post]
+func CreateScheduleAPI(c *gin.Context) {
+	var req appdto.CreateScheduleReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		gx.JSONErr(c, gx.BErr(err))
+		return
+	}
+	id, err := di.ScheduleApp.CreateSchedule(c, &req)
+	if err != nil {
+		gx.JSONErr(c, err)
+		return
+	}
+	gx.JSONSuccess(c, map[string]string{"id": id})
+}
+
+// Update Schedule
+// @Summary Update Schedule
+// @Tags Schedule
+// @Accept json
+// @Produce json
+// @Param schedule_id path string true "Schedule ID"
+// @Param req body appdto.UpdateScheduleReq true "req"
+// @Success 200 {object} gx.Response
+// @Router /schedules/{schedule_id} [put]
+func UpdateScheduleAPI(c *gin.Context) {
+	var req appdto.UpdateScheduleReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		gx.JSONErr(c, gx.BErr(err))
+		return
+	}
+	req.ID = c.Param("schedule_id")
+	if err := di.ScheduleApp.UpdateSchedule(c, &req); err != nil {
+		gx.JSONErr(c, err)
+		return
+	}
+	gx.JSONSuccess(c, nil)
+}
+
+// Delete Schedule
+// @Summary Delete Schedule
+// @Tags Schedule
+// @Accept json
+// @Produce json
+// @Param schedule_id path string true "Schedule ID"
+// @Success 200 {object} gx.Response
+// @Router /schedules/{schedule_id} [delete]
+func DeleteScheduleAPI(c *gin.Context) {
+	id := c.Param("schedule_id")
+	if err := di.ScheduleApp.DeleteSchedule(c, id); err != nil {
+		gx.JSONErr(c, err)
+		return
+	}
+	gx.JSONSuccess(c, nil)
+}
+
+// Get Schedule
+// @Summary Get Schedule
+// @Tags Schedule
+// @Accept json
+// @Produce json
+// @Param schedule_id path string true "Schedule ID"
+// @Success 200 {object} gx.Response
+// @Router /schedules/{schedule_id} [get]
+func GetScheduleAPI(c *gin.Context) {
+	id := c.Param("schedule_id")
+	schedule, err := di.ScheduleApp.GetSchedule(c, id)
+	if err != nil {
+		gx.JSONErr(c, err)
+		return
+	}
+	gx.JSONSuccess(c, schedule)
+}
+
+// Get Schedule Run List
+// @Summary Get Schedule Run List
+// @Tags Schedule
+// @Accept json
+// @Produce json
+// @Param schedule_id path string true "Schedule ID"
+// @Param page query int false "Page"
+// @Param page_size query int false "Page Size"
+// @Success 200 {object} gx.Response
+// @Router /schedules/{schedule_id}/runs [get]
+func GetScheduleRunsAPI(c *gin.Context) {
+	var req appdto.GetScheduleRunsReq
+	if err := c.ShouldBindQuery(&req); err != nil {
+		gx.JSONErr(c, gx.BErr(err))
+		return
+	}
+
+	// Default page/size
+	if req.Page <= 0 {
+		req.Page = 1
+	}
+	if req.PageSize <= 0 {
+		req.PageSize = 20
+	}
+
+	list, total, err := di.ScheduleApp.GetRuns(c, c.Param("schedule_id"), &req)
+	if err != nil {
+		gx.JSONErr(c, err)
+		return
+	}
+
+	gx.JSONSuccess(c, map[string]interface{}{
+		"list":      list,
+		"total":     total,
+		"page":      req.Page,
+		"page_size": req.PageSize,
+	})
+}
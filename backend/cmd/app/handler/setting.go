@@ -206,6 +206,43 @@ func UpdateMemorySettingAPI(c *gin.Context) {
 	gx.JSONSuccess(c, nil)
 }
 
+// GetPricingSettingAPI   Get Model Pricing Setting
+// @Summary               Get Model Pricing Setting
+// @Tags                  Setting
+// @Accept                json
+// @Produce               json
+// @Success               200     {object}    appdto.PricingSetting
+// @Router                /settings/pricing [get]
+func GetPricingSettingAPI(c *gin.Context) {
+	setting, err := di.SettingApp.GetPricingSetting(c)
+	if err != nil {
+		gx.JSONErr(c, err)
+		return
+	}
+	gx.JSONSuccess(c, setting)
+}
+
+// UpdatePricingSettingAPI Update Model Pricing Setting
+// @Summary               Update Model Pricing Setting
+// @Tags                  Setting
+// @Accept                json
+// @Produce               json
+// @Param                 body    body        appdto.UpdatePricingSettingReq true    "req"
+// @Success               200     {object}    gx.Response
+// @Router                /settings/pricing [put]
+func UpdatePricingSettingAPI(c *gin.Context) {
+	var req appdto.UpdatePricingSettingReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		gx.JSONErr(c, gx.BErr(err))
+		return
+	}
+	if err := di.SettingApp.UpdatePricingSetting(c, &req); err != nil {
+		gx.JSONErr(c, err)
+		return
+	}
+	gx.JSONSuccess(c, nil)
+}
+
 // GetSettingsAPI
 // @Summary Get Settings List
 // @Tags Setting
@@ -260,3 +297,40 @@ func DeleteSettingAPI(c *gin.Context) {
 	}
 	gx.JSONSuccess(c, nil)
 }
+
+// GetDelegationSettingAPI Get Role Delegation Setting
+// @Summary               Get Role Delegation Setting
+// @Tags                  Setting
+// @Accept                json
+// @Produce               json
+// @Success               200     {object}    appdto.DelegationSetting
+// @Router                /settings/delegation [get]
+func GetDelegationSettingAPI(c *gin.Context) {
+	setting, err := di.SettingApp.GetDelegationSetting(c)
+	if err != nil {
+		gx.JSONErr(c, err)
+		return
+	}
+	gx.JSONSuccess(c, setting)
+}
+
+// UpdateDelegationSettingAPI Update Role Delegation Setting
+// @Summary               Update Role Delegation Setting
+// @Tags                  Setting
+// @Accept                json
+// @Produce               json
+// @Param                 body    body        appdto.UpdateDelegationSettingReq true    "req"
+// @Success               200     {object}    gx.Response
+// @Router                /settings/delegation [put]
+func UpdateDelegationSettingAPI(c *gin.Context) {
+	var req appdto.UpdateDelegationSettingReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		gx.JSONErr(c, gx.BErr(err))
+		return
+	}
+	if err := di.SettingApp.UpdateDelegationSetting(c, &req); err != nil {
+		gx.JSONErr(c, err)
+		return
+	}
+	gx.JSONSuccess(c, nil)
+}
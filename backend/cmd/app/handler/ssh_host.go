@@ -0,0 +1,144 @@
+package handler
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/xichan96/cortex-lab/internal/appdto"
+	"github.com/xichan96/cortex-lab/internal/di"
+	"github.com/xichan96/cortex-lab/pkg/web/gx"
+)
+
+// Get SSH Host List
+// @Summary Get SSH Host List
+// @Tags SSH Host
+// @Accept json
+// @Produce json
+// @Param page query int false "Page"
+// @Param page_size query int false "Page Size"
+// @Success 200 {object} gx.Response
+// @Router /ssh-hosts [get]
+func GetSSHHostsAPI(c *gin.Context) {
+	var req appdto.GetSSHHostsReq
+	if err := c.ShouldBindQuery(&req); err != nil {
+		gx.JSONErr(c, gx.BErr(err))
+		return
+	}
+
+	// Default page/size
+	if req.Page <= 0 {
+		req.Page = 1
+	}
+	if req.PageSize <= 0 {
+		req.PageSize = 20
+	}
+
+	list, total, err := di.SSHHostApp.GetHosts(c, &req)
+	if err != nil {
+		gx.JSONErr(c, err)
+		return
+	}
+
+	gx.JSONSuccess(c, map[string]interface{}{
+		"list":      list,
+		"total":     total,
+		"page":      req.Page,
+		"page_size": req.PageSize,
+	})
+}
+
+// Create SSH Host
+// @Summary Create SSH Host
+// @Description Adds a host to the inventory of the ssh tool. The password or the private key is stored encrypted and never returned. Commands only run once the host key is pinned, set it here or scan it.
+// @Tags SSH Host
+// @Accept json
+// @Produce json
+// @Param req body appdto.CreateSSHHostReq true "req"
+// @Success 200 {object} gx.Response
+// @Router /ssh-hosts [post]
+func CreateSSHHostAPI(c *gin.Context) {
+	var req appdto.CreateSSHHostReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		gx.JSONErr(c, gx.BErr(err))
+		return
+	}
+	id, err := di.SSHHostApp.CreateHost(c, &req)
+	if err != nil {
+		gx.JSONErr(c, err)
+		return
+	}
+	gx.JSONSuccess(c, map[string]string{"id": id})
+}
+
+// Update SSH Host
+// @Summary Update SSH Host
+// @Description Changing the address or the port clears the pinned host key unless a new one is set.
+// @Tags SSH Host
+// @Accept json
+// @Produce json
+// @Param host_id path string true "Host ID"
+// @Param req body appdto.UpdateSSHHostReq true "req"
+// @Success 200 {object} gx.Response
+// @Router /ssh-hosts/{host_id} [put]
+func UpdateSSHHostAPI(c *gin.Context) {
+	var req appdto.UpdateSSHHostReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		gx.JSONErr(c, gx.BErr(err))
+		return
+	}
+	req.ID = c.Param("host_id")
+	if err := di.SSHHostApp.UpdateHost(c, &req); err != nil {
+		gx.JSONErr(c, err)
+		return
+	}
+	gx.JSONSuccess(c, nil)
+}
+
+// Delete SSH Host
+// @Summary Delete SSH Host
+// @Tags SSH Host
+// @Accept json
+// @Produce json
+// @Param host_id path string true "Host ID"
+// @Success 200 {object} gx.Response
+// @Router /ssh-hosts/{host_id} [delete]
+func DeleteSSHHostAPI(c *gin.Context) {
+	if err := di.SSHHostApp.DeleteHost(c, c.Param("host_id")); err != nil {
+		gx.JSONErr(c, err)
+		return
+	}
+	gx.JSONSuccess(c, nil)
+}
+
+// Get SSH Host
+// @Summary Get SSH Host
+// @Tags SSH Host
+// @Accept json
+// @Produce json
+// @Param host_id path string true "Host ID"
+// @Success 200 {object} gx.Response
+// @Router /ssh-hosts/{host_id} [get]
+func GetSSHHostAPI(c *gin.Context) {
+	host, err := di.SSHHostApp.GetHost(c, c.Param("host_id"))
+	if err != nil {
+		gx.JSONErr(c, err)
+		return
+	}
+	gx.JSONSuccess(c, host)
+}
+
+// Scan SSH Host Key
+// @Summary Scan SSH Host Key
+// @Description Connects to the host and pins the key it presents. Check the returned fingerprint against the one of the server.
+// @Tags SSH Host
+// @Accept json
+// @Produce json
+// @Param host_id path string true "Host ID"
+// @Success 200 {object} gx.Response
+// @Router /ssh-hosts/{host_id}/scan [post]
+func ScanSSHHostKeyAPI(c *gin.Context) {
+	host, err := di.SSHHostApp.ScanHostKey(c, c.Param("host_id"))
+	if err != nil {
+		gx.JSONErr(c, err)
+		return
+	}
+	gx.JSONSuccess(c, host)
+}
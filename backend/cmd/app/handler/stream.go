@@ -0,0 +1,103 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/xichan96/cortex-lab/internal/pkg/agentrun"
+	"github.com/xichan96/cortex-lab/pkg/ec"
+	"github.com/xichan96/cortex/agent/engine"
+	cortexerrors "github.com/xichan96/cortex/pkg/errors"
+	httptrigger "github.com/xichan96/cortex/trigger/http"
+)
+
+func writeSSEvent(c *gin.Context, event httptrigger.SSEvent) bool {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return false
+	}
+	if _, err := fmt.Fprintf(c.Writer, "data: %s\n\n", data); err != nil {
+		return false
+	}
+	if flusher, ok := c.Writer.(http.Flusher); ok {
+		flusher.Flush()
+	}
+	return true
+}
+
+func sseError(err error) string {
+	var errCode *ec.ErrorCode
+	if errors.As(err, &errCode) {
+		return fmt.Sprintf("%d: %s", errCode.Code, errCode.Msg)
+	}
+	var cortexErr *cortexerrors.Error
+	if errors.As(err, &cortexErr) {
+		return fmt.Sprintf("%d: %s", cortexErr.Code, cortexErr.Message)
+	}
+	return err.Error()
+}
+
+func setSSEHeaders(c *gin.Context) {
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+}
+
+// setRunHeaders tells the client the session and the run to cancel, the
+// session ID may have been generated for the request
+func setRunHeaders(c *gin.Context, run *agentrun.Run) {
+	c.Header("X-Session-Id", run.SessionID)
+	c.Header("X-Run-Id", run.ID)
+}
+
+// streamRun writes the results of a run as server-sent events. When the
+// client goes away the run is cancelled so the LLM and tools stop as well.
+func streamRun(c *gin.Context, run *agentrun.Run, stream <-chan engine.StreamResult) {
+	setSSEHeaders(c)
+	setRunHeaders(c, run)
+
+	abandon := func() {
+		agentrun.Default.Cancel(run.SessionID, run.UserID, run.ID)
+		// keep draining so the run can finish saving its partial output
+		go func() {
+			for range stream {
+			}
+		}()
+	}
+
+	ctx := c.Request.Context()
+	for {
+		select {
+		case <-ctx.Done():
+			abandon()
+			return
+		case result, ok := <-stream:
+			if !ok {
+				return
+			}
+			event := httptrigger.SSEvent{Type: result.Type}
+			switch result.Type {
+			case "chunk":
+				event.Content = result.Content
+			case "error":
+				if result.Error != nil {
+					event.Error = sseError(result.Error)
+				}
+			case "end", "cancelled":
+				event.End = true
+				event.Data = result.Result
+			case "approval_required":
+				event.Data = json.RawMessage(result.Content)
+			default:
+				continue
+			}
+			if !writeSSEvent(c, event) {
+				abandon()
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package handler
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/xichan96/cortex-lab/internal/appdto"
+	"github.com/xichan96/cortex-lab/internal/di"
+	"github.com/xichan96/cortex-lab/pkg/web/gx"
+)
+
+// Get Team List
+// @Summary Get Team List
+// @Tags Team
+// @Accept json
+// @Produce json
+// @Param page query int false "Page"
+// @Param page_size query int false "Page Size"
+// @Param keyword query string false "Keyword"
+// @Param scope query string false "Scope"
+// @Success 200 {object} gx.Response
+// @Router /teams [get]
+func GetTeamsAPI(c *gin.Context) {
+	var req appdto.GetTeamsReq
+	if err := c.ShouldBindQuery(&req); err != nil {
+		gx.JSONErr(c, gx.BErr(err))
+		return
+	}
+
+	// Default page/size
+	if req.Page <= 0 {
+		req.Page = 1
+	}
+	if req.PageSize <= 0 {
+		req.PageSize = 20
+	}
+
+	list, total, err := di.TeamApp.GetTeams(c, &req)
+	if err != nil {
+		gx.JSONErr(c, err)
+		return
+	}
+
+	gx.JSONSuccess(c, map[string]interface{}{
+		"list":      list,
+		"total":     total,
+		"page":      req.Page,
+		"page_size": req.PageSize,
+	})
+}
+
+// Create Team
+// @Summary Create Team
+// @Description A team is chatted with through the chat endpoints with its ID in place of a role ID, its supervisor role then delegates subtasks to the member roles.
+// @Tags Team
+// @Accept json
+// @Produce json
+// @Param req body appdto.CreateTeamReq true "req"
+// @Success 200 {object} gx.Response
+// @Router /teams [post]
+func CreateTeamAPI(c *gin.Context) {
+	var req appdto.CreateTeamReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		gx.JSONErr(c, gx.BErr(err))
+		return
+	}
+	id, err := di.TeamApp.CreateTeam(c, &req)
+	if err != nil {
+		gx.JSONErr(c, err)
+		return
+	}
+	gx.JSONSuccess(c, map[string]string{"id": id})
+}
+
+// Update Team
+// @Summary Update Team
+// @Tags Team
+// @Accept json
+// @Produce json
+// @Param team_id path string true "Team ID"
+// @Param req body appdto.UpdateTeamReq true "req"
+// @Success 200 {object} gx.Response
+// @Router /teams/{team_id} [put]
+func UpdateTeamAPI(c *gin.Context) {
+	var req appdto.UpdateTeamReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		gx.JSONErr(c, gx.BErr(err))
+		return
+	}
+	req.ID = c.Param("team_id")
+	if err := di.TeamApp.UpdateTeam(c, &req); err != nil {
+		gx.JSONErr(c, err)
+		return
+	}
+	gx.JSONSuccess(c, nil)
+}
+
+// Delete Team
+// @Summary Delete Team
+// @Tags Team
+// @Accept json
+// @Produce json
+// @Param team_id path string true "Team ID"
+// @Success 200 {object} gx.Response
+// @Router /teams/{team_id} [delete]
+func DeleteTeamAPI(c *gin.Context) {
+	id := c.Param("team_id")
+	if err := di.TeamApp.DeleteTeam(c, id); err != nil {
+		gx.JSONErr(c, err)
+		return
+	}
+	gx.JSONSuccess(c, nil)
+}
+
+// Get Team
+// @Summary Get Team
+// @Tags Team
+// @Accept json
+// @Produce json
+// @Param team_id path string true "Team ID"
+// @Success 200 {object} gx.Response
+// @Router /teams/{team_id} [get]
+func GetTeamAPI(c *gin.Context) {
+	id := c.Param("team_id")
+	team, err := di.TeamApp.GetTeam(c, id)
+	if err != nil {
+		gx.JSONErr(c, err)
+		return
+	}
+	gx.JSONSuccess(c, team)
+}
@@ -0,0 +1,41 @@
+package handler
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/xichan96/cortex-lab/cmd/app/middleware"
+	"github.com/xichan96/cortex-lab/internal/appdto"
+	"github.com/xichan96/cortex-lab/internal/di"
+	"github.com/xichan96/cortex-lab/pkg/web/cctx"
+	"github.com/xichan96/cortex-lab/pkg/web/gx"
+)
+
+// GetUsageAPI Get aggregated token usage and cost
+// @Summary Get Usage
+// @Description Aggregate token usage and cost grouped by session, role, user, model or day. Non-admin users only see their own usage.
+// @Tags Usage
+// @Produce json
+// @Param group_by query string false "session|role|user|model|day, default day"
+// @Param session_id query string false "session id"
+// @Param role_id query string false "role id"
+// @Param user_id query string false "user id, admin only"
+// @Param start_date query string false "start date, 2006-01-02"
+// @Param end_date query string false "end date (inclusive), 2006-01-02"
+// @Success 200 {object} appdto.Usage
+// @Router /usage [get]
+func GetUsageAPI(c *gin.Context) {
+	var req appdto.GetUsageReq
+	if err := c.ShouldBindQuery(&req); err != nil {
+		gx.JSONErr(c, gx.BErr(err))
+		return
+	}
+	if cctx.GetUserRole[string](c) != middleware.AdminRole {
+		req.UserID = cctx.GetUserID[string](c)
+	}
+
+	usage, err := di.UsageApp.GetUsage(c, &req)
+	if err != nil {
+		gx.JSONErr(c, gx.BErr(err))
+		return
+	}
+	gx.JSONSuccess(c, usage)
+}
@@ -0,0 +1,229 @@
+package handler
+
+import (
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/xichan96/cortex-lab/internal/appdto"
+	"github.com/xichan96/cortex-lab/internal/di"
+	"github.com/xichan96/cortex-lab/internal/pkg/errcode"
+	"github.com/xichan96/cortex-lab/pkg/ec"
+	"github.com/xichan96/cortex-lab/pkg/web/gx"
+)
+
+// Get Webhook List
+// @Summary Get Webhook List
+// @Tags Webhook
+// @Accept json
+// @Produce json
+// @Param page query int false "Page"
+// @Param page_size query int false "Page Size"
+// @Success 200 {object} gx.Response
+// @Router /webhooks [get]
+func GetWebhooksAPI(c *gin.Context) {
+	var req appdto.GetWebhooksReq
+	if err := c.ShouldBindQuery(&req); err != nil {
+		gx.JSONErr(c, gx.BErr(err))
+		return
+	}
+
+	// Default page/size
+	if req.Page <= 0 {
+		req.Page = 1
+	}
+	if req.PageSize <= 0 {
+		req.PageSize = 20
+	}
+
+	list, total, err := di.WebhookApp.GetWebhooks(c, &req)
+	if err != nil {
+		gx.JSONErr(c, err)
+		return
+	}
+
+	gx.JSONSuccess(c, map[string]interface{}{
+		"list":      list,
+		"total":     total,
+		"page":      req.Page,
+		"page_size": req.PageSize,
+	})
+}
+
+// Create Webhook
+// @Summary Create Webhook
+// @Description Creates a webhook bound to a role. The secret signing the events is only returned here and when it is rotated.
+// @Tags Webhook
+// @Accept json
+// @Produce json
+// @Param req body appdto.CreateWebhookReq true "req"
+// @Success 200 {object} gx.Response
+// @Router /webhooks [post]
+func CreateWebhookAPI(c *gin.Context) {
+	var req appdto.CreateWebhookReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		gx.JSONErr(c, gx.BErr(err))
+		return
+	}
+	secret, err := di.WebhookApp.CreateWebhook(c, &req)
+	if err != nil {
+		gx.JSONErr(c, err)
+		return
+	}
+	gx.JSONSuccess(c, secret)
+}
+
+// Update Webhook
+// @Summary Update Webhook
+// @Tags Webhook
+// @Accept json
+// @Produce json
+// @Param webhook_id path string true "Webhook ID"
+// @Param req body appdto.UpdateWebhookReq true "req"
+// @Success 200 {object} gx.Response
+// @Router /webhooks/{webhook_id} [put]
+func UpdateWebhookAPI(c *gin.Context) {
+	var req appdto.UpdateWebhookReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		gx.JSONErr(c, gx.BErr(err))
+		return
+	}
+	req.ID = c.Param("webhook_id")
+	if err := di.WebhookApp.UpdateWebhook(c, &req); err != nil {
+		gx.JSONErr(c, err)
+		return
+	}
+	gx.JSONSuccess(c, nil)
+}
+
+// Delete Webhook
+// @Summary Delete Webhook
+// @Tags Webhook
+// @Accept json
+// @Produce json
+// @Param webhook_id path string true "Webhook ID"
+// @Success 200 {object} gx.Response
+// @Router /webhooks/{webhook_id} [delete]
+func DeleteWebhookAPI(c *gin.Context) {
+	id := c.Param("webhook_id")
+	if err := di.WebhookApp.DeleteWebhook(c, id); err != nil {
+		gx.JSONErr(c, err)
+		return
+	}
+	gx.JSONSuccess(c, nil)
+}
+
+// Get Webhook
+// @Summary Get Webhook
+// @Tags Webhook
+// @Accept json
+// @Produce json
+// @Param webhook_id path string true "Webhook ID"
+// @Success 200 {object} gx.Response
+// @Router /webhooks/{webhook_id} [get]
+func GetWebhookAPI(c *gin.Context) {
+	id := c.Param("webhook_id")
+	webhook, err := di.WebhookApp.GetWebhook(c, id)
+	if err != nil {
+		gx.JSONErr(c, err)
+		return
+	}
+	gx.JSONSuccess(c, webhook)
+}
+
+// Rotate Webhook Secret
+// @Summary Rotate Webhook Secret
+// @Description Replaces the secret of the webhook, the events signed with the previous secret are rejected from now on.
+// @Tags Webhook
+// @Accept json
+// @Produce json
+// @Param webhook_id path string true "Webhook ID"
+// @Success 200 {object} gx.Response
+// @Router /webhooks/{webhook_id}/secret [post]
+func RotateWebhookSecretAPI(c *gin.Context) {
+	secret, err := di.WebhookApp.RotateSecret(c, c.Param("webhook_id"))
+	if err != nil {
+		gx.JSONErr(c, err)
+		return
+	}
+	gx.JSONSuccess(c, secret)
+}
+
+// Get Webhook Invocation List
+// @Summary Get Webhook Invocation List
+// @Tags Webhook
+// @Accept json
+// @Produce json
+// @Param webhook_id path string true "Webhook ID"
+// @Param page query int false "Page"
+// @Param page_size query int false "Page Size"
+// @Success 200 {object} gx.Response
+// @Router /webhooks/{webhook_id}/invocations [get]
+func GetWebhookInvocationsAPI(c *gin.Context) {
+	var req appdto.GetWebhookInvocationsReq
+	if err := c.ShouldBindQuery(&req); err != nil {
+		gx.JSONErr(c, gx.BErr(err))
+		return
+	}
+
+	// Default page/size
+	if req.Page <= 0 {
+		req.Page = 1
+	}
+	if req.PageSize <= 0 {
+		req.PageSize = 20
+	}
+
+	list, total, err := di.WebhookApp.GetInvocations(c, c.Param("webhook_id"), &req)
+	if err != nil {
+		gx.JSONErr(c, err)
+		return
+	}
+
+	gx.JSONSuccess(c, map[string]interface{}{
+		"list":      list,
+		"total":     total,
+		"page":      req.Page,
+		"page_size": req.PageSize,
+	})
+}
+
+// maxWebhookBody caps the size of the events posted to a webhook
+const maxWebhookBody = 1 << 20
+
+// Trigger Webhook
+// @Summary Trigger Webhook
+// @Description Public endpoint for external systems. The caller puts the current Unix time in seconds in the X-Signature-Timestamp header and signs "<timestamp>.<raw body>" with HMAC-SHA256 and the secret of the webhook, the hex digest is sent in the X-Signature-256 header, optionally prefixed with "sha256=". Events signed more than 5 minutes away from now are rejected. The payload is turned into a prompt with the template of the webhook and sent to its role in a new session of the owner, the template sees the request headers without the credential ones. A synchronous webhook answers with the reply of the role, an asynchronous one with the invocation ID.
+// @Tags Webhook
+// @Accept json
+// @Produce json
+// @Param webhook_id path string true "Webhook ID"
+// @Param X-Signature-Timestamp header string true "Unix time in seconds the event was signed at"
+// @Param X-Signature-256 header string true "sha256=<hex HMAC-SHA256 of timestamp.body>"
+// @Success 200 {object} gx.Response
+// @Router /hooks/{webhook_id} [post]
+func TriggerWebhookAPI(c *gin.Context) {
+	body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxWebhookBody))
+	if err != nil {
+		gx.JSONErr(c, gx.BErr(err))
+		return
+	}
+	headers := make(map[string]string, len(c.Request.Header))
+	for key := range c.Request.Header {
+		headers[key] = c.Request.Header.Get(key)
+	}
+
+	invocation, err := di.WebhookApp.Trigger(c, c.Param("webhook_id"), c.GetHeader("X-Signature-256"), c.GetHeader("X-Signature-Timestamp"), headers, body)
+	if err != nil {
+		switch {
+		case ec.IsErrCode(err, errcode.WebhookSignatureInvalid):
+			gx.JSONCodeErr(c, http.StatusUnauthorized, err)
+		case ec.IsErrCode(err, errcode.WebhookNotFound):
+			gx.JSONCodeErr(c, http.StatusNotFound, err)
+		default:
+			gx.JSONErr(c, err)
+		}
+		return
+	}
+	gx.JSONSuccess(c, invocation)
+}
@@ -0,0 +1,210 @@
+package handler
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/xichan96/cortex-lab/internal/appdto"
+	"github.com/xichan96/cortex-lab/internal/di"
+	"github.com/xichan96/cortex-lab/pkg/web/gx"
+)
+
+// Get Workflow List
+// @Summary Get Workflow List
+// @Tags Workflow
+// @Accept json
+// @Produce json
+// @Param page query int false "Page"
+// @Param page_size query int false "Page Size"
+// @Param keyword query string false "Keyword"
+// @Param scope query string false "Scope"
+// @Success 200 {object} gx.Response
+// @Router /workflows [get]
+func GetWorkflowsAPI(c *gin.Context) {
+	var req appdto.GetWorkflowsReq
+	if err := c.ShouldBindQuery(&req); err != nil {
+		gx.JSONErr(c, gx.BErr(err))
+		return
+	}
+
+	// Default page/size
+	if req.Page <= 0 {
+		req.Page = 1
+	}
+	if req.PageSize <= 0 {
+		req.PageSize = 20
+	}
+
+	list, total, err := di.WorkflowApp.GetWorkflows(c, &req)
+	if err != nil {
+		gx.JSONErr(c, err)
+		return
+	}
+
+	gx.JSONSuccess(c, map[string]interface{}{
+		"list":      list,
+		"total":     total,
+		"page":      req.Page,
+		"page_size": req.PageSize,
+	})
+}
+
+// Create Workflow
+// @Summary Create Workflow
+// @Description The steps of a workflow form a DAG, each one is run by a role. The input of a step is a template where {{input}} is the input of the run and {{steps.<id>.output}} the output of another step.
+// @Tags Workflow
+// @Accept json
+// @Produce json
+// @Param req body appdto.CreateWorkflowReq true "req"
+// @Success 200 {object} gx.Response
+// @Router /workflows [post]
+func CreateWorkflowAPI(c *gin.Context) {
+	var req appdto.CreateWorkflowReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		gx.JSONErr(c, gx.BErr(err))
+		return
+	}
+	id, err := di.WorkflowApp.CreateWorkflow(c, &req)
+	if err != nil {
+		gx.JSONErr(c, err)
+		return
+	}
+	gx.JSONSuccess(c, map[string]string{"id": id})
+}
+
+// Update Workflow
+// @Summary Update Workflow
+// @Tags Workflow
+// @Accept json
+// @Produce json
+// @Param workflow_id path string true "Workflow ID"
+// @Param req body appdto.UpdateWorkflowReq true "req"
+// @Success 200 {object} gx.Response
+// @Router /workflows/{workflow_id} [put]
+func UpdateWorkflowAPI(c *gin.Context) {
+	var req appdto.UpdateWorkflowReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		gx.JSONErr(c, gx.BErr(err))
+		return
+	}
+	req.ID = c.Param("workflow_id")
+	if err := di.WorkflowApp.UpdateWorkflow(c, &req); err != nil {
+		gx.JSONErr(c, err)
+		return
+	}
+	gx.JSONSuccess(c, nil)
+}
+
+// Delete Workflow
+// @Summary Delete Workflow
+// @Tags Workflow
+// @Accept json
+// @Produce json
+// @Param workflow_id path string true "Workflow ID"
+// @Success 200 {object} gx.Response
+// @Router /workflows/{workflow_id} [delete]
+func DeleteWorkflowAPI(c *gin.Context) {
+	id := c.Param("workflow_id")
+	if err := di.WorkflowApp.DeleteWorkflow(c, id); err != nil {
+		gx.JSONErr(c, err)
+		return
+	}
+	gx.JSONSuccess(c, nil)
+}
+
+// Get Workflow
+// @Summary Get Workflow
+// @Tags Workflow
+// @Accept json
+// @Produce json
+// @Param workflow_id path string true "Workflow ID"
+// @Success 200 {object} gx.Response
+// @Router /workflows/{workflow_id} [get]
+func GetWorkflowAPI(c *gin.Context) {
+	id := c.Param("workflow_id")
+	workflow, err := di.WorkflowApp.GetWorkflow(c, id)
+	if err != nil {
+		gx.JSONErr(c, err)
+		return
+	}
+	gx.JSONSuccess(c, workflow)
+}
+
+// Run Workflow
+// @Summary Run Workflow
+// @Description Runs the workflow and returns the run with the input and output of each step. With async the run is returned as soon as it starts.
+// @Tags Workflow
+// @Accept json
+// @Produce json
+// @Param workflow_id path string true "Workflow ID"
+// @Param req body appdto.RunWorkflowReq true "req"
+// @Success 200 {object} gx.Response
+// @Router /workflows/{workflow_id}/runs [post]
+func RunWorkflowAPI(c *gin.Context) {
+	var req appdto.RunWorkflowReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		gx.JSONErr(c, gx.BErr(err))
+		return
+	}
+	run, err := di.WorkflowApp.RunWorkflow(c, c.Param("workflow_id"), &req)
+	if err != nil {
+		gx.JSONErr(c, err)
+		return
+	}
+	gx.JSONSuccess(c, run)
+}
+
+// Get Workflow Run List
+// @Summary Get Workflow Run List
+// @Tags Workflow
+// @Accept json
+// @Produce json
+// @Param workflow_id path string true "Workflow ID"
+// @Param page query int false "Page"
+// @Param page_size query int false "Page Size"
+// @Success 200 {object} gx.Response
+// @Router /workflows/{workflow_id}/runs [get]
+func GetWorkflowRunsAPI(c *gin.Context) {
+	var req appdto.GetWorkflowRunsReq
+	if err := c.ShouldBindQuery(&req); err != nil {
+		gx.JSONErr(c, gx.BErr(err))
+		return
+	}
+
+	// Default page/size
+	if req.Page <= 0 {
+		req.Page = 1
+	}
+	if req.PageSize <= 0 {
+		req.PageSize = 20
+	}
+
+	list, total, err := di.WorkflowApp.GetRuns(c, c.Param("workflow_id"), &req)
+	if err != nil {
+		gx.JSONErr(c, err)
+		return
+	}
+
+	gx.JSONSuccess(c, map[string]interface{}{
+		"list":      list,
+		"total":     total,
+		"page":      req.Page,
+		"page_size": req.PageSize,
+	})
+}
+
+// Get Workflow Run
+// @Summary Get Workflow Run
+// @Tags Workflow
+// @Accept json
+// @Produce json
+// @Param workflow_id path string true "Workflow ID"
+// @Param run_id path string true "Run ID"
+// @Success 200 {object} gx.Response
+// @Router /workflows/{workflow_id}/runs/{run_id} [get]
+func GetWorkflowRunAPI(c *gin.Context) {
+	run, err := di.WorkflowApp.GetRun(c, c.Param("workflow_id"), c.Param("run_id"))
+	if err != nil {
+		gx.JSONErr(c, err)
+		return
+	}
+	gx.JSONSuccess(c, run)
+}
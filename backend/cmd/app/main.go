@@ -71,6 +71,10 @@ func main() {
 	initAgentSetting()
 	initMemorySetting()
 
+	di.ScheduleApp.StartScheduler(context.Background())
+	di.WorkflowApp.StartRunMonitor(context.Background())
+	di.EventApp.StartDispatcher(context.Background())
+
 	s := gx.NewServer()
 	router.RegisterAPIRouter(s.Engine)
 	s.Run()
@@ -23,6 +23,10 @@ func RegisterAPIRouter(r *gin.Engine) {
 		api.POST("/auth/login", handler.LoginAPI)
 		api.POST("/auth/logout", middleware.Auth(), handler.LogoutAPI)
 		api.GET("/auth/me", middleware.Auth(), handler.MeAPI)
+		// share links are public, the token grants read access to a single session
+		api.GET("/share/:token", handler.GetSharedChatSessionAPI)
+		// webhook triggers are public, the events are authenticated by their HMAC signature
+		api.POST("/hooks/:webhook_id", handler.TriggerWebhookAPI)
 
 		users := api.Group("/users", middleware.Auth())
 		{
@@ -41,9 +45,78 @@ func RegisterAPIRouter(r *gin.Engine) {
 			roles.DELETE("/:role_id", handler.DeleteRoleAPI)
 		}
 
+		teams := api.Group("/teams", middleware.Auth())
+		{
+			teams.GET("", handler.GetTeamsAPI)
+			teams.POST("", handler.CreateTeamAPI)
+			teams.GET("/:team_id", handler.GetTeamAPI)
+			teams.PUT("/:team_id", handler.UpdateTeamAPI)
+			teams.DELETE("/:team_id", handler.DeleteTeamAPI)
+		}
+
+		workflows := api.Group("/workflows", middleware.Auth())
+		{
+			workflows.GET("", handler.GetWorkflowsAPI)
+			workflows.POST("", handler.CreateWorkflowAPI)
+			workflows.GET("/:workflow_id", handler.GetWorkflowAPI)
+			workflows.PUT("/:workflow_id", handler.UpdateWorkflowAPI)
+			workflows.DELETE("/:workflow_id", handler.DeleteWorkflowAPI)
+			workflows.POST("/:workflow_id/runs", handler.RunWorkflowAPI)
+			workflows.GET("/:workflow_id/runs", handler.GetWorkflowRunsAPI)
+			workflows.GET("/:workflow_id/runs/:run_id", handler.GetWorkflowRunAPI)
+		}
+
+		schedules := api.Group("/schedules", middleware.Auth())
+		{
+			schedules.GET("", handler.GetSchedulesAPI)
+			schedules.POST("", handler.CreateScheduleAPI)
+			schedules.GET("/:schedule_id", handler.GetScheduleAPI)
+			schedules.PUT("/:schedule_id", handler.UpdateScheduleAPI)
+			schedules.DELETE("/:schedule_id", handler.DeleteScheduleAPI)
+			schedules.GET("/:schedule_id/runs", handler.GetScheduleRunsAPI)
+		}
+
+		webhooks := api.Group("/webhooks", middleware.Auth())
+		{
+			webhooks.GET("", handler.GetWebhooksAPI)
+			webhooks.POST("", handler.CreateWebhookAPI)
+			webhooks.GET("/:webhook_id", handler.GetWebhookAPI)
+			webhooks.PUT("/:webhook_id", handler.UpdateWebhookAPI)
+			webhooks.DELETE("/:webhook_id", handler.DeleteWebhookAPI)
+			webhooks.POST("/:webhook_id/secret", handler.RotateWebhookSecretAPI)
+			webhooks.GET("/:webhook_id/invocations", handler.GetWebhookInvocationsAPI)
+		}
+
+		subscriptions := api.Group("/event-subscriptions", middleware.Auth())
+		{
+			subscriptions.GET("", handler.GetEventSubscriptionsAPI)
+			subscriptions.POST("", handler.CreateEventSubscriptionAPI)
+			subscriptions.GET("/:subscription_id", handler.GetEventSubscriptionAPI)
+			subscriptions.PUT("/:subscription_id", handler.UpdateEventSubscriptionAPI)
+			subscriptions.DELETE("/:subscription_id", handler.DeleteEventSubscriptionAPI)
+			subscriptions.POST("/:subscription_id/secret", handler.RotateEventSubscriptionSecretAPI)
+			subscriptions.GET("/:subscription_id/deliveries", handler.GetEventDeliveriesAPI)
+			subscriptions.POST("/:subscription_id/deliveries/:delivery_id/redeliver", handler.RedeliverEventAPI)
+		}
+
+		sshHosts := api.Group("/ssh-hosts", middleware.Auth())
+		{
+			sshHosts.GET("", handler.GetSSHHostsAPI)
+			sshHosts.POST("", handler.CreateSSHHostAPI)
+			sshHosts.GET("/:host_id", handler.GetSSHHostAPI)
+			sshHosts.PUT("/:host_id", handler.UpdateSSHHostAPI)
+			sshHosts.DELETE("/:host_id", handler.DeleteSSHHostAPI)
+			sshHosts.POST("/:host_id/scan", handler.ScanSSHHostKeyAPI)
+		}
+
 		experiences := api.Group("/experiences", middleware.Auth())
 		{
 			experiences.GET("/search", handler.SearchExperienceAPI)
+			experiences.POST("/documents", handler.UploadExperienceDocumentAPI)
+			experiences.GET("/documents", handler.GetExperienceDocumentsAPI)
+			experiences.GET("/documents/:id", handler.GetExperienceDocumentAPI)
+			experiences.PUT("/documents/:id", handler.ReplaceExperienceDocumentAPI)
+			experiences.DELETE("/documents/:id", handler.DeleteExperienceDocumentAPI)
 			experiences.POST("", handler.CreateExperienceAPI)
 			experiences.GET("/:id", handler.GetExperienceAPI)
 			experiences.PUT("/:id", handler.UpdateExperienceAPI)
@@ -66,8 +139,14 @@ func RegisterAPIRouter(r *gin.Engine) {
 			settings.PUT("/agent", handler.UpdateAgentSettingAPI)
 			settings.GET("/memory", handler.GetMemorySettingAPI)
 			settings.PUT("/memory", handler.UpdateMemorySettingAPI)
+			settings.GET("/pricing", handler.GetPricingSettingAPI)
+			settings.PUT("/pricing", handler.UpdatePricingSettingAPI)
+			settings.GET("/delegation", handler.GetDelegationSettingAPI)
+			settings.PUT("/delegation", handler.UpdateDelegationSettingAPI)
 		}
 
+		api.GET("/usage", middleware.Auth(), handler.GetUsageAPI)
+
 		agent := api.Group("/agent", middleware.Auth())
 		{
 			agent.POST("/chat/stream", handler.AgentStreamChatAPI)
@@ -89,10 +168,34 @@ func RegisterAPIRouter(r *gin.Engine) {
 		{
 			chat.POST("/:role_id/model/:provider/:model_name", handler.SendChatMessageAPI)
 			chat.POST("/:role_id/model/:provider/:model_name/stream", handler.SendChatMessageStreamAPI)
+			chat.POST("/:role_id", handler.SendChatMessageAPI)
+			chat.POST("/:role_id/stream", handler.SendChatMessageStreamAPI)
+			chat.POST("/attachments", handler.UploadChatAttachmentAPI)
+			chat.GET("/attachments/:attachment_id", handler.GetChatAttachmentAPI)
+			chat.GET("/search", handler.SearchChatMessagesAPI)
+			chat.GET("/tags", handler.GetChatTagsAPI)
+			chat.GET("/approvals", handler.GetToolApprovalsAPI)
+			chat.POST("/approvals/:approval_id/approve", handler.ApproveToolCallAPI)
+			chat.POST("/approvals/:approval_id/reject", handler.RejectToolCallAPI)
+			chat.PUT("/approvals/:approval_id/arguments", handler.EditToolCallAPI)
+			chat.GET("/folders", handler.GetChatFoldersAPI)
+			chat.POST("/folders", handler.CreateChatFolderAPI)
+			chat.PUT("/folders/:folder_id", handler.UpdateChatFolderAPI)
+			chat.DELETE("/folders/:folder_id", handler.DeleteChatFolderAPI)
 			chat.GET("/session", handler.GetChatSessionsAPI)
+			chat.POST("/session/import", handler.ImportChatSessionAPI)
 			chat.GET("/session/:session_id", handler.GetChatSessionAPI)
+			chat.GET("/session/:session_id/export", handler.ExportChatSessionAPI)
+			chat.GET("/session/:session_id/calls", handler.GetChatCallTreeAPI)
+			chat.POST("/session/:session_id/share", handler.ShareChatSessionAPI)
+			chat.DELETE("/session/:session_id/share", handler.RevokeChatSessionShareAPI)
 			chat.GET("/session/:session_id/messages", handler.GetChatMessagesAPI)
+			chat.PUT("/session/:session_id/messages/:message_id", handler.EditChatMessageAPI)
+			chat.POST("/session/:session_id/messages/:message_id/regenerate", handler.RegenerateChatMessageAPI)
+			chat.PUT("/session/:session_id/branch", handler.SwitchChatBranchAPI)
+			chat.POST("/session/:session_id/cancel", handler.CancelChatRunAPI)
 			chat.PUT("/session/:session_id/title", handler.UpdateChatSessionTitleAPI)
+			chat.PUT("/session/:session_id/organize", handler.OrganizeChatSessionAPI)
 			chat.DELETE("/session/:session_id", handler.DeleteChatSessionAPI)
 		}
 
@@ -7,8 +7,12 @@ import (
 	"log/slog"
 	"time"
 
+	"github.com/xichan96/cortex-lab/internal/app/chat"
 	"github.com/xichan96/cortex-lab/internal/app/setting"
+	"github.com/xichan96/cortex-lab/internal/app/sshhost"
 	"github.com/xichan96/cortex-lab/internal/appdto"
+	"github.com/xichan96/cortex-lab/internal/pkg/agentrun"
+	"github.com/xichan96/cortex-lab/internal/pkg/sandbox"
 	"github.com/xichan96/cortex/agent/engine"
 	"github.com/xichan96/cortex/agent/tools/builtin"
 	"github.com/xichan96/cortex/agent/types"
@@ -20,17 +24,21 @@ import (
 var roleAssistantPrompt string
 
 type AppIer interface {
-	Engine(sessionID string, promptContent string, promptConfig string, promptKey string, toolConfig *appdto.RoleToolConfig) (*engine.AgentEngine, error)
+	// Engine builds the engine of a run, the ssh tool reaches the hosts of the user of ctx granted to roleID
+	// and the calls of the tools requiring approval wait for a decision of that user
+	Engine(ctx context.Context, run *agentrun.Run, roleID string, promptContent string, promptConfig string, promptKey string, toolConfig *appdto.RoleToolConfig) (*engine.AgentEngine, error)
 	GetRoleAssistantPrompt() string
 }
 
 type app struct {
 	settingSrv setting.AppIer
+	sshHosts   sshhost.AppIer
 }
 
-func NewApp(settingSrv setting.AppIer) AppIer {
+func NewApp(settingSrv setting.AppIer, sshHosts sshhost.AppIer) AppIer {
 	return &app{
 		settingSrv: settingSrv,
+		sshHosts:   sshHosts,
 	}
 }
 
@@ -38,9 +46,7 @@ func (a *app) GetRoleAssistantPrompt() string {
 	return roleAssistantPrompt
 }
 
-func (a *app) build(sessionID string, promptContent string, promptConfigStr string, promptKey string, toolConfig *appdto.RoleToolConfig) (*engine.AgentEngine, error) {
-	ctx := context.Background()
-
+func (a *app) build(ctx context.Context, run *agentrun.Run, roleID string, promptContent string, promptConfigStr string, promptKey string, toolConfig *appdto.RoleToolConfig) (*engine.AgentEngine, error) {
 	agentSetting, err := a.settingSrv.GetAgentSetting(ctx)
 	if err != nil {
 		agentSetting = &appdto.AgentSetting{AgentConfig: &appdto.AgentConfig{}}
@@ -56,24 +62,34 @@ func (a *app) build(sessionID string, promptContent string, promptConfigStr stri
 		return nil, fmt.Errorf("failed to setup LLM: %w", err)
 	}
 
-	memoryProvider := a.setupMemory(sessionID)
+	memoryProvider := a.setupMemory(run.SessionID)
 	agentConfig := a.setupAgentConfig(promptContent, promptKey, agentSetting)
+	if toolConfig != nil && len(toolConfig.RequireApproval) > 0 && agentConfig.ToolExecutionTimeout > 0 {
+		// waiting for a decision does not count against the tool timeout
+		agentConfig.ToolExecutionTimeout += chat.ApprovalTimeout
+	}
 
 	engine := engine.NewAgentEngine(llmProvider, agentConfig)
 	engine.SetMemory(memoryProvider)
 
-	if tools := a.setupTools(toolConfig); len(tools) > 0 {
+	if tools := a.runTools(ctx, run, roleID, toolConfig); len(tools) > 0 {
 		engine.AddTools(tools)
 	}
 
 	return engine, nil
 }
 
-func (a *app) Engine(sessionID string, promptContent string, promptConfig string, promptKey string, toolConfig *appdto.RoleToolConfig) (*engine.AgentEngine, error) {
-	return a.build(sessionID, promptContent, promptConfig, promptKey, toolConfig)
+func (a *app) Engine(ctx context.Context, run *agentrun.Run, roleID string, promptContent string, promptConfig string, promptKey string, toolConfig *appdto.RoleToolConfig) (*engine.AgentEngine, error) {
+	return a.build(ctx, run, roleID, promptContent, promptConfig, promptKey, toolConfig)
 }
 
-func (a *app) setupTools(config *appdto.RoleToolConfig) []types.Tool {
+// runTools returns the tools of a run with the same approval gate as a chat
+// run, a role requiring approval cannot be run here to skip it
+func (a *app) runTools(ctx context.Context, run *agentrun.Run, roleID string, config *appdto.RoleToolConfig) []types.Tool {
+	return chat.GateTools(run.Context(), a.setupTools(ctx, roleID, config), config, run.UserID, run.SessionID, roleID, run.ID)
+}
+
+func (a *app) setupTools(ctx context.Context, roleID string, config *appdto.RoleToolConfig) []types.Tool {
 	if config == nil {
 		return nil
 	}
@@ -93,15 +109,17 @@ func (a *app) setupTools(config *appdto.RoleToolConfig) []types.Tool {
 				}))
 			}
 		case "command":
-			tools = append(tools, builtin.NewCommandTool())
+			tools = append(tools, sandbox.NewCommandTool((*sandbox.Policy)(config.Sandbox)))
 		case "file":
-			tools = append(tools, builtin.NewFileTool())
+			tools = append(tools, sandbox.NewFileTool((*sandbox.Policy)(config.Sandbox)))
 		case "math_calculate":
 			tools = append(tools, builtin.NewMathTool())
 		case "net_check":
 			tools = append(tools, builtin.NewPingTool())
 		case "ssh":
-			tools = append(tools, builtin.NewSSHTool())
+			if tool := a.sshHosts.Tool(ctx, roleID); tool != nil {
+				tools = append(tools, tool)
+			}
 		case "get_time":
 			tools = append(tools, builtin.NewTimeTool())
 		}
@@ -0,0 +1,124 @@
+package agent
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/xichan96/cortex-lab/internal/app/chat"
+	"github.com/xichan96/cortex-lab/internal/appdto"
+	"github.com/xichan96/cortex-lab/internal/pkg/agentrun"
+	"github.com/xichan96/cortex-lab/pkg/web/cctx"
+	"github.com/xichan96/cortex/agent/types"
+)
+
+type result struct {
+	out interface{}
+	err error
+}
+
+// startCall runs the call of a tool in the background like the engine does
+func startCall(tool types.Tool) <-chan result {
+	done := make(chan result, 1)
+	go func() {
+		out, err := tool.Execute(map[string]interface{}{"timezone": "UTC"})
+		done <- result{out, err}
+	}()
+	return done
+}
+
+// pendingApproval waits for the call of a run to show up in the approvals of userID
+func pendingApproval(t *testing.T, approvals chat.AppIer, ctx context.Context, runID string) *appdto.ToolApproval {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		for _, a := range approvals.GetApprovals(ctx, &appdto.GetToolApprovalsReq{}) {
+			if a.RunID == runID {
+				return a
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("no approval pending for run %s", runID)
+	return nil
+}
+
+func toolByName(tools []types.Tool, name string) types.Tool {
+	for _, tool := range tools {
+		if tool.Name() == name {
+			return tool
+		}
+	}
+	return nil
+}
+
+// Both agent endpoints build the tools of their run with runTools, a tool
+// requiring approval must not run through them without a decision
+func TestRunTools_RequireApproval(t *testing.T) {
+	a := &app{}
+	config := &appdto.RoleToolConfig{
+		Builtin:         []string{"get_time", "math_calculate"},
+		RequireApproval: []string{"get_time"},
+	}
+	// the approval endpoints only use the registry shared by every chat app
+	approvals := chat.NewApp(nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil)
+	ctx := cctx.WithContext(context.Background())
+	cctx.SetUserID(ctx, "u1")
+	registry := agentrun.NewRegistry()
+
+	t.Run("ungated tools run right away", func(t *testing.T) {
+		run, _ := registry.Start("s-ungated", "u1")
+		defer registry.Finish(run)
+		tools := a.runTools(ctx, run, "r1", config)
+		if _, err := toolByName(tools, "math_calculate").Execute(map[string]interface{}{"expression": "1+1"}); err != nil {
+			t.Fatalf("math_calculate error = %v", err)
+		}
+	})
+
+	t.Run("waits for a decision until cancelled", func(t *testing.T) {
+		run, _ := registry.Start("s-cancel", "u1")
+		defer registry.Finish(run)
+		done := startCall(toolByName(a.runTools(ctx, run, "r1", config), "get_time"))
+		pendingApproval(t, approvals, ctx, run.ID)
+		select {
+		case r := <-done:
+			t.Fatalf("the call ran without a decision: %v, %v", r.out, r.err)
+		case <-time.After(100 * time.Millisecond):
+		}
+		registry.Cancel(run.SessionID, "u1", run.ID)
+		if r := <-done; r.err == nil {
+			t.Fatal("a cancelled call returned no error")
+		}
+	})
+
+	t.Run("rejected", func(t *testing.T) {
+		run, _ := registry.Start("s-reject", "u1")
+		defer registry.Finish(run)
+		done := startCall(toolByName(a.runTools(ctx, run, "r1", config), "get_time"))
+		approval := pendingApproval(t, approvals, ctx, run.ID)
+		if err := approvals.RejectToolCall(ctx, approval.ID, &appdto.RejectToolCallReq{Reason: "no"}); err != nil {
+			t.Fatalf("RejectToolCall() error = %v", err)
+		}
+		if r := <-done; r.err == nil || r.out != nil {
+			t.Fatalf("a rejected call ran: %v, %v", r.out, r.err)
+		}
+	})
+
+	t.Run("another user cannot decide", func(t *testing.T) {
+		run, _ := registry.Start("s-other", "u1")
+		defer registry.Finish(run)
+		done := startCall(toolByName(a.runTools(ctx, run, "r1", config), "get_time"))
+		approval := pendingApproval(t, approvals, ctx, run.ID)
+		other := cctx.WithContext(context.Background())
+		cctx.SetUserID(other, "u2")
+		if err := approvals.ApproveToolCall(other, approval.ID, &appdto.ApproveToolCallReq{}); err == nil {
+			t.Fatal("another user approved the call")
+		}
+		if err := approvals.ApproveToolCall(ctx, approval.ID, &appdto.ApproveToolCallReq{}); err != nil {
+			t.Fatalf("ApproveToolCall() error = %v", err)
+		}
+		if r := <-done; r.err != nil || r.out == nil {
+			t.Fatalf("an approved call did not run: %v, %v", r.out, r.err)
+		}
+	})
+}
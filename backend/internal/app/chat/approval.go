@@ -0,0 +1,302 @@
+package chat
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/xichan96/cortex-lab/internal/appdto"
+	"github.com/xichan96/cortex-lab/internal/infra/model"
+	"github.com/xichan96/cortex-lab/internal/pkg/errcode"
+	"github.com/xichan96/cortex-lab/pkg/std/snowflake"
+	"github.com/xichan96/cortex-lab/pkg/web/cctx"
+	"github.com/xichan96/cortex/agent/types"
+)
+
+// ApprovalTimeout is how long a call waits for a decision before it is given up
+const ApprovalTimeout = 10 * time.Minute
+
+// approvalPolicy holds the tools of a run whose calls wait for the user
+type approvalPolicy struct {
+	tools     map[string]bool
+	userID    string
+	sessionID string
+	roleID    string
+	runID     string
+	// notify is called when a call starts waiting, nil when no client streams the run
+	notify func(approval *appdto.ToolApproval)
+}
+
+// newApprovalPolicy returns the policy of a role, nil when no tool requires approval
+func newApprovalPolicy(cfg *appdto.RoleToolConfig, userID, sessionID, roleID, runID string) *approvalPolicy {
+	if cfg == nil || len(cfg.RequireApproval) == 0 {
+		return nil
+	}
+	tools := make(map[string]bool, len(cfg.RequireApproval))
+	for _, name := range cfg.RequireApproval {
+		tools[name] = true
+	}
+	return &approvalPolicy{tools: tools, userID: userID, sessionID: sessionID, roleID: roleID, runID: runID}
+}
+
+func (p *approvalPolicy) requires(tool string) bool {
+	return p != nil && p.tools[tool]
+}
+
+type approvalDecision struct {
+	approved  bool
+	arguments map[string]interface{}
+	reason    string
+	by        string
+	at        time.Time
+}
+
+// pendingApproval is a tool call waiting for a decision
+type pendingApproval struct {
+	mu       sync.Mutex
+	approval appdto.ToolApproval
+	userID   string
+	closed   bool
+	decision chan approvalDecision
+}
+
+func (p *pendingApproval) snapshot() *appdto.ToolApproval {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	out := p.approval
+	return &out
+}
+
+// edit replaces the arguments the call runs with once approved
+func (p *pendingApproval) edit(args map[string]interface{}) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return false
+	}
+	p.approval.Arguments = args
+	return true
+}
+
+// decide hands the decision to the waiting call, false when it was already
+// decided or gave up waiting
+func (p *pendingApproval) decide(d approvalDecision) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return false
+	}
+	if d.approved && d.arguments == nil {
+		d.arguments = p.approval.Arguments
+	}
+	p.closed = true
+	p.decision <- d
+	return true
+}
+
+// close stops accepting decisions, false when a decision was already made
+func (p *pendingApproval) close() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return false
+	}
+	p.closed = true
+	return true
+}
+
+// approvalRegistry holds the calls waiting for a decision. It is shared by
+// every instance of the app since the decision is not made on the instance
+// running the call.
+type approvalRegistry struct {
+	mu      sync.Mutex
+	pending map[string]*pendingApproval
+}
+
+var approvals = &approvalRegistry{pending: make(map[string]*pendingApproval)}
+
+func (g *approvalRegistry) add(p *pendingApproval) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.pending[p.approval.ID] = p
+}
+
+func (g *approvalRegistry) remove(id string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	delete(g.pending, id)
+}
+
+// get returns a call of userID waiting for a decision
+func (g *approvalRegistry) get(id, userID string) (*pendingApproval, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	p, ok := g.pending[id]
+	if !ok || p.userID != userID {
+		return nil, false
+	}
+	return p, true
+}
+
+// list returns the calls of userID waiting for a decision, oldest first
+func (g *approvalRegistry) list(userID, sessionID string) []*appdto.ToolApproval {
+	g.mu.Lock()
+	pending := make([]*pendingApproval, 0, len(g.pending))
+	for _, p := range g.pending {
+		if p.userID == userID && (sessionID == "" || p.approval.SessionID == sessionID) {
+			pending = append(pending, p)
+		}
+	}
+	g.mu.Unlock()
+
+	out := make([]*appdto.ToolApproval, len(pending))
+	for i, p := range pending {
+		out[i] = p.snapshot()
+	}
+	slices.SortFunc(out, func(a, b *appdto.ToolApproval) int {
+		return a.CreatedAt.Compare(b.CreatedAt)
+	})
+	return out
+}
+
+// await blocks a call of tool until the user decides on it and returns the
+// arguments to run it with. A rejected call, or one nobody decided on within
+// ApprovalTimeout, returns an error the model sees instead of a result.
+func (p *approvalPolicy) await(ctx context.Context, tool types.Tool, args map[string]interface{}) (map[string]interface{}, *model.ToolApproval, error) {
+	now := time.Now()
+	pending := &pendingApproval{
+		approval: appdto.ToolApproval{
+			ID:        snowflake.NewUUID(),
+			SessionID: p.sessionID,
+			RunID:     p.runID,
+			RoleID:    p.roleID,
+			Tool:      tool.Name(),
+			Arguments: args,
+			Sensitive: sensitiveArguments(tool, args),
+			CreatedAt: now,
+			ExpiresAt: now.Add(ApprovalTimeout),
+		},
+		userID:   p.userID,
+		decision: make(chan approvalDecision, 1),
+	}
+	approvals.add(pending)
+	defer approvals.remove(pending.approval.ID)
+	if p.notify != nil {
+		p.notify(pending.snapshot())
+	}
+
+	timer := time.NewTimer(ApprovalTimeout)
+	defer timer.Stop()
+	var d approvalDecision
+	select {
+	case d = <-pending.decision:
+	case <-timer.C:
+		if pending.close() {
+			return args, &model.ToolApproval{Status: model.ToolApprovalTimedOut, WaitedMs: time.Since(now).Milliseconds()},
+				fmt.Errorf("the call to %s was not approved within %s", tool.Name(), ApprovalTimeout)
+		}
+		d = <-pending.decision
+	case <-ctx.Done():
+		if pending.close() {
+			return args, nil, errRunCancelled
+		}
+		d = <-pending.decision
+	}
+
+	record := &model.ToolApproval{
+		DecidedBy: d.by,
+		DecidedAt: &d.at,
+		Reason:    d.reason,
+		WaitedMs:  d.at.Sub(now).Milliseconds(),
+	}
+	if !d.approved {
+		record.Status = model.ToolApprovalRejected
+		if d.reason != "" {
+			return args, record, fmt.Errorf("the user rejected the call to %s: %s", tool.Name(), d.reason)
+		}
+		return args, record, fmt.Errorf("the user rejected the call to %s", tool.Name())
+	}
+	record.Status = model.ToolApprovalApproved
+	if !reflect.DeepEqual(d.arguments, args) {
+		record.OriginalArguments = args
+	}
+	return d.arguments, record, nil
+}
+
+// gatedTool makes the calls of a tool wait for a decision in the runs built
+// outside of the chat app, which have no trace to record them on
+type gatedTool struct {
+	types.Tool
+	policy *approvalPolicy
+	ctx    context.Context
+}
+
+func (t *gatedTool) Execute(input map[string]interface{}) (interface{}, error) {
+	input, _, err := t.policy.await(t.ctx, t.Tool, input)
+	if err != nil {
+		return nil, err
+	}
+	return executeTool(t.ctx, t.Tool, input)
+}
+
+// GateTools makes the calls of the tools requiring approval in cfg wait for
+// a decision of userID, like the calls of a chat run. It is used by the runs
+// built outside of the chat app, ctx is the context of the run.
+func GateTools(ctx context.Context, tools []types.Tool, cfg *appdto.RoleToolConfig, userID, sessionID, roleID, runID string) []types.Tool {
+	policy := newApprovalPolicy(cfg, userID, sessionID, roleID, runID)
+	if policy == nil {
+		return tools
+	}
+	out := make([]types.Tool, len(tools))
+	for i, t := range tools {
+		out[i] = t
+		if policy.requires(t.Name()) {
+			out[i] = &gatedTool{Tool: t, policy: policy, ctx: ctx}
+		}
+	}
+	return out
+}
+
+// GetApprovals lists the tool calls of the current user waiting for a
+// decision, of a session when sessionID is set
+func (a *app) GetApprovals(ctx context.Context, req *appdto.GetToolApprovalsReq) []*appdto.ToolApproval {
+	return approvals.list(cctx.GetUserID[string](ctx), req.SessionID)
+}
+
+// ApproveToolCall runs a call waiting for the current user, with the
+// arguments of the request when set
+func (a *app) ApproveToolCall(ctx context.Context, id string, req *appdto.ApproveToolCallReq) error {
+	return a.decide(ctx, id, approvalDecision{approved: true, arguments: req.Arguments})
+}
+
+// RejectToolCall answers a call waiting for the current user with an error
+// instead of running it
+func (a *app) RejectToolCall(ctx context.Context, id string, req *appdto.RejectToolCallReq) error {
+	return a.decide(ctx, id, approvalDecision{reason: req.Reason})
+}
+
+func (a *app) decide(ctx context.Context, id string, d approvalDecision) error {
+	userID := cctx.GetUserID[string](ctx)
+	pending, ok := approvals.get(id, userID)
+	if !ok {
+		return errcode.ToolApprovalNotFound
+	}
+	d.by, d.at = userID, time.Now()
+	if !pending.decide(d) {
+		return errcode.ToolApprovalNotFound
+	}
+	return nil
+}
+
+// EditToolCall replaces the arguments of a call waiting for the current
+// user, it still waits for a decision
+func (a *app) EditToolCall(ctx context.Context, id string, req *appdto.EditToolCallReq) (*appdto.ToolApproval, error) {
+	pending, ok := approvals.get(id, cctx.GetUserID[string](ctx))
+	if !ok || !pending.edit(req.Arguments) {
+		return nil, errcode.ToolApprovalNotFound
+	}
+	return pending.snapshot(), nil
+}
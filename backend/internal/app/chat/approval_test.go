@@ -0,0 +1,142 @@
+package chat
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/xichan96/cortex-lab/internal/appdto"
+	"github.com/xichan96/cortex-lab/internal/infra/model"
+	"github.com/xichan96/cortex/agent/tools/builtin"
+)
+
+// waitPending returns the call of userID waiting for a decision
+func waitPending(t *testing.T, userID string) *appdto.ToolApproval {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if list := approvals.list(userID, ""); len(list) > 0 {
+			return list[0]
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatal("no call waiting for a decision")
+	return nil
+}
+
+func TestPendingApproval_DecidedOnce(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		p := &pendingApproval{decision: make(chan approvalDecision, 1)}
+		var won atomic.Int32
+		var wg sync.WaitGroup
+		for j := 0; j < 8; j++ {
+			wg.Add(2)
+			go func() {
+				defer wg.Done()
+				if p.decide(approvalDecision{approved: true}) {
+					won.Add(1)
+				}
+			}()
+			go func() {
+				defer wg.Done()
+				if p.close() {
+					won.Add(1)
+				}
+			}()
+		}
+		wg.Wait()
+		if won.Load() != 1 {
+			t.Fatalf("%d callers won the decision, want 1", won.Load())
+		}
+	}
+}
+
+func TestApprovalPolicy_Await(t *testing.T) {
+	tool := builtin.NewTimeTool()
+	policy := newApprovalPolicy(&appdto.RoleToolConfig{RequireApproval: []string{tool.Name()}}, "u1", "s1", "r1", "run1")
+	if !policy.requires(tool.Name()) || policy.requires("file") {
+		t.Fatal("requires() does not match the configured tools")
+	}
+	args := map[string]interface{}{"timezone": "UTC"}
+
+	type outcome struct {
+		args   map[string]interface{}
+		record *model.ToolApproval
+		err    error
+	}
+	await := func(ctx context.Context) <-chan outcome {
+		done := make(chan outcome, 1)
+		go func() {
+			a, r, err := policy.await(ctx, tool, args)
+			done <- outcome{a, r, err}
+		}()
+		return done
+	}
+
+	t.Run("edited then approved", func(t *testing.T) {
+		done := await(context.Background())
+		pending := waitPending(t, "u1")
+		if _, ok := approvals.get(pending.ID, "u2"); ok {
+			t.Fatal("another user got the call")
+		}
+		p, _ := approvals.get(pending.ID, "u1")
+		edited := map[string]interface{}{"timezone": "Asia/Tokyo"}
+		if !p.edit(edited) || !p.decide(approvalDecision{approved: true, by: "u1", at: time.Now()}) {
+			t.Fatal("the call could not be edited and approved")
+		}
+		if p.decide(approvalDecision{approved: true}) || p.edit(args) {
+			t.Fatal("a decided call accepted another decision")
+		}
+		o := <-done
+		if o.err != nil || o.args["timezone"] != "Asia/Tokyo" {
+			t.Fatalf("await() = %v, %v, want the edited arguments", o.args, o.err)
+		}
+		if o.record.Status != model.ToolApprovalApproved || o.record.OriginalArguments["timezone"] != "UTC" || o.record.DecidedBy != "u1" {
+			t.Errorf("record = %+v", o.record)
+		}
+	})
+
+	t.Run("rejected", func(t *testing.T) {
+		done := await(context.Background())
+		p, _ := approvals.get(waitPending(t, "u1").ID, "u1")
+		p.decide(approvalDecision{reason: "not now", by: "u1", at: time.Now()})
+		o := <-done
+		if o.err == nil || o.record.Status != model.ToolApprovalRejected || o.record.Reason != "not now" {
+			t.Fatalf("await() = %+v, %v, want a rejection", o.record, o.err)
+		}
+	})
+
+	t.Run("cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		done := await(ctx)
+		pending := waitPending(t, "u1")
+		cancel()
+		if o := <-done; o.err != errRunCancelled {
+			t.Fatalf("await() error = %v, want errRunCancelled", o.err)
+		}
+		if _, ok := approvals.get(pending.ID, "u1"); ok {
+			t.Fatal("a cancelled call is still waiting")
+		}
+	})
+
+	t.Run("decided while cancelled", func(t *testing.T) {
+		// whichever of the decision and the cancel wins, await returns and the call is removed
+		for i := 0; i < 20; i++ {
+			ctx, cancel := context.WithCancel(context.Background())
+			done := await(ctx)
+			p, _ := approvals.get(waitPending(t, "u1").ID, "u1")
+			go cancel()
+			p.decide(approvalDecision{approved: true, by: "u1", at: time.Now()})
+			select {
+			case <-done:
+			case <-time.After(2 * time.Second):
+				t.Fatal("await() did not return")
+			}
+			if len(approvals.list("u1", "")) != 0 {
+				t.Fatal("a finished call is still waiting")
+			}
+		}
+	})
+}
@@ -0,0 +1,303 @@
+package chat
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/jinzhu/copier"
+	"github.com/tmc/langchaingo/llms"
+	"github.com/xichan96/cortex-lab/internal/appdto"
+	"github.com/xichan96/cortex-lab/internal/config"
+	"github.com/xichan96/cortex-lab/internal/infra/model"
+	"github.com/xichan96/cortex-lab/internal/pkg/errcode"
+	"github.com/xichan96/cortex-lab/internal/pkg/storage"
+	"github.com/xichan96/cortex-lab/pkg/std/snowflake"
+	"github.com/xichan96/cortex-lab/pkg/web/cctx"
+	"github.com/xichan96/cortex/agent/types"
+	"gorm.io/gorm"
+)
+
+// maxAttachmentSize is the largest file accepted by UploadAttachment
+const maxAttachmentSize = 20 << 20
+
+// maxInlineTextSize caps how much of a text attachment is put in the prompt
+const maxInlineTextSize = 32 << 10
+
+// imageTokens approximates what an image costs in the prompt of a vision model
+const imageTokens = 1000
+
+// visionModelPrefixes lists the model name prefixes that accept image input
+var visionModelPrefixes = []string{
+	"gpt-4o",
+	"gpt-4.1",
+	"gpt-4-turbo",
+	"gpt-5",
+	"o1",
+	"o3",
+	"o4",
+	"doubao-vision",
+	"doubao-1.5-vision",
+	"doubao-seed",
+}
+
+// imageMimeTypes are the image formats sent to vision models
+var imageMimeTypes = map[string]bool{
+	"image/png":  true,
+	"image/jpeg": true,
+	"image/gif":  true,
+	"image/webp": true,
+}
+
+// textMimeTypes are the non text/* formats whose content is put in the prompt
+var textMimeTypes = map[string]bool{
+	"application/json":       true,
+	"application/xml":        true,
+	"application/yaml":       true,
+	"application/x-yaml":     true,
+	"application/javascript": true,
+	"application/x-sh":       true,
+}
+
+// supportsVision reports whether modelName accepts image parts
+func supportsVision(modelName string) bool {
+	name := strings.ToLower(modelName)
+	if strings.Contains(name, "vision") || strings.Contains(name, "-vl") {
+		return true
+	}
+	for _, prefix := range visionModelPrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func isImage(mimeType string) bool {
+	return imageMimeTypes[mimeType]
+}
+
+func isText(mimeType string) bool {
+	return strings.HasPrefix(mimeType, "text/") || textMimeTypes[mimeType]
+}
+
+func attachmentKey(id string) string {
+	return "attachments/" + id
+}
+
+func attachmentStorage() *storage.Local {
+	return storage.NewLocal(config.Config.StoragePath())
+}
+
+// detectMimeType sniffs the content type of an upload, the file extension is
+// used when the content alone is not conclusive
+func detectMimeType(fileName string, head []byte) string {
+	detected := http.DetectContentType(head)
+	if mediaType, _, err := mime.ParseMediaType(detected); err == nil {
+		detected = mediaType
+	}
+	if detected != "application/octet-stream" && detected != "text/plain" {
+		return detected
+	}
+	if byExt := mime.TypeByExtension(strings.ToLower(filepath.Ext(fileName))); byExt != "" {
+		if mediaType, _, err := mime.ParseMediaType(byExt); err == nil {
+			return mediaType
+		}
+	}
+	return detected
+}
+
+// UploadAttachment stores a file on the local disk so it can be sent with a chat message
+func (a *app) UploadAttachment(ctx context.Context, fileName string, r io.Reader) (*appdto.ChatAttachment, error) {
+	reader := bufio.NewReader(r)
+	head, err := reader.Peek(512)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return nil, err
+	}
+
+	id := snowflake.NewUUID()
+	key := attachmentKey(id)
+	store := attachmentStorage()
+	size, err := store.Save(key, io.LimitReader(reader, maxAttachmentSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to store attachment: %w", err)
+	}
+	if size > maxAttachmentSize {
+		_ = store.Delete(key)
+		return nil, errcode.ChatAttachmentTooLarge
+	}
+
+	attachment := &model.ChatAttachment{
+		ID:         id,
+		UserID:     cctx.GetUserID[string](ctx),
+		FileName:   filepath.Base(fileName),
+		MimeType:   detectMimeType(fileName, head),
+		Size:       size,
+		StorageKey: key,
+	}
+	if _, err := a.ap.Create(ctx, attachment); err != nil {
+		_ = store.Delete(key)
+		return nil, err
+	}
+	dto := &appdto.ChatAttachment{}
+	_ = copier.Copy(dto, attachment)
+	return dto, nil
+}
+
+// OpenAttachment opens an attachment uploaded by the current user, the caller closes the file
+func (a *app) OpenAttachment(ctx context.Context, id string) (*appdto.ChatAttachment, *os.File, error) {
+	attachment, err := a.ap.GetByID(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	if attachment.UserID != cctx.GetUserID[string](ctx) {
+		return nil, nil, gorm.ErrRecordNotFound
+	}
+	file, err := attachmentStorage().Open(attachment.StorageKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	dto := &appdto.ChatAttachment{}
+	_ = copier.Copy(dto, attachment)
+	return dto, file, nil
+}
+
+// resolveAttachments loads the attachments referenced by a message, they must
+// have been uploaded by the current user
+func (a *app) resolveAttachments(ctx context.Context, ids []string) ([]model.AttachmentRef, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	userID := cctx.GetUserID[string](ctx)
+	attachments, err := a.ap.GetList(ctx, func(db *gorm.DB) *gorm.DB {
+		return db.Where("id IN ? AND user_id = ?", ids, userID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[string]*model.ChatAttachment, len(attachments))
+	for _, att := range attachments {
+		byID[att.ID] = att
+	}
+	refs := make([]model.AttachmentRef, 0, len(ids))
+	for _, id := range ids {
+		att, ok := byID[id]
+		if !ok {
+			return nil, errcode.ChatAttachmentNotFound
+		}
+		refs = append(refs, model.AttachmentRef{
+			ID:       att.ID,
+			FileName: att.FileName,
+			MimeType: att.MimeType,
+			Size:     att.Size,
+		})
+	}
+	return refs, nil
+}
+
+// attachmentParts renders attachments as message parts. Images are sent
+// inline to vision models, text files are inlined and everything else is
+// only named so the model knows it was attached.
+func attachmentParts(refs []model.AttachmentRef, vision bool) []types.MessagePart {
+	if len(refs) == 0 {
+		return nil
+	}
+	store := attachmentStorage()
+	parts := make([]types.MessagePart, 0, len(refs))
+	for _, ref := range refs {
+		switch {
+		case isImage(ref.MimeType) && vision:
+			data, err := store.Read(attachmentKey(ref.ID))
+			if err != nil {
+				slog.Error("Failed to read attachment", "error", err, "attachment_id", ref.ID)
+				parts = append(parts, types.TextPart{Text: fmt.Sprintf("[Image %s is no longer available]", ref.FileName)})
+				continue
+			}
+			// the OpenAI protocol takes inline images as data URLs
+			parts = append(parts, types.ImageURLPart{URL: "data:" + ref.MimeType + ";base64," + base64.StdEncoding.EncodeToString(data)})
+		case isImage(ref.MimeType):
+			parts = append(parts, types.TextPart{Text: fmt.Sprintf("[Image %s attached, the current model cannot view images]", ref.FileName)})
+		case isText(ref.MimeType):
+			data, err := store.Read(attachmentKey(ref.ID))
+			if err != nil {
+				slog.Error("Failed to read attachment", "error", err, "attachment_id", ref.ID)
+				parts = append(parts, types.TextPart{Text: fmt.Sprintf("[File %s is no longer available]", ref.FileName)})
+				continue
+			}
+			parts = append(parts, types.TextPart{Text: inlineText(ref.FileName, data)})
+		default:
+			parts = append(parts, types.TextPart{Text: fmt.Sprintf("[File %s (%s, %d bytes) attached, its content cannot be read]", ref.FileName, ref.MimeType, ref.Size)})
+		}
+	}
+	return parts
+}
+
+func inlineText(fileName string, data []byte) string {
+	truncated := false
+	if len(data) > maxInlineTextSize {
+		data = data[:maxInlineTextSize]
+		for len(data) > 0 && !utf8.Valid(data) {
+			data = data[:len(data)-1]
+		}
+		truncated = true
+	}
+	text := fmt.Sprintf("File %s:\n```\n%s\n```", fileName, data)
+	if truncated {
+		text += "\n[truncated]"
+	}
+	return text
+}
+
+// messageParts returns the parts of a message with attachments, nil when it has none
+func messageParts(content string, refs []model.AttachmentRef, vision bool) []types.MessagePart {
+	if len(refs) == 0 {
+		return nil
+	}
+	parts := []types.MessagePart{types.TextPart{Text: content}}
+	return append(parts, attachmentParts(refs, vision)...)
+}
+
+// attachmentTokens approximates the prompt tokens of attachments
+func attachmentTokens(refs []model.AttachmentRef, vision bool) int {
+	tokens := 0
+	for _, ref := range refs {
+		switch {
+		case isImage(ref.MimeType) && vision:
+			tokens += imageTokens
+		case isText(ref.MimeType):
+			size := ref.Size
+			if size > maxInlineTextSize {
+				size = maxInlineTextSize
+			}
+			tokens += int(size/4) + messageOverhead
+		default:
+			tokens += messageOverhead
+		}
+	}
+	return tokens
+}
+
+// toContentParts converts cortex message parts to langchaingo content parts
+func toContentParts(parts []types.MessagePart) []llms.ContentPart {
+	out := make([]llms.ContentPart, 0, len(parts))
+	for _, part := range parts {
+		switch p := part.(type) {
+		case types.TextPart:
+			out = append(out, llms.TextPart(p.Text))
+		case types.ImageURLPart:
+			out = append(out, llms.ImageURLPart(p.URL))
+		case types.ImageDataPart:
+			out = append(out, llms.ImageURLPart("data:"+p.MIMEType+";base64,"+base64.StdEncoding.EncodeToString(p.Data)))
+		}
+	}
+	return out
+}
@@ -0,0 +1,170 @@
+package chat
+
+import (
+	"context"
+
+	"github.com/xichan96/cortex-lab/internal/infra/model"
+	"github.com/xichan96/cortex-lab/internal/infra/persist"
+	"gorm.io/gorm"
+)
+
+// branchPoint is where a run attaches to the message tree of a session
+type branchPoint struct {
+	// parentID is the message the run input follows, empty for a new root message
+	parentID string
+	// inputID is an existing user message reused as the run input when regenerating
+	inputID string
+}
+
+func strPtr(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+func derefStr(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+func sessionMessages(ctx context.Context, mp persist.ChatMessagePersistIer, sessionID string) ([]*model.ChatMessage, error) {
+	return mp.GetList(ctx,
+		func(db *gorm.DB) *gorm.DB {
+			return db.Where("session_id = ?", sessionID)
+		},
+		func(db *gorm.DB) *gorm.DB {
+			return db.Order("created_at ASC")
+		},
+	)
+}
+
+// sessionTree loads the links of the messages of a session without their
+// content, enough to walk the tree
+func sessionTree(ctx context.Context, mp persist.ChatMessagePersistIer, sessionID string) ([]*model.ChatMessage, error) {
+	return mp.GetList(ctx,
+		func(db *gorm.DB) *gorm.DB {
+			return db.Select("id", "parent_id").Where("session_id = ?", sessionID)
+		},
+		func(db *gorm.DB) *gorm.DB {
+			return db.Order("created_at ASC")
+		},
+	)
+}
+
+// loadMessages loads the messages of a walk of sessionTree with their
+// content, in the same order
+func loadMessages(ctx context.Context, mp persist.ChatMessagePersistIer, links []*model.ChatMessage) ([]*model.ChatMessage, error) {
+	if len(links) == 0 {
+		return nil, nil
+	}
+	ids := make([]string, len(links))
+	for i, m := range links {
+		ids[i] = m.ID
+	}
+	messages, err := mp.GetList(ctx, func(db *gorm.DB) *gorm.DB {
+		return db.Where("id IN ?", ids)
+	})
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[string]*model.ChatMessage, len(messages))
+	for _, m := range messages {
+		byID[m.ID] = m
+	}
+	out := make([]*model.ChatMessage, 0, len(links))
+	for _, m := range links {
+		// a message deleted in between is left out
+		if full, ok := byID[m.ID]; ok {
+			out = append(out, full)
+		}
+	}
+	return out, nil
+}
+
+// activeMessages returns the messages from the root of the tree down to
+// leafID, only the content of the path is loaded
+func activeMessages(ctx context.Context, mp persist.ChatMessagePersistIer, sessionID, leafID string) ([]*model.ChatMessage, error) {
+	tree, err := sessionTree(ctx, mp, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	return loadMessages(ctx, mp, activePath(tree, leafID))
+}
+
+// currentLeaf returns the last message of the active branch of a session.
+// Sessions created before branching existed have no parent links; their
+// messages are chained in creation order the first time they are used.
+func currentLeaf(ctx context.Context, mp persist.ChatMessagePersistIer, sp persist.ChatSessionPersistIer, session *model.ChatSession) (string, error) {
+	if session.CurrentMessageID != nil {
+		return *session.CurrentMessageID, nil
+	}
+	messages, err := sessionTree(ctx, mp, session.ID)
+	if err != nil {
+		return "", err
+	}
+	if len(messages) == 0 {
+		return "", nil
+	}
+	for i := 1; i < len(messages); i++ {
+		if messages[i].ParentID != nil {
+			continue
+		}
+		if err := mp.Update(ctx, &model.ChatMessage{ID: messages[i].ID, ParentID: &messages[i-1].ID}); err != nil {
+			return "", err
+		}
+	}
+	leaf := messages[len(messages)-1].ID
+	if err := sp.Update(ctx, &model.ChatSession{ID: session.ID, CurrentMessageID: &leaf}); err != nil {
+		return "", err
+	}
+	session.CurrentMessageID = &leaf
+	return leaf, nil
+}
+
+// activePath returns the messages from the root of the tree down to leafID
+func activePath(messages []*model.ChatMessage, leafID string) []*model.ChatMessage {
+	byID := make(map[string]*model.ChatMessage, len(messages))
+	for _, m := range messages {
+		byID[m.ID] = m
+	}
+	var path []*model.ChatMessage
+	seen := make(map[string]bool)
+	for id := leafID; id != "" && !seen[id]; {
+		m, ok := byID[id]
+		if !ok {
+			break
+		}
+		seen[id] = true
+		path = append(path, m)
+		id = derefStr(m.ParentID)
+	}
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+	return path
+}
+
+// latestLeaf follows the most recent child from messageID down to a leaf
+func latestLeaf(messages []*model.ChatMessage, messageID string) string {
+	children := make(map[string]string, len(messages))
+	// messages are ordered by creation time, so the last child wins
+	for _, m := range messages {
+		if m.ParentID != nil {
+			children[*m.ParentID] = m.ID
+		}
+	}
+	seen := make(map[string]bool)
+	id := messageID
+	for !seen[id] {
+		seen[id] = true
+		child, ok := children[id]
+		if !ok {
+			break
+		}
+		id = child
+	}
+	return id
+}
@@ -0,0 +1,105 @@
+package chat
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"github.com/xichan96/cortex-lab/internal/infra/model"
+	"github.com/xichan96/cortex-lab/internal/infra/persist"
+	"github.com/xichan96/cortex-lab/pkg/sql"
+)
+
+// newMessageStore opens an in-memory DB holding a session with two branches:
+// root -> a -> b, and root -> a -> c created after b
+func newMessageStore(t *testing.T) persist.ChatMessagePersistIer {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// every connection to :memory: opens a new DB
+	if sqlDB, err := db.DB(); err == nil {
+		sqlDB.SetMaxOpenConns(1)
+	}
+	if err := db.AutoMigrate(&model.ChatMessage{}); err != nil {
+		t.Fatal(err)
+	}
+	sql.SetDefaultDB(func() *gorm.DB { return db })
+	t.Cleanup(func() { sql.SetDefaultDB(nil) })
+
+	mp := persist.NewChatMessagePersist()
+	start := time.Now()
+	for i, m := range []*model.ChatMessage{
+		{ID: "root", Role: "user", Content: "hi"},
+		{ID: "a", ParentID: strPtr("root"), Role: "assistant", Content: "hello"},
+		{ID: "b", ParentID: strPtr("a"), Role: "user", Content: "first branch"},
+		{ID: "c", ParentID: strPtr("a"), Role: "user", Content: "second branch"},
+		{ID: "other", Role: "user", Content: "another session"},
+	} {
+		m.SessionID = "s1"
+		if m.ID == "other" {
+			m.SessionID = "s2"
+		}
+		m.CreatedAt = start.Add(time.Duration(i) * time.Second)
+		if _, err := mp.Create(context.Background(), m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !db.Migrator().HasIndex(&model.ChatMessage{}, "idx_chat_message_session_parent") {
+		t.Errorf("the messages have no index on (session_id, parent_id)")
+	}
+	return mp
+}
+
+func TestSessionTree(t *testing.T) {
+	mp := newMessageStore(t)
+	tree, err := sessionTree(context.Background(), mp, "s1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tree) != 4 {
+		t.Fatalf("sessionTree() = %d messages, want 4", len(tree))
+	}
+	for _, m := range tree {
+		if m.Content != "" {
+			t.Errorf("sessionTree() loaded the content of %s", m.ID)
+		}
+	}
+	if got := latestLeaf(tree, "root"); got != "c" {
+		t.Errorf("latestLeaf() = %s, want c", got)
+	}
+}
+
+func TestActiveMessages(t *testing.T) {
+	mp := newMessageStore(t)
+	tests := []struct {
+		leaf string
+		want []string
+	}{
+		{"b", []string{"hi", "hello", "first branch"}},
+		{"c", []string{"hi", "hello", "second branch"}},
+		{"a", []string{"hi", "hello"}},
+		{"", nil},
+		{"missing", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.leaf, func(t *testing.T) {
+			got, err := activeMessages(context.Background(), mp, "s1", tt.leaf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("activeMessages() = %d messages, want %d", len(got), len(tt.want))
+			}
+			for i, m := range got {
+				if m.Content != tt.want[i] {
+					t.Errorf("activeMessages()[%d] = %q, want %q", i, m.Content, tt.want[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,168 @@
+package chat
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+
+	"github.com/xichan96/cortex-lab/internal/appdto"
+	"github.com/xichan96/cortex-lab/internal/infra/model"
+	"github.com/xichan96/cortex/agent/types"
+)
+
+const (
+	// ContextStrategySlidingWindow fills the context with the latest raw messages only
+	ContextStrategySlidingWindow = "sliding_window"
+	// ContextStrategySummaryWindow puts the latest memory summary first and fills the rest with the messages after it
+	ContextStrategySummaryWindow = "summary_window"
+)
+
+// defaultContextWindow is used for models missing from modelContextWindows
+const defaultContextWindow = 32768
+
+// messageOverhead approximates the tokens a chat message costs besides its content
+const messageOverhead = 4
+
+// modelContextWindows maps model name prefixes to their context window in tokens
+var modelContextWindows = map[string]int{
+	"gpt-5":         400000,
+	"gpt-4.1":       1047576,
+	"gpt-4o":        128000,
+	"gpt-4-turbo":   128000,
+	"gpt-4":         8192,
+	"gpt-3.5-turbo": 16385,
+	"o1":            200000,
+	"o3":            200000,
+	"o4":            200000,
+	"deepseek":      65536,
+	"doubao":        32768,
+}
+
+// contextWindow returns the context window of modelName, the longest matching prefix wins
+func contextWindow(modelName string) int {
+	name := strings.ToLower(modelName)
+	prefixes := make([]string, 0, len(modelContextWindows))
+	for prefix := range modelContextWindows {
+		prefixes = append(prefixes, prefix)
+	}
+	sort.Slice(prefixes, func(i, j int) bool { return len(prefixes[i]) > len(prefixes[j]) })
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(name, prefix) {
+			return modelContextWindows[prefix]
+		}
+	}
+	return defaultContextWindow
+}
+
+// MemoryOptions configures how a DatabaseMemoryProvider assembles the history
+type MemoryOptions struct {
+	// MaxHistory caps the number of history messages regardless of the budget
+	MaxHistory int
+	// TokenBudget is the number of tokens the history may use, 0 for no budget
+	TokenBudget int
+	// Strategy is ContextStrategySlidingWindow or ContextStrategySummaryWindow
+	Strategy string
+	// CompressRatio is the share of the history summarized by CompressMemory
+	CompressRatio float32
+	// Vision is set when the model accepts image attachments
+	Vision bool
+}
+
+// memoryOptions derives the history limits of a run. The token budget is the
+// context window of the model minus the reply, the system prompt and the tool
+// definitions, which the engine always sends.
+func memoryOptions(cfg *appdto.MemoryConfig, modelName string, agentConfig *types.AgentConfig, tools []types.Tool) MemoryOptions {
+	opts := MemoryOptions{MaxHistory: 100, Strategy: ContextStrategySummaryWindow}
+	if cfg == nil {
+		cfg = &appdto.MemoryConfig{}
+	}
+	if cfg.Simple.MaxHistoryMessages > 0 {
+		opts.MaxHistory = cfg.Simple.MaxHistoryMessages
+	} else if cfg.SQLite.MaxHistoryMessages > 0 {
+		opts.MaxHistory = cfg.SQLite.MaxHistoryMessages
+	} else if cfg.MySQL.MaxHistoryMessages > 0 {
+		opts.MaxHistory = cfg.MySQL.MaxHistoryMessages
+	} else if cfg.MongoDB.MaxHistoryMessages > 0 {
+		opts.MaxHistory = cfg.MongoDB.MaxHistoryMessages
+	} else if cfg.Redis.MaxHistoryMessages > 0 {
+		opts.MaxHistory = cfg.Redis.MaxHistoryMessages
+	}
+	if cfg.Context.Strategy == ContextStrategySlidingWindow {
+		opts.Strategy = ContextStrategySlidingWindow
+	}
+	opts.CompressRatio = cfg.Compress.Ratio
+	opts.Vision = supportsVision(modelName)
+
+	window := cfg.Context.ContextWindow
+	if window <= 0 {
+		window = contextWindow(modelName)
+	}
+	reserved := agentConfig.MaxTokens + estimateTokens(agentConfig.SystemMessage)
+	if cfg.Context.ReserveTokens > 0 {
+		reserved += cfg.Context.ReserveTokens
+	}
+	for _, tool := range tools {
+		schema, _ := json.Marshal(tool.Schema())
+		reserved += estimateTokens(tool.Name()+tool.Description()) + estimateTokens(string(schema))
+	}
+	opts.TokenBudget = window - reserved
+	if opts.TokenBudget < 1 {
+		// the fixed part alone exceeds the window, keep the latest turn only
+		opts.TokenBudget = 1
+	}
+	return opts
+}
+
+func messageTokens(content string) int {
+	return estimateTokens(content) + messageOverhead
+}
+
+// historyTokens approximates what a history message costs, attachments included
+func historyTokens(m *model.ChatMessage, vision bool) int {
+	tokens := messageTokens(m.Content)
+	if m.Meta != nil {
+		tokens += attachmentTokens(m.Meta.Attachments, vision)
+	}
+	return tokens
+}
+
+// selectHistory picks the history sent to the model from the active path.
+// The latest turn is always kept, older messages are added backwards while
+// they fit in the token budget. With the summary strategy the latest summary
+// replaces the messages it covers and is paid for first.
+func selectHistory(path []*model.ChatMessage, opts MemoryOptions) (*model.MemorySummary, []*model.ChatMessage) {
+	var summary *model.MemorySummary
+	messages := path
+	if opts.Strategy != ContextStrategySlidingWindow {
+		summary, messages = summarizedPath(path)
+	}
+	if opts.MaxHistory > 0 && len(messages) > opts.MaxHistory {
+		messages = messages[len(messages)-opts.MaxHistory:]
+	}
+	if opts.TokenBudget <= 0 {
+		return summary, messages
+	}
+
+	budget := opts.TokenBudget
+	if summary != nil {
+		budget -= messageTokens(summary.Content)
+	}
+	start := len(messages)
+	// the latest turn is kept whatever its size: the last user message and what follows it
+	for start > 0 {
+		start--
+		budget -= historyTokens(messages[start], opts.Vision)
+		if messages[start].Role == "user" {
+			break
+		}
+	}
+	for start > 0 {
+		cost := historyTokens(messages[start-1], opts.Vision)
+		if cost > budget {
+			break
+		}
+		budget -= cost
+		start--
+	}
+	return summary, messages[start:]
+}
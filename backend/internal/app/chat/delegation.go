@@ -0,0 +1,294 @@
+package chat
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/xichan96/cortex-lab/internal/appdto"
+	"github.com/xichan96/cortex-lab/internal/infra/model"
+	"github.com/xichan96/cortex-lab/internal/pkg/agentrun"
+	"github.com/xichan96/cortex-lab/internal/pkg/errcode"
+	"github.com/xichan96/cortex-lab/pkg/sql"
+	"github.com/xichan96/cortex-lab/pkg/std/snowflake"
+	"github.com/xichan96/cortex-lab/pkg/web/cctx"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultDelegationDepth   = 3
+	defaultDelegationTimeout = 300 * time.Second
+	// maxDeliveryWait caps the wait for the run of the calling session to end
+	// before the reply of an async call is delivered to it
+	maxDeliveryWait  = 10 * time.Minute
+	deliveryInterval = time.Second
+)
+
+// delegation is the call chain of a run started by another role. It travels
+// in the context of the run so the notify_role calls it makes see it.
+type delegation struct {
+	chain    []string // role IDs, the first one started the call tree
+	deadline time.Time
+	// parentSessionID is the session of the caller, the session created for
+	// the called role is linked to it
+	parentSessionID string
+}
+
+type delegationKey struct{}
+
+func delegationFrom(ctx context.Context) *delegation {
+	d, _ := ctx.Value(delegationKey{}).(*delegation)
+	return d
+}
+
+func withDelegation(ctx context.Context, d *delegation) context.Context {
+	return context.WithValue(ctx, delegationKey{}, d)
+}
+
+// delegate checks a call from roleID in sessionID to targetID and returns the
+// delegation of the called run. The first call of a tree sets the deadline of the tree.
+func (a *app) delegate(ctx context.Context, sessionID, roleID, targetID string) (*delegation, error) {
+	setting, err := a.settingSrv.GetDelegationSetting(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get delegation setting: %w", err)
+	}
+	maxDepth, timeout := defaultDelegationDepth, defaultDelegationTimeout
+	if cfg := setting.DelegationConfig; cfg != nil {
+		if cfg.MaxDepth > 0 {
+			maxDepth = cfg.MaxDepth
+		}
+		if cfg.TimeoutSeconds > 0 {
+			timeout = time.Duration(cfg.TimeoutSeconds) * time.Second
+		}
+	}
+
+	parent := delegationFrom(ctx)
+	if parent == nil {
+		parent = &delegation{chain: []string{roleID}, deadline: time.Now().Add(timeout)}
+	}
+	if slices.Contains(parent.chain, targetID) {
+		return nil, errcode.DelegationCycle
+	}
+	if len(parent.chain) > maxDepth {
+		return nil, errcode.DelegationTooDeep
+	}
+	if !time.Now().Before(parent.deadline) {
+		return nil, errcode.DelegationTimeout
+	}
+	return &delegation{
+		chain:           append(slices.Clone(parent.chain), targetID),
+		deadline:        parent.deadline,
+		parentSessionID: sessionID,
+	}, nil
+}
+
+// callRole sends req to targetID within the call tree of d and returns the
+// session of the called role and its last reply
+func (a *app) callRole(ctx context.Context, targetID string, d *delegation, req *appdto.SendChatMessageReq) (string, string, error) {
+	ctx, cancel := context.WithDeadline(withDelegation(ctx, d), d.deadline)
+	defer cancel()
+	sessionID, messages, err := a.SendMessage(ctx, targetID, "", "", "", req)
+	if err != nil {
+		return sessionID, "", err
+	}
+	if len(messages) == 0 {
+		return sessionID, "", nil
+	}
+	return sessionID, messages[len(messages)-1].Content, nil
+}
+
+// callRoleAsync calls targetID in the background and delivers its reply to
+// the session of the caller once it is ready
+func (a *app) callRoleAsync(userID, callerSessionID, targetID string, d *delegation, req *appdto.SendChatMessageReq) {
+	ctx := cctx.WithContext(context.Background())
+	cctx.SetUserID(ctx, userID)
+
+	result := &model.DelegationResult{RoleID: targetID}
+	if role, err := a.roleApp.GetRole(ctx, targetID); err == nil {
+		result.RoleName = role.Name
+	}
+	sessionID, reply, err := a.callRole(ctx, targetID, d, req)
+	result.SessionID = sessionID
+	if err != nil {
+		result.Error = err.Error()
+	}
+	if err := a.deliver(ctx, callerSessionID, reply, result); err != nil {
+		slog.Error("Failed to deliver role reply", "error", err, "session_id", callerSessionID, "role_id", targetID)
+	}
+}
+
+// deliver appends the reply of an async call to the active branch of the
+// calling session. It waits for the run in flight of the session to end.
+func (a *app) deliver(ctx context.Context, sessionID, reply string, result *model.DelegationResult) error {
+	userID := cctx.GetUserID[string](ctx)
+	var run *agentrun.Run
+	for waited := time.Duration(0); ; waited += deliveryInterval {
+		var ok bool
+		if run, ok = agentrun.Default.Start(sessionID, userID); ok {
+			break
+		}
+		if waited >= maxDeliveryWait {
+			return errcode.ChatSessionBusy
+		}
+		time.Sleep(deliveryInterval)
+	}
+	defer agentrun.Default.Finish(run)
+
+	session, err := a.sp.GetByID(ctx, sessionID)
+	if err != nil {
+		return err
+	}
+	leaf, err := currentLeaf(ctx, a.mp, a.sp, session)
+	if err != nil {
+		return err
+	}
+	// the run of the caller has ended, its reply is the message that made the call
+	if result.SessionID != "" {
+		if err := a.sp.UpdateFields(ctx, map[string]interface{}{"parent_message_id": leaf}, func(db *gorm.DB) *gorm.DB {
+			return db.Where("id = ? AND parent_message_id IS NULL", result.SessionID)
+		}); err != nil {
+			return err
+		}
+	}
+	name := result.RoleName
+	if name == "" {
+		name = result.RoleID
+	}
+	msg := &model.ChatMessage{
+		ID:        snowflake.NewUUID(),
+		SessionID: sessionID,
+		ParentID:  strPtr(leaf),
+		Role:      "assistant",
+		Content:   fmt.Sprintf("Reply from role %s:\n\n%s", name, reply),
+		Meta:      &model.MessageMeta{Delegation: result},
+	}
+	if result.Error != "" {
+		msg.Content = fmt.Sprintf("Role %s could not reply: %s", name, result.Error)
+		msg.Meta.Error = &result.Error
+	}
+	return sql.NewSqlTX().Execute(ctx, func(ctx context.Context) error {
+		if _, err := a.mp.Create(ctx, msg); err != nil {
+			return err
+		}
+		now := time.Now()
+		return a.sp.Update(ctx, &model.ChatSession{ID: sessionID, CurrentMessageID: &msg.ID, LastActiveAt: &now})
+	})
+}
+
+// linkCalls links the sessions created by the calls a run made to the reply
+// of the run
+func (a *app) linkCalls(ctx context.Context, sessionID string, msg *model.ChatMessage) {
+	if msg.Meta == nil || !slices.ContainsFunc(msg.Meta.ToolCalls, func(call model.ToolCallTrace) bool {
+		return call.Name == "notify_role" || strings.HasPrefix(call.Name, teamToolPrefix)
+	}) {
+		return
+	}
+	err := a.sp.UpdateFields(ctx, map[string]interface{}{"parent_message_id": msg.ID}, func(db *gorm.DB) *gorm.DB {
+		return db.Where("parent_session_id = ? AND parent_message_id IS NULL", sessionID)
+	})
+	if err != nil {
+		slog.Error("Failed to link called sessions", "error", err, "session_id", sessionID, "message_id", msg.ID)
+	}
+}
+
+// maxCallTreeDepth caps the levels of a call tree that are loaded
+const maxCallTreeDepth = 20
+
+// GetCallTree returns the call tree of the conversation a session of the
+// current user belongs to, starting from the session that started it
+func (a *app) GetCallTree(ctx context.Context, sessionID string) (*appdto.ChatCallNode, error) {
+	session, err := a.ownedSession(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	for i := 0; session.ParentSessionID != nil && i < maxCallTreeDepth; i++ {
+		parent, err := a.ownedSession(ctx, *session.ParentSessionID)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			// the caller was deleted, the tree starts here
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		session = parent
+	}
+
+	root, err := a.callNode(ctx, session, false)
+	if err != nil {
+		return nil, err
+	}
+	userID := cctx.GetUserID[string](ctx)
+	level := []*appdto.ChatCallNode{root}
+	for depth := 0; len(level) > 0 && depth < maxCallTreeDepth; depth++ {
+		byID := make(map[string]*appdto.ChatCallNode, len(level))
+		ids := make([]string, len(level))
+		for i, node := range level {
+			byID[node.SessionID] = node
+			ids[i] = node.SessionID
+		}
+		children, err := a.sp.GetList(ctx, func(db *gorm.DB) *gorm.DB {
+			return db.Where("user_id = ? AND parent_session_id IN ?", userID, ids).Order("created_at ASC")
+		})
+		if err != nil {
+			return nil, err
+		}
+		level = level[:0:0]
+		for _, child := range children {
+			node, err := a.callNode(ctx, child, true)
+			if err != nil {
+				return nil, err
+			}
+			parent := byID[*child.ParentSessionID]
+			parent.Children = append(parent.Children, node)
+			level = append(level, node)
+		}
+	}
+	return root, nil
+}
+
+// callNode summarizes the active branch of a session of a call tree
+func (a *app) callNode(ctx context.Context, session *model.ChatSession, called bool) (*appdto.ChatCallNode, error) {
+	leaf, err := currentLeaf(ctx, a.mp, a.sp, session)
+	if err != nil {
+		return nil, err
+	}
+	path, err := activeMessages(ctx, a.mp, session.ID, leaf)
+	if err != nil {
+		return nil, err
+	}
+	node := &appdto.ChatCallNode{
+		SessionID:       session.ID,
+		ParentMessageID: session.ParentMessageID,
+		RoleID:          session.RoleID,
+		RoleName:        session.RoleName,
+		Provider:        session.Provider,
+		ModelName:       session.ModelName,
+		Title:           session.Title,
+		CreatedAt:       session.CreatedAt,
+		Children:        []*appdto.ChatCallNode{},
+	}
+	var reply *model.ChatMessage
+	for _, m := range path {
+		switch {
+		case m.Role == "user" && node.Input == "":
+			node.Input = m.Content
+		case m.Role == "assistant":
+			reply = m
+		}
+	}
+	if reply == nil {
+		return node, nil
+	}
+	node.Output = reply.Content
+	if reply.Meta != nil && reply.Meta.Error != nil {
+		node.Error = *reply.Meta.Error
+	}
+	if called {
+		node.LatencyMs = reply.CreatedAt.Sub(session.CreatedAt).Milliseconds()
+	}
+	return node, nil
+}
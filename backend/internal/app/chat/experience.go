@@ -5,8 +5,10 @@ import (
 	"encoding/json"
 	"fmt"
 
+	"github.com/xichan96/cortex-lab/internal/app/event"
 	"github.com/xichan96/cortex-lab/internal/app/experience"
 	"github.com/xichan96/cortex-lab/internal/appdto"
+	"github.com/xichan96/cortex-lab/internal/infra/model"
 	"github.com/xichan96/cortex/agent/types"
 )
 
@@ -21,9 +23,10 @@ type BaseExperienceTool struct {
 // CreateExperienceTool create experience tool
 type CreateExperienceTool struct {
 	BaseExperienceTool
+	events event.AppIer
 }
 
-func NewCreateExperienceTool(ctx context.Context, userID, roleID string, app experience.AppIer) *CreateExperienceTool {
+func NewCreateExperienceTool(ctx context.Context, userID, roleID string, app experience.AppIer, events event.AppIer) *CreateExperienceTool {
 	return &CreateExperienceTool{
 		BaseExperienceTool: BaseExperienceTool{
 			ctx:    ctx,
@@ -31,6 +34,7 @@ func NewCreateExperienceTool(ctx context.Context, userID, roleID string, app exp
 			roleID: roleID,
 			app:    app,
 		},
+		events: events,
 	}
 }
 
@@ -88,6 +92,12 @@ func (t *CreateExperienceTool) Execute(input map[string]interface{}) (interface{
 	if err != nil {
 		return nil, err
 	}
+	t.events.Publish(t.ctx, t.userID, model.EventExperienceCreated, map[string]interface{}{
+		"experience_id": id,
+		"role_id":       t.roleID,
+		"type":          req.Type,
+		"title":         req.Title,
+	})
 	return map[string]string{"id": id, "status": "success"}, nil
 }
 
@@ -221,15 +231,18 @@ func (t *GetExperienceTool) Metadata() types.ToolMetadata {
 // UpdateExperienceTool update experience tool
 type UpdateExperienceTool struct {
 	BaseExperienceTool
+	events event.AppIer
 }
 
-func NewUpdateExperienceTool(ctx context.Context, userID string, app experience.AppIer) *UpdateExperienceTool {
+func NewUpdateExperienceTool(ctx context.Context, userID, roleID string, app experience.AppIer, events event.AppIer) *UpdateExperienceTool {
 	return &UpdateExperienceTool{
 		BaseExperienceTool: BaseExperienceTool{
 			ctx:    ctx,
 			userID: userID,
+			roleID: roleID,
 			app:    app,
 		},
+		events: events,
 	}
 }
 
@@ -280,6 +293,11 @@ func (t *UpdateExperienceTool) Execute(input map[string]interface{}) (interface{
 	if err != nil {
 		return nil, err
 	}
+	t.events.Publish(t.ctx, t.userID, model.EventExperienceUpdated, map[string]interface{}{
+		"experience_id": req.ID,
+		"role_id":       t.roleID,
+		"title":         req.Title,
+	})
 	return map[string]string{"status": "success"}, nil
 }
 
@@ -0,0 +1,295 @@
+package chat
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jinzhu/copier"
+	"github.com/xichan96/cortex-lab/internal/appdto"
+	"github.com/xichan96/cortex-lab/internal/infra/model"
+	"github.com/xichan96/cortex-lab/internal/pkg/errcode"
+	"github.com/xichan96/cortex-lab/pkg/sql"
+	"github.com/xichan96/cortex-lab/pkg/std/snowflake"
+	"github.com/xichan96/cortex-lab/pkg/web/cctx"
+	"gorm.io/gorm"
+)
+
+// chatBundleVersion is the version of the JSON export format
+const chatBundleVersion = 1
+
+// maxTranscriptObservation caps the tool results shown in a Markdown transcript
+const maxTranscriptObservation = 2000
+
+// ownedSession loads a session of the current user
+func (a *app) ownedSession(ctx context.Context, id string) (*model.ChatSession, error) {
+	session, err := a.sp.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if session.UserID != cctx.GetUserID[string](ctx) {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return session, nil
+}
+
+// ExportSession exports a session of the current user with every message of every branch
+func (a *app) ExportSession(ctx context.Context, id string) (*appdto.ChatSessionBundle, error) {
+	bundle, _, err := a.exportSession(ctx, id)
+	return bundle, err
+}
+
+// ExportSessionMarkdown renders the active branch of a session as a Markdown transcript
+func (a *app) ExportSessionMarkdown(ctx context.Context, id string) (string, error) {
+	bundle, messages, err := a.exportSession(ctx, id)
+	if err != nil {
+		return "", err
+	}
+	path := activePath(messages, derefStr(bundle.Session.CurrentMessageID))
+	return renderTranscript(bundle, path), nil
+}
+
+func (a *app) exportSession(ctx context.Context, id string) (*appdto.ChatSessionBundle, []*model.ChatMessage, error) {
+	session, err := a.ownedSession(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	if _, err := currentLeaf(ctx, a.mp, a.sp, session); err != nil {
+		return nil, nil, err
+	}
+	messages, err := sessionMessages(ctx, a.mp, id)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	bundle := &appdto.ChatSessionBundle{
+		Version:    chatBundleVersion,
+		ExportedAt: time.Now(),
+		Session:    &appdto.ChatSession{},
+		Role:       &appdto.ChatRoleSnapshot{ID: session.RoleID, Name: session.RoleName},
+		Messages:   toMessageDTOs(messages),
+	}
+	_ = copier.Copy(bundle.Session, session)
+	// an export is passed around, it must not carry the share link
+	bundle.Session.ShareToken, bundle.Session.ShareExpiresAt = nil, nil
+	// the role may have been deleted since, the session keeps its name
+	if role, err := a.roleApp.GetRole(ctx, session.RoleID); err == nil {
+		bundle.Role = &appdto.ChatRoleSnapshot{
+			ID:          role.ID,
+			Name:        role.Name,
+			Description: role.Description,
+			Prompt:      role.Prompt,
+			Principle:   role.Principle,
+			Tools:       role.Tools,
+		}
+	}
+	return bundle, messages, nil
+}
+
+func renderTranscript(bundle *appdto.ChatSessionBundle, path []*model.ChatMessage) string {
+	session := bundle.Session
+	title := derefStr(session.Title)
+	if title == "" {
+		title = "Chat with " + session.RoleName
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "# %s\n\n", title)
+	fmt.Fprintf(&sb, "- Role: %s\n", bundle.Role.Name)
+	fmt.Fprintf(&sb, "- Model: %s / %s\n", session.Provider, session.ModelName)
+	fmt.Fprintf(&sb, "- Created: %s\n", session.CreatedAt.Format(time.DateTime))
+	fmt.Fprintf(&sb, "- Exported: %s\n", bundle.ExportedAt.Format(time.DateTime))
+	fmt.Fprintf(&sb, "- Session ID: %s\n", session.ID)
+	if bundle.Role.Prompt != "" {
+		sb.WriteString("\n<details><summary>Role prompt</summary>\n\n")
+		sb.WriteString(bundle.Role.Prompt)
+		if bundle.Role.Principle != "" {
+			sb.WriteString("\n\nPrinciple: " + bundle.Role.Principle)
+		}
+		sb.WriteString("\n\n</details>\n")
+	}
+
+	for _, m := range path {
+		name := m.Role
+		if name != "" {
+			name = strings.ToUpper(name[:1]) + name[1:]
+		}
+		fmt.Fprintf(&sb, "\n---\n\n### %s · %s\n\n", name, m.CreatedAt.Format(time.DateTime))
+		meta := m.Meta
+		if meta != nil && meta.Summary != nil {
+			fmt.Fprintf(&sb, "> Summary of the %d messages up to here: %s\n\n", meta.Summary.MessageCount, strings.ReplaceAll(meta.Summary.Content, "\n", "\n> "))
+		}
+		if meta != nil && len(meta.ToolCalls) > 0 {
+			fmt.Fprintf(&sb, "<details><summary>Tool calls (%d)</summary>\n\n", len(meta.ToolCalls))
+			for _, call := range meta.ToolCalls {
+				args, _ := json.Marshal(call.Arguments)
+				fmt.Fprintf(&sb, "- `%s` %s (%d ms)\n", call.Name, args, call.DurationMs)
+				if call.Error != "" {
+					fmt.Fprintf(&sb, "  - Error: %s\n", call.Error)
+				} else if call.Observation != "" {
+					fmt.Fprintf(&sb, "\n  ```\n  %s\n  ```\n", strings.ReplaceAll(clip(call.Observation, maxTranscriptObservation), "\n", "\n  "))
+				}
+			}
+			sb.WriteString("\n</details>\n\n")
+		}
+		sb.WriteString(strings.TrimSpace(m.Content))
+		sb.WriteString("\n")
+		if meta == nil {
+			continue
+		}
+		if len(meta.Attachments) > 0 {
+			names := make([]string, len(meta.Attachments))
+			for i, att := range meta.Attachments {
+				names[i] = att.FileName
+			}
+			fmt.Fprintf(&sb, "\n_Attachments: %s_\n", strings.Join(names, ", "))
+		}
+		if meta.Error != nil {
+			fmt.Fprintf(&sb, "\n_Error: %s_\n", *meta.Error)
+		}
+		if meta.Cancelled {
+			sb.WriteString("\n_Cancelled by the user_\n")
+		}
+		if meta.Usage != nil {
+			fmt.Fprintf(&sb, "\n_Tokens: %d prompt, %d completion_\n", meta.Usage.PromptTokens, meta.Usage.CompletionTokens)
+		}
+	}
+	return sb.String()
+}
+
+// ImportSession creates a session of the current user from a JSON export.
+// Messages get new IDs and keep their branches and timestamps. Attachments
+// are only kept when the current user owns them, the files of other users
+// are not shared by an export.
+func (a *app) ImportSession(ctx context.Context, bundle *appdto.ChatSessionBundle) (string, error) {
+	if bundle == nil || bundle.Session == nil || bundle.Version > chatBundleVersion {
+		return "", errcode.ChatBundleInvalid
+	}
+	src := bundle.Session
+	if src.RoleID == "" || src.Provider == "" || src.ModelName == "" || !(*model.ModelParams)(src.ModelParams).Valid() {
+		return "", errcode.ChatBundleInvalid
+	}
+
+	ids := make(map[string]string, len(bundle.Messages))
+	var attachmentIDs []string
+	metas := make([]*model.MessageMeta, len(bundle.Messages))
+	for i, m := range bundle.Messages {
+		if m == nil || m.ID == "" {
+			return "", errcode.ChatBundleInvalid
+		}
+		ids[m.ID] = snowflake.NewUUID()
+		if m.Meta == nil {
+			continue
+		}
+		data, err := json.Marshal(m.Meta)
+		if err != nil {
+			return "", errcode.ChatBundleInvalid
+		}
+		meta := &model.MessageMeta{}
+		if err := json.Unmarshal(data, meta); err != nil {
+			return "", errcode.ChatBundleInvalid
+		}
+		for _, att := range meta.Attachments {
+			attachmentIDs = append(attachmentIDs, att.ID)
+		}
+		metas[i] = meta
+	}
+	owned, err := a.ownedAttachments(ctx, attachmentIDs)
+	if err != nil {
+		return "", err
+	}
+
+	session := &model.ChatSession{
+		ID:          snowflake.NewUUID(),
+		UserID:      cctx.GetUserID[string](ctx),
+		RoleID:      src.RoleID,
+		RoleName:    src.RoleName,
+		Provider:    src.Provider,
+		ModelName:   src.ModelName,
+		Title:       src.Title,
+		TitleSource: src.TitleSource,
+		ModelParams: (*model.ModelParams)(src.ModelParams),
+	}
+	switch {
+	case src.Title == nil:
+		session.TitleSource = model.ChatTitleSourceAuto
+	case session.TitleSource == "":
+		session.TitleSource = model.ChatTitleSourceManual
+	}
+	messages := make([]*model.ChatMessage, len(bundle.Messages))
+	for i, m := range bundle.Messages {
+		msg := &model.ChatMessage{
+			ID:        ids[m.ID],
+			SessionID: session.ID,
+			Role:      m.Role,
+			Content:   m.Content,
+			Meta:      metas[i],
+			CreatedAt: m.CreatedAt,
+			UpdatedAt: m.UpdatedAt,
+		}
+		if parentID, ok := ids[derefStr(m.ParentID)]; ok {
+			msg.ParentID = &parentID
+		}
+		if msg.Meta != nil && len(msg.Meta.Attachments) > 0 {
+			kept := msg.Meta.Attachments[:0]
+			for _, att := range msg.Meta.Attachments {
+				if owned[att.ID] {
+					kept = append(kept, att)
+				}
+			}
+			msg.Meta.Attachments = kept
+		}
+		messages[i] = msg
+	}
+	// the imported session keeps its place in the history
+	var lastActiveAt time.Time
+	for _, m := range messages {
+		if m.CreatedAt.After(lastActiveAt) {
+			lastActiveAt = m.CreatedAt
+		}
+	}
+	if lastActiveAt.IsZero() {
+		lastActiveAt = time.Now()
+	}
+	session.LastActiveAt = &lastActiveAt
+	if leaf, ok := ids[derefStr(src.CurrentMessageID)]; ok {
+		session.CurrentMessageID = &leaf
+	} else if len(messages) > 0 {
+		session.CurrentMessageID = &messages[len(messages)-1].ID
+	}
+
+	err = sql.NewSqlTX().Execute(ctx, func(ctx context.Context) error {
+		if _, err := a.sp.Create(ctx, session); err != nil {
+			return err
+		}
+		if len(messages) == 0 {
+			return nil
+		}
+		return a.mp.CreateBatch(ctx, messages)
+	})
+	if err != nil {
+		return "", err
+	}
+	return session.ID, nil
+}
+
+// ownedAttachments tells which of ids were uploaded by the current user
+func (a *app) ownedAttachments(ctx context.Context, ids []string) (map[string]bool, error) {
+	owned := make(map[string]bool, len(ids))
+	if len(ids) == 0 {
+		return owned, nil
+	}
+	userID := cctx.GetUserID[string](ctx)
+	attachments, err := a.ap.GetList(ctx, func(db *gorm.DB) *gorm.DB {
+		return db.Where("id IN ? AND user_id = ?", ids, userID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, att := range attachments {
+		owned[att.ID] = true
+	}
+	return owned, nil
+}
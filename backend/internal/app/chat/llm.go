@@ -4,12 +4,19 @@ import (
 	"context"
 	"fmt"
 
+	"github.com/tmc/langchaingo/llms"
+	"github.com/tmc/langchaingo/llms/openai"
 	"github.com/xichan96/cortex-lab/internal/appdto"
-	"github.com/xichan96/cortex/agent/llm"
+	"github.com/xichan96/cortex-lab/internal/pkg/errcode"
+	"github.com/xichan96/cortex/agent/providers"
 	"github.com/xichan96/cortex/agent/types"
+	"github.com/xichan96/cortex/pkg/errors"
 )
 
-func (a *app) setupLLM(provider, modelName string) (types.LLMProvider, error) {
+// setupLLM builds the LLM provider of a run. All supported providers speak the
+// OpenAI protocol, so the langchaingo client is built here directly and wrapped
+// to report token usage to the run trace.
+func (a *app) setupLLM(provider, modelName string, trace *runTrace) (types.LLMProvider, error) {
 	chatLLMSetting, err := a.settingSrv.GetChatLLMSetting(context.Background())
 	if err != nil {
 		return nil, fmt.Errorf("failed to get Chat LLM setting: %w", err)
@@ -22,77 +29,250 @@ func (a *app) setupLLM(provider, modelName string) (types.LLMProvider, error) {
 
 	switch provider {
 	case "openai":
-		return a.initOpenAI(cfg, modelName)
+		return a.initOpenAI(cfg, modelName, trace)
 	case "deepseek":
-		return a.initDeepSeek(cfg, modelName)
+		return a.initDeepSeek(cfg, modelName, trace)
 	case "volce":
-		return a.initVolce(cfg, modelName)
+		return a.initVolce(cfg, modelName, trace)
 	default:
 		return nil, fmt.Errorf("unsupported LLM provider: %s", provider)
 	}
 }
 
-func (a *app) initOpenAI(cfg *appdto.ChatLLMConfig, modelName string) (types.LLMProvider, error) {
+func (a *app) initOpenAI(cfg *appdto.ChatLLMConfig, modelName string, trace *runTrace) (types.LLMProvider, error) {
 	if modelName == "" && len(cfg.OpenAI.Models) > 0 {
 		modelName = cfg.OpenAI.Models[0]
 	}
 	if modelName == "" {
 		modelName = "gpt-4o"
 	}
-
-	opts := llm.OpenAIOptions{
-		APIKey:  cfg.OpenAI.APIKey,
-		BaseURL: cfg.OpenAI.BaseURL,
-		Model:   modelName,
-		OrgID:   cfg.OpenAI.OrgID,
-		APIType: cfg.OpenAI.APIType,
+	baseURL := cfg.OpenAI.BaseURL
+	if baseURL == "" {
+		baseURL = "https://api.openai.com"
 	}
 
-	provider, err := llm.NewOpenAIClient(opts)
+	provider, err := newOpenAICompatible(cfg.OpenAI.APIKey, baseURL, modelName, trace, openai.WithOrganization(cfg.OpenAI.OrgID))
 	if err != nil {
 		return nil, fmt.Errorf("failed to initialize OpenAI client: %w", err)
 	}
 	return provider, nil
 }
 
-func (a *app) initDeepSeek(cfg *appdto.ChatLLMConfig, modelName string) (types.LLMProvider, error) {
+func (a *app) initDeepSeek(cfg *appdto.ChatLLMConfig, modelName string, trace *runTrace) (types.LLMProvider, error) {
 	if modelName == "" && len(cfg.DeepSeek.Models) > 0 {
 		modelName = cfg.DeepSeek.Models[0]
 	}
 	if modelName == "" {
 		modelName = "deepseek-chat"
 	}
-
-	opts := llm.DeepSeekOptions{
-		APIKey:  cfg.DeepSeek.APIKey,
-		BaseURL: cfg.DeepSeek.BaseURL,
-		Model:   modelName,
+	baseURL := cfg.DeepSeek.BaseURL
+	if baseURL == "" {
+		baseURL = "https://api.deepseek.com"
 	}
 
-	provider, err := llm.NewDeepSeekClient(opts)
+	provider, err := newOpenAICompatible(cfg.DeepSeek.APIKey, baseURL, modelName, trace)
 	if err != nil {
 		return nil, fmt.Errorf("failed to initialize DeepSeek client: %w", err)
 	}
 	return provider, nil
 }
 
-func (a *app) initVolce(cfg *appdto.ChatLLMConfig, modelName string) (types.LLMProvider, error) {
+func (a *app) initVolce(cfg *appdto.ChatLLMConfig, modelName string, trace *runTrace) (types.LLMProvider, error) {
 	if modelName == "" && len(cfg.Volce.Models) > 0 {
 		modelName = cfg.Volce.Models[0]
 	}
 	if modelName == "" {
 		modelName = "volce-chat"
 	}
-
-	opts := llm.VolceOptions{
-		APIKey:  cfg.Volce.APIKey,
-		BaseURL: cfg.Volce.BaseURL,
-		Model:   modelName,
+	baseURL := cfg.Volce.BaseURL
+	if baseURL == "" {
+		baseURL = "https://ark.cn-beijing.volces.com/api/v3"
 	}
 
-	provider, err := llm.NewVolceClient(opts)
+	provider, err := newOpenAICompatible(cfg.Volce.APIKey, baseURL, modelName, trace)
 	if err != nil {
 		return nil, fmt.Errorf("failed to initialize Volce client: %w", err)
 	}
 	return provider, nil
 }
+
+func newOpenAICompatible(apiKey, baseURL, modelName string, trace *runTrace, extra ...openai.Option) (types.LLMProvider, error) {
+	if apiKey == "" {
+		return nil, errors.EC_LLM_API_KEY_REQUIRED
+	}
+	opts := []openai.Option{
+		openai.WithToken(apiKey),
+		openai.WithBaseURL(baseURL),
+		openai.WithModel(modelName),
+		openai.WithHTTPClient(providers.GetPooledHTTPClient()),
+	}
+	opts = append(opts, extra...)
+
+	client, err := openai.New(opts...)
+	if err != nil {
+		return nil, errors.NewError(errors.EC_LLM_CLIENT_CREATE_FAILED.Code, errors.EC_LLM_CLIENT_CREATE_FAILED.Message).Wrap(err)
+	}
+	return providers.NewLangChainLLMProvider(&meteredModel{Model: client, trace: trace}, modelName), nil
+}
+
+// meteredModel reports the token usage of every completion to the run trace.
+// Usage is taken from the provider response and estimated locally when the
+// provider does not return it. Calls are bound to the run context so they are
+// aborted when the run is cancelled, and streamed text is kept as the partial
+// reply of the run. The engine only takes the run input as text, so the
+// attachments of the input are added to its message here.
+type meteredModel struct {
+	llms.Model
+	trace *runTrace
+}
+
+func (m *meteredModel) GenerateContent(ctx context.Context, messages []llms.MessageContent, options ...llms.CallOption) (*llms.ContentResponse, error) {
+	if m.trace != nil {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithCancel(ctx)
+		defer cancel()
+		stop := context.AfterFunc(m.trace.context(), cancel)
+		defer stop()
+
+		var opts llms.CallOptions
+		for _, opt := range options {
+			opt(&opts)
+		}
+		if streaming := opts.StreamingFunc; streaming != nil {
+			options = append(options, llms.WithStreamingFunc(func(ctx context.Context, chunk []byte) error {
+				m.trace.appendPartial(string(chunk))
+				return streaming(ctx, chunk)
+			}))
+		}
+		messages = m.attachInput(messages)
+	}
+
+	resp, err := m.Model.GenerateContent(ctx, messages, options...)
+	if err != nil || resp == nil || m.trace == nil {
+		return resp, err
+	}
+
+	var prompt, completion int
+	for _, choice := range resp.Choices {
+		prompt += infoInt(choice.GenerationInfo, "PromptTokens")
+		completion += infoInt(choice.GenerationInfo, "CompletionTokens")
+	}
+	if prompt > 0 || completion > 0 {
+		m.trace.addUsage(prompt, completion, false)
+		return resp, nil
+	}
+
+	for _, msg := range messages {
+		for _, part := range msg.Parts {
+			if text, ok := part.(llms.TextContent); ok {
+				prompt += estimateTokens(text.Text)
+			}
+		}
+	}
+	for _, choice := range resp.Choices {
+		completion += estimateTokens(choice.Content)
+		for _, tc := range choice.ToolCalls {
+			if tc.FunctionCall != nil {
+				completion += estimateTokens(tc.FunctionCall.Name + tc.FunctionCall.Arguments)
+			}
+		}
+	}
+	m.trace.addUsage(prompt, completion, true)
+	return resp, nil
+}
+
+// attachInput appends the attachment parts of the run input to the last user
+// message when it is the input. Other calls, such as memory summaries, are left as is.
+func (m *meteredModel) attachInput(messages []llms.MessageContent) []llms.MessageContent {
+	input, parts := m.trace.inputAttachments()
+	if len(parts) == 0 {
+		return messages
+	}
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role != llms.ChatMessageTypeHuman {
+			continue
+		}
+		if len(messages[i].Parts) != 1 {
+			return messages
+		}
+		if text, ok := messages[i].Parts[0].(llms.TextContent); !ok || text.Text != input {
+			return messages
+		}
+		out := make([]llms.MessageContent, len(messages))
+		copy(out, messages)
+		out[i] = llms.MessageContent{
+			Role:  messages[i].Role,
+			Parts: append([]llms.ContentPart{messages[i].Parts[0]}, toContentParts(parts)...),
+		}
+		return out
+	}
+	return messages
+}
+
+func (m *meteredModel) Call(ctx context.Context, prompt string, options ...llms.CallOption) (string, error) {
+	return llms.GenerateFromSinglePrompt(ctx, m, prompt, options...)
+}
+
+func infoInt(info map[string]any, key string) int {
+	switch v := info[key].(type) {
+	case int:
+		return v
+	case int64:
+		return int(v)
+	case float64:
+		return int(v)
+	}
+	return 0
+}
+
+// resolveModel chooses the model of a call that does not give one: the model
+// of the session, then the preferred model of the role or the first of its
+// fallbacks configured in the chat LLM settings, then the first configured model.
+func (a *app) resolveModel(ctx context.Context, roleID, sessionID, provider, modelName string) (string, string, error) {
+	if provider != "" && modelName != "" {
+		return provider, modelName, nil
+	}
+	if provider == "" && sessionID != "" {
+		session, err := a.sp.GetByID(ctx, sessionID)
+		if err != nil {
+			return "", "", err
+		}
+		return session.Provider, session.ModelName, nil
+	}
+	chatLLMSetting, err := a.settingSrv.GetChatLLMSetting(ctx)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to get Chat LLM setting: %w", err)
+	}
+	var cfg *appdto.ChatLLMConfig
+	if chatLLMSetting != nil {
+		cfg = chatLLMSetting.ChatLLMConfig
+	}
+	if provider != "" {
+		if models := cfg.Models(provider); len(models) > 0 {
+			return provider, models[0], nil
+		}
+		return "", "", errcode.ChatModelUnavailable
+	}
+
+	role, err := a.roleApp.GetRole(ctx, roleID)
+	if err != nil {
+		return "", "", err
+	}
+	var candidates []appdto.RoleModel
+	if role.Provider != "" {
+		candidates = append(candidates, appdto.RoleModel{Provider: role.Provider, Model: role.ModelName})
+	}
+	candidates = append(candidates, role.FallbackModels...)
+	for _, m := range candidates {
+		if cfg.HasModel(m.Provider, m.Model) {
+			return m.Provider, m.Model, nil
+		}
+	}
+	if len(candidates) > 0 {
+		return "", "", errcode.RoleModelNotConfigured
+	}
+	if provider, modelName = cfg.FirstModel(); provider == "" {
+		return "", "", errcode.ChatModelUnavailable
+	}
+	return provider, modelName, nil
+}
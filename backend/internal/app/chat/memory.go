@@ -2,8 +2,11 @@ package chat
 
 import (
 	"context"
+	"fmt"
 	"log/slog"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/xichan96/cortex-lab/internal/config"
 	"github.com/xichan96/cortex-lab/internal/infra/model"
@@ -12,24 +15,59 @@ import (
 	"gorm.io/gorm"
 )
 
+// defaultCompressRatio is the share of the history summarized when no ratio is configured
+const defaultCompressRatio = 0.5
+
 type DatabaseMemoryProvider struct {
-	mp         persist.ChatMessagePersistIer
-	sessionID  string
-	maxHistory int
-	mu         sync.RWMutex
+	mp        persist.ChatMessagePersistIer
+	sp        persist.ChatSessionPersistIer
+	sessionID string
+	opts      MemoryOptions
+	trace     *runTrace
+	branch    *branchPoint
+	mu        sync.RWMutex
 }
 
-func NewDatabaseMemoryProvider(mp persist.ChatMessagePersistIer, sessionID string, maxHistory int) types.MemoryProvider {
-	if maxHistory <= 0 {
-		maxHistory = 100
+// NewDatabaseMemoryProvider creates a memory provider for a session. The run is
+// attached to branch, or continues the active branch of the session when nil.
+func NewDatabaseMemoryProvider(mp persist.ChatMessagePersistIer, sp persist.ChatSessionPersistIer, sessionID string, opts MemoryOptions, trace *runTrace, branch *branchPoint) types.MemoryProvider {
+	if opts.MaxHistory <= 0 {
+		opts.MaxHistory = 100
+	}
+	if opts.CompressRatio <= 0 || opts.CompressRatio >= 1 {
+		opts.CompressRatio = defaultCompressRatio
+	}
+	if trace == nil {
+		trace = newRunTrace()
 	}
 	return &DatabaseMemoryProvider{
-		mp:         mp,
-		sessionID:  sessionID,
-		maxHistory: maxHistory,
+		mp:        mp,
+		sp:        sp,
+		sessionID: sessionID,
+		opts:      opts,
+		trace:     trace,
+		branch:    branch,
 	}
 }
 
+func (p *DatabaseMemoryProvider) resolveBranch(ctx context.Context) (*branchPoint, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.branch != nil {
+		return p.branch, nil
+	}
+	session, err := p.sp.GetByID(ctx, p.sessionID)
+	if err != nil {
+		return nil, err
+	}
+	leaf, err := currentLeaf(ctx, p.mp, p.sp, session)
+	if err != nil {
+		return nil, err
+	}
+	p.branch = &branchPoint{parentID: leaf}
+	return p.branch, nil
+}
+
 func (p *DatabaseMemoryProvider) initTable(ctx context.Context) error {
 	if config.Var.DB == nil {
 		return nil
@@ -56,28 +94,54 @@ func (p *DatabaseMemoryProvider) SaveContext(input, output map[string]interface{
 		slog.Error("Failed to init table", "error", err)
 		return err
 	}
-	if inputMsg, ok := input["input"].(string); ok {
+	branch, err := p.resolveBranch(ctx)
+	if err != nil {
+		slog.Error("Failed to resolve branch", "error", err, "session_id", p.sessionID)
+		return err
+	}
+	parentID := branch.parentID
+	if branch.inputID != "" {
+		parentID = branch.inputID
+	} else if inputMsg, ok := input["input"].(string); ok {
 		msg := &model.ChatMessage{
 			SessionID: p.sessionID,
+			ParentID:  strPtr(parentID),
 			Role:      "user",
 			Content:   inputMsg,
+			Meta:      p.trace.inputMeta(),
 		}
 		if _, err := p.mp.Create(ctx, msg); err != nil {
 			slog.Error("Failed to save user message", "error", err, "session_id", p.sessionID)
 			return err
 		}
+		p.trace.addSaved(msg)
+		parentID = msg.ID
 	}
 	if outputMsg, ok := output["output"].(string); ok {
 		msg := &model.ChatMessage{
 			SessionID: p.sessionID,
+			ParentID:  strPtr(parentID),
 			Role:      "assistant",
 			Content:   outputMsg,
+			Meta:      p.trace.assistantMeta(),
 		}
 		if _, err := p.mp.Create(ctx, msg); err != nil {
 			slog.Error("Failed to save assistant message", "error", err, "session_id", p.sessionID)
 			return err
 		}
+		p.trace.addSaved(msg)
+		parentID = msg.ID
 	}
+
+	// the saved turn becomes the tip of the active branch
+	now := time.Now()
+	if err := p.sp.Update(ctx, &model.ChatSession{ID: p.sessionID, CurrentMessageID: strPtr(parentID), LastActiveAt: &now}); err != nil {
+		slog.Error("Failed to update active branch", "error", err, "session_id", p.sessionID)
+		return err
+	}
+	p.mu.Lock()
+	p.branch = &branchPoint{parentID: parentID}
+	p.mu.Unlock()
 	return nil
 }
 
@@ -88,6 +152,15 @@ func (p *DatabaseMemoryProvider) Clear() error {
 		slog.Error("Failed to clear memory", "error", err, "session_id", p.sessionID)
 		return err
 	}
+	session := &model.ChatSession{ID: p.sessionID}
+	if err := p.sp.Update(ctx, session, func(db *gorm.DB) *gorm.DB {
+		return db.Select("current_message_id")
+	}); err != nil {
+		return err
+	}
+	p.mu.Lock()
+	p.branch = &branchPoint{}
+	p.mu.Unlock()
 	return nil
 }
 
@@ -99,46 +172,131 @@ func (p *DatabaseMemoryProvider) GetChatHistory() ([]types.Message, error) {
 		return nil, err
 	}
 
+	branch, err := p.resolveBranch(ctx)
+	if err != nil {
+		slog.Error("Failed to resolve branch", "error", err, "session_id", p.sessionID)
+		return nil, err
+	}
+
 	p.mu.RLock()
-	maxHistory := p.maxHistory
+	opts := p.opts
 	sessionID := p.sessionID
 	p.mu.RUnlock()
 
-	opts := []func(*gorm.DB) *gorm.DB{
-		func(db *gorm.DB) *gorm.DB {
-			return db.Where("session_id = ?", sessionID)
-		},
-		func(db *gorm.DB) *gorm.DB {
-			return db.Order("created_at ASC")
-		},
-	}
-
-	if maxHistory > 0 {
-		opts = append(opts, func(db *gorm.DB) *gorm.DB {
-			return db.Limit(maxHistory)
-		})
-	}
-
-	messages, err := p.mp.GetList(ctx, opts...)
+	// only the active branch is visible to the model
+	path, err := activeMessages(ctx, p.mp, sessionID, branch.parentID)
 	if err != nil {
 		slog.Error("Failed to get chat history", "error", err, "session_id", sessionID)
 		return nil, err
 	}
+	summary, messages := selectHistory(path, opts)
 
-	result := make([]types.Message, len(messages))
-	for i, m := range messages {
-		result[i] = types.Message{
+	result := make([]types.Message, 0, len(messages)+1)
+	if summary != nil {
+		result = append(result, summaryMessage(summary))
+	}
+	for _, m := range messages {
+		msg := types.Message{
 			Role:    m.Role,
 			Content: m.Content,
 		}
+		if m.Meta != nil {
+			msg.Parts = messageParts(m.Content, m.Meta.Attachments, opts.Vision)
+		}
+		result = append(result, msg)
 	}
 
 	return result, nil
 }
 
-// CompressMemory compresses memory (implements MemoryProvider interface)
+// summarizedPath splits path at its latest summary, returning the summary and
+// the messages after it. The raw messages stay in the database for display.
+func summarizedPath(path []*model.ChatMessage) (*model.MemorySummary, []*model.ChatMessage) {
+	for i := len(path) - 1; i >= 0; i-- {
+		if path[i].Meta != nil && path[i].Meta.Summary != nil {
+			return path[i].Meta.Summary, path[i+1:]
+		}
+	}
+	return nil, path
+}
+
+func summaryMessage(summary *model.MemorySummary) types.Message {
+	return types.Message{
+		Role:    "system",
+		Content: "Previous conversation summary: " + summary.Content,
+	}
+}
+
+// CompressMemory summarizes the older turns of the active branch once more
+// than maxMessages are in context (implements MemoryProvider interface). The
+// summary covers the previous summary as well and is stored on the last
+// message it covers, so each branch keeps its own summaries.
 func (p *DatabaseMemoryProvider) CompressMemory(llm types.LLMProvider, maxMessages int) error {
-	// Memory compression is not implemented for database provider
-	// The database already handles history limits through queries
+	if llm == nil {
+		return fmt.Errorf("LLM provider is required for memory compression")
+	}
+	ctx := context.Background()
+	branch, err := p.resolveBranch(ctx)
+	if err != nil {
+		return err
+	}
+	path, err := activeMessages(ctx, p.mp, p.sessionID, branch.parentID)
+	if err != nil {
+		return err
+	}
+	previous, messages := summarizedPath(path)
+	if len(messages) <= maxMessages {
+		return nil
+	}
+
+	p.mu.RLock()
+	count := int(float32(len(messages)) * p.opts.CompressRatio)
+	p.mu.RUnlock()
+	if count < 1 {
+		count = 1
+	}
+	if count >= len(messages) {
+		count = len(messages) - 1
+	}
+	older := messages[:count]
+
+	var prompt strings.Builder
+	prompt.WriteString("Please provide a concise summary of the following conversation history, preserving key information and context:\n\n")
+	covered := count
+	if previous != nil {
+		prompt.WriteString("Summary of the earlier conversation: " + previous.Content + "\n\n")
+		covered += previous.MessageCount
+	}
+	for _, m := range older {
+		prompt.WriteString(fmt.Sprintf("%s: %s\n", m.Role, m.Content))
+	}
+	reply, err := llm.Chat([]types.Message{
+		{
+			Role:    "system",
+			Content: "You are a helpful assistant that summarizes conversation history while preserving important context and key information.",
+		},
+		{
+			Role:    "user",
+			Content: prompt.String(),
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to generate memory summary: %w", err)
+	}
+
+	last := older[len(older)-1]
+	meta := model.MessageMeta{}
+	if last.Meta != nil {
+		meta = *last.Meta
+	}
+	meta.Summary = &model.MemorySummary{
+		Content:      reply.Content,
+		MessageCount: covered,
+		CreatedAt:    time.Now(),
+	}
+	if err := p.mp.Update(ctx, &model.ChatMessage{ID: last.ID, Meta: &meta}); err != nil {
+		slog.Error("Failed to save memory summary", "error", err, "session_id", p.sessionID)
+		return err
+	}
 	return nil
 }
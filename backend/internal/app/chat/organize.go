@@ -0,0 +1,254 @@
+package chat
+
+import (
+	"context"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/jinzhu/copier"
+	"github.com/xichan96/cortex-lab/internal/appdto"
+	"github.com/xichan96/cortex-lab/internal/infra/model"
+	"github.com/xichan96/cortex-lab/internal/pkg/errcode"
+	"github.com/xichan96/cortex-lab/pkg/sql"
+	"github.com/xichan96/cortex-lab/pkg/web/cctx"
+	"gorm.io/gorm"
+)
+
+const (
+	maxSessionTags  = 20
+	maxTagLength    = 32
+	maxFolderLength = 64
+)
+
+// normalizeTags trims tags and drops empty and repeated ones
+func normalizeTags(tags []string) ([]string, error) {
+	out := make([]string, 0, len(tags))
+	seen := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if tag == "" || seen[tag] {
+			continue
+		}
+		if utf8.RuneCountInString(tag) > maxTagLength {
+			return nil, errcode.ChatTagInvalid
+		}
+		seen[tag] = true
+		out = append(out, tag)
+	}
+	if len(out) > maxSessionTags {
+		return nil, errcode.ChatTagInvalid
+	}
+	return out, nil
+}
+
+// sessionTags loads the tags of sessions, keyed by session ID
+func (a *app) sessionTags(ctx context.Context, ids ...string) (map[string][]string, error) {
+	tags := make(map[string][]string, len(ids))
+	if len(ids) == 0 {
+		return tags, nil
+	}
+	list, err := a.tp.GetList(ctx, func(db *gorm.DB) *gorm.DB {
+		return db.Where("session_id IN ?", ids).Order("created_at ASC, tag ASC")
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range list {
+		tags[t.SessionID] = append(tags[t.SessionID], t.Tag)
+	}
+	return tags, nil
+}
+
+// fillTags sets the tags of session DTOs
+func (a *app) fillTags(ctx context.Context, sessions ...*appdto.ChatSession) error {
+	ids := make([]string, len(sessions))
+	for i, s := range sessions {
+		ids[i] = s.ID
+	}
+	tags, err := a.sessionTags(ctx, ids...)
+	if err != nil {
+		return err
+	}
+	for _, s := range sessions {
+		s.Tags = tags[s.ID]
+		if s.Tags == nil {
+			s.Tags = []string{}
+		}
+	}
+	return nil
+}
+
+// OrganizeSession pins, archives, files or tags a session of the current user
+func (a *app) OrganizeSession(ctx context.Context, id string, req *appdto.UpdateChatSessionOrganizeReq) error {
+	session, err := a.ownedSession(ctx, id)
+	if err != nil {
+		return err
+	}
+	fields := make(map[string]interface{})
+	if req.Pinned != nil {
+		fields["pinned"] = *req.Pinned
+	}
+	if req.Archived != nil {
+		fields["archived"] = *req.Archived
+	}
+	if req.FolderID != nil {
+		if *req.FolderID == "" {
+			fields["folder_id"] = nil
+		} else {
+			if _, err := a.ownedFolder(ctx, *req.FolderID); err != nil {
+				return err
+			}
+			fields["folder_id"] = *req.FolderID
+		}
+	}
+	var tags []*model.ChatSessionTag
+	if req.Tags != nil {
+		names, err := normalizeTags(req.Tags)
+		if err != nil {
+			return err
+		}
+		for _, name := range names {
+			tags = append(tags, &model.ChatSessionTag{SessionID: session.ID, Tag: name, UserID: session.UserID})
+		}
+	}
+
+	return sql.NewSqlTX().Execute(ctx, func(ctx context.Context) error {
+		if len(fields) > 0 {
+			if err := a.sp.UpdateFields(ctx, fields, func(db *gorm.DB) *gorm.DB {
+				return db.Where("id = ?", session.ID)
+			}); err != nil {
+				return err
+			}
+		}
+		if req.Tags == nil {
+			return nil
+		}
+		if err := a.tp.DeleteBySessionID(ctx, session.ID); err != nil {
+			return err
+		}
+		if len(tags) == 0 {
+			return nil
+		}
+		return a.tp.CreateBatch(ctx, tags)
+	})
+}
+
+// GetTags lists the tags of the current user with the number of sessions using them
+func (a *app) GetTags(ctx context.Context) ([]*appdto.ChatTag, error) {
+	userID := cctx.GetUserID[string](ctx)
+	var tags []*appdto.ChatTag
+	err := a.tp.Gets(ctx, &tags, func(db *gorm.DB) *gorm.DB {
+		return db.Select("tag, COUNT(*) AS session_count").
+			Where("user_id = ?", userID).
+			Group("tag").
+			Order("tag ASC")
+	})
+	if err != nil {
+		return nil, err
+	}
+	return tags, nil
+}
+
+// ownedFolder loads a folder of the current user
+func (a *app) ownedFolder(ctx context.Context, id string) (*model.ChatFolder, error) {
+	folder, err := a.fp.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if folder.UserID != cctx.GetUserID[string](ctx) {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return folder, nil
+}
+
+// checkFolderName trims a folder name and makes sure the current user has no other folder with it
+func (a *app) checkFolderName(ctx context.Context, name, excludeID string) (string, error) {
+	name = strings.TrimSpace(name)
+	if utf8.RuneCountInString(name) > maxFolderLength {
+		name = string([]rune(name)[:maxFolderLength])
+	}
+	userID := cctx.GetUserID[string](ctx)
+	count, err := a.fp.Count(ctx, func(db *gorm.DB) *gorm.DB {
+		return db.Where("user_id = ? AND name = ? AND id <> ?", userID, name, excludeID)
+	})
+	if err != nil {
+		return "", err
+	}
+	if count > 0 {
+		return "", errcode.ChatFolderNameExisted
+	}
+	return name, nil
+}
+
+func (a *app) CreateFolder(ctx context.Context, req *appdto.CreateChatFolderReq) (string, error) {
+	name, err := a.checkFolderName(ctx, req.Name, "")
+	if err != nil {
+		return "", err
+	}
+	return a.fp.Create(ctx, &model.ChatFolder{
+		UserID: cctx.GetUserID[string](ctx),
+		Name:   name,
+	})
+}
+
+func (a *app) UpdateFolder(ctx context.Context, req *appdto.UpdateChatFolderReq) error {
+	folder, err := a.ownedFolder(ctx, req.ID)
+	if err != nil {
+		return err
+	}
+	if folder.Name, err = a.checkFolderName(ctx, req.Name, folder.ID); err != nil {
+		return err
+	}
+	return a.fp.Update(ctx, folder)
+}
+
+// DeleteFolder deletes a folder of the current user, its sessions are kept outside any folder
+func (a *app) DeleteFolder(ctx context.Context, id string) error {
+	folder, err := a.ownedFolder(ctx, id)
+	if err != nil {
+		return err
+	}
+	return sql.NewSqlTX().Execute(ctx, func(ctx context.Context) error {
+		if err := a.sp.UpdateFields(ctx, map[string]interface{}{"folder_id": nil}, func(db *gorm.DB) *gorm.DB {
+			return db.Where("folder_id = ?", folder.ID)
+		}); err != nil {
+			return err
+		}
+		return a.fp.Delete(ctx, folder)
+	})
+}
+
+// GetFolders lists the folders of the current user with the number of sessions they hold
+func (a *app) GetFolders(ctx context.Context) ([]*appdto.ChatFolder, error) {
+	userID := cctx.GetUserID[string](ctx)
+	folders, err := a.fp.GetList(ctx, func(db *gorm.DB) *gorm.DB {
+		return db.Where("user_id = ?", userID).Order("name ASC")
+	})
+	if err != nil {
+		return nil, err
+	}
+	var counts []struct {
+		FolderID     string
+		SessionCount int64
+	}
+	if err := a.sp.Gets(ctx, &counts, func(db *gorm.DB) *gorm.DB {
+		return db.Select("folder_id, COUNT(*) AS session_count").
+			Where("user_id = ? AND folder_id IS NOT NULL", userID).
+			Group("folder_id")
+	}); err != nil {
+		return nil, err
+	}
+	byFolder := make(map[string]int64, len(counts))
+	for _, c := range counts {
+		byFolder[c.FolderID] = c.SessionCount
+	}
+
+	dtos := make([]*appdto.ChatFolder, len(folders))
+	for i, f := range folders {
+		dto := &appdto.ChatFolder{}
+		_ = copier.Copy(dto, f)
+		dto.SessionCount = byFolder[f.ID]
+		dtos[i] = dto
+	}
+	return dtos, nil
+}
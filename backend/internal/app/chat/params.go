@@ -0,0 +1,90 @@
+package chat
+
+import (
+	"context"
+	"time"
+
+	"github.com/xichan96/cortex-lab/internal/appdto"
+	"github.com/xichan96/cortex-lab/internal/infra/model"
+	"github.com/xichan96/cortex-lab/internal/pkg/errcode"
+	"github.com/xichan96/cortex/agent/types"
+	"gorm.io/gorm"
+)
+
+// applyParams sets the fields of params on the agent configuration
+func applyParams(cfg *types.AgentConfig, params *model.ModelParams) {
+	if params == nil {
+		return
+	}
+	if params.Temperature != nil {
+		cfg.Temperature = *params.Temperature
+	}
+	if params.MaxTokens != nil {
+		cfg.MaxTokens = *params.MaxTokens
+	}
+	if params.TopP != nil {
+		cfg.TopP = *params.TopP
+	}
+	if params.FrequencyPenalty != nil {
+		cfg.FrequencyPenalty = *params.FrequencyPenalty
+	}
+	if params.PresencePenalty != nil {
+		cfg.PresencePenalty = *params.PresencePenalty
+	}
+	if params.StopSequences != nil {
+		cfg.StopSequences = params.StopSequences
+	}
+	if params.MaxIterations != nil {
+		cfg.MaxIterations = *params.MaxIterations
+	}
+	if params.TimeoutSeconds != nil {
+		cfg.Timeout = time.Duration(*params.TimeoutSeconds) * time.Second
+	}
+	if params.ToolTimeoutSeconds != nil {
+		cfg.ToolExecutionTimeout = time.Duration(*params.ToolTimeoutSeconds) * time.Second
+	}
+	if params.RetryAttempts != nil {
+		cfg.RetryAttempts = *params.RetryAttempts
+	}
+	if params.RetryDelayMs != nil {
+		cfg.RetryDelay = time.Duration(*params.RetryDelayMs) * time.Millisecond
+	}
+}
+
+// usedParams returns every parameter of the agent configuration, it is kept
+// on the reply so the run can be reproduced
+func usedParams(cfg *types.AgentConfig) *model.ModelParams {
+	c := *cfg
+	timeout := int(c.Timeout / time.Second)
+	toolTimeout := int(c.ToolExecutionTimeout / time.Second)
+	retryDelay := int(c.RetryDelay / time.Millisecond)
+	return &model.ModelParams{
+		Temperature:        &c.Temperature,
+		MaxTokens:          &c.MaxTokens,
+		TopP:               &c.TopP,
+		FrequencyPenalty:   &c.FrequencyPenalty,
+		PresencePenalty:    &c.PresencePenalty,
+		StopSequences:      append([]string{}, c.StopSequences...),
+		MaxIterations:      &c.MaxIterations,
+		TimeoutSeconds:     &timeout,
+		ToolTimeoutSeconds: &toolTimeout,
+		RetryAttempts:      &c.RetryAttempts,
+		RetryDelayMs:       &retryDelay,
+	}
+}
+
+// checkParams validates parameters sent by the client
+func checkParams(params *appdto.ModelParams) (*model.ModelParams, error) {
+	p := (*model.ModelParams)(params)
+	if !p.Valid() {
+		return nil, errcode.ModelParamsInvalid
+	}
+	return p, nil
+}
+
+// saveSessionParams replaces the parameter overrides of a session
+func (a *app) saveSessionParams(ctx context.Context, sessionID string, params *model.ModelParams) error {
+	return a.sp.UpdateFields(ctx, map[string]interface{}{"model_params": params}, func(db *gorm.DB) *gorm.DB {
+		return db.Where("id = ?", sessionID)
+	})
+}
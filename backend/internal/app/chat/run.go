@@ -0,0 +1,105 @@
+package chat
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+
+	"github.com/xichan96/cortex-lab/internal/appdto"
+	"github.com/xichan96/cortex-lab/internal/infra/model"
+	"github.com/xichan96/cortex-lab/internal/pkg/agentrun"
+	"github.com/xichan96/cortex-lab/pkg/web/cctx"
+	"github.com/xichan96/cortex/agent/engine"
+	"github.com/xichan96/cortex/agent/types"
+)
+
+// chatRun is the engine of a chat turn together with what it records
+type chatRun struct {
+	engine *engine.AgentEngine
+	trace  *runTrace
+	memory types.MemoryProvider
+	// notices are relayed on the stream next to the results of the engine
+	notices chan engine.StreamResult
+}
+
+// streamApprovals announces the tool calls waiting for approval on the
+// stream of the run with an "approval_required" result
+func (r *chatRun) streamApprovals(run *agentrun.Run) {
+	if r.trace.approval == nil {
+		return
+	}
+	r.notices = make(chan engine.StreamResult)
+	r.trace.approval.notify = func(approval *appdto.ToolApproval) {
+		data, err := json.Marshal(approval)
+		if err != nil {
+			return
+		}
+		select {
+		case r.notices <- engine.StreamResult{Type: "approval_required", Content: string(data)}:
+		case <-run.Context().Done():
+		}
+	}
+}
+
+// saveCancelled persists the turn of a cancelled run with the output streamed
+// so far. The engine does not save a turn that ended with an error, so the
+// input and the partial reply are stored here unless the memory already did.
+func (r *chatRun) saveCancelled(input string) ([]*model.ChatMessage, error) {
+	if saved := r.trace.savedMessages(); len(saved) > 0 {
+		return saved, nil
+	}
+	r.trace.markCancelled()
+	err := r.memory.SaveContext(
+		map[string]interface{}{"input": input},
+		map[string]interface{}{"output": r.trace.partialOutput()},
+	)
+	if err != nil {
+		return nil, err
+	}
+	return r.trace.savedMessages(), nil
+}
+
+// forward relays the results of a streamed run and its notices, and releases
+// the run once the engine is done. Errors caused by the cancellation are
+// replaced by a single "cancelled" result.
+func (r *chatRun) forward(run *agentrun.Run, stream <-chan engine.StreamResult, input string) <-chan engine.StreamResult {
+	out := make(chan engine.StreamResult, engine.DefaultChannelBuffer)
+	go func() {
+		defer close(out)
+		defer agentrun.Default.Finish(run)
+
+		ended := false
+		for open := true; open; {
+			select {
+			case notice := <-r.notices:
+				out <- notice
+			case result, ok := <-stream:
+				if !ok {
+					open = false
+					continue
+				}
+				if result.Type == "error" && run.Cancelled() {
+					continue
+				}
+				ended = ended || result.Type == "end"
+				out <- result
+			}
+		}
+		if ended || !run.Cancelled() {
+			return
+		}
+		if _, err := r.saveCancelled(input); err != nil {
+			slog.Error("Failed to save cancelled run", "error", err, "session_id", run.SessionID, "run_id", run.ID)
+		}
+		out <- engine.StreamResult{
+			Type:   "cancelled",
+			Result: &engine.AgentResult{Output: r.trace.partialOutput()},
+		}
+	}()
+	return out
+}
+
+// CancelRun cancels the run in flight of a session started by the current user
+func (a *app) CancelRun(ctx context.Context, sessionID, runID string) bool {
+	return agentrun.Default.Cancel(sessionID, cctx.GetUserID[string](ctx), runID)
+}
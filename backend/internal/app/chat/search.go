@@ -0,0 +1,292 @@
+package chat
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/jinzhu/copier"
+	"github.com/xichan96/cortex-lab/internal/appdto"
+	"github.com/xichan96/cortex-lab/internal/config"
+	"github.com/xichan96/cortex-lab/internal/infra/model"
+	"github.com/xichan96/cortex-lab/pkg/web/cctx"
+	"gorm.io/gorm"
+)
+
+const (
+	// maxSearchTerms caps the words of a search keyword
+	maxSearchTerms = 8
+	// maxSearchMatches is the number of matching messages returned for each session
+	maxSearchMatches = 3
+	// snippetRadius is the number of characters kept before the first match of a snippet
+	snippetRadius = 60
+	snippetLength = 3 * snippetRadius
+)
+
+type searchSessionRow struct {
+	SessionID  string
+	MatchCount int64
+}
+
+type searchMessageRow struct {
+	ID        string
+	SessionID string
+	Role      string
+	Content   string
+	CreatedAt time.Time
+}
+
+// searchTerms splits a search keyword into distinct words
+func searchTerms(keyword string) []string {
+	var terms []string
+	seen := make(map[string]bool)
+	for _, term := range strings.Fields(keyword) {
+		key := strings.ToLower(term)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		terms = append(terms, term)
+		if len(terms) == maxSearchTerms {
+			break
+		}
+	}
+	return terms
+}
+
+func shortestTerm(terms []string) int {
+	shortest := 0
+	for i, term := range terms {
+		if n := utf8.RuneCountInString(term); i == 0 || n < shortest {
+			shortest = n
+		}
+	}
+	return shortest
+}
+
+// matchFilter selects the messages that contain every term. The full-text
+// index is used when it is available and can match the terms: the SQLite
+// trigram tokenizer needs three characters, the MySQL ngram parser two.
+func matchFilter(terms []string) func(*gorm.DB) *gorm.DB {
+	m := model.TableChatMessage
+	return func(db *gorm.DB) *gorm.DB {
+		if config.Var.FullTextSearch {
+			if config.Config.DBDriver == "sqlite" && shortestTerm(terms) >= 3 {
+				fts := model.TableChatMessageFTS
+				phrases := make([]string, len(terms))
+				for i, term := range terms {
+					phrases[i] = `"` + strings.ReplaceAll(term, `"`, `""`) + `"`
+				}
+				return db.Where(m+".rowid IN (SELECT rowid FROM "+fts+" WHERE "+fts+" MATCH ?)", strings.Join(phrases, " "))
+			}
+			if config.Config.DBDriver != "sqlite" && shortestTerm(terms) >= 2 {
+				phrases := make([]string, len(terms))
+				for i, term := range terms {
+					phrases[i] = `+"` + strings.ReplaceAll(term, `"`, "") + `"`
+				}
+				return db.Where("MATCH("+m+".content) AGAINST(? IN BOOLEAN MODE)", strings.Join(phrases, " "))
+			}
+		}
+		escape := strings.NewReplacer("!", "!!", "%", "!%", "_", "!_")
+		for _, term := range terms {
+			db = db.Where(m+".content LIKE ? ESCAPE '!'", "%"+escape.Replace(term)+"%")
+		}
+		return db
+	}
+}
+
+// searchFilters selects the messages of the current user matching req
+func searchFilters(ctx context.Context, req *appdto.SearchChatMessagesReq, terms []string) ([]func(*gorm.DB) *gorm.DB, error) {
+	m, s := model.TableChatMessage, model.TableChatSession
+	userID := cctx.GetUserID[string](ctx)
+	opts := []func(*gorm.DB) *gorm.DB{
+		func(db *gorm.DB) *gorm.DB {
+			return db.Joins("JOIN "+s+" ON "+s+".id = "+m+".session_id").
+				Where(s+".user_id = ? AND "+m+".deleted_at IS NULL", userID).
+				Where(m+".role IN ?", []string{"user", "assistant"})
+		},
+		matchFilter(terms),
+	}
+	if req.RoleID != "" {
+		opts = append(opts, func(db *gorm.DB) *gorm.DB {
+			return db.Where(s+".role_id = ?", req.RoleID)
+		})
+	}
+	if req.ModelName != "" {
+		opts = append(opts, func(db *gorm.DB) *gorm.DB {
+			return db.Where(s+".model_name = ?", req.ModelName)
+		})
+	}
+	if req.StartDate != "" {
+		start, err := time.ParseInLocation(time.DateOnly, req.StartDate, time.Local)
+		if err != nil {
+			return nil, fmt.Errorf("invalid start_date: %w", err)
+		}
+		opts = append(opts, func(db *gorm.DB) *gorm.DB {
+			return db.Where(m+".created_at >= ?", start)
+		})
+	}
+	if req.EndDate != "" {
+		end, err := time.ParseInLocation(time.DateOnly, req.EndDate, time.Local)
+		if err != nil {
+			return nil, fmt.Errorf("invalid end_date: %w", err)
+		}
+		// end_date is inclusive
+		end = end.AddDate(0, 0, 1)
+		opts = append(opts, func(db *gorm.DB) *gorm.DB {
+			return db.Where(m+".created_at < ?", end)
+		})
+	}
+	return opts, nil
+}
+
+// SearchMessages searches the messages of all sessions of the current user.
+// Sessions are ordered by their most recent match and come with the latest
+// matching messages, highlighted.
+func (a *app) SearchMessages(ctx context.Context, req *appdto.SearchChatMessagesReq) ([]*appdto.ChatSearchResult, int64, error) {
+	terms := searchTerms(req.Keyword)
+	if len(terms) == 0 {
+		return []*appdto.ChatSearchResult{}, 0, nil
+	}
+	filters, err := searchFilters(ctx, req, terms)
+	if err != nil {
+		return nil, 0, err
+	}
+	m := model.TableChatMessage
+
+	var count struct{ Total int64 }
+	countOpts := append(filters[:len(filters):len(filters)], func(db *gorm.DB) *gorm.DB {
+		return db.Select("COUNT(DISTINCT " + m + ".session_id) AS total")
+	})
+	if err := a.mp.Gets(ctx, &count, countOpts...); err != nil {
+		return nil, 0, err
+	}
+	total := count.Total
+
+	var rows []*searchSessionRow
+	sessionOpts := append(filters[:len(filters):len(filters)], func(db *gorm.DB) *gorm.DB {
+		db = db.Select(m + ".session_id AS session_id, COUNT(*) AS match_count").
+			Group(m + ".session_id").
+			Order("MAX(" + m + ".created_at) DESC")
+		if req.Page > 0 && req.PageSize > 0 {
+			db = db.Offset((req.Page - 1) * req.PageSize).Limit(req.PageSize)
+		}
+		return db
+	})
+	if err := a.mp.Gets(ctx, &rows, sessionOpts...); err != nil {
+		return nil, 0, err
+	}
+	if len(rows) == 0 {
+		return []*appdto.ChatSearchResult{}, total, nil
+	}
+
+	ids := make([]string, len(rows))
+	for i, r := range rows {
+		ids[i] = r.SessionID
+	}
+	sessions, err := a.sp.GetList(ctx, func(db *gorm.DB) *gorm.DB {
+		return db.Where("id IN ?", ids)
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	byID := make(map[string]*model.ChatSession, len(sessions))
+	for _, s := range sessions {
+		byID[s.ID] = s
+	}
+
+	results := make([]*appdto.ChatSearchResult, 0, len(rows))
+	for _, r := range rows {
+		session, ok := byID[r.SessionID]
+		if !ok {
+			continue
+		}
+		var messages []*searchMessageRow
+		messageOpts := append(filters[:len(filters):len(filters)], func(db *gorm.DB) *gorm.DB {
+			return db.Select(m+".id, "+m+".session_id, "+m+".role, "+m+".content, "+m+".created_at").
+				Where(m+".session_id = ?", r.SessionID).
+				Order(m + ".created_at DESC").
+				Limit(maxSearchMatches)
+		})
+		if err := a.mp.Gets(ctx, &messages, messageOpts...); err != nil {
+			return nil, 0, err
+		}
+		result := &appdto.ChatSearchResult{MatchCount: r.MatchCount, Matches: make([]*appdto.ChatSearchMatch, len(messages))}
+		_ = copier.Copy(&result.ChatSession, session)
+		for i, msg := range messages {
+			result.Matches[i] = &appdto.ChatSearchMatch{
+				MessageID: msg.ID,
+				Role:      msg.Role,
+				Snippet:   highlight(msg.Content, terms),
+				CreatedAt: msg.CreatedAt,
+			}
+		}
+		results = append(results, result)
+	}
+	return results, total, nil
+}
+
+// highlight cuts a snippet of content around the first match of terms and
+// wraps every match in <mark>. The rest of the text is HTML escaped.
+func highlight(content string, terms []string) string {
+	text := []rune(strings.Join(strings.Fields(content), " "))
+	lower := make([]rune, len(text))
+	for i, r := range text {
+		lower[i] = unicode.ToLower(r)
+	}
+	needles := make([][]rune, 0, len(terms))
+	for _, term := range terms {
+		needles = append(needles, []rune(strings.ToLower(term)))
+	}
+	matchAt := func(i int) int {
+		longest := 0
+		for _, needle := range needles {
+			if len(needle) > longest && i+len(needle) <= len(lower) && string(lower[i:i+len(needle)]) == string(needle) {
+				longest = len(needle)
+			}
+		}
+		return longest
+	}
+
+	first := -1
+	for i := range lower {
+		if matchAt(i) > 0 {
+			first = i
+			break
+		}
+	}
+	start := 0
+	if first > snippetRadius {
+		start = first - snippetRadius
+	}
+	end := min(len(text), start+snippetLength)
+
+	var sb strings.Builder
+	if start > 0 {
+		sb.WriteString("...")
+	}
+	plain := start
+	for i := start; i < end; {
+		n := matchAt(i)
+		if n == 0 {
+			i++
+			continue
+		}
+		n = min(n, len(text)-i)
+		sb.WriteString(html.EscapeString(string(text[plain:i])))
+		sb.WriteString("<mark>" + html.EscapeString(string(text[i:i+n])) + "</mark>")
+		i += n
+		plain = i
+	}
+	if plain < end {
+		sb.WriteString(html.EscapeString(string(text[plain:end])))
+	}
+	if max(end, plain) < len(text) {
+		sb.WriteString("...")
+	}
+	return sb.String()
+}
@@ -3,15 +3,24 @@ package chat
 import (
 	"context"
 	"fmt"
+	"io"
+	"os"
 	"time"
 
 	"github.com/jinzhu/copier"
+	"github.com/xichan96/cortex-lab/internal/app/event"
 	"github.com/xichan96/cortex-lab/internal/app/experience"
 	"github.com/xichan96/cortex-lab/internal/app/role"
 	"github.com/xichan96/cortex-lab/internal/app/setting"
+	"github.com/xichan96/cortex-lab/internal/app/sshhost"
+	"github.com/xichan96/cortex-lab/internal/app/team"
 	"github.com/xichan96/cortex-lab/internal/appdto"
 	"github.com/xichan96/cortex-lab/internal/infra/model"
 	"github.com/xichan96/cortex-lab/internal/infra/persist"
+	"github.com/xichan96/cortex-lab/internal/pkg/agentrun"
+	"github.com/xichan96/cortex-lab/internal/pkg/errcode"
+	"github.com/xichan96/cortex-lab/pkg/sql"
+	"github.com/xichan96/cortex-lab/pkg/std/snowflake"
 	"github.com/xichan96/cortex-lab/pkg/web/cctx"
 	"github.com/xichan96/cortex/agent/engine"
 	"github.com/xichan96/cortex/agent/types"
@@ -37,35 +46,104 @@ type AppIer interface {
 	DeleteSession(ctx context.Context, id string) error
 	GetSession(ctx context.Context, id string) (*appdto.ChatSession, error)
 	GetSessions(ctx context.Context, req *appdto.GetChatSessionsReq) ([]*appdto.ChatSession, int64, error)
+	SearchMessages(ctx context.Context, req *appdto.SearchChatMessagesReq) ([]*appdto.ChatSearchResult, int64, error)
+	ExportSession(ctx context.Context, id string) (*appdto.ChatSessionBundle, error)
+	ExportSessionMarkdown(ctx context.Context, id string) (string, error)
+	ImportSession(ctx context.Context, bundle *appdto.ChatSessionBundle) (string, error)
+	ShareSession(ctx context.Context, id string, req *appdto.ShareChatSessionReq) (*appdto.ChatShare, error)
+	RevokeShare(ctx context.Context, id string) error
+	GetSharedSession(ctx context.Context, token string) (*appdto.SharedChatSession, error)
+	OrganizeSession(ctx context.Context, id string, req *appdto.UpdateChatSessionOrganizeReq) error
+	GetTags(ctx context.Context) ([]*appdto.ChatTag, error)
+	CreateFolder(ctx context.Context, req *appdto.CreateChatFolderReq) (string, error)
+	UpdateFolder(ctx context.Context, req *appdto.UpdateChatFolderReq) error
+	DeleteFolder(ctx context.Context, id string) error
+	GetFolders(ctx context.Context) ([]*appdto.ChatFolder, error)
+	GetCallTree(ctx context.Context, sessionID string) (*appdto.ChatCallNode, error)
 	SendMessage(ctx context.Context, roleID, provider, modelName, sessionID string, req *appdto.SendChatMessageReq) (string, []*appdto.ChatMessage, error)
 	GetMessages(ctx context.Context, sessionID string, req *appdto.GetChatMessagesReq) ([]*appdto.ChatMessage, int64, error)
+	EditMessage(ctx context.Context, sessionID, messageID string, req *appdto.EditChatMessageReq) ([]*appdto.ChatMessage, error)
+	RegenerateMessage(ctx context.Context, sessionID, messageID string) ([]*appdto.ChatMessage, error)
+	SwitchBranch(ctx context.Context, sessionID, messageID string) error
 	Engine(ctx context.Context, sessionID, roleID, provider, modelName string) (*engine.AgentEngine, error)
-	PrepareStreamMessage(ctx context.Context, roleID, provider, modelName, sessionID string, userInput string) (string, *engine.AgentEngine, error)
+	StreamMessage(ctx context.Context, roleID, provider, modelName, sessionID string, input *appdto.ChatMessageItem, params *appdto.ModelParams) (string, *agentrun.Run, <-chan engine.StreamResult, error)
+	CancelRun(ctx context.Context, sessionID, runID string) bool
+	GetApprovals(ctx context.Context, req *appdto.GetToolApprovalsReq) []*appdto.ToolApproval
+	ApproveToolCall(ctx context.Context, id string, req *appdto.ApproveToolCallReq) error
+	RejectToolCall(ctx context.Context, id string, req *appdto.RejectToolCallReq) error
+	EditToolCall(ctx context.Context, id string, req *appdto.EditToolCallReq) (*appdto.ToolApproval, error)
+	UploadAttachment(ctx context.Context, fileName string, r io.Reader) (*appdto.ChatAttachment, error)
+	OpenAttachment(ctx context.Context, id string) (*appdto.ChatAttachment, *os.File, error)
 }
 
 type app struct {
 	sp           persist.ChatSessionPersistIer
 	mp           persist.ChatMessagePersistIer
+	up           persist.UsageRecordPersistIer
+	ap           persist.ChatAttachmentPersistIer
+	tp           persist.ChatSessionTagPersistIer
+	fp           persist.ChatFolderPersistIer
 	roleApp      role.AppIer
+	teamApp      team.AppIer
 	settingSrv   setting.AppIer
 	knowledgeApp experience.AppIer
+	events       event.AppIer
+	sshHosts     sshhost.AppIer
 }
 
-func NewApp(sp persist.ChatSessionPersistIer, mp persist.ChatMessagePersistIer, roleApp role.AppIer, settingSrv setting.AppIer, knowledgeApp experience.AppIer) AppIer {
-	return &app{sp: sp, mp: mp, roleApp: roleApp, settingSrv: settingSrv, knowledgeApp: knowledgeApp}
+func NewApp(sp persist.ChatSessionPersistIer, mp persist.ChatMessagePersistIer, up persist.UsageRecordPersistIer, ap persist.ChatAttachmentPersistIer, tp persist.ChatSessionTagPersistIer, fp persist.ChatFolderPersistIer, roleApp role.AppIer, teamApp team.AppIer, settingSrv setting.AppIer, knowledgeApp experience.AppIer, events event.AppIer, sshHosts sshhost.AppIer) AppIer {
+	return &app{sp: sp, mp: mp, up: up, ap: ap, tp: tp, fp: fp, roleApp: roleApp, teamApp: teamApp, settingSrv: settingSrv, knowledgeApp: knowledgeApp, events: events, sshHosts: sshHosts}
 }
 
+// CreateSession creates a session, a title given here is kept as set by the user.
+// The role may be a team, the session then chats with its supervisor.
 func (a *app) CreateSession(ctx context.Context, req *appdto.CreateChatSessionReq) (string, error) {
+	titleSource := model.ChatTitleSourceAuto
+	if req.Title != nil {
+		titleSource = model.ChatTitleSourceManual
+	}
+	roleID, teamID, err := a.resolveTeam(ctx, req.RoleID)
+	if err != nil {
+		return "", err
+	}
+	req.RoleID = roleID
+	return a.createSession(ctx, req, titleSource, teamID)
+}
+
+func (a *app) createSession(ctx context.Context, req *appdto.CreateChatSessionReq, titleSource string, teamID *string) (string, error) {
+	params, err := checkParams(req.ModelParams)
+	if err != nil {
+		return "", err
+	}
 	userID := cctx.GetUserID[string](ctx)
+	now := time.Now()
 	session := &model.ChatSession{
-		UserID:    userID,
-		RoleID:    req.RoleID,
-		RoleName:  req.RoleName,
-		Provider:  req.Provider,
-		ModelName: req.ModelName,
-		Title:     req.Title,
-	}
-	return a.sp.Create(ctx, session)
+		UserID:       userID,
+		RoleID:       req.RoleID,
+		RoleName:     req.RoleName,
+		Provider:     req.Provider,
+		ModelName:    req.ModelName,
+		Title:        req.Title,
+		TitleSource:  titleSource,
+		LastActiveAt: &now,
+		ModelParams:  params,
+		TeamID:       teamID,
+	}
+	// a session created for a call from another role is linked to the caller
+	if d := delegationFrom(ctx); d != nil {
+		session.ParentSessionID = strPtr(d.parentSessionID)
+	}
+	id, err := a.sp.Create(ctx, session)
+	if err != nil {
+		return "", err
+	}
+	a.events.Publish(ctx, userID, model.EventSessionCreated, map[string]interface{}{
+		"session_id":        id,
+		"role_id":           session.RoleID,
+		"team_id":           session.TeamID,
+		"parent_session_id": session.ParentSessionID,
+	})
+	return id, nil
 }
 
 func (a *app) UpdateSessionTitle(ctx context.Context, req *appdto.UpdateChatSessionTitleReq) error {
@@ -74,6 +152,7 @@ func (a *app) UpdateSessionTitle(ctx context.Context, req *appdto.UpdateChatSess
 		return err
 	}
 	session.Title = req.Title
+	session.TitleSource = model.ChatTitleSourceManual
 	session.UpdatedAt = time.Now()
 	return a.sp.Update(ctx, session)
 }
@@ -83,7 +162,12 @@ func (a *app) DeleteSession(ctx context.Context, id string) error {
 	if err != nil {
 		return err
 	}
-	return a.sp.Delete(ctx, session)
+	return sql.NewSqlTX().Execute(ctx, func(ctx context.Context) error {
+		if err := a.tp.DeleteBySessionID(ctx, session.ID); err != nil {
+			return err
+		}
+		return a.sp.Delete(ctx, session)
+	})
 }
 
 func (a *app) GetSession(ctx context.Context, id string) (*appdto.ChatSession, error) {
@@ -93,23 +177,54 @@ func (a *app) GetSession(ctx context.Context, id string) (*appdto.ChatSession, e
 	}
 	dto := &appdto.ChatSession{}
 	_ = copier.Copy(dto, session)
+	if err := a.fillTags(ctx, dto); err != nil {
+		return nil, err
+	}
 	return dto, nil
 }
 
+// GetSessions lists the sessions of the current user, pinned sessions first,
+// then by last activity. Archived sessions are only listed on their own.
 func (a *app) GetSessions(ctx context.Context, req *appdto.GetChatSessionsReq) ([]*appdto.ChatSession, int64, error) {
 	userID := cctx.GetUserID[string](ctx)
 	opts := []func(*gorm.DB) *gorm.DB{
 		func(db *gorm.DB) *gorm.DB {
-			return db.Where("user_id = ?", userID)
+			return db.Where("user_id = ? AND archived = ?", userID, req.Archived)
 		},
 	}
+	if !req.IncludeChildren {
+		opts = append(opts, func(db *gorm.DB) *gorm.DB {
+			return db.Where("parent_session_id IS NULL")
+		})
+	}
+	if req.RoleID != "" {
+		opts = append(opts, func(db *gorm.DB) *gorm.DB {
+			return db.Where("role_id = ?", req.RoleID)
+		})
+	}
+	if req.ModelName != "" {
+		opts = append(opts, func(db *gorm.DB) *gorm.DB {
+			return db.Where("model_name = ?", req.ModelName)
+		})
+	}
+	if req.FolderID != "" {
+		opts = append(opts, func(db *gorm.DB) *gorm.DB {
+			return db.Where("folder_id = ?", req.FolderID)
+		})
+	}
+	if req.Tag != "" {
+		opts = append(opts, func(db *gorm.DB) *gorm.DB {
+			tagged := db.Session(&gorm.Session{NewDB: true}).Table(model.TableChatSessionTag).
+				Select("session_id").Where("user_id = ? AND tag = ?", userID, req.Tag)
+			return db.Where("id IN (?)", tagged)
+		})
+	}
 	total, err := a.sp.Count(ctx, opts...)
 	if err != nil {
 		return nil, 0, err
 	}
-	// order by created_at desc
 	opts = append(opts, func(db *gorm.DB) *gorm.DB {
-		return db.Order("created_at DESC")
+		return db.Order("pinned DESC, last_active_at DESC, created_at DESC")
 	})
 	if req.Page > 0 && req.PageSize > 0 {
 		opts = append(opts, func(db *gorm.DB) *gorm.DB {
@@ -127,13 +242,39 @@ func (a *app) GetSessions(ctx context.Context, req *appdto.GetChatSessionsReq) (
 		_ = copier.Copy(dto, s)
 		dtos[i] = dto
 	}
+	if err := a.fillTags(ctx, dtos...); err != nil {
+		return nil, 0, err
+	}
 	return dtos, total, nil
 }
 
 func (a *app) SendMessage(ctx context.Context, roleID, provider, modelName, sessionID string, req *appdto.SendChatMessageReq) (string, []*appdto.ChatMessage, error) {
 	userID := cctx.GetUserID[string](ctx)
+	params, err := checkParams(req.ModelParams)
+	if err != nil {
+		return "", nil, err
+	}
+	roleID, teamID, err := a.resolveTeam(ctx, roleID)
+	if err != nil {
+		return "", nil, err
+	}
+	provider, modelName, err = a.resolveModel(ctx, roleID, sessionID, provider, modelName)
+	if err != nil {
+		return "", nil, err
+	}
+
+	// attachments are checked before anything is created
+	attachments := make([][]model.AttachmentRef, len(req.Messages))
+	for i, msg := range req.Messages {
+		refs, err := a.resolveAttachments(ctx, msg.Attachments)
+		if err != nil {
+			return "", nil, err
+		}
+		attachments[i] = refs
+	}
 
 	var finalSessionID string
+	var parentID string
 	if sessionID == "" {
 		role, err := a.roleApp.GetRole(ctx, roleID)
 		if err != nil {
@@ -152,13 +293,15 @@ func (a *app) SendMessage(ctx context.Context, roleID, provider, modelName, sess
 		}
 
 		sessionReq := &appdto.CreateChatSessionReq{
-			RoleID:    roleID,
-			RoleName:  role.Name,
-			Provider:  provider,
-			ModelName: modelName,
-			Title:     title,
+			RoleID:      roleID,
+			RoleName:    role.Name,
+			Provider:    provider,
+			ModelName:   modelName,
+			Title:       title,
+			ModelParams: req.ModelParams,
 		}
-		finalSessionID, err = a.CreateSession(ctx, sessionReq)
+		// the first message is a placeholder until the title is generated
+		finalSessionID, err = a.createSession(ctx, sessionReq, model.ChatTitleSourceAuto, teamID)
 		if err != nil {
 			return "", nil, err
 		}
@@ -179,18 +322,42 @@ func (a *app) SendMessage(ctx context.Context, roleID, provider, modelName, sess
 				return "", nil, err
 			}
 		}
+		if params != nil {
+			if err := a.saveSessionParams(ctx, session.ID, params); err != nil {
+				return "", nil, err
+			}
+		}
+		parentID, err = currentLeaf(ctx, a.mp, a.sp, session)
+		if err != nil {
+			return "", nil, err
+		}
 		finalSessionID = sessionID
 	}
 
+	// Earlier user messages in the request are stored as-is; the last one is the
+	// run input and is persisted by the memory provider together with the reply.
 	userMessages := make([]*model.ChatMessage, 0)
-	for _, msg := range req.Messages {
-		if msg.Role == "user" {
-			userMessages = append(userMessages, &model.ChatMessage{
-				SessionID: finalSessionID,
-				Role:      msg.Role,
-				Content:   msg.Content,
-			})
+	var userInput string
+	var inputAttachments []model.AttachmentRef
+	if len(req.Messages) > 0 {
+		for i, msg := range req.Messages[:len(req.Messages)-1] {
+			if msg.Role == "user" {
+				m := &model.ChatMessage{
+					ID:        snowflake.NewUUID(),
+					SessionID: finalSessionID,
+					ParentID:  strPtr(parentID),
+					Role:      msg.Role,
+					Content:   msg.Content,
+				}
+				if len(attachments[i]) > 0 {
+					m.Meta = &model.MessageMeta{Attachments: attachments[i]}
+				}
+				userMessages = append(userMessages, m)
+				parentID = m.ID
+			}
 		}
+		userInput = req.Messages[len(req.Messages)-1].Content
+		inputAttachments = attachments[len(req.Messages)-1]
 	}
 
 	if len(userMessages) > 0 {
@@ -199,33 +366,75 @@ func (a *app) SendMessage(ctx context.Context, roleID, provider, modelName, sess
 		}
 	}
 
-	engine, err := a.Engine(ctx, finalSessionID, roleID, provider, modelName)
+	saved, err := a.run(ctx, finalSessionID, roleID, provider, modelName, &branchPoint{parentID: parentID}, userInput, inputAttachments)
 	if err != nil {
-		return "", nil, fmt.Errorf("failed to create engine: %w", err)
+		return "", nil, err
 	}
 
-	var userInput string
-	if len(req.Messages) > 0 {
-		userInput = req.Messages[len(req.Messages)-1].Content
+	return finalSessionID, toMessageDTOs(append(userMessages, saved...)), nil
+}
+
+// run executes one agent turn attached to branch and returns the messages it persisted
+func (a *app) run(ctx context.Context, sessionID, roleID, provider, modelName string, branch *branchPoint, input string, attachments []model.AttachmentRef) ([]*model.ChatMessage, error) {
+	run, ok := agentrun.Default.Start(sessionID, cctx.GetUserID[string](ctx))
+	if !ok {
+		return nil, errcode.ChatSessionBusy
+	}
+	defer agentrun.Default.Finish(run)
+	// a run called by another role stops when its call tree runs out of time
+	if delegationFrom(ctx) != nil {
+		stop := context.AfterFunc(ctx, func() {
+			agentrun.Default.Cancel(sessionID, run.UserID, run.ID)
+		})
+		defer stop()
+	}
+
+	r, err := a.build(ctx, run, sessionID, roleID, provider, modelName, branch)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create engine: %w", err)
 	}
+	r.trace.setInput(input, attachments, attachmentParts(attachments, supportsVision(modelName)))
 
-	result, err := engine.Execute(userInput, nil)
+	result, err := r.engine.Execute(input, nil)
 	if err != nil {
-		return "", nil, fmt.Errorf("failed to execute: %w", err)
+		if run.Cancelled() {
+			saved, err := r.saveCancelled(input)
+			if err == nil && ctx.Err() != nil {
+				err = errcode.DelegationTimeout
+			}
+			return saved, err
+		}
+		return nil, fmt.Errorf("failed to execute: %w", err)
 	}
 
-	assistantMsg := &model.ChatMessage{
-		SessionID: finalSessionID,
-		Role:      "assistant",
-		Content:   result.Output,
+	saved := r.trace.savedMessages()
+	if len(saved) == 0 {
+		// memory failed to persist the turn, keep at least the reply
+		assistantMsg := &model.ChatMessage{
+			SessionID: sessionID,
+			Role:      "assistant",
+			Content:   result.Output,
+			Meta:      r.trace.assistantMeta(),
+		}
+		if _, err := a.mp.Create(ctx, assistantMsg); err != nil {
+			return nil, err
+		}
+		r.trace.addSaved(assistantMsg)
+		saved = append(saved, assistantMsg)
 	}
-	if _, err := a.mp.Create(ctx, assistantMsg); err != nil {
-		return "", nil, err
+	return saved, nil
+}
+
+func messageAttachments(msg *model.ChatMessage) []model.AttachmentRef {
+	if msg.Meta == nil {
+		return nil
 	}
+	return msg.Meta.Attachments
+}
 
-	allMessages := append(userMessages, assistantMsg)
-	dtos := make([]*appdto.ChatMessage, len(allMessages))
-	for i, m := range allMessages {
+func toMessageDTOs(messages []*model.ChatMessage) []*appdto.ChatMessage {
+	dtos := make([]*appdto.ChatMessage, len(messages))
+	for i, m := range messages {
 		dto := &appdto.ChatMessage{}
 		_ = copier.Copy(dto, m)
 		if m.Meta != nil {
@@ -233,8 +442,93 @@ func (a *app) SendMessage(ctx context.Context, roleID, provider, modelName, sess
 		}
 		dtos[i] = dto
 	}
+	return dtos
+}
+
+// ownedMessage loads a message of a session owned by the current user
+func (a *app) ownedMessage(ctx context.Context, sessionID, messageID string) (*model.ChatSession, *model.ChatMessage, error) {
+	userID := cctx.GetUserID[string](ctx)
+	session, err := a.sp.GetByID(ctx, sessionID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if session.UserID != userID {
+		return nil, nil, gorm.ErrRecordNotFound
+	}
+	// make sure legacy sessions have parent links before the tree is used
+	if _, err := currentLeaf(ctx, a.mp, a.sp, session); err != nil {
+		return nil, nil, err
+	}
+	msg, err := a.mp.GetByID(ctx, messageID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if msg.SessionID != sessionID {
+		return nil, nil, gorm.ErrRecordNotFound
+	}
+	return session, msg, nil
+}
+
+func (a *app) EditMessage(ctx context.Context, sessionID, messageID string, req *appdto.EditChatMessageReq) ([]*appdto.ChatMessage, error) {
+	session, msg, err := a.ownedMessage(ctx, sessionID, messageID)
+	if err != nil {
+		return nil, err
+	}
+	if msg.Role != "user" {
+		return nil, errcode.ChatMessageNotEditable
+	}
+	// the edited message keeps the attachments of the original one unless they are replaced
+	attachments := messageAttachments(msg)
+	if req.Attachments != nil {
+		if attachments, err = a.resolveAttachments(ctx, req.Attachments); err != nil {
+			return nil, err
+		}
+	}
+	// the edited message becomes a sibling of the original one
+	branch := &branchPoint{parentID: derefStr(msg.ParentID)}
+	saved, err := a.run(ctx, session.ID, session.RoleID, session.Provider, session.ModelName, branch, req.Content, attachments)
+	if err != nil {
+		return nil, err
+	}
+	return toMessageDTOs(saved), nil
+}
 
-	return finalSessionID, dtos, nil
+func (a *app) RegenerateMessage(ctx context.Context, sessionID, messageID string) ([]*appdto.ChatMessage, error) {
+	session, msg, err := a.ownedMessage(ctx, sessionID, messageID)
+	if err != nil {
+		return nil, err
+	}
+	if msg.Role == "assistant" {
+		if msg.ParentID == nil {
+			return nil, errcode.ChatMessageNotRegenerable
+		}
+		if msg, err = a.mp.GetByID(ctx, *msg.ParentID); err != nil {
+			return nil, err
+		}
+	}
+	if msg.Role != "user" {
+		return nil, errcode.ChatMessageNotRegenerable
+	}
+	// the new reply becomes a sibling of the existing replies to the same user message
+	branch := &branchPoint{parentID: derefStr(msg.ParentID), inputID: msg.ID}
+	saved, err := a.run(ctx, session.ID, session.RoleID, session.Provider, session.ModelName, branch, msg.Content, messageAttachments(msg))
+	if err != nil {
+		return nil, err
+	}
+	return toMessageDTOs(saved), nil
+}
+
+func (a *app) SwitchBranch(ctx context.Context, sessionID, messageID string) error {
+	_, _, err := a.ownedMessage(ctx, sessionID, messageID)
+	if err != nil {
+		return err
+	}
+	messages, err := sessionTree(ctx, a.mp, sessionID)
+	if err != nil {
+		return err
+	}
+	leaf := latestLeaf(messages, messageID)
+	return a.sp.Update(ctx, &model.ChatSession{ID: sessionID, CurrentMessageID: &leaf})
 }
 
 func (a *app) GetMessages(ctx context.Context, sessionID string, req *appdto.GetChatMessagesReq) ([]*appdto.ChatMessage, int64, error) {
@@ -247,6 +541,9 @@ func (a *app) GetMessages(ctx context.Context, sessionID string, req *appdto.Get
 	if session.UserID != userID {
 		return nil, 0, gorm.ErrRecordNotFound
 	}
+	if req.Branch == "active" {
+		return a.getActiveMessages(ctx, session, req)
+	}
 
 	opts := []func(*gorm.DB) *gorm.DB{
 		func(db *gorm.DB) *gorm.DB {
@@ -279,21 +576,61 @@ func (a *app) GetMessages(ctx context.Context, sessionID string, req *appdto.Get
 		return nil, 0, err
 	}
 
-	dtos := make([]*appdto.ChatMessage, len(messages))
-	for i, m := range messages {
-		dto := &appdto.ChatMessage{}
-		_ = copier.Copy(dto, m)
-		if m.Meta != nil {
-			dto.Meta = m.Meta
+	return toMessageDTOs(messages), total, nil
+}
+
+// getActiveMessages pages through the messages of the active branch only
+func (a *app) getActiveMessages(ctx context.Context, session *model.ChatSession, req *appdto.GetChatMessagesReq) ([]*appdto.ChatMessage, int64, error) {
+	leaf, err := currentLeaf(ctx, a.mp, a.sp, session)
+	if err != nil {
+		return nil, 0, err
+	}
+	tree, err := sessionTree(ctx, a.mp, session.ID)
+	if err != nil {
+		return nil, 0, err
+	}
+	path := activePath(tree, leaf)
+	if req.Order == "desc" {
+		for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+			path[i], path[j] = path[j], path[i]
 		}
-		dtos[i] = dto
 	}
-
-	return dtos, total, nil
+	total := int64(len(path))
+	if req.Page > 0 && req.PageSize > 0 {
+		offset := (req.Page - 1) * req.PageSize
+		if offset > len(path) {
+			offset = len(path)
+		}
+		end := offset + req.PageSize
+		if end > len(path) {
+			end = len(path)
+		}
+		path = path[offset:end]
+	}
+	// only the content of the page is loaded
+	messages, err := loadMessages(ctx, a.mp, path)
+	if err != nil {
+		return nil, 0, err
+	}
+	return toMessageDTOs(messages), total, nil
 }
 
 func (a *app) Engine(ctx context.Context, sessionID, roleID, provider, modelName string) (*engine.AgentEngine, error) {
-	llmProvider, err := a.setupLLM(provider, modelName)
+	r, err := a.build(ctx, nil, sessionID, roleID, provider, modelName, nil)
+	if err != nil {
+		return nil, err
+	}
+	return r.engine, nil
+}
+
+// build creates the engine of a run. When run is not nil the LLM calls and
+// tools are bound to it so they stop when the run is cancelled.
+func (a *app) build(ctx context.Context, run *agentrun.Run, sessionID, roleID, provider, modelName string, branch *branchPoint) (*chatRun, error) {
+	trace := newRunTrace()
+	if run != nil {
+		trace.ctx = run.Context()
+	}
+	llmProvider, err := a.setupLLM(provider, modelName, trace)
 	if err != nil {
 		return nil, fmt.Errorf("failed to setup LLM: %w", err)
 	}
@@ -302,22 +639,37 @@ func (a *app) Engine(ctx context.Context, sessionID, roleID, provider, modelName
 	if err != nil {
 		return nil, fmt.Errorf("failed to get memory setting: %w", err)
 	}
-	maxHistory := 100
-	if memorySetting != nil && memorySetting.MemoryConfig != nil {
-		if memorySetting.MemoryConfig.Simple.MaxHistoryMessages > 0 {
-			maxHistory = memorySetting.MemoryConfig.Simple.MaxHistoryMessages
-		} else if memorySetting.MemoryConfig.SQLite.MaxHistoryMessages > 0 {
-			maxHistory = memorySetting.MemoryConfig.SQLite.MaxHistoryMessages
-		} else if memorySetting.MemoryConfig.MySQL.MaxHistoryMessages > 0 {
-			maxHistory = memorySetting.MemoryConfig.MySQL.MaxHistoryMessages
-		} else if memorySetting.MemoryConfig.MongoDB.MaxHistoryMessages > 0 {
-			maxHistory = memorySetting.MemoryConfig.MongoDB.MaxHistoryMessages
-		} else if memorySetting.MemoryConfig.Redis.MaxHistoryMessages > 0 {
-			maxHistory = memorySetting.MemoryConfig.Redis.MaxHistoryMessages
+	var memoryConfig *appdto.MemoryConfig
+	if memorySetting != nil {
+		memoryConfig = memorySetting.MemoryConfig
+	}
+	a.loadPrice(ctx, trace, provider, modelName)
+	userID := cctx.GetUserID[string](ctx)
+	trace.onAssistantSaved = func(msg *model.ChatMessage) {
+		a.recordUsage(context.Background(), userID, roleID, provider, modelName, msg)
+		input, _ := trace.inputAttachments()
+		a.generateTitle(sessionID, input, msg)
+		a.linkCalls(context.Background(), sessionID, msg)
+		if msg.Meta == nil || !msg.Meta.Cancelled {
+			a.events.Publish(context.Background(), userID, model.EventMessageCompleted, map[string]interface{}{
+				"session_id": sessionID,
+				"message_id": msg.ID,
+				"role_id":    roleID,
+				"provider":   provider,
+				"model_name": modelName,
+				"content":    msg.Content,
+			})
 		}
 	}
-	memoryProvider := NewDatabaseMemoryProvider(a.mp, sessionID, maxHistory)
-
+	trace.onToolFailed = func(call model.ToolCallTrace) {
+		a.events.Publish(context.Background(), userID, model.EventToolFailed, map[string]interface{}{
+			"session_id":  sessionID,
+			"role_id":     roleID,
+			"tool":        call.Name,
+			"error":       call.Error,
+			"duration_ms": call.DurationMs,
+		})
+	}
 	roleInfo, err := a.roleApp.GetRole(ctx, roleID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get role: %w", err)
@@ -330,31 +682,96 @@ func (a *app) Engine(ctx context.Context, sessionID, roleID, provider, modelName
 
 	systemMessage := a.loadRolePrompt(roleInfo, experiences)
 
+	// parameters set on the session override the defaults of the role
+	var sessionParams *model.ModelParams
+	var team *appdto.Team
+	if sessionID != "" {
+		session, err := a.sp.GetByID(ctx, sessionID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get session: %w", err)
+		}
+		sessionParams = session.ModelParams
+		if team, err = a.sessionTeam(ctx, session); err != nil {
+			return nil, fmt.Errorf("failed to get team: %w", err)
+		}
+	}
+	if team != nil {
+		systemMessage += teamPrompt(team)
+	}
 	agentConfig := types.NewAgentConfig()
+	applyParams(agentConfig, (*model.ModelParams)(roleInfo.ModelParams).Merge(sessionParams))
+	trace.params = usedParams(agentConfig)
 	if systemMessage != "" {
 		agentConfig.SystemMessage = systemMessage
 	}
+	if memoryConfig != nil && memoryConfig.Compress.Enabled {
+		compress := memoryConfig.Compress
+		agentConfig.EnableMemoryCompress = true
+		if compress.Threshold > 0 {
+			agentConfig.MemoryCompressThreshold = compress.Threshold
+		}
+		if compress.Ratio > 0 && compress.Ratio < 1 {
+			agentConfig.MemoryCompressRatio = compress.Ratio
+		}
+	}
 
-	engine := engine.NewAgentEngine(llmProvider, agentConfig)
-	engine.SetMemory(memoryProvider)
+	var runID string
+	if run != nil {
+		runID = run.ID
+	}
+	trace.approval = newApprovalPolicy(roleInfo.ToolConfig, userID, sessionID, roleID, runID)
+	if trace.approval != nil && agentConfig.ToolExecutionTimeout > 0 {
+		// waiting for a decision does not count against the tool timeout
+		agentConfig.ToolExecutionTimeout += ApprovalTimeout
+	}
 
 	// Setup tools from role configuration
-	tools := a.setupTools(ctx, roleID, roleInfo.ToolConfig)
+	tools := a.setupTools(ctx, sessionID, roleID, roleInfo.ToolConfig)
+	if team != nil {
+		tools = append(tools, newTeamTools(ctx, sessionID, a, team)...)
+	}
+	memoryProvider := NewDatabaseMemoryProvider(a.mp, a.sp, sessionID, memoryOptions(memoryConfig, modelName, agentConfig, tools), trace, branch)
+
+	engine := engine.NewAgentEngine(llmProvider, agentConfig)
+	engine.SetMemory(memoryProvider)
 	if len(tools) > 0 {
-		engine.AddTools(tools)
+		engine.AddTools(traceTools(tools, trace))
+	}
+	if run != nil {
+		run.SetEngine(engine)
 	}
 
-	return engine, nil
+	return &chatRun{engine: engine, trace: trace, memory: memoryProvider}, nil
 }
 
-func (a *app) PrepareStreamMessage(ctx context.Context, roleID, provider, modelName, sessionID string, userInput string) (string, *engine.AgentEngine, error) {
+// StreamMessage starts a streamed run of input. The returned channel is
+// closed when the run ends; a cancelled run ends with a "cancelled" result
+// carrying the partial output.
+func (a *app) StreamMessage(ctx context.Context, roleID, provider, modelName, sessionID string, input *appdto.ChatMessageItem, params *appdto.ModelParams) (string, *agentrun.Run, <-chan engine.StreamResult, error) {
 	userID := cctx.GetUserID[string](ctx)
+	userInput := input.Content
+	attachments, err := a.resolveAttachments(ctx, input.Attachments)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	overrides, err := checkParams(params)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	roleID, teamID, err := a.resolveTeam(ctx, roleID)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	provider, modelName, err = a.resolveModel(ctx, roleID, sessionID, provider, modelName)
+	if err != nil {
+		return "", nil, nil, err
+	}
 
 	var finalSessionID string
 	if sessionID == "" {
 		role, err := a.roleApp.GetRole(ctx, roleID)
 		if err != nil {
-			return "", nil, err
+			return "", nil, nil, err
 		}
 
 		var title *string
@@ -364,23 +781,25 @@ func (a *app) PrepareStreamMessage(ctx context.Context, roleID, provider, modelN
 		}
 
 		sessionReq := &appdto.CreateChatSessionReq{
-			RoleID:    roleID,
-			RoleName:  role.Name,
-			Provider:  provider,
-			ModelName: modelName,
-			Title:     title,
+			RoleID:      roleID,
+			RoleName:    role.Name,
+			Provider:    provider,
+			ModelName:   modelName,
+			Title:       title,
+			ModelParams: params,
 		}
-		finalSessionID, err = a.CreateSession(ctx, sessionReq)
+		// the first message is a placeholder until the title is generated
+		finalSessionID, err = a.createSession(ctx, sessionReq, model.ChatTitleSourceAuto, teamID)
 		if err != nil {
-			return "", nil, err
+			return "", nil, nil, err
 		}
 	} else {
 		session, err := a.sp.GetByID(ctx, sessionID)
 		if err != nil {
-			return "", nil, err
+			return "", nil, nil, err
 		}
 		if session.UserID != userID {
-			return "", nil, gorm.ErrRecordNotFound
+			return "", nil, nil, gorm.ErrRecordNotFound
 		}
 		if session.RoleID != roleID || session.Provider != provider || session.ModelName != modelName {
 			// update session role/provider/model
@@ -388,18 +807,35 @@ func (a *app) PrepareStreamMessage(ctx context.Context, roleID, provider, modelN
 			session.Provider = provider
 			session.ModelName = modelName
 			if err := a.sp.Update(ctx, session); err != nil {
-				return "", nil, err
+				return "", nil, nil, err
+			}
+		}
+		if overrides != nil {
+			if err := a.saveSessionParams(ctx, session.ID, overrides); err != nil {
+				return "", nil, nil, err
 			}
 		}
 		finalSessionID = sessionID
 	}
 
-	engine, err := a.Engine(ctx, finalSessionID, roleID, provider, modelName)
+	run, ok := agentrun.Default.Start(finalSessionID, userID)
+	if !ok {
+		return "", nil, nil, errcode.ChatSessionBusy
+	}
+	r, err := a.build(ctx, run, finalSessionID, roleID, provider, modelName, nil)
+	if err != nil {
+		agentrun.Default.Finish(run)
+		return "", nil, nil, fmt.Errorf("failed to create engine: %w", err)
+	}
+	r.trace.setInput(userInput, attachments, attachmentParts(attachments, supportsVision(modelName)))
+	r.streamApprovals(run)
+	stream, err := r.engine.ExecuteStream(userInput, nil)
 	if err != nil {
-		return "", nil, fmt.Errorf("failed to create engine: %w", err)
+		agentrun.Default.Finish(run)
+		return "", nil, nil, fmt.Errorf("failed to execute: %w", err)
 	}
 
-	return finalSessionID, engine, nil
+	return finalSessionID, run, r.forward(run, stream, userInput), nil
 }
 
 func (a *app) loadRolePrompt(roleInfo *appdto.Role, experiences []*appdto.Experience) string {
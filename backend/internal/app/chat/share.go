@@ -0,0 +1,178 @@
+package chat
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/xichan96/cortex-lab/internal/appdto"
+	"github.com/xichan96/cortex-lab/internal/infra/model"
+	"github.com/xichan96/cortex-lab/internal/pkg/errcode"
+	"github.com/xichan96/cortex/agent/types"
+	"gorm.io/gorm"
+)
+
+// redactedValue replaces the value of a sensitive tool argument in a shared session
+const redactedValue = "[redacted]"
+
+// sensitiveArgumentNames are argument names treated as sensitive even when
+// the tool does not mark them, e.g. the password of the ssh tool
+var sensitiveArgumentNames = []string{"password", "passwd", "pwd", "secret", "token", "api_key", "api-key", "apikey", "private_key", "authorization", "credential"}
+
+func isSensitiveName(name string) bool {
+	name = strings.ToLower(name)
+	for _, s := range sensitiveArgumentNames {
+		if strings.Contains(name, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// sensitiveArguments returns the arguments of a call that must not be shared:
+// the properties of the tool schema marked with "sensitive": true and the
+// arguments with a sensitive name
+func sensitiveArguments(tool types.Tool, args map[string]interface{}) []string {
+	var properties map[string]interface{}
+	if schema := tool.Schema(); schema != nil {
+		properties, _ = schema["properties"].(map[string]interface{})
+	}
+	var sensitive []string
+	for name := range args {
+		prop, _ := properties[name].(map[string]interface{})
+		if marked, _ := prop["sensitive"].(bool); marked || isSensitiveName(name) {
+			sensitive = append(sensitive, name)
+		}
+	}
+	slices.Sort(sensitive)
+	return sensitive
+}
+
+// redactMeta returns a copy of meta safe to show on a share link
+func redactMeta(meta *model.MessageMeta) *model.MessageMeta {
+	if meta == nil {
+		return nil
+	}
+	out := *meta
+	out.ToolCalls = make([]model.ToolCallTrace, len(meta.ToolCalls))
+	for i, call := range meta.ToolCalls {
+		call.Arguments = redactArguments(call.Arguments, call.Sensitive)
+		if call.Approval != nil && call.Approval.OriginalArguments != nil {
+			approval := *call.Approval
+			approval.OriginalArguments = redactArguments(approval.OriginalArguments, call.Sensitive)
+			call.Approval = &approval
+		}
+		out.ToolCalls[i] = call
+	}
+	return &out
+}
+
+// redactArguments returns a copy of the arguments of a call without the
+// sensitive ones, the objects nested in an argument are redacted by name
+func redactArguments(arguments map[string]interface{}, sensitive []string) map[string]interface{} {
+	args := make(map[string]interface{}, len(arguments))
+	for name, value := range arguments {
+		if slices.Contains(sensitive, name) || isSensitiveName(name) {
+			value = redactedValue
+		}
+		args[name] = redactValue(value)
+	}
+	return args
+}
+
+func redactValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return redactArguments(v, nil)
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, item := range v {
+			out[i] = redactValue(item)
+		}
+		return out
+	default:
+		return value
+	}
+}
+
+func newShareToken() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// ShareSession creates a read-only share link of a session of the current
+// user. A new link replaces the previous one.
+func (a *app) ShareSession(ctx context.Context, id string, req *appdto.ShareChatSessionReq) (*appdto.ChatShare, error) {
+	session, err := a.ownedSession(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		return nil, errcode.ChatShareExpiresInPast
+	}
+	token, err := newShareToken()
+	if err != nil {
+		return nil, err
+	}
+	if err := a.sp.UpdateShare(ctx, session.ID, &token, req.ExpiresAt); err != nil {
+		return nil, err
+	}
+	return &appdto.ChatShare{Token: token, ExpiresAt: req.ExpiresAt}, nil
+}
+
+// RevokeShare disables the share link of a session of the current user
+func (a *app) RevokeShare(ctx context.Context, id string) error {
+	session, err := a.ownedSession(ctx, id)
+	if err != nil {
+		return err
+	}
+	return a.sp.UpdateShare(ctx, session.ID, nil, nil)
+}
+
+// GetSharedSession returns the active branch of the session shared with
+// token. It is served without authentication.
+func (a *app) GetSharedSession(ctx context.Context, token string) (*appdto.SharedChatSession, error) {
+	if token == "" {
+		return nil, errcode.ChatShareNotFound
+	}
+	session, err := a.sp.GetByShareToken(ctx, token)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errcode.ChatShareNotFound
+		}
+		return nil, err
+	}
+	if session.ShareExpiresAt != nil && time.Now().After(*session.ShareExpiresAt) {
+		return nil, errcode.ChatShareNotFound
+	}
+	leaf, err := currentLeaf(ctx, a.mp, a.sp, session)
+	if err != nil {
+		return nil, err
+	}
+	path, err := activeMessages(ctx, a.mp, session.ID, leaf)
+	if err != nil {
+		return nil, err
+	}
+	for i, m := range path {
+		shared := *m
+		shared.Meta = redactMeta(m.Meta)
+		path[i] = &shared
+	}
+
+	return &appdto.SharedChatSession{
+		Title:     session.Title,
+		RoleName:  session.RoleName,
+		Provider:  session.Provider,
+		ModelName: session.ModelName,
+		CreatedAt: session.CreatedAt,
+		ExpiresAt: session.ShareExpiresAt,
+		Messages:  toMessageDTOs(path),
+	}, nil
+}
@@ -0,0 +1,100 @@
+package chat
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/xichan96/cortex-lab/internal/infra/model"
+	"github.com/xichan96/cortex/agent/types"
+)
+
+// schemaTool is a tool with a fixed schema, it never runs
+type schemaTool struct {
+	types.Tool
+	schema map[string]interface{}
+}
+
+func (t *schemaTool) Schema() map[string]interface{} { return t.schema }
+
+func TestSensitiveArguments(t *testing.T) {
+	tool := &schemaTool{schema: map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"host":       map[string]interface{}{"type": "string"},
+			"passphrase": map[string]interface{}{"type": "string", "sensitive": true},
+		},
+	}}
+	tests := []struct {
+		name string
+		tool types.Tool
+		args map[string]interface{}
+		want []string
+	}{
+		{"marked by the schema", tool, map[string]interface{}{"host": "web", "passphrase": "x"}, []string{"passphrase"}},
+		{"sensitive names", tool, map[string]interface{}{"host": "web", "Password": "x", "github_token": "x", "X-Api-Key": "x"}, []string{"Password", "X-Api-Key", "github_token"}},
+		{"api key", tool, map[string]interface{}{"api_key": "x", "apikey": "x"}, []string{"api_key", "apikey"}},
+		{"no schema", &schemaTool{}, map[string]interface{}{"query": "x", "private_key": "x"}, []string{"private_key"}},
+		{"nothing sensitive", tool, map[string]interface{}{"host": "web"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sensitiveArguments(tt.tool, tt.args); !slices.Equal(got, tt.want) {
+				t.Errorf("sensitiveArguments() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRedactMeta(t *testing.T) {
+	if redactMeta(nil) != nil {
+		t.Errorf("redactMeta(nil) != nil")
+	}
+
+	meta := &model.MessageMeta{ToolCalls: []model.ToolCallTrace{
+		{
+			Name:      "ssh",
+			Arguments: map[string]interface{}{"host": "web", "passphrase": "p1", "password": "p2"},
+			Sensitive: []string{"passphrase"},
+			Approval: &model.ToolApproval{
+				Status:            model.ToolApprovalApproved,
+				OriginalArguments: map[string]interface{}{"host": "db", "passphrase": "p0"},
+			},
+		},
+		{Name: "time", Arguments: map[string]interface{}{"timezone": "UTC"}},
+		{Name: "http", Arguments: map[string]interface{}{
+			"config":  map[string]interface{}{"url": "https://example.com", "api_key": "k1", "auth": map[string]interface{}{"token": "t1"}},
+			"headers": []interface{}{map[string]interface{}{"name": "Accept", "authorization": "Bearer t2"}},
+		}},
+	}}
+	got := redactMeta(meta)
+
+	tests := []struct {
+		name string
+		args map[string]interface{}
+		key  string
+		want interface{}
+	}{
+		{"marked argument", got.ToolCalls[0].Arguments, "passphrase", redactedValue},
+		{"sensitive name", got.ToolCalls[0].Arguments, "password", redactedValue},
+		{"plain argument", got.ToolCalls[0].Arguments, "host", "web"},
+		{"original marked argument", got.ToolCalls[0].Approval.OriginalArguments, "passphrase", redactedValue},
+		{"original plain argument", got.ToolCalls[0].Approval.OriginalArguments, "host", "db"},
+		{"other call", got.ToolCalls[1].Arguments, "timezone", "UTC"},
+		{"nested argument", got.ToolCalls[2].Arguments["config"].(map[string]interface{}), "api_key", redactedValue},
+		{"nested plain argument", got.ToolCalls[2].Arguments["config"].(map[string]interface{}), "url", "https://example.com"},
+		{"deeply nested argument", got.ToolCalls[2].Arguments["config"].(map[string]interface{})["auth"].(map[string]interface{}), "token", redactedValue},
+		{"argument in a list", got.ToolCalls[2].Arguments["headers"].([]interface{})[0].(map[string]interface{}), "authorization", redactedValue},
+		{"plain argument in a list", got.ToolCalls[2].Arguments["headers"].([]interface{})[0].(map[string]interface{}), "name", "Accept"},
+		// the stored meta is left as it is
+		{"source nested argument", meta.ToolCalls[2].Arguments["config"].(map[string]interface{}), "api_key", "k1"},
+		{"source argument", meta.ToolCalls[0].Arguments, "passphrase", "p1"},
+		{"source original argument", meta.ToolCalls[0].Approval.OriginalArguments, "passphrase", "p0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.args[tt.key] != tt.want {
+				t.Errorf("%s = %v, want %v", tt.key, tt.args[tt.key], tt.want)
+			}
+		})
+	}
+}
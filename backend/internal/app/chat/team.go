@@ -0,0 +1,164 @@
+package chat
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/xichan96/cortex-lab/internal/appdto"
+	"github.com/xichan96/cortex-lab/internal/infra/model"
+	"github.com/xichan96/cortex-lab/pkg/web/cctx"
+	"github.com/xichan96/cortex/agent/types"
+	"gorm.io/gorm"
+)
+
+// teamToolPrefix starts the names of the tools the supervisor of a team
+// delegates to its members with
+const teamToolPrefix = "ask_"
+
+// maxMemberToolName caps the part of a tool name taken from the name of a member
+const maxMemberToolName = 40
+
+var toolNameInvalid = regexp.MustCompile(`[^a-z0-9_]+`)
+
+// resolveTeam returns the supervisor role when id is a team visible to the
+// current user, chatting with a team is chatting with its supervisor
+func (a *app) resolveTeam(ctx context.Context, id string) (string, *string, error) {
+	team, err := a.teamApp.GetTeam(ctx, id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return id, nil, nil
+	}
+	if err != nil {
+		return "", nil, err
+	}
+	return team.SupervisorRoleID, &team.ID, nil
+}
+
+// sessionTeam loads the team of a session, nil when the session is not with a
+// team or the team was deleted since
+func (a *app) sessionTeam(ctx context.Context, session *model.ChatSession) (*appdto.Team, error) {
+	if session.TeamID == nil {
+		return nil, nil
+	}
+	team, err := a.teamApp.GetTeam(ctx, *session.TeamID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return team, err
+}
+
+// teamPrompt tells the supervisor of a team how to work with its members
+func teamPrompt(team *appdto.Team) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "\n\nYou are the supervisor of the team %q.", team.Name)
+	if team.Description != "" {
+		sb.WriteString(" " + team.Description)
+	}
+	sb.WriteString("\nSplit the request into subtasks and delegate each one to the member best suited for it with the " +
+		teamToolPrefix + "* tools. A member only sees the task you send, include all the context it needs. " +
+		"Review the results of the members and combine them into a single answer. Handle a request yourself when no member fits it.\nMembers:\n")
+	for _, m := range team.Members {
+		fmt.Fprintf(&sb, "- %s: %s\n", m.Name, m.Description)
+	}
+	return sb.String()
+}
+
+// newTeamTools gives the supervisor of a team one tool per member
+func newTeamTools(ctx context.Context, sessionID string, app *app, team *appdto.Team) []types.Tool {
+	userID := cctx.GetUserID[string](ctx)
+	used := make(map[string]bool, len(team.Members))
+	tools := make([]types.Tool, 0, len(team.Members))
+	for i, m := range team.Members {
+		name := strings.Trim(toolNameInvalid.ReplaceAllString(strings.ToLower(m.Name), "_"), "_")
+		if len(name) > maxMemberToolName {
+			name = name[:maxMemberToolName]
+		}
+		if name == "" || used[name] {
+			name = fmt.Sprintf("member_%d", i+1)
+		}
+		used[name] = true
+		tools = append(tools, &TeamMemberTool{
+			ctx:          ctx,
+			userID:       userID,
+			sessionID:    sessionID,
+			supervisorID: team.SupervisorRoleID,
+			name:         teamToolPrefix + name,
+			member:       m,
+			app:          app,
+		})
+	}
+	return tools
+}
+
+// TeamMemberTool allows the supervisor of a team to delegate a subtask to a
+// member and wait for its result
+type TeamMemberTool struct {
+	ctx          context.Context
+	userID       string
+	sessionID    string
+	supervisorID string
+	name         string
+	member       *appdto.TeamMember
+	app          *app
+}
+
+func (t *TeamMemberTool) Name() string {
+	return t.name
+}
+
+func (t *TeamMemberTool) Description() string {
+	desc := fmt.Sprintf("Delegate a subtask to %s, a member of your team, and get its result.", t.member.Name)
+	if t.member.Description != "" {
+		desc += " " + t.member.Name + ": " + t.member.Description
+	}
+	return desc
+}
+
+func (t *TeamMemberTool) Schema() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"task": map[string]any{
+				"type":        "string",
+				"description": "The subtask with all the context the member needs, the member does not see this conversation",
+			},
+		},
+		"required": []string{"task"},
+	}
+}
+
+func (t *TeamMemberTool) Execute(args map[string]any) (any, error) {
+	task, _ := args["task"].(string)
+	if task == "" {
+		return nil, fmt.Errorf("task is required")
+	}
+
+	ctx := cctx.WithContext(t.ctx)
+	cctx.SetUserID(ctx, t.userID)
+
+	d, err := t.app.delegate(ctx, t.sessionID, t.supervisorID, t.member.RoleID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to delegate to %s: %w", t.member.Name, err)
+	}
+	req := &appdto.SendChatMessageReq{
+		Messages: []appdto.ChatMessageItem{
+			{Role: "user", Content: task},
+		},
+	}
+	_, reply, err := t.app.callRole(ctx, t.member.RoleID, d, req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to delegate to %s: %w", t.member.Name, err)
+	}
+	if reply == "" {
+		return fmt.Sprintf("%s returned no result", t.member.Name), nil
+	}
+	return reply, nil
+}
+
+func (t *TeamMemberTool) Metadata() types.ToolMetadata {
+	return types.ToolMetadata{
+		ToolType: "builtin",
+	}
+}
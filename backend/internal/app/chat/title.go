@@ -0,0 +1,124 @@
+package chat
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/xichan96/cortex-lab/internal/infra/model"
+	"github.com/xichan96/cortex/agent/types"
+	"gorm.io/gorm"
+)
+
+// titleTimeout bounds the background call that names a session
+const titleTimeout = 30 * time.Second
+
+// maxTitleContext caps how much of each side of the first exchange is sent to the title model
+const maxTitleContext = 2000
+
+const titlePrompt = "Write a short title for the following conversation, in the language of the user. " +
+	"Use at most 8 words, no quotes and no trailing punctuation. Reply with the title only."
+
+// titling holds the sessions whose title is being generated
+var titling sync.Map
+
+// generateTitle names a session from its first exchange in the background, so
+// the reply is not delayed. Only sessions still titled with their first
+// message are renamed, titles set by the user are never overwritten.
+func (a *app) generateTitle(sessionID, input string, reply *model.ChatMessage) {
+	if strings.TrimSpace(input) == "" {
+		return
+	}
+	if _, busy := titling.LoadOrStore(sessionID, struct{}{}); busy {
+		return
+	}
+	go func() {
+		defer titling.Delete(sessionID)
+		ctx, cancel := context.WithTimeout(context.Background(), titleTimeout)
+		defer cancel()
+		if err := a.summarizeTitle(ctx, sessionID, input, reply.Content); err != nil {
+			slog.Warn("Failed to generate session title", "error", err, "session_id", sessionID)
+		}
+	}()
+}
+
+func (a *app) summarizeTitle(ctx context.Context, sessionID, input, output string) error {
+	session, err := a.sp.GetByID(ctx, sessionID)
+	if err != nil {
+		return err
+	}
+	if session.TitleSource != model.ChatTitleSourceAuto {
+		return nil
+	}
+	chatLLMSetting, err := a.settingSrv.GetChatLLMSetting(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get Chat LLM setting: %w", err)
+	}
+	if chatLLMSetting == nil || chatLLMSetting.ChatLLMConfig == nil {
+		return nil
+	}
+	cfg := chatLLMSetting.Title
+	if cfg.Disabled {
+		return nil
+	}
+	provider, modelName := session.Provider, session.ModelName
+	if cfg.Provider != "" {
+		provider, modelName = cfg.Provider, cfg.Model
+	} else if cfg.Model != "" {
+		modelName = cfg.Model
+	}
+
+	trace := newRunTrace()
+	trace.ctx = ctx
+	llm, err := a.setupLLM(provider, modelName, trace)
+	if err != nil {
+		return fmt.Errorf("failed to setup LLM: %w", err)
+	}
+	reply, err := llm.Chat([]types.Message{
+		{
+			Role:    "system",
+			Content: titlePrompt,
+		},
+		{
+			Role:    "user",
+			Content: fmt.Sprintf("User: %s\n\nAssistant: %s", clip(input, maxTitleContext), clip(output, maxTitleContext)),
+		},
+	})
+	if err != nil {
+		return err
+	}
+	title := cleanTitle(reply.Content)
+	if title == "" {
+		return nil
+	}
+	// the user may have renamed the session in the meantime
+	_, err = a.sp.UpdateTitle(ctx, sessionID, title, model.ChatTitleSourceModel, func(db *gorm.DB) *gorm.DB {
+		return db.Where("title_source = ?", model.ChatTitleSourceAuto)
+	})
+	return err
+}
+
+// cleanTitle keeps the first line of a model reply without quotes, labels and trailing punctuation
+func cleanTitle(reply string) string {
+	title := strings.TrimSpace(reply)
+	if i := strings.IndexByte(title, '\n'); i >= 0 {
+		title = title[:i]
+	}
+	for _, label := range []string{"Title:", "title:", "标题：", "标题:"} {
+		title = strings.TrimPrefix(title, label)
+	}
+	title = strings.Trim(title, " \t*#\"'`“”‘’「」《》")
+	title = strings.TrimRight(title, ".。!！,，;；:：")
+	return truncateTitle(strings.TrimSpace(title))
+}
+
+func clip(text string, limit int) string {
+	runes := []rune(text)
+	if len(runes) <= limit {
+		return text
+	}
+	return string(runes[:limit]) + "..."
+}
@@ -7,6 +7,7 @@ import (
 
 	"github.com/xichan96/cortex-lab/internal/appdto"
 	"github.com/xichan96/cortex-lab/internal/pkg/email"
+	"github.com/xichan96/cortex-lab/internal/pkg/sandbox"
 	"github.com/xichan96/cortex-lab/pkg/web/cctx"
 	"github.com/xichan96/cortex/agent/tools/builtin"
 	"github.com/xichan96/cortex/agent/types"
@@ -14,14 +15,14 @@ import (
 	"github.com/xichan96/cortex/pkg/mcp"
 )
 
-func (a *app) setupTools(ctx context.Context, roleID string, config *appdto.RoleToolConfig) []types.Tool {
+func (a *app) setupTools(ctx context.Context, sessionID, roleID string, config *appdto.RoleToolConfig) []types.Tool {
 	var tools []types.Tool
 
 	// Experience tools are enabled by default
 	userID := cctx.GetUserID[string](ctx)
 	tools = append(tools,
-		NewCreateExperienceTool(ctx, userID, roleID, a.knowledgeApp),
-		NewUpdateExperienceTool(ctx, userID, a.knowledgeApp),
+		NewCreateExperienceTool(ctx, userID, roleID, a.knowledgeApp, a.events),
+		NewUpdateExperienceTool(ctx, userID, roleID, a.knowledgeApp, a.events),
 		NewDeleteExperienceTool(ctx, userID, a.knowledgeApp),
 		NewGetExperienceTool(ctx, userID, a.knowledgeApp),
 		NewSearchExperienceTool(ctx, userID, roleID, a.knowledgeApp),
@@ -49,15 +50,20 @@ func (a *app) setupTools(ctx context.Context, roleID string, config *appdto.Role
 				slog.Warn("send_email tool enabled but no config provided")
 			}
 		case "command":
-			tools = append(tools, builtin.NewCommandTool())
+			tools = append(tools, sandbox.NewCommandTool((*sandbox.Policy)(config.Sandbox)))
 		case "file":
-			tools = append(tools, builtin.NewFileTool())
+			tools = append(tools, sandbox.NewFileTool((*sandbox.Policy)(config.Sandbox)))
 		case "math_calculate":
 			tools = append(tools, builtin.NewMathTool())
 		case "net_check":
 			tools = append(tools, builtin.NewPingTool())
 		case "ssh":
-			tools = append(tools, builtin.NewSSHTool())
+			// the hosts and their credentials come from the inventory, never from the model
+			if tool := a.sshHosts.Tool(ctx, roleID); tool != nil {
+				tools = append(tools, tool)
+			} else {
+				slog.Warn("ssh tool enabled but no SSH host granted to the role", "role_id", roleID)
+			}
 		case "get_time":
 			tools = append(tools, builtin.NewTimeTool())
 		}
@@ -70,7 +76,7 @@ func (a *app) setupTools(ctx context.Context, roleID string, config *appdto.Role
 		allowedRoleIDs = append(allowedRoleIDs, note.TargetRoleIDs...)
 	}
 	if len(allowedRoleIDs) > 0 {
-		tools = append(tools, NewNotifyRoleTool(ctx, userID, a, allowedRoleIDs))
+		tools = append(tools, NewNotifyRoleTool(ctx, userID, sessionID, roleID, a, allowedRoleIDs))
 	}
 
 	// Human notifications
@@ -112,6 +118,9 @@ func (a *app) setupTools(ctx context.Context, roleID string, config *appdto.Role
 		if len(allTools) == 0 {
 			continue
 		}
+		for i, t := range allTools {
+			allTools[i] = &mcpTool{Tool: t, client: client}
+		}
 
 		// If specific tools are listed, only add those; otherwise add all tools
 		if len(mcpCfg.Tools) > 0 {
@@ -0,0 +1,72 @@
+package chat
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/xichan96/cortex/agent/types"
+	"github.com/xichan96/cortex/pkg/mcp"
+)
+
+// errRunCancelled is returned by tools invoked after the run was cancelled
+var errRunCancelled = fmt.Errorf("run cancelled")
+
+// contextTool is implemented by tools that abort their work when ctx is cancelled.
+// The cortex Tool interface has no context, so long-running tools are wrapped
+// here to stop when the client cancels the run.
+type contextTool interface {
+	ExecuteContext(ctx context.Context, input map[string]interface{}) (interface{}, error)
+}
+
+// executeTool runs tool bound to ctx. Tools that cannot be cancelled keep
+// running in the background but their result is dropped.
+func executeTool(ctx context.Context, tool types.Tool, input map[string]interface{}) (interface{}, error) {
+	if ctx.Err() != nil {
+		return nil, errRunCancelled
+	}
+	if ct, ok := tool.(contextTool); ok {
+		result, err := ct.ExecuteContext(ctx, input)
+		if ctx.Err() != nil {
+			return nil, errRunCancelled
+		}
+		return result, err
+	}
+
+	type outcome struct {
+		result interface{}
+		err    error
+	}
+	done := make(chan outcome, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- outcome{err: fmt.Errorf("tool execution panic: %v", r)}
+			}
+		}()
+		result, err := tool.Execute(input)
+		done <- outcome{result: result, err: err}
+	}()
+	select {
+	case o := <-done:
+		return o.result, o.err
+	case <-ctx.Done():
+		return nil, errRunCancelled
+	}
+}
+
+// mcpTool calls an MCP tool with the run context so the request is aborted on cancel
+type mcpTool struct {
+	types.Tool
+	client *mcp.Client
+}
+
+func (t *mcpTool) Execute(input map[string]interface{}) (interface{}, error) {
+	return t.ExecuteContext(context.Background(), input)
+}
+
+func (t *mcpTool) ExecuteContext(ctx context.Context, input map[string]interface{}) (interface{}, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	return t.client.CallTool(ctx, t.Name(), input)
+}
@@ -10,24 +10,30 @@ import (
 	"github.com/xichan96/cortex/agent/types"
 )
 
-// NotifyRoleTool allows an agent to send a message to another role
+// NotifyRoleTool allows an agent to send a message to another role. The call
+// either waits for the reply or, in async mode, returns at once and the reply
+// is delivered to the session of the caller later.
 type NotifyRoleTool struct {
-	ctx     context.Context
-	userID  string
-	app     AppIer
-	allowed map[string]bool
+	ctx       context.Context
+	userID    string
+	sessionID string
+	roleID    string
+	app       *app
+	allowed   map[string]bool
 }
 
-func NewNotifyRoleTool(ctx context.Context, userID string, app AppIer, allowedRoleIDs []string) *NotifyRoleTool {
+func NewNotifyRoleTool(ctx context.Context, userID, sessionID, roleID string, app *app, allowedRoleIDs []string) *NotifyRoleTool {
 	allowed := make(map[string]bool)
 	for _, id := range allowedRoleIDs {
 		allowed[id] = true
 	}
 	return &NotifyRoleTool{
-		ctx:     ctx,
-		userID:  userID,
-		app:     app,
-		allowed: allowed,
+		ctx:       ctx,
+		userID:    userID,
+		sessionID: sessionID,
+		roleID:    roleID,
+		app:       app,
+		allowed:   allowed,
 	}
 }
 
@@ -36,7 +42,8 @@ func (t *NotifyRoleTool) Name() string {
 }
 
 func (t *NotifyRoleTool) Description() string {
-	return "Send a message/notification to another AI role. Use this when you need to consult or notify another role."
+	return "Send a message/notification to another AI role. Use this when you need to consult or notify another role. " +
+		"Set async to true to continue without waiting, the reply is then added to this conversation when it is ready."
 }
 
 func (t *NotifyRoleTool) Schema() map[string]any {
@@ -51,6 +58,10 @@ func (t *NotifyRoleTool) Schema() map[string]any {
 				"type":        "string",
 				"description": "The message content to send",
 			},
+			"async": map[string]any{
+				"type":        "boolean",
+				"description": "Return at once instead of waiting for the reply of the role",
+			},
 		},
 		"required": []string{"target_role_id", "content"},
 	}
@@ -59,55 +70,48 @@ func (t *NotifyRoleTool) Schema() map[string]any {
 func (t *NotifyRoleTool) Execute(args map[string]any) (any, error) {
 	targetID, _ := args["target_role_id"].(string)
 	content, _ := args["content"].(string)
+	async, _ := args["async"].(bool)
 
 	if targetID == "" || content == "" {
 		return nil, fmt.Errorf("target_role_id and content are required")
 	}
 
 	if !t.allowed[targetID] {
-		// return "", fmt.Errorf("permission denied: not allowed to notify role %s", targetID)
-		// For now, allow notifying any role if the ID is known?
-		// The requirement implies "Configured" notifications.
-		// If I enforce allowed list, the model can only notify configured roles.
-		// If the user selects roles in UI, they expect only those to be notified.
 		return nil, fmt.Errorf("permission denied: role %s is not in the notification list", targetID)
 	}
 
-	// Create context with UserID as the tool execution happens in background or async
-	// But here we share the context?
-	// We need to ensure UserID is in context for CreateSession/SendMessage
+	// the tool runs outside of the request, the user is set again for the called role
 	ctx := cctx.WithContext(t.ctx)
 	cctx.SetUserID(ctx, t.userID)
 
-	// Send message to target role
-	// We assume a default provider/model for the target role since we don't know it.
-	// TODO: Make this configurable or fetch from target role's preferences if available.
-	// For now, hardcode "openai/gpt-4o" or similar generic one.
-	provider := "openai"
-	modelName := "gpt-4o"
-
+	// cycles, the depth and the deadline of the call tree are checked before
+	// anything runs, the target role runs on its preferred model, see resolveModel
+	d, err := t.app.delegate(ctx, t.sessionID, t.roleID, targetID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send message to role: %w", err)
+	}
 	req := &appdto.SendChatMessageReq{
 		Messages: []appdto.ChatMessageItem{
 			{Role: "user", Content: content},
 		},
 	}
 
-	// We don't have a sessionID for this interaction yet.
-	// SendMessage will create one.
-	// Note: This is a synchronous call. It might take time.
-	// For "Notification", maybe we don't need the reply?
-	// But usually we want the result.
-	_, responseMsgs, err := t.app.SendMessage(ctx, targetID, provider, modelName, "", req)
+	if async {
+		if t.sessionID == "" {
+			return nil, fmt.Errorf("async mode needs a chat session to deliver the reply to")
+		}
+		go t.app.callRoleAsync(t.userID, t.sessionID, targetID, d, req)
+		return fmt.Sprintf("Message sent to role %s, its reply will be added to this conversation when it is ready", targetID), nil
+	}
+
+	_, reply, err := t.app.callRole(ctx, targetID, d, req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to send message to role: %w", err)
 	}
-
-	if len(responseMsgs) > 0 {
-		lastMsg := responseMsgs[len(responseMsgs)-1]
-		return lastMsg.Content, nil
+	if reply == "" {
+		return "Message sent, but no response received", nil
 	}
-
-	return "Message sent, but no response received", nil
+	return reply, nil
 }
 
 func (t *NotifyRoleTool) Metadata() types.ToolMetadata {
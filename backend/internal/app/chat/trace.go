@@ -0,0 +1,256 @@
+package chat
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/xichan96/cortex-lab/internal/appdto"
+	"github.com/xichan96/cortex-lab/internal/infra/model"
+	"github.com/xichan96/cortex/agent/types"
+)
+
+// maxObservationLength caps how much of a tool result is kept in the trace
+const maxObservationLength = 8192
+
+// runTrace collects what happens during a single agent run so it can be
+// persisted on the assistant message produced by that run.
+type runTrace struct {
+	mu        sync.Mutex
+	toolCalls []model.ToolCallTrace
+	usage     model.TokenUsage
+	price     *appdto.ModelPrice
+	currency  string
+	saved     []*model.ChatMessage
+	// partial is the text streamed so far, kept as the reply when the run is cancelled
+	partial   strings.Builder
+	cancelled bool
+	// ctx is cancelled when the client cancels the run, nil when the run cannot be cancelled
+	ctx context.Context
+	// input is the text of the run input, inputParts render its attachments for the model
+	input       string
+	attachments []model.AttachmentRef
+	inputParts  []types.MessagePart
+	// onAssistantSaved is called after the assistant message of the run is persisted
+	onAssistantSaved func(msg *model.ChatMessage)
+	// onToolFailed is called after a tool call of the run returned an error
+	onToolFailed func(call model.ToolCallTrace)
+	// params are the model parameters of the run, recorded on the reply
+	params *model.ModelParams
+	// approval holds the tools whose calls wait for the user, nil when none does
+	approval *approvalPolicy
+}
+
+func newRunTrace() *runTrace {
+	return &runTrace{}
+}
+
+// context returns the context LLM calls and tools of the run are bound to
+func (t *runTrace) context() context.Context {
+	if t.ctx == nil {
+		return context.Background()
+	}
+	return t.ctx
+}
+
+// setInput records the input of the run with its attachments
+func (t *runTrace) setInput(input string, attachments []model.AttachmentRef, parts []types.MessagePart) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.input = input
+	t.attachments = attachments
+	t.inputParts = parts
+}
+
+// inputAttachments returns the run input and the parts its attachments are sent as
+func (t *runTrace) inputAttachments() (string, []types.MessagePart) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.input, t.inputParts
+}
+
+// inputMeta builds the meta stored on the user message, nil when there is nothing to store
+func (t *runTrace) inputMeta() *model.MessageMeta {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.attachments) == 0 {
+		return nil
+	}
+	return &model.MessageMeta{Attachments: t.attachments}
+}
+
+func (t *runTrace) appendPartial(chunk string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.partial.WriteString(chunk)
+}
+
+func (t *runTrace) partialOutput() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.partial.String()
+}
+
+// markCancelled flags the assistant message of the run as cancelled
+func (t *runTrace) markCancelled() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.cancelled = true
+}
+
+func (t *runTrace) recordToolCall(call model.ToolCallTrace) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.toolCalls = append(t.toolCalls, call)
+}
+
+// setPrice sets the price used to compute the cost of the run, nil when the model has no price
+func (t *runTrace) setPrice(price *appdto.ModelPrice, currency string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.price = price
+	t.currency = currency
+}
+
+// addUsage accumulates the tokens of one LLM call, a run usually makes several
+func (t *runTrace) addUsage(prompt, completion int, estimated bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.usage.PromptTokens += prompt
+	t.usage.CompletionTokens += completion
+	t.usage.TotalTokens += prompt + completion
+	t.usage.Estimated = t.usage.Estimated || estimated
+}
+
+// assistantMeta builds the meta stored on the assistant message, nil when there is nothing to store
+func (t *runTrace) assistantMeta() *model.MessageMeta {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.toolCalls) == 0 && t.usage.TotalTokens == 0 && !t.cancelled && t.params == nil {
+		return nil
+	}
+	meta := &model.MessageMeta{Cancelled: t.cancelled, Params: t.params}
+	if len(t.toolCalls) > 0 {
+		meta.ToolCalls = make([]model.ToolCallTrace, len(t.toolCalls))
+		copy(meta.ToolCalls, t.toolCalls)
+	}
+	if t.usage.TotalTokens > 0 {
+		usage := t.usage
+		if t.price != nil {
+			usage.Cost = tokenCost(usage.PromptTokens, usage.CompletionTokens, t.price)
+			usage.Currency = t.currency
+		}
+		total := usage.TotalTokens
+		meta.Usage = &usage
+		meta.TokenCount = &total
+	}
+	return meta
+}
+
+func (t *runTrace) addSaved(msg *model.ChatMessage) {
+	t.mu.Lock()
+	t.saved = append(t.saved, msg)
+	hook := t.onAssistantSaved
+	t.mu.Unlock()
+	if hook != nil && msg.Role == "assistant" {
+		hook(msg)
+	}
+}
+
+// savedMessages returns the messages persisted by the memory provider during the run
+func (t *runTrace) savedMessages() []*model.ChatMessage {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	out := make([]*model.ChatMessage, len(t.saved))
+	copy(out, t.saved)
+	return out
+}
+
+// tracedTool records every invocation of the wrapped tool on a run trace.
+// The engine only reports the tool calls of its last iteration, so the
+// wrapper is the only place that sees every call of a multi-step run.
+type tracedTool struct {
+	types.Tool
+	trace *runTrace
+}
+
+func traceTools(tools []types.Tool, trace *runTrace) []types.Tool {
+	out := make([]types.Tool, len(tools))
+	for i, t := range tools {
+		out[i] = &tracedTool{Tool: t, trace: trace}
+	}
+	return out
+}
+
+func (t *tracedTool) Execute(input map[string]interface{}) (interface{}, error) {
+	var approval *model.ToolApproval
+	if t.trace.approval.requires(t.Name()) {
+		var err error
+		input, approval, err = t.trace.approval.await(t.trace.context(), t.Tool, input)
+		if err != nil {
+			t.record(input, approval, time.Now(), nil, err)
+			return nil, err
+		}
+	}
+	args := make(map[string]interface{}, len(input))
+	for k, v := range input {
+		args[k] = v
+	}
+
+	start := time.Now()
+	result, err := executeTool(t.trace.context(), t.Tool, input)
+	t.record(args, approval, start, result, err)
+
+	return result, err
+}
+
+// record adds a call to the trace, a call that never ran has no duration
+func (t *tracedTool) record(args map[string]interface{}, approval *model.ToolApproval, start time.Time, result interface{}, err error) {
+	call := model.ToolCallTrace{
+		Name:       t.Name(),
+		Arguments:  args,
+		Sensitive:  sensitiveArguments(t.Tool, args),
+		DurationMs: time.Since(start).Milliseconds(),
+		StartedAt:  start,
+		Approval:   approval,
+	}
+	if err != nil {
+		call.Error = err.Error()
+	} else {
+		call.Observation = formatObservation(result)
+	}
+	t.trace.recordToolCall(call)
+	if err != nil && t.trace.onToolFailed != nil {
+		t.trace.onToolFailed(call)
+	}
+}
+
+func formatObservation(result interface{}) string {
+	if result == nil {
+		return ""
+	}
+	var s string
+	switch v := result.(type) {
+	case string:
+		s = v
+	default:
+		if b, err := json.Marshal(v); err == nil {
+			s = string(b)
+		} else {
+			s = fmt.Sprintf("%v", v)
+		}
+	}
+	if len(s) > maxObservationLength {
+		// back off to the start of a rune so the cut never splits a character
+		cut := maxObservationLength
+		for cut > 0 && !utf8.RuneStart(s[cut]) {
+			cut--
+		}
+		s = s[:cut] + "..."
+	}
+	return s
+}
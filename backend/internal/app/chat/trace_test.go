@@ -0,0 +1,35 @@
+package chat
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestFormatObservation(t *testing.T) {
+	tests := []struct {
+		name   string
+		result interface{}
+		want   string
+	}{
+		{"nil", nil, ""},
+		{"string", "done", "done"},
+		{"json", map[string]interface{}{"ok": true}, `{"ok":true}`},
+		{"at the limit", strings.Repeat("a", maxObservationLength), strings.Repeat("a", maxObservationLength)},
+		{"ascii over the limit", strings.Repeat("a", maxObservationLength+1), strings.Repeat("a", maxObservationLength) + "..."},
+		// 3-byte runes, the limit falls in the middle of one
+		{"multibyte over the limit", strings.Repeat("界", maxObservationLength), strings.Repeat("界", maxObservationLength/3) + "..."},
+		{"rune ending at the limit", strings.Repeat("a", maxObservationLength-3) + "界界", strings.Repeat("a", maxObservationLength-3) + "界..."},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := formatObservation(tt.result)
+			if got != tt.want {
+				t.Errorf("formatObservation() = %d bytes, want %d bytes", len(got), len(tt.want))
+			}
+			if !utf8.ValidString(got) {
+				t.Errorf("formatObservation() returned invalid UTF-8")
+			}
+		})
+	}
+}
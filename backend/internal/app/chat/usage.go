@@ -0,0 +1,75 @@
+package chat
+
+import (
+	"context"
+	"log/slog"
+	"unicode"
+
+	"github.com/xichan96/cortex-lab/internal/appdto"
+	"github.com/xichan96/cortex-lab/internal/infra/model"
+)
+
+// estimateTokens approximates the token count of text when the provider does
+// not report usage: about four characters per token for latin text and one
+// token per CJK character.
+func estimateTokens(text string) int {
+	if text == "" {
+		return 0
+	}
+	var cjk, other int
+	for _, r := range text {
+		if unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul) {
+			cjk++
+		} else {
+			other++
+		}
+	}
+	return cjk + (other+3)/4
+}
+
+// tokenCost computes the cost of the tokens, prices are per one million tokens
+func tokenCost(prompt, completion int, price *appdto.ModelPrice) float64 {
+	if price == nil {
+		return 0
+	}
+	return (float64(prompt)*price.PromptPrice + float64(completion)*price.CompletionPrice) / 1e6
+}
+
+// loadPrice looks up the configured price of provider/model for the run trace
+func (a *app) loadPrice(ctx context.Context, trace *runTrace, provider, modelName string) {
+	pricing, err := a.settingSrv.GetPricingSetting(ctx)
+	if err != nil {
+		slog.Warn("Failed to get pricing setting", "error", err)
+		return
+	}
+	if pricing == nil || pricing.PricingConfig == nil {
+		return
+	}
+	if price := pricing.Price(provider, modelName); price != nil {
+		trace.setPrice(price, pricing.Currency)
+	}
+}
+
+// recordUsage stores the usage of an assistant message so it can be aggregated later
+func (a *app) recordUsage(ctx context.Context, userID, roleID, provider, modelName string, msg *model.ChatMessage) {
+	if msg.Meta == nil || msg.Meta.Usage == nil {
+		return
+	}
+	usage := msg.Meta.Usage
+	record := &model.UsageRecord{
+		UserID:           userID,
+		SessionID:        msg.SessionID,
+		MessageID:        msg.ID,
+		RoleID:           roleID,
+		Provider:         provider,
+		ModelName:        modelName,
+		PromptTokens:     usage.PromptTokens,
+		CompletionTokens: usage.CompletionTokens,
+		TotalTokens:      usage.TotalTokens,
+		Cost:             usage.Cost,
+		Estimated:        usage.Estimated,
+	}
+	if _, err := a.up.Create(ctx, record); err != nil {
+		slog.Error("Failed to record usage", "error", err, "session_id", msg.SessionID, "message_id", msg.ID)
+	}
+}
@@ -0,0 +1,289 @@
+package event
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/xichan96/cortex-lab/internal/infra/model"
+	"github.com/xichan96/cortex-lab/internal/pkg/secret"
+	"github.com/xichan96/cortex-lab/pkg/std/snowflake"
+	"gorm.io/gorm"
+)
+
+const (
+	// dispatchInterval is how often the due deliveries are looked up when no event wakes the dispatcher
+	dispatchInterval = 5 * time.Second
+	// maxDueDeliveries caps the deliveries attempted by one dispatch, the rest wait for the next one
+	maxDueDeliveries = 100
+	// maxAttempts is how many times a delivery is attempted before it fails
+	maxAttempts = 6
+	// retryBackoff is the wait after the first failed attempt, doubled after every other one
+	retryBackoff = 30 * time.Second
+	// claimLease is when a claimed delivery is attempted again if its attempt never records an outcome
+	claimLease = 2 * time.Minute
+)
+
+// deliveryClient only reaches public addresses and does not follow redirects,
+// a subscription cannot make the server call its own network. The addresses
+// are checked once resolved, a host name cannot point to a private one.
+var deliveryClient = &http.Client{
+	Timeout: 15 * time.Second,
+	Transport: &http.Transport{
+		DialContext: (&net.Dialer{
+			Timeout: 10 * time.Second,
+			Control: func(network, address string, _ syscall.RawConn) error {
+				host, _, err := net.SplitHostPort(address)
+				if err != nil {
+					return err
+				}
+				ip, err := netip.ParseAddr(host)
+				if err != nil {
+					return err
+				}
+				if !publicAddr(ip) {
+					return fmt.Errorf("%s is not a public address", ip)
+				}
+				return nil
+			},
+		}).DialContext,
+		TLSHandshakeTimeout: 10 * time.Second,
+		MaxIdleConnsPerHost: 4,
+		IdleConnTimeout:     90 * time.Second,
+	},
+	CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}
+
+// internalPrefixes are the ranges the netip predicates miss: "this network",
+// which reaches the host itself, and the carrier-grade NAT range
+var internalPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+}
+
+// publicAddr reports whether ip may receive deliveries, the loopback,
+// private, link-local (cloud metadata included) and unspecified addresses
+// may not
+func publicAddr(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	if !ip.IsValid() || ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() {
+		return false
+	}
+	for _, prefix := range internalPrefixes {
+		if prefix.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// wake starts a dispatch without waiting for the next tick. It is shared by
+// every instance of the app since the events are published on other
+// instances than the one running the dispatcher.
+var wake = make(chan struct{}, 1)
+
+// envelope is the body posted for an event
+type envelope struct {
+	ID        string    `json:"id"`
+	Event     string    `json:"event"`
+	UserID    string    `json:"user_id"`
+	CreatedAt time.Time `json:"created_at"`
+	Data      any       `json:"data"`
+}
+
+func (a *app) Publish(ctx context.Context, userID, event string, data any) {
+	if userID == "" {
+		return
+	}
+	// the event outlives the request that raised it
+	ctx = context.WithoutCancel(ctx)
+	subscriptions, err := a.sp.GetList(ctx, func(db *gorm.DB) *gorm.DB {
+		return db.Where("user_id = ? AND enabled = ?", userID, 1)
+	})
+	if err != nil {
+		slog.Error("Failed to load event subscriptions", "error", err, "event", event, "user_id", userID)
+		return
+	}
+	var targets []*model.EventSubscription
+	for _, s := range subscriptions {
+		if s.Events.Has(event) {
+			targets = append(targets, s)
+		}
+	}
+	if len(targets) == 0 {
+		return
+	}
+
+	now := time.Now().UTC()
+	eventID := snowflake.NewUUID()
+	payload, err := json.Marshal(&envelope{ID: eventID, Event: event, UserID: userID, CreatedAt: now, Data: data})
+	if err != nil {
+		slog.Error("Failed to encode event", "error", err, "event", event)
+		return
+	}
+	for _, s := range targets {
+		delivery := &model.EventDelivery{
+			SubscriptionID: s.ID,
+			UserID:         userID,
+			EventID:        eventID,
+			Event:          event,
+			Payload:        string(payload),
+			Status:         model.EventDeliveryPending,
+			NextAttemptAt:  &now,
+		}
+		if _, err := a.dp.Create(ctx, delivery); err != nil {
+			slog.Error("Failed to queue event delivery", "error", err, "event", event, "subscription_id", s.ID)
+		}
+	}
+	a.notify()
+}
+
+// notify wakes the dispatcher, a dispatch already pending covers the new deliveries
+func (a *app) notify() {
+	select {
+	case wake <- struct{}{}:
+	default:
+	}
+}
+
+// StartDispatcher posts the due deliveries in the background until ctx is
+// done. The deliveries claimed by a previous process that never recorded
+// their outcome are attempted again once their claim expires.
+func (a *app) StartDispatcher(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(dispatchInterval)
+		defer ticker.Stop()
+		for {
+			a.dispatch(ctx)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			case <-wake:
+			}
+		}
+	}()
+}
+
+// dispatch attempts the due deliveries. Each attempt is claimed in the DB
+// first so that several servers sharing the DB attempt it once.
+func (a *app) dispatch(ctx context.Context) {
+	now := time.Now().UTC()
+	due, err := a.dp.GetList(ctx, func(db *gorm.DB) *gorm.DB {
+		return db.Where("status = ? AND next_attempt_at IS NOT NULL AND next_attempt_at <= ?", model.EventDeliveryPending, now).
+			Order("next_attempt_at ASC").
+			Limit(maxDueDeliveries)
+	})
+	if err != nil {
+		slog.Error("Failed to load due event deliveries", "error", err)
+		return
+	}
+	for _, delivery := range due {
+		claimed, err := a.dp.Claim(ctx, delivery.ID, *delivery.NextAttemptAt, now.Add(claimLease))
+		if err != nil {
+			slog.Error("Failed to claim an event delivery", "error", err, "delivery_id", delivery.ID)
+			continue
+		}
+		if claimed {
+			go a.deliver(context.WithoutCancel(ctx), delivery)
+		}
+	}
+}
+
+// deliver posts a delivery to its subscription and records the outcome. A
+// failed attempt is retried with an exponential backoff until maxAttempts.
+func (a *app) deliver(ctx context.Context, delivery *model.EventDelivery) {
+	subscription, err := a.sp.GetByID(ctx, delivery.SubscriptionID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		// the subscription was deleted with its deliveries
+		return
+	}
+	if err != nil {
+		slog.Error("Failed to load event subscription", "error", err, "subscription_id", delivery.SubscriptionID)
+		return
+	}
+
+	fields := map[string]interface{}{}
+	if subscription.Enabled != 1 {
+		fields["status"], fields["error"], fields["next_attempt_at"] = model.EventDeliveryFailed, "the subscription is disabled", nil
+	} else {
+		code, err := post(ctx, subscription, delivery)
+		attempts := delivery.Attempts + 1
+		now := time.Now().UTC()
+		fields["attempts"], fields["response_code"] = attempts, code
+		switch {
+		case err == nil:
+			fields["status"], fields["error"], fields["next_attempt_at"], fields["delivered_at"] = model.EventDeliverySucceeded, "", nil, now
+		case attempts >= maxAttempts:
+			fields["status"], fields["error"], fields["next_attempt_at"] = model.EventDeliveryFailed, err.Error(), nil
+		default:
+			next := now.Add(backoff(attempts))
+			fields["error"], fields["next_attempt_at"] = err.Error(), next
+		}
+	}
+	if err := a.dp.UpdateFields(ctx, fields, func(db *gorm.DB) *gorm.DB {
+		return db.Where("id = ?", delivery.ID)
+	}); err != nil {
+		slog.Error("Failed to record an event delivery", "error", err, "delivery_id", delivery.ID)
+	}
+}
+
+// backoff is the wait before the next attempt of a delivery that failed attempts times
+func backoff(attempts int) time.Duration {
+	return retryBackoff << (attempts - 1)
+}
+
+// post sends the payload of a delivery signed with the secret of its
+// subscription, over timestamp + "." + body like the inbound webhooks. Any
+// status outside 2xx is an error.
+func post(ctx context.Context, subscription *model.EventSubscription, delivery *model.EventDelivery) (int, error) {
+	key, err := secret.Decrypt(subscription.Secret)
+	if err != nil {
+		return 0, err
+	}
+	body := []byte(delivery.Payload)
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, subscription.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Cortex-Lab-Events")
+	req.Header.Set("X-Cortex-Event", delivery.Event)
+	req.Header.Set("X-Cortex-Event-ID", delivery.EventID)
+	req.Header.Set("X-Cortex-Delivery", delivery.ID)
+	req.Header.Set("X-Signature-Timestamp", timestamp)
+	req.Header.Set("X-Signature-256", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+
+	resp, err := deliveryClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	// the body is never kept, only the status is recorded on the delivery
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return resp.StatusCode, nil
+	}
+	return resp.StatusCode, fmt.Errorf("unexpected status %d", resp.StatusCode)
+}
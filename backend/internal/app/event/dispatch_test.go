@@ -0,0 +1,167 @@
+package event
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/xichan96/cortex-lab/internal/config"
+	"github.com/xichan96/cortex-lab/internal/infra/model"
+	"github.com/xichan96/cortex-lab/internal/pkg/secret"
+)
+
+func TestPublicAddr(t *testing.T) {
+	tests := []struct {
+		addr string
+		want bool
+	}{
+		{"93.184.216.34", true},
+		{"2606:2800:220:1:248:1893:25c8:1946", true},
+		{"127.0.0.1", false},
+		{"::1", false},
+		{"10.1.2.3", false},
+		{"172.16.0.1", false},
+		{"192.168.1.1", false},
+		{"169.254.169.254", false},
+		{"fe80::1", false},
+		{"fd00::1", false},
+		{"0.0.0.0", false},
+		{"0.1.2.3", false},
+		{"::", false},
+		{"100.64.0.1", false},
+		{"::ffff:127.0.0.1", false},
+		{"::ffff:169.254.169.254", false},
+		{"224.0.0.1", false},
+	}
+	for _, tt := range tests {
+		if got := publicAddr(netip.MustParseAddr(tt.addr)); got != tt.want {
+			t.Errorf("publicAddr(%s) = %v, want %v", tt.addr, got, tt.want)
+		}
+	}
+}
+
+func TestCheck_URL(t *testing.T) {
+	tests := []struct {
+		url     string
+		wantErr bool
+	}{
+		{"https://example.com/hook", false},
+		{"http://93.184.216.34:8080/hook", false},
+		{"ftp://example.com/hook", true},
+		{"/hook", true},
+		{"http://localhost:8080/hook", true},
+		{"http://127.0.0.1/hook", true},
+		{"http://[::1]/hook", true},
+		{"http://10.0.0.5/hook", true},
+		{"http://169.254.169.254/latest/meta-data", true},
+	}
+	for _, tt := range tests {
+		s := &model.EventSubscription{URL: tt.url, Events: model.EventNames{model.Events[0]}}
+		if err := check(s); (err != nil) != tt.wantErr {
+			t.Errorf("check(%q) error = %v, wantErr %v", tt.url, err, tt.wantErr)
+		}
+	}
+}
+
+func TestDeliveryClient_RefusesLoopback(t *testing.T) {
+	hit := false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { hit = true }))
+	defer srv.Close()
+
+	resp, err := deliveryClient.Post(srv.URL, "application/json", strings.NewReader("{}"))
+	if err == nil {
+		resp.Body.Close()
+		t.Fatal("the delivery client reached a loopback address")
+	}
+	if hit || !strings.Contains(err.Error(), "not a public address") {
+		t.Errorf("Post() error = %v, want the address refused before connecting", err)
+	}
+}
+
+func TestDeliveryClient_NoRedirect(t *testing.T) {
+	followed := false
+	mux := http.NewServeMux()
+	mux.HandleFunc("/hook", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/internal", http.StatusTemporaryRedirect)
+	})
+	mux.HandleFunc("/internal", func(w http.ResponseWriter, r *http.Request) { followed = true })
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	// the test server is on loopback, only the redirect policy is used here
+	client := &http.Client{CheckRedirect: deliveryClient.CheckRedirect}
+	resp, err := client.Post(srv.URL+"/hook", "application/json", strings.NewReader("{}"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if followed || resp.StatusCode != http.StatusTemporaryRedirect {
+		t.Errorf("status = %d followed = %v, want the redirect returned as is", resp.StatusCode, followed)
+	}
+}
+
+func TestPost(t *testing.T) {
+	security := config.Config.Security
+	config.Config.Security = &config.SecurityConfig{SecretKey: "test-key"}
+	defer func() { config.Config.Security = security }()
+	sealed, err := secret.Encrypt("s3cret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	subscription := &model.EventSubscription{ID: "sub", Secret: sealed}
+	delivery := &model.EventDelivery{ID: "d1", EventID: "e1", Event: model.Events[0], Payload: `{"id":"e1"}`}
+
+	status := http.StatusOK
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		timestamp := r.Header.Get("X-Signature-Timestamp")
+		if sec, err := strconv.ParseInt(timestamp, 10, 64); err != nil || time.Since(time.Unix(sec, 0)).Abs() > time.Minute {
+			t.Errorf("X-Signature-Timestamp = %q, want the current Unix time", timestamp)
+		}
+		mac := hmac.New(sha256.New, []byte("s3cret"))
+		mac.Write([]byte(timestamp + "."))
+		mac.Write(body)
+		if r.Header.Get("X-Signature-256") != "sha256="+hex.EncodeToString(mac.Sum(nil)) ||
+			r.Header.Get("X-Cortex-Event") != delivery.Event || r.Header.Get("X-Cortex-Delivery") != "d1" {
+			t.Errorf("unexpected headers %v", r.Header)
+		}
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte("internal details"))
+	}))
+	defer srv.Close()
+	subscription.URL = srv.URL
+
+	client := deliveryClient
+	deliveryClient = &http.Client{Timeout: 5 * time.Second, CheckRedirect: client.CheckRedirect}
+	defer func() { deliveryClient = client }()
+
+	if code, err := post(context.Background(), subscription, delivery); err != nil || code != http.StatusOK {
+		t.Fatalf("post() = %d, %v, want 200", code, err)
+	}
+	status = http.StatusInternalServerError
+	code, err := post(context.Background(), subscription, delivery)
+	if err == nil || code != http.StatusInternalServerError {
+		t.Fatalf("post() = %d, %v, want a 500 error", code, err)
+	}
+	if strings.Contains(err.Error(), "internal details") {
+		t.Errorf("post() error = %v, the response body must not be kept", err)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	want := []time.Duration{30 * time.Second, time.Minute, 2 * time.Minute, 4 * time.Minute, 8 * time.Minute}
+	for i, w := range want {
+		if got := backoff(i + 1); got != w {
+			t.Errorf("backoff(%d) = %v, want %v", i+1, got, w)
+		}
+	}
+}
@@ -0,0 +1,320 @@
+package event
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/netip"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/jinzhu/copier"
+	"github.com/xichan96/cortex-lab/internal/appdto"
+	"github.com/xichan96/cortex-lab/internal/infra/model"
+	"github.com/xichan96/cortex-lab/internal/infra/persist"
+	"github.com/xichan96/cortex-lab/internal/pkg/errcode"
+	"github.com/xichan96/cortex-lab/internal/pkg/secret"
+	"github.com/xichan96/cortex-lab/pkg/ec"
+	"github.com/xichan96/cortex-lab/pkg/sql"
+	"github.com/xichan96/cortex-lab/pkg/web/cctx"
+	"gorm.io/gorm"
+)
+
+type AppIer interface {
+	CreateSubscription(ctx context.Context, req *appdto.CreateEventSubscriptionReq) (*appdto.EventSubscriptionSecret, error)
+	UpdateSubscription(ctx context.Context, req *appdto.UpdateEventSubscriptionReq) error
+	DeleteSubscription(ctx context.Context, id string) error
+	GetSubscription(ctx context.Context, id string) (*appdto.EventSubscription, error)
+	GetSubscriptions(ctx context.Context, req *appdto.GetEventSubscriptionsReq) ([]*appdto.EventSubscription, int64, error)
+	RotateSecret(ctx context.Context, id string) (*appdto.EventSubscriptionSecret, error)
+	GetDeliveries(ctx context.Context, subscriptionID string, req *appdto.GetEventDeliveriesReq) ([]*appdto.EventDelivery, int64, error)
+	// Redeliver posts the event of a delivery again as a new delivery
+	Redeliver(ctx context.Context, subscriptionID, deliveryID string) (*appdto.EventDelivery, error)
+	// Publish queues an event of a user for the subscriptions of that user, it never fails the caller
+	Publish(ctx context.Context, userID, event string, data any)
+	// StartDispatcher posts the queued events in the background until ctx is done
+	StartDispatcher(ctx context.Context)
+}
+
+type app struct {
+	sp persist.EventSubscriptionPersistIer
+	dp persist.EventDeliveryPersistIer
+}
+
+func NewApp(sp persist.EventSubscriptionPersistIer, dp persist.EventDeliveryPersistIer) AppIer {
+	return &app{sp: sp, dp: dp}
+}
+
+// newSecret returns a random secret and the encrypted value it is stored as
+func newSecret() (plain, sealed string, err error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	plain = hex.EncodeToString(b)
+	if sealed, err = secret.Encrypt(plain); err != nil {
+		return "", "", err
+	}
+	return plain, sealed, nil
+}
+
+// check makes sure the URL is an absolute http(s) URL of a public host and the events are known
+func check(subscription *model.EventSubscription) error {
+	u, err := url.Parse(subscription.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return ec.Wrap(errcode.EventSubscriptionInvalid, "the URL must be an absolute http or https URL")
+	}
+	// the addresses a host name resolves to are checked when the events are delivered
+	if ip, err := netip.ParseAddr(u.Hostname()); (err == nil && !publicAddr(ip)) || strings.EqualFold(u.Hostname(), "localhost") {
+		return ec.Wrap(errcode.EventSubscriptionInvalid, "the URL must point to a public address")
+	}
+	if len(subscription.Events) == 0 {
+		return ec.Wrap(errcode.EventSubscriptionInvalid, "subscribe to at least one event")
+	}
+	for _, name := range subscription.Events {
+		if !slices.Contains(model.Events, name) {
+			return ec.Wrapf(errcode.EventSubscriptionInvalid, "unknown event %q", name)
+		}
+	}
+	return nil
+}
+
+// ownedSubscription loads a subscription of the current user
+func (a *app) ownedSubscription(ctx context.Context, id string) (*model.EventSubscription, error) {
+	subscription, err := a.sp.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if subscription.UserID != cctx.GetUserID[string](ctx) {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return subscription, nil
+}
+
+func (a *app) CreateSubscription(ctx context.Context, req *appdto.CreateEventSubscriptionReq) (*appdto.EventSubscriptionSecret, error) {
+	plain, sealed, err := newSecret()
+	if err != nil {
+		return nil, err
+	}
+	subscription := &model.EventSubscription{
+		UserID:  cctx.GetUserID[string](ctx),
+		Name:    req.Name,
+		URL:     req.URL,
+		Secret:  sealed,
+		Events:  slices.Compact(slices.Sorted(slices.Values(req.Events))),
+		Enabled: 1,
+	}
+	if req.Enabled != nil && !*req.Enabled {
+		subscription.Enabled = 0
+	}
+	if err := check(subscription); err != nil {
+		return nil, err
+	}
+	id, err := a.sp.Create(ctx, subscription)
+	if err != nil {
+		return nil, err
+	}
+	return &appdto.EventSubscriptionSecret{ID: id, Secret: plain}, nil
+}
+
+func (a *app) UpdateSubscription(ctx context.Context, req *appdto.UpdateEventSubscriptionReq) error {
+	subscription, err := a.ownedSubscription(ctx, req.ID)
+	if err != nil {
+		return err
+	}
+
+	if req.Name != "" {
+		subscription.Name = req.Name
+	}
+	if req.URL != "" {
+		subscription.URL = req.URL
+	}
+	if req.Events != nil {
+		subscription.Events = slices.Compact(slices.Sorted(slices.Values(req.Events)))
+	}
+	if req.Enabled != nil {
+		subscription.Enabled = 0
+		if *req.Enabled {
+			subscription.Enabled = 1
+		}
+	}
+	if err := check(subscription); err != nil {
+		return err
+	}
+
+	return a.sp.UpdateFields(ctx, map[string]interface{}{
+		"name":       subscription.Name,
+		"url":        subscription.URL,
+		"events":     subscription.Events,
+		"enabled":    subscription.Enabled,
+		"updated_at": time.Now(),
+	}, func(db *gorm.DB) *gorm.DB {
+		return db.Where("id = ?", subscription.ID)
+	})
+}
+
+// DeleteSubscription deletes a subscription of the current user with its
+// deliveries, the pending ones are dropped
+func (a *app) DeleteSubscription(ctx context.Context, id string) error {
+	subscription, err := a.ownedSubscription(ctx, id)
+	if err != nil {
+		return err
+	}
+	return sql.NewSqlTX().Execute(ctx, func(ctx context.Context) error {
+		if err := a.dp.DeleteBySubscriptionID(ctx, subscription.ID); err != nil {
+			return err
+		}
+		return a.sp.Delete(ctx, subscription)
+	})
+}
+
+func (a *app) GetSubscription(ctx context.Context, id string) (*appdto.EventSubscription, error) {
+	subscription, err := a.ownedSubscription(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return toSubscriptionDTO(subscription), nil
+}
+
+func (a *app) GetSubscriptions(ctx context.Context, req *appdto.GetEventSubscriptionsReq) ([]*appdto.EventSubscription, int64, error) {
+	userID := cctx.GetUserID[string](ctx)
+	opts := []func(*gorm.DB) *gorm.DB{
+		func(db *gorm.DB) *gorm.DB {
+			return db.Where("user_id = ?", userID)
+		},
+	}
+	total, err := a.sp.Count(ctx, opts...)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	opts = append(opts, func(db *gorm.DB) *gorm.DB {
+		return db.Order("created_at DESC")
+	})
+	if req.Page > 0 && req.PageSize > 0 {
+		opts = append(opts, func(db *gorm.DB) *gorm.DB {
+			offset := (req.Page - 1) * req.PageSize
+			return db.Offset(offset).Limit(req.PageSize)
+		})
+	}
+
+	subscriptions, err := a.sp.GetList(ctx, opts...)
+	if err != nil {
+		return nil, 0, err
+	}
+	dtos := make([]*appdto.EventSubscription, len(subscriptions))
+	for i, s := range subscriptions {
+		dtos[i] = toSubscriptionDTO(s)
+	}
+	return dtos, total, nil
+}
+
+// RotateSecret replaces the secret of a subscription of the current user, the
+// deliveries attempted from now on are signed with the new one
+func (a *app) RotateSecret(ctx context.Context, id string) (*appdto.EventSubscriptionSecret, error) {
+	subscription, err := a.ownedSubscription(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	plain, sealed, err := newSecret()
+	if err != nil {
+		return nil, err
+	}
+	if err := a.sp.UpdateFields(ctx, map[string]interface{}{"secret": sealed, "updated_at": time.Now()}, func(db *gorm.DB) *gorm.DB {
+		return db.Where("id = ?", subscription.ID)
+	}); err != nil {
+		return nil, err
+	}
+	return &appdto.EventSubscriptionSecret{ID: subscription.ID, Secret: plain}, nil
+}
+
+// GetDeliveries lists the deliveries of a subscription of the current user, latest first
+func (a *app) GetDeliveries(ctx context.Context, subscriptionID string, req *appdto.GetEventDeliveriesReq) ([]*appdto.EventDelivery, int64, error) {
+	subscription, err := a.ownedSubscription(ctx, subscriptionID)
+	if err != nil {
+		return nil, 0, err
+	}
+	opts := []func(*gorm.DB) *gorm.DB{
+		func(db *gorm.DB) *gorm.DB {
+			return db.Where("subscription_id = ?", subscription.ID)
+		},
+	}
+	if req.Status != "" {
+		opts = append(opts, func(db *gorm.DB) *gorm.DB {
+			return db.Where("status = ?", req.Status)
+		})
+	}
+	total, err := a.dp.Count(ctx, opts...)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	opts = append(opts, func(db *gorm.DB) *gorm.DB {
+		return db.Order("created_at DESC")
+	})
+	if req.Page > 0 && req.PageSize > 0 {
+		opts = append(opts, func(db *gorm.DB) *gorm.DB {
+			offset := (req.Page - 1) * req.PageSize
+			return db.Offset(offset).Limit(req.PageSize)
+		})
+	}
+
+	deliveries, err := a.dp.GetList(ctx, opts...)
+	if err != nil {
+		return nil, 0, err
+	}
+	dtos := make([]*appdto.EventDelivery, len(deliveries))
+	for i, d := range deliveries {
+		dtos[i] = toDeliveryDTO(d)
+	}
+	return dtos, total, nil
+}
+
+func (a *app) Redeliver(ctx context.Context, subscriptionID, deliveryID string) (*appdto.EventDelivery, error) {
+	subscription, err := a.ownedSubscription(ctx, subscriptionID)
+	if err != nil {
+		return nil, err
+	}
+	if subscription.Enabled != 1 {
+		return nil, ec.Wrap(errcode.EventSubscriptionInvalid, "the subscription is disabled")
+	}
+	delivery, err := a.dp.GetByID(ctx, deliveryID)
+	if err != nil {
+		return nil, err
+	}
+	if delivery.SubscriptionID != subscription.ID {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	now := time.Now().UTC()
+	redelivery := &model.EventDelivery{
+		SubscriptionID: subscription.ID,
+		UserID:         subscription.UserID,
+		EventID:        delivery.EventID,
+		Event:          delivery.Event,
+		Payload:        delivery.Payload,
+		Status:         model.EventDeliveryPending,
+		NextAttemptAt:  &now,
+	}
+	if _, err := a.dp.Create(ctx, redelivery); err != nil {
+		return nil, err
+	}
+	a.notify()
+	return toDeliveryDTO(redelivery), nil
+}
+
+func toSubscriptionDTO(subscription *model.EventSubscription) *appdto.EventSubscription {
+	dto := &appdto.EventSubscription{}
+	_ = copier.Copy(dto, subscription)
+	dto.Events = []string(subscription.Events)
+	dto.Enabled = subscription.Enabled == 1
+	return dto
+}
+
+func toDeliveryDTO(delivery *model.EventDelivery) *appdto.EventDelivery {
+	dto := &appdto.EventDelivery{}
+	_ = copier.Copy(dto, delivery)
+	return dto
+}
@@ -0,0 +1,361 @@
+package experience
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/ledongthuc/pdf"
+	"golang.org/x/net/html"
+
+	"github.com/xichan96/cortex-lab/internal/pkg/errcode"
+)
+
+const (
+	DocumentFormatMarkdown = "markdown"
+	DocumentFormatText     = "text"
+	DocumentFormatHTML     = "html"
+	DocumentFormatPDF      = "pdf"
+)
+
+const (
+	// maxDocumentSize is the largest file accepted for ingestion
+	maxDocumentSize = 10 << 20
+	// defaultChunkSize and defaultChunkOverlap are in characters
+	defaultChunkSize    = 1000
+	defaultChunkOverlap = 100
+	minChunkSize        = 100
+	maxChunkSize        = 8000
+	maxTitleLength      = 255
+)
+
+var documentExtensions = map[string]string{
+	".md":       DocumentFormatMarkdown,
+	".markdown": DocumentFormatMarkdown,
+	".txt":      DocumentFormatText,
+	".text":     DocumentFormatText,
+	".log":      DocumentFormatText,
+	".html":     DocumentFormatHTML,
+	".htm":      DocumentFormatHTML,
+	".pdf":      DocumentFormatPDF,
+}
+
+// documentFormat tells the format of an upload from its extension, then from its content
+func documentFormat(fileName string, data []byte) (string, error) {
+	if format, ok := documentExtensions[strings.ToLower(filepath.Ext(fileName))]; ok {
+		return format, nil
+	}
+	switch {
+	case bytes.HasPrefix(data, []byte("%PDF-")):
+		return DocumentFormatPDF, nil
+	case utf8.Valid(data):
+		head := strings.ToLower(strings.TrimSpace(string(data[:min(len(data), 512)])))
+		if strings.HasPrefix(head, "<!doctype html") || strings.HasPrefix(head, "<html") {
+			return DocumentFormatHTML, nil
+		}
+		return DocumentFormatText, nil
+	}
+	return "", errcode.DocumentFormatUnsupported
+}
+
+// extractText returns the text of a document. HTML headings are kept as
+// Markdown headings so every format is split along its sections the same way.
+func extractText(format string, data []byte) (string, error) {
+	var text string
+	switch format {
+	case DocumentFormatMarkdown, DocumentFormatText:
+		if !utf8.Valid(data) {
+			return "", errcode.DocumentFormatUnsupported
+		}
+		text = string(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf")))
+	case DocumentFormatHTML:
+		var err error
+		if text, err = htmlText(data); err != nil {
+			return "", err
+		}
+	case DocumentFormatPDF:
+		var err error
+		if text, err = pdfText(data); err != nil {
+			return "", err
+		}
+	default:
+		return "", errcode.DocumentFormatUnsupported
+	}
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	if strings.TrimSpace(text) == "" {
+		return "", errcode.DocumentEmpty
+	}
+	return text, nil
+}
+
+var htmlBlockTags = map[string]bool{
+	"p": true, "div": true, "section": true, "article": true, "br": true, "tr": true,
+	"ul": true, "ol": true, "table": true, "pre": true, "blockquote": true, "hr": true,
+}
+
+var htmlSkipTags = map[string]bool{
+	"script": true, "style": true, "head": true, "noscript": true, "template": true, "svg": true,
+}
+
+var whitespace = regexp.MustCompile(`\s+`)
+
+func htmlText(data []byte) (string, error) {
+	var sb strings.Builder
+	skip := 0
+	z := html.NewTokenizer(bytes.NewReader(data))
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			if err := z.Err(); err != io.EOF {
+				return "", fmt.Errorf("failed to parse HTML: %w", err)
+			}
+			return sb.String(), nil
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, _ := z.TagName()
+			tag := string(name)
+			switch {
+			case htmlSkipTags[tag]:
+				skip++
+			case len(tag) == 2 && tag[0] == 'h' && tag[1] >= '1' && tag[1] <= '6':
+				sb.WriteString("\n\n" + strings.Repeat("#", int(tag[1]-'0')) + " ")
+			case tag == "li":
+				sb.WriteString("\n- ")
+			case htmlBlockTags[tag]:
+				sb.WriteString("\n\n")
+			case tag == "td" || tag == "th":
+				sb.WriteString(" | ")
+			}
+		case html.EndTagToken:
+			name, _ := z.TagName()
+			tag := string(name)
+			if htmlSkipTags[tag] && skip > 0 {
+				skip--
+			} else if htmlBlockTags[tag] || (len(tag) == 2 && tag[0] == 'h' && tag[1] >= '1' && tag[1] <= '6') {
+				sb.WriteString("\n\n")
+			}
+		case html.TextToken:
+			if skip == 0 {
+				sb.WriteString(whitespace.ReplaceAllString(string(z.Text()), " "))
+			}
+		}
+	}
+}
+
+func pdfText(data []byte) (text string, err error) {
+	// the PDF reader panics on some malformed files
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("failed to read PDF: %v", r)
+		}
+	}()
+	reader, err := pdf.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return "", fmt.Errorf("failed to read PDF: %w", err)
+	}
+	fonts := make(map[string]*pdf.Font)
+	pages := make([]string, 0, reader.NumPage())
+	for i := 1; i <= reader.NumPage(); i++ {
+		page := reader.Page(i)
+		for _, name := range page.Fonts() {
+			if _, ok := fonts[name]; !ok {
+				font := page.Font(name)
+				fonts[name] = &font
+			}
+		}
+		pageText, err := page.GetPlainText(fonts)
+		if err != nil {
+			return "", fmt.Errorf("failed to read PDF page %d: %w", i, err)
+		}
+		pages = append(pages, pageText)
+	}
+	return strings.Join(pages, "\n\n"), nil
+}
+
+// chunk is a fragment of a document with the heading path it belongs to
+type chunk struct {
+	heading string
+	content string
+}
+
+var markdownHeading = regexp.MustCompile(`^(#{1,6})\s+(.+?)\s*#*\s*$`)
+
+type section struct {
+	heading string
+	body    string
+}
+
+// splitSections splits text at its Markdown headings. The heading of a
+// section is the path of the headings above it, e.g. "Deploy > Rollback".
+func splitSections(text string) []section {
+	var sections []section
+	var path []string
+	var body strings.Builder
+	inFence := false
+	flush := func() {
+		if strings.TrimSpace(body.String()) != "" {
+			sections = append(sections, section{heading: strings.Join(path, " > "), body: body.String()})
+		}
+		body.Reset()
+	}
+	for _, line := range strings.Split(text, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			inFence = !inFence
+		}
+		if m := markdownHeading.FindStringSubmatch(line); m != nil && !inFence {
+			flush()
+			level := len(m[1])
+			if level <= len(path) {
+				path = path[:level-1]
+			}
+			for len(path) < level-1 {
+				path = append(path, "")
+			}
+			path = append(path, strings.TrimSpace(m[2]))
+			continue
+		}
+		body.WriteString(line)
+		body.WriteString("\n")
+	}
+	flush()
+	for i := range sections {
+		parts := strings.Split(sections[i].heading, " > ")
+		kept := parts[:0]
+		for _, p := range parts {
+			if p != "" {
+				kept = append(kept, p)
+			}
+		}
+		sections[i].heading = strings.Join(kept, " > ")
+	}
+	return sections
+}
+
+// splitChunks splits text into chunks of at most size characters. Sections
+// and paragraphs are kept whole when they fit, longer ones are cut at sentence
+// or word boundaries. A chunk starts with the trailing paragraphs of the
+// previous chunk of its section that fit in overlap characters.
+func splitChunks(text string, size, overlap int) []chunk {
+	var chunks []chunk
+	for _, sec := range splitSections(text) {
+		for _, content := range packParagraphs(paragraphs(sec.body, size), size, overlap) {
+			chunks = append(chunks, chunk{heading: sec.heading, content: content})
+		}
+	}
+	return chunks
+}
+
+var blankLines = regexp.MustCompile(`\n\s*\n`)
+
+// paragraphs splits body at blank lines, paragraphs longer than size are cut into pieces
+func paragraphs(body string, size int) []string {
+	var out []string
+	for _, p := range blankLines.Split(body, -1) {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+		for utf8.RuneCountInString(p) > size {
+			cut := cutPoint(p, size)
+			out = append(out, strings.TrimSpace(p[:cut]))
+			p = strings.TrimSpace(p[cut:])
+		}
+		if p != "" {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// cutPoint returns the byte offset to cut s at so the first part has at most
+// size characters, preferring the end of a sentence, then a space
+func cutPoint(s string, size int) int {
+	limit := len(s)
+	count := 0
+	for i := range s {
+		if count == size {
+			limit = i
+			break
+		}
+		count++
+	}
+	window := s[:limit]
+	half := len(window) / 2
+	for _, sep := range []string{"\n", "。", ". ", "! ", "? ", "；", "; "} {
+		if i := strings.LastIndex(window, sep); i > half {
+			return i + len(sep)
+		}
+	}
+	if i := strings.LastIndexFunc(window, unicode.IsSpace); i > half {
+		return i + 1
+	}
+	return limit
+}
+
+// packParagraphs joins paragraphs into chunks of at most size characters
+func packParagraphs(paras []string, size, overlap int) []string {
+	var chunks []string
+	var current []string
+	length := 0
+	for _, p := range paras {
+		n := utf8.RuneCountInString(p)
+		if length > 0 && length+2+n > size {
+			chunks = append(chunks, strings.Join(current, "\n\n"))
+			current, length = overlapTail(current, overlap)
+			if length > 0 && length+2+n > size {
+				current, length = nil, 0
+			}
+		}
+		current = append(current, p)
+		if length > 0 {
+			length += 2
+		}
+		length += n
+	}
+	if length > 0 {
+		chunks = append(chunks, strings.Join(current, "\n\n"))
+	}
+	return chunks
+}
+
+// overlapTail returns the trailing paragraphs of a chunk that fit in overlap characters
+func overlapTail(paras []string, overlap int) ([]string, int) {
+	if overlap <= 0 {
+		return nil, 0
+	}
+	length := 0
+	start := len(paras)
+	for start > 0 {
+		n := utf8.RuneCountInString(paras[start-1])
+		if length > 0 {
+			n += 2
+		}
+		if length+n > overlap {
+			break
+		}
+		length += n
+		start--
+	}
+	if start == len(paras) {
+		return nil, 0
+	}
+	tail := make([]string, len(paras)-start)
+	copy(tail, paras[start:])
+	return tail, length
+}
+
+// fragmentTitle names the i-th of n fragments of a document
+func fragmentTitle(docTitle string, c chunk, i, n int) string {
+	title := fmt.Sprintf("%s (%d/%d)", docTitle, i+1, n)
+	if c.heading != "" {
+		title = fmt.Sprintf("%s - %s (%d/%d)", docTitle, c.heading, i+1, n)
+	}
+	if utf8.RuneCountInString(title) > maxTitleLength {
+		title = string([]rune(title)[:maxTitleLength])
+	}
+	return title
+}
@@ -0,0 +1,240 @@
+package experience
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+
+	"github.com/jinzhu/copier"
+	"github.com/xichan96/cortex-lab/internal/appdto"
+	"github.com/xichan96/cortex-lab/internal/infra/model"
+	"github.com/xichan96/cortex-lab/internal/pkg/errcode"
+	"github.com/xichan96/cortex-lab/pkg/sql"
+	"gorm.io/gorm"
+)
+
+// parseDocument extracts the text of an upload and splits it into chunks
+func parseDocument(fileName string, data []byte, req *appdto.IngestDocumentReq) (string, []chunk, error) {
+	if len(data) > maxDocumentSize {
+		return "", nil, errcode.DocumentTooLarge
+	}
+	format, err := documentFormat(fileName, data)
+	if err != nil {
+		return "", nil, err
+	}
+	text, err := extractText(format, data)
+	if err != nil {
+		return "", nil, err
+	}
+
+	size := req.ChunkSize
+	if size <= 0 {
+		size = defaultChunkSize
+	}
+	size = max(minChunkSize, min(size, maxChunkSize))
+	overlap := req.ChunkOverlap
+	if overlap <= 0 {
+		overlap = defaultChunkOverlap
+	}
+	overlap = min(overlap, size/2)
+
+	chunks := splitChunks(text, size, overlap)
+	if len(chunks) == 0 {
+		return "", nil, errcode.DocumentEmpty
+	}
+	return format, chunks, nil
+}
+
+// saveFragments stores the chunks of a document as document_fragment experiences
+func (a *app) saveFragments(ctx context.Context, doc *model.ExperienceDocument, chunks []chunk) error {
+	fragments := make([]*model.Experience, len(chunks))
+	for i, c := range chunks {
+		fragments[i] = &model.Experience{
+			Type:      model.ExperienceTypeDocumentFragment,
+			Title:     fragmentTitle(doc.Title, c, i, len(chunks)),
+			Content:   c.content,
+			SourceID:  &doc.ID,
+			Tags:      doc.Tags,
+			CreatedBy: doc.CreatedBy,
+		}
+	}
+	if err := a.kp.CreateBatch(ctx, fragments); err != nil {
+		return err
+	}
+	if doc.RoleID == "" {
+		return nil
+	}
+	relations := make([]*model.RoleExperienceRelation, len(fragments))
+	for i, f := range fragments {
+		relations[i] = &model.RoleExperienceRelation{RoleID: doc.RoleID, ExperienceID: f.ID}
+	}
+	return a.rkrp.CreateBatch(ctx, relations)
+}
+
+// deleteFragments removes the fragments of a document and their role relations
+func (a *app) deleteFragments(ctx context.Context, docID string) error {
+	if err := a.rkrp.DeleteBySourceID(ctx, docID); err != nil {
+		return err
+	}
+	return a.kp.DeleteBySourceID(ctx, docID)
+}
+
+// IngestDocument splits an uploaded document into fragments of the experience library
+func (a *app) IngestDocument(ctx context.Context, userID string, req *appdto.IngestDocumentReq, fileName string, data []byte) (*appdto.ExperienceDocument, error) {
+	format, chunks, err := parseDocument(fileName, data, req)
+	if err != nil {
+		return nil, err
+	}
+
+	title := req.Title
+	if title == "" {
+		title = strings.TrimSuffix(filepath.Base(fileName), filepath.Ext(fileName))
+	}
+	tags := req.Tags
+	if tags == "" {
+		tags = "[]"
+	}
+	doc := &model.ExperienceDocument{
+		Title:      title,
+		FileName:   filepath.Base(fileName),
+		Format:     format,
+		Size:       int64(len(data)),
+		ChunkCount: len(chunks),
+		RoleID:     req.RoleID,
+		Tags:       tags,
+		CreatedBy:  userID,
+	}
+	err = sql.NewSqlTX().Execute(ctx, func(ctx context.Context) error {
+		if _, err := a.dp.Create(ctx, doc); err != nil {
+			return err
+		}
+		return a.saveFragments(ctx, doc, chunks)
+	})
+	if err != nil {
+		return nil, err
+	}
+	dto := &appdto.ExperienceDocument{}
+	copier.Copy(dto, doc)
+	return dto, nil
+}
+
+// ReingestDocument replaces the content of a document with a new upload. The
+// fragments are rebuilt, title, tags and role are kept unless given.
+func (a *app) ReingestDocument(ctx context.Context, id string, req *appdto.IngestDocumentReq, fileName string, data []byte) (*appdto.ExperienceDocument, error) {
+	doc, err := a.dp.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	format, chunks, err := parseDocument(fileName, data, req)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Title != "" {
+		doc.Title = req.Title
+	}
+	if req.Tags != "" {
+		doc.Tags = req.Tags
+	}
+	if req.RoleID != "" {
+		doc.RoleID = req.RoleID
+	}
+	doc.FileName = filepath.Base(fileName)
+	doc.Format = format
+	doc.Size = int64(len(data))
+	doc.ChunkCount = len(chunks)
+	err = sql.NewSqlTX().Execute(ctx, func(ctx context.Context) error {
+		if err := a.deleteFragments(ctx, doc.ID); err != nil {
+			return err
+		}
+		if err := a.dp.Update(ctx, doc); err != nil {
+			return err
+		}
+		return a.saveFragments(ctx, doc, chunks)
+	})
+	if err != nil {
+		return nil, err
+	}
+	dto := &appdto.ExperienceDocument{}
+	copier.Copy(dto, doc)
+	return dto, nil
+}
+
+func (a *app) GetDocuments(ctx context.Context, req *appdto.GetExperienceDocumentsReq) ([]*appdto.ExperienceDocument, int64, error) {
+	opts := []func(*gorm.DB) *gorm.DB{}
+	if req.RoleID != "" {
+		opts = append(opts, func(db *gorm.DB) *gorm.DB {
+			return db.Where("role_id = ?", req.RoleID)
+		})
+	}
+	if req.Keyword != "" {
+		opts = append(opts, func(db *gorm.DB) *gorm.DB {
+			pattern := "%" + req.Keyword + "%"
+			return db.Where("title LIKE ? OR file_name LIKE ?", pattern, pattern)
+		})
+	}
+
+	total, err := a.dp.Count(ctx, opts...)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	opts = append(opts, func(db *gorm.DB) *gorm.DB {
+		return db.Order("created_at DESC")
+	})
+	if req.Page > 0 && req.PageSize > 0 {
+		opts = append(opts, func(db *gorm.DB) *gorm.DB {
+			offset := (req.Page - 1) * req.PageSize
+			return db.Offset(offset).Limit(req.PageSize)
+		})
+	}
+
+	list, err := a.dp.GetList(ctx, opts...)
+	if err != nil {
+		return nil, 0, err
+	}
+	dtos := make([]*appdto.ExperienceDocument, len(list))
+	for i, d := range list {
+		dto := &appdto.ExperienceDocument{}
+		copier.Copy(dto, d)
+		dtos[i] = dto
+	}
+	return dtos, total, nil
+}
+
+// GetDocument returns a document with its fragments in document order
+func (a *app) GetDocument(ctx context.Context, id string) (*appdto.ExperienceDocumentDetail, error) {
+	doc, err := a.dp.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	// fragment IDs are increasing, so they sort in the order they were created
+	fragments, err := a.kp.GetList(ctx, func(db *gorm.DB) *gorm.DB {
+		return db.Where("source_id = ?", id).Order("id ASC")
+	})
+	if err != nil {
+		return nil, err
+	}
+	dto := &appdto.ExperienceDocumentDetail{Fragments: make([]*appdto.Experience, len(fragments))}
+	copier.Copy(&dto.ExperienceDocument, doc)
+	for i, f := range fragments {
+		fragment := &appdto.Experience{}
+		copier.Copy(fragment, f)
+		dto.Fragments[i] = fragment
+	}
+	return dto, nil
+}
+
+// DeleteDocument deletes a document together with its fragments
+func (a *app) DeleteDocument(ctx context.Context, id string) error {
+	doc, err := a.dp.GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	return sql.NewSqlTX().Execute(ctx, func(ctx context.Context) error {
+		if err := a.deleteFragments(ctx, doc.ID); err != nil {
+			return err
+		}
+		return a.dp.Delete(ctx, doc)
+	})
+}
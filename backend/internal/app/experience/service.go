@@ -19,19 +19,25 @@ type AppIer interface {
 	GetExperience(ctx context.Context, id string) (*appdto.Experience, error)
 	GetExperienceList(ctx context.Context, roleID string, req *appdto.GetExperienceReq) ([]*appdto.Experience, int64, error)
 	SearchExperience(ctx context.Context, roleID string, keywords []string) ([]*appdto.Experience, error)
+	IngestDocument(ctx context.Context, userID string, req *appdto.IngestDocumentReq, fileName string, data []byte) (*appdto.ExperienceDocument, error)
+	ReingestDocument(ctx context.Context, id string, req *appdto.IngestDocumentReq, fileName string, data []byte) (*appdto.ExperienceDocument, error)
+	GetDocuments(ctx context.Context, req *appdto.GetExperienceDocumentsReq) ([]*appdto.ExperienceDocument, int64, error)
+	GetDocument(ctx context.Context, id string) (*appdto.ExperienceDocumentDetail, error)
+	DeleteDocument(ctx context.Context, id string) error
 }
 
 type app struct {
 	kp   persist.ExperiencePersistIer
 	rkrp persist.RoleExperienceRelationPersistIer
+	dp   persist.ExperienceDocumentPersistIer
 	seg  gse.Segmenter
 }
 
-func NewApp(kp persist.ExperiencePersistIer, rkrp persist.RoleExperienceRelationPersistIer) AppIer {
+func NewApp(kp persist.ExperiencePersistIer, rkrp persist.RoleExperienceRelationPersistIer, dp persist.ExperienceDocumentPersistIer) AppIer {
 	var seg gse.Segmenter
 	// Use embedded dictionary to avoid file path issues in Docker
 	seg.LoadDictEmbed()
-	return &app{kp: kp, rkrp: rkrp, seg: seg}
+	return &app{kp: kp, rkrp: rkrp, dp: dp, seg: seg}
 }
 
 func (a *app) CreateExperience(ctx context.Context, userID, roleID string, req *appdto.CreateExperienceReq) (string, error) {
@@ -123,6 +129,12 @@ func (a *app) GetExperienceList(ctx context.Context, roleID string, req *appdto.
 		})
 	}
 
+	if req.SourceID != "" {
+		opts = append(opts, func(db *gorm.DB) *gorm.DB {
+			return db.Where("source_id = ?", req.SourceID)
+		})
+	}
+
 	keyword := req.Keyword
 	if keyword == "" && req.Q != "" {
 		keyword = req.Q
@@ -3,12 +3,18 @@ package role
 import (
 	"context"
 	"encoding/json"
+	"slices"
 	"time"
 
 	"github.com/jinzhu/copier"
+	"github.com/xichan96/cortex-lab/internal/app/event"
+	"github.com/xichan96/cortex-lab/internal/app/setting"
 	"github.com/xichan96/cortex-lab/internal/appdto"
 	"github.com/xichan96/cortex-lab/internal/infra/model"
 	"github.com/xichan96/cortex-lab/internal/infra/persist"
+	"github.com/xichan96/cortex-lab/internal/pkg/errcode"
+	"github.com/xichan96/cortex-lab/internal/pkg/sandbox"
+	"github.com/xichan96/cortex-lab/pkg/ec"
 	"github.com/xichan96/cortex-lab/pkg/web/cctx"
 	"gorm.io/gorm"
 )
@@ -22,11 +28,49 @@ type AppIer interface {
 }
 
 type app struct {
-	rp persist.RolePersistIer
+	rp         persist.RolePersistIer
+	settingSrv setting.AppIer
+	events     event.AppIer
 }
 
-func NewApp(rp persist.RolePersistIer) AppIer {
-	return &app{rp: rp}
+func NewApp(rp persist.RolePersistIer, settingSrv setting.AppIer, events event.AppIer) AppIer {
+	return &app{rp: rp, settingSrv: settingSrv, events: events}
+}
+
+// checkModels makes sure the preferred and fallback models of a role are
+// configured in the chat LLM settings
+func (a *app) checkModels(ctx context.Context, provider, modelName string, fallbacks []appdto.RoleModel) error {
+	if provider == "" && modelName == "" && len(fallbacks) == 0 {
+		return nil
+	}
+	if (provider == "") != (modelName == "") {
+		return errcode.RoleModelNotConfigured
+	}
+	chatLLMSetting, err := a.settingSrv.GetChatLLMSetting(ctx)
+	if err != nil {
+		return err
+	}
+	var cfg *appdto.ChatLLMConfig
+	if chatLLMSetting != nil {
+		cfg = chatLLMSetting.ChatLLMConfig
+	}
+	if provider != "" && !cfg.HasModel(provider, modelName) {
+		return errcode.RoleModelNotConfigured
+	}
+	for _, m := range fallbacks {
+		if !cfg.HasModel(m.Provider, m.Model) {
+			return errcode.RoleModelNotConfigured
+		}
+	}
+	return nil
+}
+
+// checkToolConfig validates the sandbox policy of a tool configuration
+func checkToolConfig(cfg *appdto.RoleToolConfig) error {
+	if err := (*sandbox.Policy)(cfg.Sandbox).Check(slices.Contains(cfg.Builtin, "command")); err != nil {
+		return ec.Wrap(errcode.SandboxPolicyInvalid, err.Error())
+	}
+	return nil
 }
 
 func (a *app) CreateRole(ctx context.Context, req *appdto.CreateRoleReq) (string, error) {
@@ -34,26 +78,42 @@ func (a *app) CreateRole(ctx context.Context, req *appdto.CreateRoleReq) (string
 
 	toolsPayload := any(req.Tools)
 	if req.ToolConfig != nil {
+		if err := checkToolConfig(req.ToolConfig); err != nil {
+			return "", err
+		}
 		toolsPayload = req.ToolConfig
 	}
 	toolsJSON, _ := json.Marshal(toolsPayload)
 	permissionsJSON, _ := json.Marshal(req.Permissions)
 
+	params := (*model.ModelParams)(req.ModelParams)
+	if !params.Valid() {
+		return "", errcode.ModelParamsInvalid
+	}
+	if err := a.checkModels(ctx, req.Provider, req.ModelName, req.FallbackModels); err != nil {
+		return "", err
+	}
+	fallbacksJSON, _ := json.Marshal(req.FallbackModels)
+
 	isPublic := 0
 	if req.IsPublic {
 		isPublic = 1
 	}
 
 	role := &model.Role{
-		Name:        req.Name,
-		Description: req.Description,
-		Avatar:      req.Avatar,
-		Prompt:      req.Prompt,
-		Principle:   req.Principle,
-		Tools:       string(toolsJSON),
-		Permissions: string(permissionsJSON),
-		CreatorID:   userID,
-		IsPublic:    isPublic,
+		Name:           req.Name,
+		Description:    req.Description,
+		Avatar:         req.Avatar,
+		Prompt:         req.Prompt,
+		Principle:      req.Principle,
+		Tools:          string(toolsJSON),
+		Permissions:    string(permissionsJSON),
+		CreatorID:      userID,
+		IsPublic:       isPublic,
+		ModelParams:    params,
+		Provider:       req.Provider,
+		ModelName:      req.ModelName,
+		FallbackModels: string(fallbacksJSON),
 	}
 	return a.rp.Create(ctx, role)
 }
@@ -80,6 +140,9 @@ func (a *app) UpdateRole(ctx context.Context, req *appdto.UpdateRoleReq) error {
 		role.Principle = req.Principle
 	}
 	if req.ToolConfig != nil {
+		if err := checkToolConfig(req.ToolConfig); err != nil {
+			return err
+		}
 		toolsJSON, _ := json.Marshal(req.ToolConfig)
 		role.Tools = string(toolsJSON)
 	} else if req.Tools != nil {
@@ -98,8 +161,43 @@ func (a *app) UpdateRole(ctx context.Context, req *appdto.UpdateRoleReq) error {
 		}
 	}
 
+	if req.ModelParams != nil {
+		params := (*model.ModelParams)(req.ModelParams)
+		if !params.Valid() {
+			return errcode.ModelParamsInvalid
+		}
+		role.ModelParams = params
+	}
+
+	if req.Provider != nil || req.ModelName != nil || req.FallbackModels != nil {
+		if req.Provider != nil {
+			role.Provider = *req.Provider
+		}
+		if req.ModelName != nil {
+			role.ModelName = *req.ModelName
+		}
+		fallbacks := req.FallbackModels
+		if fallbacks == nil {
+			_ = json.Unmarshal([]byte(role.FallbackModels), &fallbacks)
+		}
+		if err := a.checkModels(ctx, role.Provider, role.ModelName, fallbacks); err != nil {
+			return err
+		}
+		fallbacksJSON, _ := json.Marshal(fallbacks)
+		role.FallbackModels = string(fallbacksJSON)
+	}
+
 	role.UpdatedAt = time.Now()
-	return a.rp.Update(ctx, role)
+	if err := a.rp.Update(ctx, role); err != nil {
+		return err
+	}
+	// the event goes to the creator of the role, whoever updated it
+	a.events.Publish(ctx, role.CreatorID, model.EventRoleUpdated, map[string]interface{}{
+		"role_id":    role.ID,
+		"name":       role.Name,
+		"updated_by": cctx.GetUserID[string](ctx),
+	})
+	return nil
 }
 
 func (a *app) DeleteRole(ctx context.Context, id string) error {
@@ -122,6 +220,8 @@ func (a *app) GetRole(ctx context.Context, id string) (*appdto.Role, error) {
 	_ = json.Unmarshal([]byte(role.Permissions), &dto.Permissions)
 
 	dto.IsPublic = role.IsPublic == 1
+	dto.ModelParams = (*appdto.ModelParams)(role.ModelParams)
+	_ = json.Unmarshal([]byte(role.FallbackModels), &dto.FallbackModels)
 
 	return dto, nil
 }
@@ -174,6 +274,8 @@ func (a *app) GetRoles(ctx context.Context, req *appdto.GetRolesReq) ([]*appdto.
 		dto.ToolConfig, dto.Tools = parseRoleTools(r.Tools)
 		_ = json.Unmarshal([]byte(r.Permissions), &dto.Permissions)
 		dto.IsPublic = r.IsPublic == 1
+		dto.ModelParams = (*appdto.ModelParams)(r.ModelParams)
+		_ = json.Unmarshal([]byte(r.FallbackModels), &dto.FallbackModels)
 		dtos[i] = dto
 	}
 	return dtos, total, nil
@@ -186,7 +288,7 @@ func parseRoleTools(toolsJSON string) (*appdto.RoleToolConfig, []string) {
 
 	var cfg appdto.RoleToolConfig
 	if err := json.Unmarshal([]byte(toolsJSON), &cfg); err == nil {
-		if len(cfg.Builtin) > 0 || len(cfg.MCP) > 0 || cfg.EmailConfig != nil || len(cfg.RoleNotifications) > 0 || len(cfg.HumanNotifications) > 0 {
+		if len(cfg.Builtin) > 0 || len(cfg.MCP) > 0 || cfg.EmailConfig != nil || len(cfg.RoleNotifications) > 0 || len(cfg.HumanNotifications) > 0 || len(cfg.RequireApproval) > 0 || cfg.Sandbox != nil {
 			return &cfg, flattenRoleToolConfig(&cfg)
 		}
 	}
@@ -0,0 +1,163 @@
+package schedule
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/xichan96/cortex-lab/internal/appdto"
+	"github.com/xichan96/cortex-lab/internal/infra/model"
+	"github.com/xichan96/cortex-lab/pkg/web/cctx"
+	"gorm.io/gorm"
+)
+
+const (
+	// schedulerInterval is how often the due schedules are looked up
+	schedulerInterval = 30 * time.Second
+	// maxDueSchedules caps the schedules started by one tick, the rest wait for the next one
+	maxDueSchedules = 100
+	// heartbeatInterval is how often a server refreshes the heartbeat of the runs it executes
+	heartbeatInterval = time.Minute
+	// runLease is how long a running run outlives its last heartbeat before it is considered interrupted
+	runLease = 3 * heartbeatInterval
+)
+
+// StartScheduler runs the due schedules in the background until ctx is done.
+// The runs whose server stopped executing them are marked failed on start and
+// on every tick, the runs of the other servers sharing the DB keep running. A
+// schedule that was due while the server was down runs once on start, the
+// runs it missed are not caught up.
+func (a *app) StartScheduler(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(schedulerInterval)
+		defer ticker.Stop()
+		for {
+			a.closeInterrupted(ctx)
+			a.tick(ctx)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// closeInterrupted marks failed the running runs whose heartbeat is older
+// than runLease, the server executing them stopped. The heartbeats are written
+// in UTC and only compared with each other, the drivers store times with the
+// offset of the server writing them.
+func (a *app) closeInterrupted(ctx context.Context) {
+	now := time.Now().UTC()
+	// the runs recorded before heartbeats existed get one now, they are closed once it expires
+	err := a.rp.Update(ctx, &model.ScheduleRun{HeartbeatAt: &now}, func(db *gorm.DB) *gorm.DB {
+		return db.Where("status = ? AND heartbeat_at IS NULL", model.ScheduleRunRunning)
+	})
+	if err != nil {
+		slog.Error("Failed to start the heartbeat of schedule runs", "error", err)
+	}
+	interrupted := &model.ScheduleRun{Status: model.ScheduleRunFailed, Error: "interrupted, the server running it stopped", FinishedAt: &now}
+	err = a.rp.Update(ctx, interrupted, func(db *gorm.DB) *gorm.DB {
+		return db.Where("status = ? AND heartbeat_at < ?", model.ScheduleRunRunning, now.Add(-runLease))
+	})
+	if err != nil {
+		slog.Error("Failed to close interrupted schedule runs", "error", err)
+	}
+}
+
+// heartbeat refreshes the heartbeat of a run until done is closed
+func (a *app) heartbeat(ctx context.Context, runID string, done <-chan struct{}) {
+	ticker := time.NewTicker(heartbeatInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+		now := time.Now().UTC()
+		err := a.rp.Update(ctx, &model.ScheduleRun{ID: runID, HeartbeatAt: &now}, func(db *gorm.DB) *gorm.DB {
+			return db.Where("status = ?", model.ScheduleRunRunning)
+		})
+		if err != nil {
+			slog.Error("Failed to refresh the heartbeat of a schedule run", "error", err, "run_id", runID)
+		}
+	}
+}
+
+// tick starts the due schedules. Each run is claimed in the DB first so that
+// several servers sharing the DB run it once.
+func (a *app) tick(ctx context.Context) {
+	now := time.Now()
+	due, err := a.sp.GetList(ctx, func(db *gorm.DB) *gorm.DB {
+		return db.Where("enabled = ? AND next_run_at IS NOT NULL AND next_run_at <= ?", 1, now.UTC()).
+			Order("next_run_at ASC").
+			Limit(maxDueSchedules)
+	})
+	if err != nil {
+		slog.Error("Failed to load due schedules", "error", err)
+		return
+	}
+	for _, schedule := range due {
+		next, err := nextRun(schedule.Cron, schedule.Timezone, now)
+		if err != nil {
+			// the time zone may have gone from the system, the schedule stops
+			slog.Error("Failed to compute the next run of a schedule", "error", err, "schedule_id", schedule.ID)
+		}
+		at := *schedule.NextRunAt
+		claimed, err := a.sp.Claim(ctx, schedule.ID, at, next)
+		if err != nil {
+			slog.Error("Failed to claim a schedule run", "error", err, "schedule_id", schedule.ID)
+			continue
+		}
+		if claimed {
+			go a.run(schedule, at)
+		}
+	}
+}
+
+// run sends the input of a schedule to its role as the owner of the schedule
+// and records the outcome
+func (a *app) run(schedule *model.Schedule, at time.Time) {
+	ctx := cctx.WithContext(context.Background())
+	cctx.SetUserID(ctx, schedule.UserID)
+
+	sessionID := ""
+	if schedule.SessionID != nil {
+		sessionID = *schedule.SessionID
+	}
+	started := time.Now().UTC()
+	run := &model.ScheduleRun{
+		ScheduleID:  schedule.ID,
+		UserID:      schedule.UserID,
+		SessionID:   sessionID,
+		Status:      model.ScheduleRunRunning,
+		ScheduledAt: at,
+		HeartbeatAt: &started,
+	}
+	if _, err := a.rp.Create(ctx, run); err != nil {
+		slog.Error("Failed to record a schedule run", "error", err, "schedule_id", schedule.ID)
+		return
+	}
+	done := make(chan struct{})
+	go a.heartbeat(ctx, run.ID, done)
+	defer close(done)
+
+	req := &appdto.SendChatMessageReq{
+		Messages: []appdto.ChatMessageItem{
+			{Role: "user", Content: schedule.Input},
+		},
+	}
+	sessionID, messages, err := a.chatApp.SendMessage(ctx, schedule.RoleID, schedule.Provider, schedule.ModelName, sessionID, req)
+	now := time.Now().UTC()
+	run.SessionID, run.FinishedAt = sessionID, &now
+	run.Status = model.ScheduleRunSucceeded
+	if err != nil {
+		run.Status, run.Error = model.ScheduleRunFailed, err.Error()
+	} else if len(messages) > 0 {
+		run.Output = messages[len(messages)-1].Content
+	}
+	if err := a.rp.Update(ctx, run); err != nil {
+		slog.Error("Failed to record a schedule run", "error", err, "schedule_id", schedule.ID, "run_id", run.ID)
+	}
+}
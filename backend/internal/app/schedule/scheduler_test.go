@@ -0,0 +1,79 @@
+package schedule
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"github.com/xichan96/cortex-lab/internal/infra/model"
+	"github.com/xichan96/cortex-lab/internal/infra/persist"
+	"github.com/xichan96/cortex-lab/pkg/sql"
+)
+
+func TestCloseInterrupted(t *testing.T) {
+	for _, zone := range []*time.Location{time.FixedZone("UTC+8", 8*3600), time.FixedZone("UTC-5", -5*3600)} {
+		t.Run(zone.String(), func(t *testing.T) {
+			local := time.Local
+			time.Local = zone
+			t.Cleanup(func() { time.Local = local })
+
+			db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			// every connection to :memory: opens a new DB
+			if sqlDB, err := db.DB(); err == nil {
+				sqlDB.SetMaxOpenConns(1)
+			}
+			if err := db.AutoMigrate(&model.ScheduleRun{}); err != nil {
+				t.Fatal(err)
+			}
+			sql.SetDefaultDB(func() *gorm.DB { return db })
+			t.Cleanup(func() { sql.SetDefaultDB(nil) })
+
+			ctx := context.Background()
+			a := &app{rp: persist.NewScheduleRunPersist()}
+			now := time.Now().UTC()
+			live, dead := now.Add(-heartbeatInterval), now.Add(-2*runLease)
+			runs := map[string]*model.ScheduleRun{
+				"live":   {HeartbeatAt: &live},
+				"dead":   {HeartbeatAt: &dead},
+				"legacy": {},
+			}
+			for id, run := range runs {
+				run.ID, run.ScheduleID, run.UserID = id, "s1", "u1"
+				run.Status, run.ScheduledAt = model.ScheduleRunRunning, now
+				if _, err := a.rp.Create(ctx, run); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			a.closeInterrupted(ctx)
+
+			tests := []struct {
+				id   string
+				want string
+			}{
+				{"live", model.ScheduleRunRunning},
+				{"dead", model.ScheduleRunFailed},
+				// a run without a heartbeat gets a lease first
+				{"legacy", model.ScheduleRunRunning},
+			}
+			for _, tt := range tests {
+				run, err := a.rp.GetByID(ctx, tt.id)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if run.Status != tt.want {
+					t.Errorf("run %s status = %s, want %s", tt.id, run.Status, tt.want)
+				}
+				if tt.id == "legacy" && run.HeartbeatAt == nil {
+					t.Errorf("run %s has no heartbeat", tt.id)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,309 @@
+package schedule
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/jinzhu/copier"
+	"github.com/xichan96/cortex-lab/internal/app/chat"
+	"github.com/xichan96/cortex-lab/internal/app/role"
+	"github.com/xichan96/cortex-lab/internal/appdto"
+	"github.com/xichan96/cortex-lab/internal/infra/model"
+	"github.com/xichan96/cortex-lab/internal/infra/persist"
+	"github.com/xichan96/cortex-lab/internal/pkg/errcode"
+	"github.com/xichan96/cortex-lab/pkg/ec"
+	"github.com/xichan96/cortex-lab/pkg/sql"
+	"github.com/xichan96/cortex-lab/pkg/std/cron"
+	"github.com/xichan96/cortex-lab/pkg/web/cctx"
+	"gorm.io/gorm"
+)
+
+type AppIer interface {
+	CreateSchedule(ctx context.Context, req *appdto.CreateScheduleReq) (string, error)
+	UpdateSchedule(ctx context.Context, req *appdto.UpdateScheduleReq) error
+	DeleteSchedule(ctx context.Context, id string) error
+	GetSchedule(ctx context.Context, id string) (*appdto.Schedule, error)
+	GetSchedules(ctx context.Context, req *appdto.GetSchedulesReq) ([]*appdto.Schedule, int64, error)
+	GetRuns(ctx context.Context, scheduleID string, req *appdto.GetScheduleRunsReq) ([]*appdto.ScheduleRun, int64, error)
+	// StartScheduler runs the due schedules in the background until ctx is done
+	StartScheduler(ctx context.Context)
+}
+
+type app struct {
+	sp      persist.SchedulePersistIer
+	rp      persist.ScheduleRunPersistIer
+	roleApp role.AppIer
+	chatApp chat.AppIer
+}
+
+func NewApp(sp persist.SchedulePersistIer, rp persist.ScheduleRunPersistIer, roleApp role.AppIer, chatApp chat.AppIer) AppIer {
+	return &app{sp: sp, rp: rp, roleApp: roleApp, chatApp: chatApp}
+}
+
+// nextRun returns the first activation of a cron expression after from, in
+// UTC so the stored times compare the same on every driver. It is nil when the
+// expression never matches.
+func nextRun(expr, timezone string, from time.Time) (*time.Time, error) {
+	s, err := cron.Parse(expr)
+	if err != nil {
+		return nil, ec.Wrap(errcode.ScheduleInvalid, err.Error())
+	}
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		return nil, ec.Wrapf(errcode.ScheduleInvalid, "unknown time zone %q", timezone)
+	}
+	next := s.Next(from.In(loc))
+	if next.IsZero() {
+		return nil, nil
+	}
+	next = next.UTC()
+	return &next, nil
+}
+
+// checkTarget makes sure the role exists and that the session, when set, is
+// a session of the current user with that role
+func (a *app) checkTarget(ctx context.Context, roleID, sessionID, provider, modelName string) error {
+	if (provider == "") != (modelName == "") {
+		return ec.Wrap(errcode.ScheduleInvalid, "set both the provider and the model, or neither")
+	}
+	if _, err := a.roleApp.GetRole(ctx, roleID); err != nil {
+		return err
+	}
+	if sessionID == "" {
+		return nil
+	}
+	session, err := a.chatApp.GetSession(ctx, sessionID)
+	if err != nil {
+		return err
+	}
+	if session.UserID != cctx.GetUserID[string](ctx) {
+		return gorm.ErrRecordNotFound
+	}
+	if session.RoleID != roleID {
+		return ec.Wrap(errcode.ScheduleInvalid, "the session is with another role")
+	}
+	return nil
+}
+
+// ownedSchedule loads a schedule of the current user
+func (a *app) ownedSchedule(ctx context.Context, id string) (*model.Schedule, error) {
+	schedule, err := a.sp.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if schedule.UserID != cctx.GetUserID[string](ctx) {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return schedule, nil
+}
+
+func (a *app) CreateSchedule(ctx context.Context, req *appdto.CreateScheduleReq) (string, error) {
+	if strings.TrimSpace(req.Input) == "" {
+		return "", ec.Wrap(errcode.ScheduleInvalid, "the input is empty")
+	}
+	timezone := req.Timezone
+	if timezone == "" {
+		timezone = "UTC"
+	}
+	next, err := nextRun(req.Cron, timezone, time.Now())
+	if err != nil {
+		return "", err
+	}
+	if err := a.checkTarget(ctx, req.RoleID, req.SessionID, req.Provider, req.ModelName); err != nil {
+		return "", err
+	}
+
+	schedule := &model.Schedule{
+		UserID:    cctx.GetUserID[string](ctx),
+		Name:      req.Name,
+		RoleID:    req.RoleID,
+		Provider:  req.Provider,
+		ModelName: req.ModelName,
+		Cron:      strings.TrimSpace(req.Cron),
+		Timezone:  timezone,
+		Input:     req.Input,
+		Enabled:   1,
+		NextRunAt: next,
+	}
+	if req.SessionID != "" {
+		schedule.SessionID = &req.SessionID
+	}
+	if req.Enabled != nil && !*req.Enabled {
+		schedule.Enabled = 0
+	}
+	return a.sp.Create(ctx, schedule)
+}
+
+func (a *app) UpdateSchedule(ctx context.Context, req *appdto.UpdateScheduleReq) error {
+	schedule, err := a.ownedSchedule(ctx, req.ID)
+	if err != nil {
+		return err
+	}
+
+	if req.Name != "" {
+		schedule.Name = req.Name
+	}
+	if req.RoleID != "" {
+		schedule.RoleID = req.RoleID
+	}
+	if req.Provider != nil {
+		schedule.Provider = *req.Provider
+	}
+	if req.ModelName != nil {
+		schedule.ModelName = *req.ModelName
+	}
+	if req.Input != "" {
+		schedule.Input = req.Input
+	}
+	if req.SessionID != nil {
+		schedule.SessionID = nil
+		if *req.SessionID != "" {
+			schedule.SessionID = req.SessionID
+		}
+	}
+	reschedule := false
+	if req.Cron != "" {
+		schedule.Cron, reschedule = strings.TrimSpace(req.Cron), true
+	}
+	if req.Timezone != "" {
+		schedule.Timezone, reschedule = req.Timezone, true
+	}
+	if req.Enabled != nil {
+		enabled := 0
+		if *req.Enabled {
+			enabled = 1
+		}
+		// a schedule enabled again does not catch up on the runs it missed
+		reschedule = reschedule || enabled > schedule.Enabled
+		schedule.Enabled = enabled
+	}
+	if reschedule {
+		if schedule.NextRunAt, err = nextRun(schedule.Cron, schedule.Timezone, time.Now()); err != nil {
+			return err
+		}
+	}
+	sessionID := ""
+	if schedule.SessionID != nil {
+		sessionID = *schedule.SessionID
+	}
+	if err := a.checkTarget(ctx, schedule.RoleID, sessionID, schedule.Provider, schedule.ModelName); err != nil {
+		return err
+	}
+
+	return a.sp.UpdateFields(ctx, map[string]interface{}{
+		"name":        schedule.Name,
+		"role_id":     schedule.RoleID,
+		"provider":    schedule.Provider,
+		"model_name":  schedule.ModelName,
+		"cron":        schedule.Cron,
+		"timezone":    schedule.Timezone,
+		"input":       schedule.Input,
+		"session_id":  schedule.SessionID,
+		"enabled":     schedule.Enabled,
+		"next_run_at": schedule.NextRunAt,
+		"updated_at":  time.Now(),
+	}, func(db *gorm.DB) *gorm.DB {
+		return db.Where("id = ?", schedule.ID)
+	})
+}
+
+// DeleteSchedule deletes a schedule of the current user with its run history
+func (a *app) DeleteSchedule(ctx context.Context, id string) error {
+	schedule, err := a.ownedSchedule(ctx, id)
+	if err != nil {
+		return err
+	}
+	return sql.NewSqlTX().Execute(ctx, func(ctx context.Context) error {
+		if err := a.rp.DeleteByScheduleID(ctx, schedule.ID); err != nil {
+			return err
+		}
+		return a.sp.Delete(ctx, schedule)
+	})
+}
+
+func (a *app) GetSchedule(ctx context.Context, id string) (*appdto.Schedule, error) {
+	schedule, err := a.ownedSchedule(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return toScheduleDTO(schedule), nil
+}
+
+func (a *app) GetSchedules(ctx context.Context, req *appdto.GetSchedulesReq) ([]*appdto.Schedule, int64, error) {
+	userID := cctx.GetUserID[string](ctx)
+	opts := []func(*gorm.DB) *gorm.DB{
+		func(db *gorm.DB) *gorm.DB {
+			return db.Where("user_id = ?", userID)
+		},
+	}
+	total, err := a.sp.Count(ctx, opts...)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	opts = append(opts, func(db *gorm.DB) *gorm.DB {
+		return db.Order("created_at DESC")
+	})
+	if req.Page > 0 && req.PageSize > 0 {
+		opts = append(opts, func(db *gorm.DB) *gorm.DB {
+			offset := (req.Page - 1) * req.PageSize
+			return db.Offset(offset).Limit(req.PageSize)
+		})
+	}
+
+	schedules, err := a.sp.GetList(ctx, opts...)
+	if err != nil {
+		return nil, 0, err
+	}
+	dtos := make([]*appdto.Schedule, len(schedules))
+	for i, s := range schedules {
+		dtos[i] = toScheduleDTO(s)
+	}
+	return dtos, total, nil
+}
+
+// GetRuns lists the runs of a schedule of the current user, latest first
+func (a *app) GetRuns(ctx context.Context, scheduleID string, req *appdto.GetScheduleRunsReq) ([]*appdto.ScheduleRun, int64, error) {
+	schedule, err := a.ownedSchedule(ctx, scheduleID)
+	if err != nil {
+		return nil, 0, err
+	}
+	opts := []func(*gorm.DB) *gorm.DB{
+		func(db *gorm.DB) *gorm.DB {
+			return db.Where("schedule_id = ?", schedule.ID)
+		},
+	}
+	total, err := a.rp.Count(ctx, opts...)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	opts = append(opts, func(db *gorm.DB) *gorm.DB {
+		return db.Order("created_at DESC")
+	})
+	if req.Page > 0 && req.PageSize > 0 {
+		opts = append(opts, func(db *gorm.DB) *gorm.DB {
+			offset := (req.Page - 1) * req.PageSize
+			return db.Offset(offset).Limit(req.PageSize)
+		})
+	}
+
+	runs, err := a.rp.GetList(ctx, opts...)
+	if err != nil {
+		return nil, 0, err
+	}
+	dtos := make([]*appdto.ScheduleRun, len(runs))
+	for i, r := range runs {
+		dtos[i] = &appdto.ScheduleRun{}
+		_ = copier.Copy(dtos[i], r)
+	}
+	return dtos, total, nil
+}
+
+func toScheduleDTO(schedule *model.Schedule) *appdto.Schedule {
+	dto := &appdto.Schedule{}
+	_ = copier.Copy(dto, schedule)
+	dto.Enabled = schedule.Enabled == 1
+	return dto
+}
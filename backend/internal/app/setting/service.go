@@ -10,6 +10,7 @@ import (
 	"github.com/xichan96/cortex-lab/internal/appdto"
 	"github.com/xichan96/cortex-lab/internal/infra/model"
 	"github.com/xichan96/cortex-lab/internal/infra/persist"
+	"github.com/xichan96/cortex-lab/internal/pkg/errcode"
 	"github.com/xichan96/cortex-lab/pkg/ec"
 	"gorm.io/gorm"
 )
@@ -28,6 +29,10 @@ type AppIer interface {
 	UpdateMemorySetting(ctx context.Context, req *appdto.UpdateMemorySettingReq) error
 	GetChatLLMSetting(ctx context.Context) (*appdto.ChatLLMSetting, error)
 	UpdateChatLLMSetting(ctx context.Context, req *appdto.UpdateChatLLMSettingReq) error
+	GetPricingSetting(ctx context.Context) (*appdto.PricingSetting, error)
+	UpdatePricingSetting(ctx context.Context, req *appdto.UpdatePricingSettingReq) error
+	GetDelegationSetting(ctx context.Context) (*appdto.DelegationSetting, error)
+	UpdateDelegationSetting(ctx context.Context, req *appdto.UpdateDelegationSettingReq) error
 }
 
 type app struct {
@@ -252,3 +257,82 @@ func (a *app) UpdateMemorySetting(ctx context.Context, req *appdto.UpdateMemoryS
 	setting.Value = string(valueBytes)
 	return a.sp.Update(ctx, setting)
 }
+
+func (a *app) GetPricingSetting(ctx context.Context) (*appdto.PricingSetting, error) {
+	setting, err := a.sp.GetBy(ctx, a.sp.Where(a.sp.Field().Group.Eq("llm"), a.sp.Field().Key.Eq("pricing")))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) || ec.IsErrCode(err, ec.NoFound) {
+			pricingConfig := &appdto.PricingConfig{}
+			return &appdto.PricingSetting{PricingConfig: pricingConfig}, nil
+		}
+		return nil, err
+	}
+	pricingConfig := &appdto.PricingConfig{}
+	if err := json.Unmarshal([]byte(setting.Value), pricingConfig); err != nil {
+		return nil, err
+	}
+	return &appdto.PricingSetting{PricingConfig: pricingConfig}, nil
+}
+
+func (a *app) UpdatePricingSetting(ctx context.Context, req *appdto.UpdatePricingSettingReq) error {
+	valueBytes, err := json.Marshal(req.PricingConfig)
+	if err != nil {
+		return err
+	}
+	setting, err := a.sp.GetBy(ctx, a.sp.Where(a.sp.Field().Group.Eq("llm"), a.sp.Field().Key.Eq("pricing")))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) || ec.IsErrCode(err, ec.NoFound) {
+			setting = &model.Setting{
+				Group: "llm",
+				Key:   "pricing",
+				Value: string(valueBytes),
+			}
+			_, err = a.sp.Create(ctx, setting)
+			return err
+		}
+		return err
+	}
+	setting.Value = string(valueBytes)
+	return a.sp.Update(ctx, setting)
+}
+
+func (a *app) GetDelegationSetting(ctx context.Context) (*appdto.DelegationSetting, error) {
+	setting, err := a.sp.GetBy(ctx, a.sp.Where(a.sp.Field().Group.Eq("agent"), a.sp.Field().Key.Eq("delegation")))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) || ec.IsErrCode(err, ec.NoFound) {
+			delegationConfig := &appdto.DelegationConfig{}
+			return &appdto.DelegationSetting{DelegationConfig: delegationConfig}, nil
+		}
+		return nil, err
+	}
+	delegationConfig := &appdto.DelegationConfig{}
+	if err := json.Unmarshal([]byte(setting.Value), delegationConfig); err != nil {
+		return nil, err
+	}
+	return &appdto.DelegationSetting{DelegationConfig: delegationConfig}, nil
+}
+
+func (a *app) UpdateDelegationSetting(ctx context.Context, req *appdto.UpdateDelegationSettingReq) error {
+	if req.DelegationConfig == nil || req.MaxDepth < 0 || req.TimeoutSeconds < 0 {
+		return errcode.DelegationSettingInvalid
+	}
+	valueBytes, err := json.Marshal(req.DelegationConfig)
+	if err != nil {
+		return err
+	}
+	setting, err := a.sp.GetBy(ctx, a.sp.Where(a.sp.Field().Group.Eq("agent"), a.sp.Field().Key.Eq("delegation")))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) || ec.IsErrCode(err, ec.NoFound) {
+			setting = &model.Setting{
+				Group: "agent",
+				Key:   "delegation",
+				Value: string(valueBytes),
+			}
+			_, err = a.sp.Create(ctx, setting)
+			return err
+		}
+		return err
+	}
+	setting.Value = string(valueBytes)
+	return a.sp.Update(ctx, setting)
+}
@@ -0,0 +1,414 @@
+package sshhost
+
+import (
+	"context"
+	"encoding/base64"
+	"log/slog"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/jinzhu/copier"
+	"github.com/xichan96/cortex-lab/internal/app/role"
+	"github.com/xichan96/cortex-lab/internal/appdto"
+	"github.com/xichan96/cortex-lab/internal/infra/model"
+	"github.com/xichan96/cortex-lab/internal/infra/persist"
+	"github.com/xichan96/cortex-lab/internal/pkg/errcode"
+	"github.com/xichan96/cortex-lab/internal/pkg/secret"
+	"github.com/xichan96/cortex-lab/pkg/ec"
+	"github.com/xichan96/cortex-lab/pkg/sql"
+	"github.com/xichan96/cortex-lab/pkg/web/cctx"
+	"github.com/xichan96/cortex/agent/types"
+	"golang.org/x/crypto/ssh"
+	"gorm.io/gorm"
+)
+
+type AppIer interface {
+	CreateHost(ctx context.Context, req *appdto.CreateSSHHostReq) (string, error)
+	UpdateHost(ctx context.Context, req *appdto.UpdateSSHHostReq) error
+	DeleteHost(ctx context.Context, id string) error
+	GetHost(ctx context.Context, id string) (*appdto.SSHHost, error)
+	GetHosts(ctx context.Context, req *appdto.GetSSHHostsReq) ([]*appdto.SSHHost, int64, error)
+	// ScanHostKey connects to a host of the current user and pins the key it presents
+	ScanHostKey(ctx context.Context, id string) (*appdto.SSHHost, error)
+	// Tool returns the ssh tool of a role run by the current user, nil when
+	// none of the hosts of that user are granted to the role
+	Tool(ctx context.Context, roleID string) types.Tool
+}
+
+type app struct {
+	hp      persist.SSHHostPersistIer
+	gp      persist.SSHHostGrantPersistIer
+	roleApp role.AppIer
+}
+
+func NewApp(hp persist.SSHHostPersistIer, gp persist.SSHHostGrantPersistIer, roleApp role.AppIer) AppIer {
+	return &app{hp: hp, gp: gp, roleApp: roleApp}
+}
+
+var hostNameRegexp = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// check validates a host before it is saved, the credential is checked by
+// the caller since it is only known in clear there
+func (a *app) check(ctx context.Context, host *model.SSHHost) error {
+	if !hostNameRegexp.MatchString(host.Name) {
+		return ec.Wrap(errcode.SSHHostInvalid, "the name may only contain letters, digits, dots, dashes and underscores")
+	}
+	if host.Address == "" || strings.ContainsAny(host.Address, " /") {
+		return ec.Wrap(errcode.SSHHostInvalid, "the address must be a host name or an IP address")
+	}
+	if host.Port < 1 || host.Port > 65535 {
+		return ec.Wrap(errcode.SSHHostInvalid, "the port must be between 1 and 65535")
+	}
+	if host.Username == "" {
+		return ec.Wrap(errcode.SSHHostInvalid, "the username is required")
+	}
+	if host.HostKey != "" {
+		sum, err := base64.RawStdEncoding.DecodeString(strings.TrimPrefix(host.HostKey, "SHA256:"))
+		if !strings.HasPrefix(host.HostKey, "SHA256:") || err != nil || len(sum) != 32 {
+			return ec.Wrap(errcode.SSHHostInvalid, "the host key must be a SHA256 fingerprint, as printed by ssh-keygen -lf")
+		}
+	}
+	for _, name := range host.AllowCommands {
+		if name == "" || strings.ContainsAny(name, "/ \t") {
+			return ec.Wrapf(errcode.SSHHostInvalid, "invalid command name %q, list program names without a path", name)
+		}
+	}
+
+	count, err := a.hp.Count(ctx, func(db *gorm.DB) *gorm.DB {
+		return db.Where("user_id = ? AND name = ? AND id <> ?", host.UserID, host.Name, host.ID)
+	})
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return ec.Wrapf(errcode.SSHHostInvalid, "a host named %s already exists", host.Name)
+	}
+	return nil
+}
+
+// checkRoles makes sure the roles granted a host are roles of the current
+// user, the hosts of a user are never reached by the roles of another one
+func (a *app) checkRoles(ctx context.Context, roleIDs []string) error {
+	userID := cctx.GetUserID[string](ctx)
+	for _, id := range roleIDs {
+		r, err := a.roleApp.GetRole(ctx, id)
+		if err != nil {
+			return err
+		}
+		if r.CreatorID != userID {
+			return gorm.ErrRecordNotFound
+		}
+	}
+	return nil
+}
+
+// setCredential encrypts the password or the private key of a host
+func setCredential(host *model.SSHHost, password, privateKey, passphrase string) error {
+	if (password == "") == (privateKey == "") {
+		return ec.Wrap(errcode.SSHHostInvalid, "set either a password or a private key")
+	}
+	plain := password
+	host.AuthType = model.SSHAuthPassword
+	if privateKey != "" {
+		var err error
+		if passphrase != "" {
+			_, err = ssh.ParsePrivateKeyWithPassphrase([]byte(privateKey), []byte(passphrase))
+		} else {
+			_, err = ssh.ParsePrivateKey([]byte(privateKey))
+		}
+		if err != nil {
+			return ec.Wrapf(errcode.SSHHostInvalid, "invalid private key: %v", err)
+		}
+		plain = privateKey
+		host.AuthType = model.SSHAuthKey
+	}
+
+	credential, err := secret.Encrypt(plain)
+	if err != nil {
+		return err
+	}
+	host.Credential = credential
+	if host.Passphrase, err = secret.Encrypt(passphrase); err != nil {
+		return err
+	}
+	return nil
+}
+
+// ownedHost loads a host of the current user
+func (a *app) ownedHost(ctx context.Context, id string) (*model.SSHHost, error) {
+	host, err := a.hp.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if host.UserID != cctx.GetUserID[string](ctx) {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return host, nil
+}
+
+func (a *app) CreateHost(ctx context.Context, req *appdto.CreateSSHHostReq) (string, error) {
+	host := &model.SSHHost{
+		UserID:        cctx.GetUserID[string](ctx),
+		Name:          req.Name,
+		Description:   req.Description,
+		Address:       req.Address,
+		Port:          req.Port,
+		Username:      req.Username,
+		HostKey:       req.HostKey,
+		AllowCommands: slices.Compact(slices.Sorted(slices.Values(req.AllowCommands))),
+	}
+	if host.Port == 0 {
+		host.Port = 22
+	}
+	if err := a.check(ctx, host); err != nil {
+		return "", err
+	}
+	if err := setCredential(host, req.Password, req.PrivateKey, req.Passphrase); err != nil {
+		return "", err
+	}
+	roleIDs := slices.Compact(slices.Sorted(slices.Values(req.RoleIDs)))
+	if err := a.checkRoles(ctx, roleIDs); err != nil {
+		return "", err
+	}
+
+	var id string
+	err := sql.NewSqlTX().Execute(ctx, func(ctx context.Context) error {
+		var err error
+		if id, err = a.hp.Create(ctx, host); err != nil {
+			return err
+		}
+		return a.grant(ctx, id, roleIDs)
+	})
+	return id, err
+}
+
+func (a *app) grant(ctx context.Context, hostID string, roleIDs []string) error {
+	if len(roleIDs) == 0 {
+		return nil
+	}
+	grants := make([]*model.SSHHostGrant, len(roleIDs))
+	for i, roleID := range roleIDs {
+		grants[i] = &model.SSHHostGrant{HostID: hostID, RoleID: roleID}
+	}
+	return a.gp.CreateBatch(ctx, grants)
+}
+
+func (a *app) UpdateHost(ctx context.Context, req *appdto.UpdateSSHHostReq) error {
+	host, err := a.ownedHost(ctx, req.ID)
+	if err != nil {
+		return err
+	}
+
+	if req.Name != "" {
+		host.Name = req.Name
+	}
+	if req.Description != nil {
+		host.Description = *req.Description
+	}
+	moved := false
+	if req.Address != "" && req.Address != host.Address {
+		host.Address, moved = req.Address, true
+	}
+	if req.Port != 0 && req.Port != host.Port {
+		host.Port, moved = req.Port, true
+	}
+	if req.Username != "" {
+		host.Username = req.Username
+	}
+	switch {
+	case req.HostKey != nil:
+		host.HostKey = *req.HostKey
+	case moved:
+		// the key pinned for the previous address is not the one of the new server
+		host.HostKey = ""
+	}
+	if req.AllowCommands != nil {
+		host.AllowCommands = slices.Compact(slices.Sorted(slices.Values(req.AllowCommands)))
+	}
+	if err := a.check(ctx, host); err != nil {
+		return err
+	}
+	fields := map[string]interface{}{
+		"name":           host.Name,
+		"description":    host.Description,
+		"address":        host.Address,
+		"port":           host.Port,
+		"username":       host.Username,
+		"host_key":       host.HostKey,
+		"allow_commands": host.AllowCommands,
+		"updated_at":     time.Now(),
+	}
+	if req.Password != "" || req.PrivateKey != "" {
+		if err := setCredential(host, req.Password, req.PrivateKey, req.Passphrase); err != nil {
+			return err
+		}
+		fields["auth_type"], fields["credential"], fields["passphrase"] = host.AuthType, host.Credential, host.Passphrase
+	}
+	var roleIDs []string
+	if req.RoleIDs != nil {
+		roleIDs = slices.Compact(slices.Sorted(slices.Values(req.RoleIDs)))
+		if err := a.checkRoles(ctx, roleIDs); err != nil {
+			return err
+		}
+	}
+
+	return sql.NewSqlTX().Execute(ctx, func(ctx context.Context) error {
+		if err := a.hp.UpdateFields(ctx, fields, func(db *gorm.DB) *gorm.DB {
+			return db.Where("id = ?", host.ID)
+		}); err != nil {
+			return err
+		}
+		if req.RoleIDs == nil {
+			return nil
+		}
+		if err := a.gp.DeleteByHostID(ctx, host.ID); err != nil {
+			return err
+		}
+		return a.grant(ctx, host.ID, roleIDs)
+	})
+}
+
+// DeleteHost deletes a host of the current user with its grants
+func (a *app) DeleteHost(ctx context.Context, id string) error {
+	host, err := a.ownedHost(ctx, id)
+	if err != nil {
+		return err
+	}
+	return sql.NewSqlTX().Execute(ctx, func(ctx context.Context) error {
+		if err := a.gp.DeleteByHostID(ctx, host.ID); err != nil {
+			return err
+		}
+		return a.hp.Delete(ctx, host)
+	})
+}
+
+func (a *app) GetHost(ctx context.Context, id string) (*appdto.SSHHost, error) {
+	host, err := a.ownedHost(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	dtos, err := a.toHostDTOs(ctx, []*model.SSHHost{host})
+	if err != nil {
+		return nil, err
+	}
+	return dtos[0], nil
+}
+
+func (a *app) GetHosts(ctx context.Context, req *appdto.GetSSHHostsReq) ([]*appdto.SSHHost, int64, error) {
+	userID := cctx.GetUserID[string](ctx)
+	opts := []func(*gorm.DB) *gorm.DB{
+		func(db *gorm.DB) *gorm.DB {
+			return db.Where("user_id = ?", userID)
+		},
+	}
+	total, err := a.hp.Count(ctx, opts...)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	opts = append(opts, func(db *gorm.DB) *gorm.DB {
+		return db.Order("name ASC")
+	})
+	if req.Page > 0 && req.PageSize > 0 {
+		opts = append(opts, func(db *gorm.DB) *gorm.DB {
+			offset := (req.Page - 1) * req.PageSize
+			return db.Offset(offset).Limit(req.PageSize)
+		})
+	}
+
+	hosts, err := a.hp.GetList(ctx, opts...)
+	if err != nil {
+		return nil, 0, err
+	}
+	dtos, err := a.toHostDTOs(ctx, hosts)
+	if err != nil {
+		return nil, 0, err
+	}
+	return dtos, total, nil
+}
+
+func (a *app) ScanHostKey(ctx context.Context, id string) (*appdto.SSHHost, error) {
+	host, err := a.ownedHost(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	fingerprint, err := scanHostKey(ctx, host)
+	if err != nil {
+		return nil, ec.Wrap(errcode.SSHHostUnreachable, err.Error())
+	}
+	if err := a.hp.UpdateFields(ctx, map[string]interface{}{"host_key": fingerprint, "updated_at": time.Now()}, func(db *gorm.DB) *gorm.DB {
+		return db.Where("id = ?", host.ID)
+	}); err != nil {
+		return nil, err
+	}
+	host.HostKey = fingerprint
+	dtos, err := a.toHostDTOs(ctx, []*model.SSHHost{host})
+	if err != nil {
+		return nil, err
+	}
+	return dtos[0], nil
+}
+
+func (a *app) Tool(ctx context.Context, roleID string) types.Tool {
+	userID := cctx.GetUserID[string](ctx)
+	hosts, err := a.grantedHosts(ctx, userID, roleID)
+	if err != nil {
+		slog.Error("Failed to load the SSH hosts of the role", "error", err, "role_id", roleID)
+		return nil
+	}
+	if len(hosts) == 0 {
+		return nil
+	}
+	return &sshTool{app: a, userID: userID, roleID: roleID, hosts: hosts}
+}
+
+// grantedHosts loads the hosts of userID granted to roleID
+func (a *app) grantedHosts(ctx context.Context, userID, roleID string) ([]*model.SSHHost, error) {
+	if userID == "" || roleID == "" {
+		return nil, nil
+	}
+	grants, err := a.gp.GetList(ctx, func(db *gorm.DB) *gorm.DB {
+		return db.Where("role_id = ?", roleID)
+	})
+	if err != nil || len(grants) == 0 {
+		return nil, err
+	}
+	hostIDs := make([]string, len(grants))
+	for i, g := range grants {
+		hostIDs[i] = g.HostID
+	}
+	return a.hp.GetList(ctx, func(db *gorm.DB) *gorm.DB {
+		return db.Where("id IN ? AND user_id = ?", hostIDs, userID).Order("name ASC")
+	})
+}
+
+func (a *app) toHostDTOs(ctx context.Context, hosts []*model.SSHHost) ([]*appdto.SSHHost, error) {
+	dtos := make([]*appdto.SSHHost, len(hosts))
+	if len(hosts) == 0 {
+		return dtos, nil
+	}
+	hostIDs := make([]string, len(hosts))
+	for i, h := range hosts {
+		hostIDs[i] = h.ID
+	}
+	grants, err := a.gp.GetList(ctx, func(db *gorm.DB) *gorm.DB {
+		return db.Where("host_id IN ?", hostIDs).Order("role_id ASC")
+	})
+	if err != nil {
+		return nil, err
+	}
+	roleIDs := make(map[string][]string, len(hosts))
+	for _, g := range grants {
+		roleIDs[g.HostID] = append(roleIDs[g.HostID], g.RoleID)
+	}
+	for i, h := range hosts {
+		dto := &appdto.SSHHost{}
+		_ = copier.Copy(dto, h)
+		dto.AllowCommands = []string(h.AllowCommands)
+		dto.RoleIDs = roleIDs[h.ID]
+		dtos[i] = dto
+	}
+	return dtos, nil
+}
@@ -0,0 +1,311 @@
+package sshhost
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/xichan96/cortex-lab/internal/infra/model"
+	"github.com/xichan96/cortex-lab/internal/pkg/secret"
+	"github.com/xichan96/cortex/agent/types"
+	cortexerrors "github.com/xichan96/cortex/pkg/errors"
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	// dialTimeout caps the connection and the handshake with a host
+	dialTimeout = 15 * time.Second
+	// defaultTimeout and maxTimeout bound how long a command runs
+	defaultTimeout = 30 * time.Second
+	maxTimeout     = 5 * time.Minute
+	// maxOutputBytes caps the stdout and the stderr of a command
+	maxOutputBytes = 64 << 10
+)
+
+// shellMetacharacters chain or substitute commands, they are refused on the
+// hosts with an allowlist since only the first program would be checked
+const shellMetacharacters = ";&|$`<>(){}\n\r\\"
+
+// errHostKeyScanned aborts the handshake of a scan once the key is known
+var errHostKeyScanned = errors.New("host key scanned")
+
+// sshTool runs commands on the hosts granted to a role. The model only
+// names the host, the address and the credential stay on the server.
+type sshTool struct {
+	app    *app
+	userID string
+	roleID string
+	// hosts are the hosts granted when the tool was set up, they describe it to the model
+	hosts []*model.SSHHost
+}
+
+func (t *sshTool) Name() string {
+	return "ssh"
+}
+
+func (t *sshTool) Description() string {
+	var b strings.Builder
+	b.WriteString("Execute a command on a remote server via SSH. Pick the server by its name, the connection is managed for you. Available hosts:")
+	for _, h := range t.hosts {
+		fmt.Fprintf(&b, "\n- %s (%s@%s)", h.Name, h.Username, net.JoinHostPort(h.Address, strconv.Itoa(h.Port)))
+		if h.Description != "" {
+			b.WriteString(": " + h.Description)
+		}
+		if len(h.AllowCommands) > 0 {
+			b.WriteString(". Allowed commands: " + strings.Join(h.AllowCommands, ", "))
+		}
+	}
+	return b.String()
+}
+
+func (t *sshTool) Schema() map[string]interface{} {
+	names := make([]string, len(t.hosts))
+	for i, h := range t.hosts {
+		names[i] = h.Name
+	}
+	return map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"host": map[string]interface{}{
+				"type":        "string",
+				"description": "Name of the host to run the command on",
+				"enum":        names,
+			},
+			"command": map[string]interface{}{
+				"type":        "string",
+				"description": "Command to execute on the remote server",
+			},
+			"timeout": map[string]interface{}{
+				"type":        "integer",
+				"description": "Command timeout in seconds (default: 30, at most 300)",
+			},
+		},
+		"required": []string{"host", "command"},
+	}
+}
+
+func (t *sshTool) Metadata() types.ToolMetadata {
+	return types.ToolMetadata{
+		SourceNodeName: "ssh",
+		IsFromToolkit:  false,
+		ToolType:       "builtin",
+	}
+}
+
+func (t *sshTool) Execute(input map[string]interface{}) (interface{}, error) {
+	return t.ExecuteContext(context.Background(), input)
+}
+
+func (t *sshTool) ExecuteContext(ctx context.Context, input map[string]interface{}) (interface{}, error) {
+	name, ok := input["host"].(string)
+	if !ok || name == "" {
+		return nil, cortexerrors.EC_PARAMETER_MISSING.Wrap(fmt.Errorf("'host' parameter must be the name of a host"))
+	}
+	command, ok := input["command"].(string)
+	if !ok {
+		return nil, cortexerrors.EC_TOOL_PARAMETER_INVALID.Wrap(fmt.Errorf("invalid 'command' parameter: must be a string"))
+	}
+	if strings.TrimSpace(command) == "" {
+		return nil, cortexerrors.EC_PARAMETER_MISSING.Wrap(fmt.Errorf("'command' parameter cannot be empty"))
+	}
+	timeout := defaultTimeout
+	if timeoutVal, ok := input["timeout"].(float64); ok && timeoutVal > 0 {
+		timeout = time.Duration(timeoutVal) * time.Second
+	} else if timeoutVal, ok := input["timeout"].(int); ok && timeoutVal > 0 {
+		timeout = time.Duration(timeoutVal) * time.Second
+	}
+	timeout = min(timeout, maxTimeout)
+
+	// the grants and the host are loaded again, they may have changed since the run started
+	hosts, err := t.app.grantedHosts(ctx, t.userID, t.roleID)
+	if err != nil {
+		return nil, cortexerrors.EC_TOOL_EXECUTION_FAILED.Wrap(err)
+	}
+	i := slices.IndexFunc(hosts, func(h *model.SSHHost) bool { return h.Name == name })
+	if i < 0 {
+		names := make([]string, len(hosts))
+		for i, h := range hosts {
+			names[i] = h.Name
+		}
+		return nil, cortexerrors.EC_TOOL_PARAMETER_INVALID.Wrap(fmt.Errorf("unknown host %q, available hosts: %s", name, strings.Join(names, ", ")))
+	}
+	host := hosts[i]
+	if err := allow(host, command); err != nil {
+		return nil, cortexerrors.EC_PERMISSION_DENIED.Wrap(err)
+	}
+	if host.HostKey == "" {
+		return nil, cortexerrors.EC_TOOL_EXECUTION_FAILED.Wrap(fmt.Errorf("the host key of %s is not pinned, it must be scanned before commands run on it", host.Name))
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	client, err := dial(ctx, host, pinnedHostKey(host.HostKey))
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return nil, cortexerrors.EC_TOOL_EXECUTION_TIMEOUT.Wrap(fmt.Errorf("connection to %s timed out after %v", host.Name, timeout))
+		}
+		return nil, cortexerrors.EC_TOOL_EXECUTION_FAILED.Wrap(fmt.Errorf("failed to establish SSH connection to %s: %w", host.Name, err))
+	}
+	// closing the client interrupts the command when the call is cancelled or times out
+	stop := context.AfterFunc(ctx, func() { client.Close() })
+	defer stop()
+	defer client.Close()
+
+	session, err := client.NewSession()
+	if err != nil {
+		return nil, cortexerrors.EC_TOOL_EXECUTION_FAILED.Wrap(fmt.Errorf("failed to open SSH session on %s: %w", host.Name, err))
+	}
+	defer session.Close()
+	stdout, stderr := &limitedBuffer{max: maxOutputBytes}, &limitedBuffer{max: maxOutputBytes}
+	session.Stdout = stdout
+	session.Stderr = stderr
+
+	err = session.Run(command)
+	if ctx.Err() == context.DeadlineExceeded {
+		return nil, cortexerrors.EC_TOOL_EXECUTION_TIMEOUT.Wrap(fmt.Errorf("command execution timeout after %v", timeout))
+	}
+
+	output := map[string]interface{}{
+		"host":      host.Name,
+		"command":   command,
+		"exit_code": 0,
+		"stdout":    stdout.String(),
+		"stderr":    stderr.String(),
+	}
+	if stdout.truncated || stderr.truncated {
+		output["truncated"] = true
+	}
+	if err != nil {
+		var exitErr *ssh.ExitError
+		if !errors.As(err, &exitErr) {
+			return nil, cortexerrors.EC_TOOL_EXECUTION_FAILED.Wrap(fmt.Errorf("failed to execute command: %w", err))
+		}
+		output["exit_code"] = exitErr.ExitStatus()
+	}
+	return output, nil
+}
+
+// allow checks a command against the allowlist of the host, any command is
+// allowed on a host without one
+func allow(host *model.SSHHost, command string) error {
+	if len(host.AllowCommands) == 0 {
+		return nil
+	}
+	if strings.ContainsAny(command, shellMetacharacters) {
+		return fmt.Errorf("commands on %s cannot use shell operators, run one allowed command at a time", host.Name)
+	}
+	program := strings.Fields(command)[0]
+	if strings.Contains(program, "/") {
+		// the allowlist names programs, a path could point at any binary named like one
+		return fmt.Errorf("commands on %s are run by name, %s is a path", host.Name, program)
+	}
+	if !slices.Contains(host.AllowCommands, program) {
+		return fmt.Errorf("%s is not allowed to run on %s, allowed commands: %s", program, host.Name, strings.Join(host.AllowCommands, ", "))
+	}
+	return nil
+}
+
+// pinnedHostKey accepts the host only when it presents the pinned key
+func pinnedHostKey(fingerprint string) ssh.HostKeyCallback {
+	return func(_ string, _ net.Addr, key ssh.PublicKey) error {
+		if got := ssh.FingerprintSHA256(key); got != fingerprint {
+			return fmt.Errorf("host key mismatch: the host presented %s but %s is pinned", got, fingerprint)
+		}
+		return nil
+	}
+}
+
+// scanHostKey returns the fingerprint of the key a host presents, without
+// authenticating
+func scanHostKey(ctx context.Context, host *model.SSHHost) (string, error) {
+	var fingerprint string
+	_, err := dial(ctx, host, func(_ string, _ net.Addr, key ssh.PublicKey) error {
+		fingerprint = ssh.FingerprintSHA256(key)
+		return errHostKeyScanned
+	})
+	if fingerprint != "" {
+		return fingerprint, nil
+	}
+	if err == nil {
+		err = errors.New("the host presented no key")
+	}
+	return "", err
+}
+
+// dial connects to a host with its decrypted credential
+func dial(ctx context.Context, host *model.SSHHost, hostKeyCallback ssh.HostKeyCallback) (*ssh.Client, error) {
+	auth, err := authMethod(host)
+	if err != nil {
+		return nil, err
+	}
+	addr := net.JoinHostPort(host.Address, strconv.Itoa(host.Port))
+	dialer := &net.Dialer{Timeout: dialTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	// the handshake is bounded by the context as well as by dialTimeout
+	deadline := time.Now().Add(dialTimeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	_ = conn.SetDeadline(deadline)
+	c, chans, reqs, err := ssh.NewClientConn(conn, addr, &ssh.ClientConfig{
+		User:            host.Username,
+		Auth:            []ssh.AuthMethod{auth},
+		HostKeyCallback: hostKeyCallback,
+		Timeout:         dialTimeout,
+	})
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	_ = conn.SetDeadline(time.Time{})
+	return ssh.NewClient(c, chans, reqs), nil
+}
+
+func authMethod(host *model.SSHHost) (ssh.AuthMethod, error) {
+	credential, err := secret.Decrypt(host.Credential)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt the credential: %w", err)
+	}
+	if host.AuthType == model.SSHAuthPassword {
+		return ssh.Password(credential), nil
+	}
+	passphrase, err := secret.Decrypt(host.Passphrase)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt the passphrase: %w", err)
+	}
+	var signer ssh.Signer
+	if passphrase != "" {
+		signer, err = ssh.ParsePrivateKeyWithPassphrase([]byte(credential), []byte(passphrase))
+	} else {
+		signer, err = ssh.ParsePrivateKey([]byte(credential))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid private key: %w", err)
+	}
+	return ssh.PublicKeys(signer), nil
+}
+
+// limitedBuffer keeps the first max bytes written to it
+type limitedBuffer struct {
+	bytes.Buffer
+	max       int
+	truncated bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if b.Len()+len(p) > b.max {
+		b.truncated = true
+		b.Buffer.Write(p[:max(b.max-b.Len(), 0)])
+		return len(p), nil
+	}
+	return b.Buffer.Write(p)
+}
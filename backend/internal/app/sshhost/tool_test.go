@@ -0,0 +1,69 @@
+package sshhost
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"testing"
+
+	"github.com/xichan96/cortex-lab/internal/infra/model"
+	"golang.org/x/crypto/ssh"
+)
+
+func TestAllow(t *testing.T) {
+	restricted := &model.SSHHost{Name: "web", AllowCommands: model.SSHCommands{"ls", "uptime", "systemctl"}}
+	open := &model.SSHHost{Name: "dev"}
+
+	tests := []struct {
+		name    string
+		host    *model.SSHHost
+		command string
+		wantErr bool
+	}{
+		{"no allowlist", open, "rm -rf /tmp/x; reboot", false},
+		{"allowed", restricted, "ls -la /var/log", false},
+		{"allowed with leading space", restricted, "  uptime", false},
+		{"not allowed", restricted, "rm -rf /", true},
+		{"absolute path", restricted, "/tmp/evil/ls -la", true},
+		{"relative path", restricted, "./ls", true},
+		{"semicolon", restricted, "ls; rm -rf /", true},
+		{"and", restricted, "ls && rm -rf /", true},
+		{"pipe", restricted, "ls | sh", true},
+		{"substitution", restricted, "ls $(rm -rf /)", true},
+		{"backticks", restricted, "ls `reboot`", true},
+		{"redirect", restricted, "ls > /etc/passwd", true},
+		{"newline", restricted, "ls\nreboot", true},
+		{"background", restricted, "uptime & reboot", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := allow(tt.host, tt.command); (err != nil) != tt.wantErr {
+				t.Errorf("allow(%q) error = %v, wantErr %v", tt.command, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func newHostKey(t *testing.T) ssh.PublicKey {
+	t.Helper()
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := ssh.NewPublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func TestPinnedHostKey(t *testing.T) {
+	pinned := newHostKey(t)
+	callback := pinnedHostKey(ssh.FingerprintSHA256(pinned))
+
+	if err := callback("web:22", nil, pinned); err != nil {
+		t.Errorf("pinnedHostKey() rejected the pinned key: %v", err)
+	}
+	if err := callback("web:22", nil, newHostKey(t)); err == nil {
+		t.Errorf("pinnedHostKey() accepted another key, want a host key mismatch")
+	}
+}
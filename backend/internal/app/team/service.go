@@ -0,0 +1,250 @@
+package team
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/jinzhu/copier"
+	"github.com/xichan96/cortex-lab/internal/app/role"
+	"github.com/xichan96/cortex-lab/internal/appdto"
+	"github.com/xichan96/cortex-lab/internal/infra/model"
+	"github.com/xichan96/cortex-lab/internal/infra/persist"
+	"github.com/xichan96/cortex-lab/internal/pkg/errcode"
+	"github.com/xichan96/cortex-lab/pkg/web/cctx"
+	"gorm.io/gorm"
+)
+
+type AppIer interface {
+	CreateTeam(ctx context.Context, req *appdto.CreateTeamReq) (string, error)
+	UpdateTeam(ctx context.Context, req *appdto.UpdateTeamReq) error
+	DeleteTeam(ctx context.Context, id string) error
+	GetTeam(ctx context.Context, id string) (*appdto.Team, error)
+	GetTeams(ctx context.Context, req *appdto.GetTeamsReq) ([]*appdto.Team, int64, error)
+}
+
+type app struct {
+	tp      persist.TeamPersistIer
+	roleApp role.AppIer
+}
+
+func NewApp(tp persist.TeamPersistIer, roleApp role.AppIer) AppIer {
+	return &app{tp: tp, roleApp: roleApp}
+}
+
+// checkRoles makes sure the supervisor and the members of a team exist and
+// that the supervisor is not one of the members
+func (a *app) checkRoles(ctx context.Context, supervisorID string, memberIDs []string) ([]string, error) {
+	if supervisorID == "" {
+		return nil, errcode.TeamInvalid
+	}
+	members := make([]string, 0, len(memberIDs))
+	for _, id := range memberIDs {
+		if id == "" || id == supervisorID {
+			return nil, errcode.TeamInvalid
+		}
+		if !slices.Contains(members, id) {
+			members = append(members, id)
+		}
+	}
+	if len(members) == 0 {
+		return nil, errcode.TeamInvalid
+	}
+	for _, id := range append([]string{supervisorID}, members...) {
+		if _, err := a.roleApp.GetRole(ctx, id); err != nil {
+			return nil, err
+		}
+	}
+	return members, nil
+}
+
+// ownedTeam loads a team created by the current user
+func (a *app) ownedTeam(ctx context.Context, id string) (*model.Team, error) {
+	team, err := a.tp.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if team.CreatorID != cctx.GetUserID[string](ctx) {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return team, nil
+}
+
+func (a *app) CreateTeam(ctx context.Context, req *appdto.CreateTeamReq) (string, error) {
+	members, err := a.checkRoles(ctx, req.SupervisorRoleID, req.MemberRoleIDs)
+	if err != nil {
+		return "", err
+	}
+	membersJSON, _ := json.Marshal(members)
+
+	isPublic := 0
+	if req.IsPublic {
+		isPublic = 1
+	}
+
+	team := &model.Team{
+		Name:             req.Name,
+		Description:      req.Description,
+		Avatar:           req.Avatar,
+		SupervisorRoleID: req.SupervisorRoleID,
+		MemberRoleIDs:    string(membersJSON),
+		CreatorID:        cctx.GetUserID[string](ctx),
+		IsPublic:         isPublic,
+	}
+	return a.tp.Create(ctx, team)
+}
+
+func (a *app) UpdateTeam(ctx context.Context, req *appdto.UpdateTeamReq) error {
+	team, err := a.ownedTeam(ctx, req.ID)
+	if err != nil {
+		return err
+	}
+
+	if req.Name != "" {
+		team.Name = req.Name
+	}
+	if req.Description != "" {
+		team.Description = req.Description
+	}
+	if req.Avatar != "" {
+		team.Avatar = req.Avatar
+	}
+	if req.IsPublic != nil {
+		if *req.IsPublic {
+			team.IsPublic = 1
+		} else {
+			team.IsPublic = 0
+		}
+	}
+
+	if req.SupervisorRoleID != "" || req.MemberRoleIDs != nil {
+		if req.SupervisorRoleID != "" {
+			team.SupervisorRoleID = req.SupervisorRoleID
+		}
+		members := req.MemberRoleIDs
+		if members == nil {
+			_ = json.Unmarshal([]byte(team.MemberRoleIDs), &members)
+		}
+		if members, err = a.checkRoles(ctx, team.SupervisorRoleID, members); err != nil {
+			return err
+		}
+		membersJSON, _ := json.Marshal(members)
+		team.MemberRoleIDs = string(membersJSON)
+	}
+
+	team.UpdatedAt = time.Now()
+	return a.tp.Update(ctx, team)
+}
+
+func (a *app) DeleteTeam(ctx context.Context, id string) error {
+	team, err := a.ownedTeam(ctx, id)
+	if err != nil {
+		return err
+	}
+	return a.tp.Delete(ctx, team)
+}
+
+// GetTeam returns a team visible to the current user with its supervisor and members
+func (a *app) GetTeam(ctx context.Context, id string) (*appdto.Team, error) {
+	team, err := a.tp.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if team.IsPublic != 1 && team.CreatorID != cctx.GetUserID[string](ctx) {
+		return nil, gorm.ErrRecordNotFound
+	}
+	dto := toTeamDTO(team)
+	if dto.Supervisor, err = a.member(ctx, team.SupervisorRoleID); err != nil {
+		return nil, err
+	}
+	dto.Members = make([]*appdto.TeamMember, 0, len(dto.MemberRoleIDs))
+	for _, roleID := range dto.MemberRoleIDs {
+		m, err := a.member(ctx, roleID)
+		if err != nil {
+			return nil, err
+		}
+		if m != nil {
+			dto.Members = append(dto.Members, m)
+		}
+	}
+	return dto, nil
+}
+
+// member loads a role of a team, nil when the role was deleted
+func (a *app) member(ctx context.Context, roleID string) (*appdto.TeamMember, error) {
+	r, err := a.roleApp.GetRole(ctx, roleID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &appdto.TeamMember{
+		RoleID:      r.ID,
+		Name:        r.Name,
+		Description: r.Description,
+		Avatar:      r.Avatar,
+	}, nil
+}
+
+func (a *app) GetTeams(ctx context.Context, req *appdto.GetTeamsReq) ([]*appdto.Team, int64, error) {
+	userID := cctx.GetUserID[string](ctx)
+	opts := []func(*gorm.DB) *gorm.DB{}
+
+	if req.Keyword != "" {
+		opts = append(opts, func(db *gorm.DB) *gorm.DB {
+			return db.Where("name LIKE ? OR description LIKE ?", "%"+req.Keyword+"%", "%"+req.Keyword+"%")
+		})
+	}
+
+	switch req.Scope {
+	case "mine":
+		opts = append(opts, func(db *gorm.DB) *gorm.DB {
+			return db.Where("creator_id = ?", userID)
+		})
+	case "public":
+		opts = append(opts, func(db *gorm.DB) *gorm.DB {
+			return db.Where("is_public = ?", 1)
+		})
+	default:
+		opts = append(opts, func(db *gorm.DB) *gorm.DB {
+			return db.Where("is_public = ? OR creator_id = ?", 1, userID)
+		})
+	}
+
+	total, err := a.tp.Count(ctx, opts...)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	opts = append(opts, func(db *gorm.DB) *gorm.DB {
+		return db.Order("created_at DESC")
+	})
+	if req.Page > 0 && req.PageSize > 0 {
+		opts = append(opts, func(db *gorm.DB) *gorm.DB {
+			offset := (req.Page - 1) * req.PageSize
+			return db.Offset(offset).Limit(req.PageSize)
+		})
+	}
+
+	teams, err := a.tp.GetList(ctx, opts...)
+	if err != nil {
+		return nil, 0, err
+	}
+	dtos := make([]*appdto.Team, len(teams))
+	for i, t := range teams {
+		dtos[i] = toTeamDTO(t)
+	}
+	return dtos, total, nil
+}
+
+func toTeamDTO(team *model.Team) *appdto.Team {
+	dto := &appdto.Team{}
+	_ = copier.Copy(dto, team)
+	dto.MemberRoleIDs = nil
+	_ = json.Unmarshal([]byte(team.MemberRoleIDs), &dto.MemberRoleIDs)
+	dto.IsPublic = team.IsPublic == 1
+	return dto
+}
@@ -0,0 +1,151 @@
+package usage
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/xichan96/cortex-lab/internal/app/setting"
+	"github.com/xichan96/cortex-lab/internal/appdto"
+	"github.com/xichan96/cortex-lab/internal/config"
+	"github.com/xichan96/cortex-lab/internal/infra/persist"
+	"gorm.io/gorm"
+)
+
+type AppIer interface {
+	GetUsage(ctx context.Context, req *appdto.GetUsageReq) (*appdto.Usage, error)
+}
+
+type app struct {
+	up         persist.UsageRecordPersistIer
+	settingSrv setting.AppIer
+}
+
+func NewApp(up persist.UsageRecordPersistIer, settingSrv setting.AppIer) AppIer {
+	return &app{up: up, settingSrv: settingSrv}
+}
+
+type usageRow struct {
+	GroupKey         string
+	Messages         int64
+	PromptTokens     int64
+	CompletionTokens int64
+	TotalTokens      int64
+	Cost             float64
+}
+
+const usageColumns = "COUNT(*) AS messages, " +
+	"COALESCE(SUM(prompt_tokens), 0) AS prompt_tokens, " +
+	"COALESCE(SUM(completion_tokens), 0) AS completion_tokens, " +
+	"COALESCE(SUM(total_tokens), 0) AS total_tokens, " +
+	"COALESCE(SUM(cost), 0) AS cost"
+
+// groupExpr returns the SQL expression usage records are grouped by
+func groupExpr(groupBy string) string {
+	switch groupBy {
+	case "session":
+		return "session_id"
+	case "role":
+		return "role_id"
+	case "user":
+		return "user_id"
+	case "model":
+		return "model_name"
+	default:
+		if config.Config.DBDriver == "sqlite" {
+			return "substr(created_at, 1, 10)"
+		}
+		return "DATE_FORMAT(created_at, '%Y-%m-%d')"
+	}
+}
+
+func (a *app) GetUsage(ctx context.Context, req *appdto.GetUsageReq) (*appdto.Usage, error) {
+	filters, err := usageFilters(req)
+	if err != nil {
+		return nil, err
+	}
+
+	var total usageRow
+	totalOpts := append(filters[:len(filters):len(filters)], func(db *gorm.DB) *gorm.DB {
+		return db.Select(usageColumns)
+	})
+	if err := a.up.Gets(ctx, &total, totalOpts...); err != nil {
+		return nil, err
+	}
+
+	expr := groupExpr(req.GroupBy)
+	order := "cost DESC"
+	if req.GroupBy == "" || req.GroupBy == "day" {
+		order = "group_key ASC"
+	}
+	var rows []*usageRow
+	groupOpts := append(filters[:len(filters):len(filters)], func(db *gorm.DB) *gorm.DB {
+		return db.Select(expr + " AS group_key, " + usageColumns).Group(expr).Order(order)
+	})
+	if err := a.up.Gets(ctx, &rows, groupOpts...); err != nil {
+		return nil, err
+	}
+
+	usage := &appdto.Usage{
+		Total: toSummary(&total),
+		List:  make([]*appdto.UsageSummary, len(rows)),
+	}
+	for i, r := range rows {
+		usage.List[i] = toSummary(r)
+	}
+	if pricing, err := a.settingSrv.GetPricingSetting(ctx); err == nil && pricing != nil && pricing.PricingConfig != nil {
+		usage.Currency = pricing.Currency
+	}
+	return usage, nil
+}
+
+func usageFilters(req *appdto.GetUsageReq) ([]func(*gorm.DB) *gorm.DB, error) {
+	var opts []func(*gorm.DB) *gorm.DB
+	if req.SessionID != "" {
+		opts = append(opts, func(db *gorm.DB) *gorm.DB {
+			return db.Where("session_id = ?", req.SessionID)
+		})
+	}
+	if req.RoleID != "" {
+		opts = append(opts, func(db *gorm.DB) *gorm.DB {
+			return db.Where("role_id = ?", req.RoleID)
+		})
+	}
+	if req.UserID != "" {
+		opts = append(opts, func(db *gorm.DB) *gorm.DB {
+			return db.Where("user_id = ?", req.UserID)
+		})
+	}
+	if req.StartDate != "" {
+		start, err := time.ParseInLocation(time.DateOnly, req.StartDate, time.Local)
+		if err != nil {
+			return nil, fmt.Errorf("invalid start_date: %w", err)
+		}
+		opts = append(opts, func(db *gorm.DB) *gorm.DB {
+			return db.Where("created_at >= ?", start)
+		})
+	}
+	if req.EndDate != "" {
+		end, err := time.ParseInLocation(time.DateOnly, req.EndDate, time.Local)
+		if err != nil {
+			return nil, fmt.Errorf("invalid end_date: %w", err)
+		}
+		// end_date is inclusive
+		end = end.AddDate(0, 0, 1)
+		opts = append(opts, func(db *gorm.DB) *gorm.DB {
+			return db.Where("created_at < ?", end)
+		})
+	}
+	return opts, nil
+}
+
+func toSummary(r *usageRow) *appdto.UsageSummary {
+	return &appdto.UsageSummary{
+		Key:              r.GroupKey,
+		Messages:         r.Messages,
+		PromptTokens:     r.PromptTokens,
+		CompletionTokens: r.CompletionTokens,
+		TotalTokens:      r.TotalTokens,
+		Cost:             r.Cost,
+	}
+}
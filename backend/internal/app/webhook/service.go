@@ -0,0 +1,291 @@
+package webhook
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+
+	"github.com/jinzhu/copier"
+	"github.com/xichan96/cortex-lab/internal/app/chat"
+	"github.com/xichan96/cortex-lab/internal/app/role"
+	"github.com/xichan96/cortex-lab/internal/appdto"
+	"github.com/xichan96/cortex-lab/internal/infra/model"
+	"github.com/xichan96/cortex-lab/internal/infra/persist"
+	"github.com/xichan96/cortex-lab/internal/pkg/errcode"
+	"github.com/xichan96/cortex-lab/internal/pkg/secret"
+	"github.com/xichan96/cortex-lab/pkg/ec"
+	"github.com/xichan96/cortex-lab/pkg/sql"
+	"github.com/xichan96/cortex-lab/pkg/web/cctx"
+	"gorm.io/gorm"
+)
+
+type AppIer interface {
+	CreateWebhook(ctx context.Context, req *appdto.CreateWebhookReq) (*appdto.WebhookSecret, error)
+	UpdateWebhook(ctx context.Context, req *appdto.UpdateWebhookReq) error
+	DeleteWebhook(ctx context.Context, id string) error
+	GetWebhook(ctx context.Context, id string) (*appdto.Webhook, error)
+	GetWebhooks(ctx context.Context, req *appdto.GetWebhooksReq) ([]*appdto.Webhook, int64, error)
+	RotateSecret(ctx context.Context, id string) (*appdto.WebhookSecret, error)
+	GetInvocations(ctx context.Context, webhookID string, req *appdto.GetWebhookInvocationsReq) ([]*appdto.WebhookInvocation, int64, error)
+	// Trigger hands an event posted to a webhook to its role. It is served without authentication,
+	// the body must be signed at timestamp with the secret of the webhook.
+	Trigger(ctx context.Context, id, signature, timestamp string, headers map[string]string, body []byte) (*appdto.WebhookInvocation, error)
+}
+
+type app struct {
+	wp      persist.WebhookPersistIer
+	ip      persist.WebhookInvocationPersistIer
+	roleApp role.AppIer
+	chatApp chat.AppIer
+}
+
+func NewApp(wp persist.WebhookPersistIer, ip persist.WebhookInvocationPersistIer, roleApp role.AppIer, chatApp chat.AppIer) AppIer {
+	return &app{wp: wp, ip: ip, roleApp: roleApp, chatApp: chatApp}
+}
+
+// newSecret returns a random secret and the encrypted value it is stored as
+func newSecret() (plain, sealed string, err error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	plain = hex.EncodeToString(b)
+	if sealed, err = secret.Encrypt(plain); err != nil {
+		return "", "", err
+	}
+	return plain, sealed, nil
+}
+
+func boolInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// check makes sure the role exists, the model is complete and the template parses
+func (a *app) check(ctx context.Context, webhook *model.Webhook) error {
+	if (webhook.Provider == "") != (webhook.ModelName == "") {
+		return ec.Wrap(errcode.WebhookInvalid, "set both the provider and the model, or neither")
+	}
+	if _, err := parseTemplate(webhook.PromptTemplate); err != nil {
+		return ec.Wrap(errcode.WebhookInvalid, err.Error())
+	}
+	if _, err := a.roleApp.GetRole(ctx, webhook.RoleID); err != nil {
+		return err
+	}
+	return nil
+}
+
+// ownedWebhook loads a webhook of the current user
+func (a *app) ownedWebhook(ctx context.Context, id string) (*model.Webhook, error) {
+	webhook, err := a.wp.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if webhook.UserID != cctx.GetUserID[string](ctx) {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return webhook, nil
+}
+
+func (a *app) CreateWebhook(ctx context.Context, req *appdto.CreateWebhookReq) (*appdto.WebhookSecret, error) {
+	plain, sealed, err := newSecret()
+	if err != nil {
+		return nil, err
+	}
+	webhook := &model.Webhook{
+		UserID:         cctx.GetUserID[string](ctx),
+		Name:           req.Name,
+		RoleID:         req.RoleID,
+		Provider:       req.Provider,
+		ModelName:      req.ModelName,
+		Secret:         sealed,
+		PromptTemplate: req.PromptTemplate,
+		Async:          boolInt(req.Async),
+		Enabled:        1,
+	}
+	if req.Enabled != nil {
+		webhook.Enabled = boolInt(*req.Enabled)
+	}
+	if err := a.check(ctx, webhook); err != nil {
+		return nil, err
+	}
+	id, err := a.wp.Create(ctx, webhook)
+	if err != nil {
+		return nil, err
+	}
+	return &appdto.WebhookSecret{ID: id, Secret: plain}, nil
+}
+
+func (a *app) UpdateWebhook(ctx context.Context, req *appdto.UpdateWebhookReq) error {
+	webhook, err := a.ownedWebhook(ctx, req.ID)
+	if err != nil {
+		return err
+	}
+
+	if req.Name != "" {
+		webhook.Name = req.Name
+	}
+	if req.RoleID != "" {
+		webhook.RoleID = req.RoleID
+	}
+	if req.Provider != nil {
+		webhook.Provider = *req.Provider
+	}
+	if req.ModelName != nil {
+		webhook.ModelName = *req.ModelName
+	}
+	if req.PromptTemplate != nil {
+		webhook.PromptTemplate = *req.PromptTemplate
+	}
+	if req.Async != nil {
+		webhook.Async = boolInt(*req.Async)
+	}
+	if req.Enabled != nil {
+		webhook.Enabled = boolInt(*req.Enabled)
+	}
+	if err := a.check(ctx, webhook); err != nil {
+		return err
+	}
+
+	return a.wp.UpdateFields(ctx, map[string]interface{}{
+		"name":            webhook.Name,
+		"role_id":         webhook.RoleID,
+		"provider":        webhook.Provider,
+		"model_name":      webhook.ModelName,
+		"prompt_template": webhook.PromptTemplate,
+		"async":           webhook.Async,
+		"enabled":         webhook.Enabled,
+		"updated_at":      time.Now(),
+	}, func(db *gorm.DB) *gorm.DB {
+		return db.Where("id = ?", webhook.ID)
+	})
+}
+
+// DeleteWebhook deletes a webhook of the current user with its invocations,
+// the sessions of the invocations are kept
+func (a *app) DeleteWebhook(ctx context.Context, id string) error {
+	webhook, err := a.ownedWebhook(ctx, id)
+	if err != nil {
+		return err
+	}
+	return sql.NewSqlTX().Execute(ctx, func(ctx context.Context) error {
+		if err := a.ip.DeleteByWebhookID(ctx, webhook.ID); err != nil {
+			return err
+		}
+		return a.wp.Delete(ctx, webhook)
+	})
+}
+
+func (a *app) GetWebhook(ctx context.Context, id string) (*appdto.Webhook, error) {
+	webhook, err := a.ownedWebhook(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return toWebhookDTO(webhook), nil
+}
+
+func (a *app) GetWebhooks(ctx context.Context, req *appdto.GetWebhooksReq) ([]*appdto.Webhook, int64, error) {
+	userID := cctx.GetUserID[string](ctx)
+	opts := []func(*gorm.DB) *gorm.DB{
+		func(db *gorm.DB) *gorm.DB {
+			return db.Where("user_id = ?", userID)
+		},
+	}
+	total, err := a.wp.Count(ctx, opts...)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	opts = append(opts, func(db *gorm.DB) *gorm.DB {
+		return db.Order("created_at DESC")
+	})
+	if req.Page > 0 && req.PageSize > 0 {
+		opts = append(opts, func(db *gorm.DB) *gorm.DB {
+			offset := (req.Page - 1) * req.PageSize
+			return db.Offset(offset).Limit(req.PageSize)
+		})
+	}
+
+	webhooks, err := a.wp.GetList(ctx, opts...)
+	if err != nil {
+		return nil, 0, err
+	}
+	dtos := make([]*appdto.Webhook, len(webhooks))
+	for i, w := range webhooks {
+		dtos[i] = toWebhookDTO(w)
+	}
+	return dtos, total, nil
+}
+
+// RotateSecret replaces the secret of a webhook of the current user, the
+// events signed with the previous one are rejected from now on
+func (a *app) RotateSecret(ctx context.Context, id string) (*appdto.WebhookSecret, error) {
+	webhook, err := a.ownedWebhook(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	plain, sealed, err := newSecret()
+	if err != nil {
+		return nil, err
+	}
+	if err := a.wp.UpdateFields(ctx, map[string]interface{}{"secret": sealed, "updated_at": time.Now()}, func(db *gorm.DB) *gorm.DB {
+		return db.Where("id = ?", webhook.ID)
+	}); err != nil {
+		return nil, err
+	}
+	return &appdto.WebhookSecret{ID: webhook.ID, Secret: plain}, nil
+}
+
+// GetInvocations lists the invocations of a webhook of the current user, latest first
+func (a *app) GetInvocations(ctx context.Context, webhookID string, req *appdto.GetWebhookInvocationsReq) ([]*appdto.WebhookInvocation, int64, error) {
+	webhook, err := a.ownedWebhook(ctx, webhookID)
+	if err != nil {
+		return nil, 0, err
+	}
+	opts := []func(*gorm.DB) *gorm.DB{
+		func(db *gorm.DB) *gorm.DB {
+			return db.Where("webhook_id = ?", webhook.ID)
+		},
+	}
+	total, err := a.ip.Count(ctx, opts...)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	opts = append(opts, func(db *gorm.DB) *gorm.DB {
+		return db.Order("created_at DESC")
+	})
+	if req.Page > 0 && req.PageSize > 0 {
+		opts = append(opts, func(db *gorm.DB) *gorm.DB {
+			offset := (req.Page - 1) * req.PageSize
+			return db.Offset(offset).Limit(req.PageSize)
+		})
+	}
+
+	invocations, err := a.ip.GetList(ctx, opts...)
+	if err != nil {
+		return nil, 0, err
+	}
+	dtos := make([]*appdto.WebhookInvocation, len(invocations))
+	for i, inv := range invocations {
+		dtos[i] = toInvocationDTO(inv)
+	}
+	return dtos, total, nil
+}
+
+func toWebhookDTO(webhook *model.Webhook) *appdto.Webhook {
+	dto := &appdto.Webhook{}
+	_ = copier.Copy(dto, webhook)
+	dto.Async = webhook.Async == 1
+	dto.Enabled = webhook.Enabled == 1
+	return dto
+}
+
+func toInvocationDTO(invocation *model.WebhookInvocation) *appdto.WebhookInvocation {
+	dto := &appdto.WebhookInvocation{}
+	_ = copier.Copy(dto, invocation)
+	return dto
+}
@@ -0,0 +1,176 @@
+package webhook
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/xichan96/cortex-lab/internal/appdto"
+	"github.com/xichan96/cortex-lab/internal/infra/model"
+	"github.com/xichan96/cortex-lab/internal/pkg/errcode"
+	"github.com/xichan96/cortex-lab/internal/pkg/secret"
+	"github.com/xichan96/cortex-lab/pkg/ec"
+	"github.com/xichan96/cortex-lab/pkg/web/cctx"
+	"gorm.io/gorm"
+)
+
+// defaultPromptTemplate is used by the webhooks without a template
+const defaultPromptTemplate = "Event received by the webhook {{.Name}}:\n\n{{.Body}}"
+
+var templateFuncs = template.FuncMap{
+	"json": func(v any) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	},
+}
+
+func parseTemplate(text string) (*template.Template, error) {
+	if strings.TrimSpace(text) == "" {
+		text = defaultPromptTemplate
+	}
+	return template.New("prompt").Funcs(templateFuncs).Option("missingkey=zero").Parse(text)
+}
+
+// promptData is what the prompt template of a webhook sees
+type promptData struct {
+	Name    string
+	Payload any // nil when the body is not JSON
+	Body    string
+	Headers map[string]string // without the credential headers
+}
+
+// signatureTolerance is how far the timestamp of a signed event may be from
+// now, a captured event cannot be replayed once it is older
+const signatureTolerance = 5 * time.Minute
+
+// credentialHeaders are the parts of the header names kept out of the prompt,
+// the role must not see the credentials a caller sends along
+var credentialHeaders = []string{"authorization", "cookie", "token", "secret", "signature", "password", "key"}
+
+// verifySignature checks the HMAC-SHA256 of timestamp + "." + body, given as
+// hex with or without the "sha256=" prefix. The timestamp is the Unix time in
+// seconds the event was signed at.
+func verifySignature(secret, signature, timestamp string, body []byte, now time.Time) bool {
+	sec, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return false
+	}
+	if skew := now.Sub(time.Unix(sec, 0)); skew > signatureTolerance || skew < -signatureTolerance {
+		return false
+	}
+	got, err := hex.DecodeString(strings.TrimPrefix(strings.TrimSpace(signature), "sha256="))
+	if err != nil || len(got) == 0 {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return hmac.Equal(got, mac.Sum(nil))
+}
+
+// promptHeaders returns the request headers without the credential ones
+func promptHeaders(headers map[string]string) map[string]string {
+	out := make(map[string]string, len(headers))
+	for name, value := range headers {
+		lower := strings.ToLower(name)
+		if !slices.ContainsFunc(credentialHeaders, func(part string) bool { return strings.Contains(lower, part) }) {
+			out[name] = value
+		}
+	}
+	return out
+}
+
+func (a *app) Trigger(ctx context.Context, id, signature, timestamp string, headers map[string]string, body []byte) (*appdto.WebhookInvocation, error) {
+	webhook, err := a.wp.GetByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errcode.WebhookNotFound
+		}
+		return nil, err
+	}
+	if webhook.Enabled != 1 {
+		return nil, errcode.WebhookNotFound
+	}
+	key, err := secret.Decrypt(webhook.Secret)
+	if err != nil {
+		return nil, err
+	}
+	if !verifySignature(key, signature, timestamp, body, time.Now()) {
+		return nil, errcode.WebhookSignatureInvalid
+	}
+
+	tmpl, err := parseTemplate(webhook.PromptTemplate)
+	if err != nil {
+		return nil, ec.Wrap(errcode.WebhookInvalid, err.Error())
+	}
+	data := &promptData{Name: webhook.Name, Body: string(body), Headers: promptHeaders(headers)}
+	_ = json.Unmarshal(body, &data.Payload)
+	var prompt strings.Builder
+	if err := tmpl.Execute(&prompt, data); err != nil {
+		return nil, ec.Wrap(errcode.WebhookInvalid, err.Error())
+	}
+	if strings.TrimSpace(prompt.String()) == "" {
+		return nil, ec.Wrap(errcode.WebhookInvalid, "the prompt rendered from the payload is empty")
+	}
+
+	// the role runs as the owner of the webhook
+	ctx = cctx.WithContext(ctx)
+	cctx.SetUserID(ctx, webhook.UserID)
+
+	now := time.Now()
+	invocation := &model.WebhookInvocation{
+		WebhookID: webhook.ID,
+		UserID:    webhook.UserID,
+		Status:    model.WebhookInvocationRunning,
+	}
+	if _, err := a.ip.Create(ctx, invocation); err != nil {
+		return nil, err
+	}
+	if err := a.wp.UpdateFields(ctx, map[string]interface{}{"last_triggered_at": now}, func(db *gorm.DB) *gorm.DB {
+		return db.Where("id = ?", webhook.ID)
+	}); err != nil {
+		slog.Error("Failed to update webhook", "error", err, "webhook_id", webhook.ID)
+	}
+
+	if webhook.Async == 1 {
+		dto := toInvocationDTO(invocation)
+		bg := cctx.WithContext(context.Background())
+		cctx.SetUserID(bg, webhook.UserID)
+		go a.invoke(bg, webhook, invocation, prompt.String())
+		return dto, nil
+	}
+	a.invoke(ctx, webhook, invocation, prompt.String())
+	return toInvocationDTO(invocation), nil
+}
+
+// invoke sends the prompt to the role of the webhook in a new session and
+// records the outcome
+func (a *app) invoke(ctx context.Context, webhook *model.Webhook, invocation *model.WebhookInvocation, prompt string) {
+	req := &appdto.SendChatMessageReq{
+		Messages: []appdto.ChatMessageItem{
+			{Role: "user", Content: prompt},
+		},
+	}
+	sessionID, messages, err := a.chatApp.SendMessage(ctx, webhook.RoleID, webhook.Provider, webhook.ModelName, "", req)
+	now := time.Now()
+	invocation.SessionID, invocation.FinishedAt = sessionID, &now
+	invocation.Status = model.WebhookInvocationSucceeded
+	if err != nil {
+		invocation.Status, invocation.Error = model.WebhookInvocationFailed, err.Error()
+	} else if len(messages) > 0 {
+		invocation.Output = messages[len(messages)-1].Content
+	}
+	// the invocation is recorded even when the caller went away
+	if err := a.ip.Update(context.WithoutCancel(ctx), invocation); err != nil {
+		slog.Error("Failed to record webhook invocation", "error", err, "webhook_id", webhook.ID, "invocation_id", invocation.ID)
+	}
+}
@@ -0,0 +1,87 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestVerifySignature(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	ts := strconv.FormatInt(now.Unix(), 10)
+	stale := strconv.FormatInt(now.Add(-6*time.Minute).Unix(), 10)
+	future := strconv.FormatInt(now.Add(6*time.Minute).Unix(), 10)
+	recent := strconv.FormatInt(now.Add(-4*time.Minute).Unix(), 10)
+	body := []byte(`{"action":"opened"}`)
+
+	tests := []struct {
+		name      string
+		secret    string
+		signature string
+		timestamp string
+		body      []byte
+		want      bool
+	}{
+		{"valid", "s3cret", sign("s3cret", ts, body), ts, body, true},
+		{"valid with prefix", "s3cret", "sha256=" + sign("s3cret", ts, body), ts, body, true},
+		{"within tolerance", "s3cret", sign("s3cret", recent, body), recent, body, true},
+		{"tampered body", "s3cret", sign("s3cret", ts, body), ts, []byte(`{"action":"closed"}`), false},
+		{"wrong secret", "other", sign("s3cret", ts, body), ts, body, false},
+		{"body signed alone", "s3cret", bodyOnly("s3cret", body), ts, body, false},
+		{"timestamp swapped", "s3cret", sign("s3cret", recent, body), ts, body, false},
+		{"stale timestamp", "s3cret", sign("s3cret", stale, body), stale, body, false},
+		{"future timestamp", "s3cret", sign("s3cret", future, body), future, body, false},
+		{"missing timestamp", "s3cret", sign("s3cret", "", body), "", body, false},
+		{"missing signature", "s3cret", "", ts, body, false},
+		{"not hex", "s3cret", "sha256=zz", ts, body, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := verifySignature(tt.secret, tt.signature, tt.timestamp, tt.body, now); got != tt.want {
+				t.Errorf("verifySignature() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// bodyOnly is the signature of the body without the timestamp
+func bodyOnly(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestPromptHeaders(t *testing.T) {
+	headers := map[string]string{
+		"Content-Type":          "application/json",
+		"User-Agent":            "GitHub-Hookshot/1",
+		"X-Github-Event":        "push",
+		"Authorization":         "Bearer abc",
+		"Proxy-Authorization":   "Basic abc",
+		"Cookie":                "session=abc",
+		"X-Api-Key":             "abc",
+		"X-Auth-Token":          "abc",
+		"X-Signature-256":       "sha256=abc",
+		"X-Signature-Timestamp": "1700000000",
+		"X-Webhook-Secret":      "abc",
+	}
+	got := promptHeaders(headers)
+	for _, name := range []string{"Content-Type", "User-Agent", "X-Github-Event"} {
+		if got[name] != headers[name] {
+			t.Errorf("promptHeaders() dropped %s", name)
+		}
+	}
+	if len(got) != 3 {
+		t.Errorf("promptHeaders() = %v, want only the non-credential headers", got)
+	}
+}
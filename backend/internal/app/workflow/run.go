@@ -0,0 +1,364 @@
+package workflow
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jinzhu/copier"
+	"github.com/xichan96/cortex-lab/internal/appdto"
+	"github.com/xichan96/cortex-lab/internal/infra/model"
+	"github.com/xichan96/cortex-lab/pkg/web/cctx"
+	"gorm.io/gorm"
+)
+
+const (
+	// heartbeatInterval is how often a server refreshes the heartbeat of the runs it executes
+	heartbeatInterval = time.Minute
+	// runLease is how long a running run outlives its last heartbeat before it is considered interrupted
+	runLease = 3 * heartbeatInterval
+)
+
+// RunWorkflow runs a workflow visible to the current user. Each step is sent
+// to its role through the chat engine once the steps it depends on have
+// ended, independent steps run concurrently.
+func (a *app) RunWorkflow(ctx context.Context, id string, req *appdto.RunWorkflowReq) (*appdto.WorkflowRun, error) {
+	workflow, err := a.visibleWorkflow(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	userID := cctx.GetUserID[string](ctx)
+	run := &model.WorkflowRun{
+		WorkflowID: workflow.ID,
+		UserID:     userID,
+		Status:     model.WorkflowRunRunning,
+		Input:      req.Input,
+		Steps:      make(model.WorkflowStepRuns, len(workflow.Steps)),
+	}
+	for i, step := range workflow.Steps {
+		run.Steps[i] = &model.WorkflowStepRun{StepID: step.ID, RoleID: step.RoleID, Status: model.WorkflowStepPending}
+	}
+	started := time.Now().UTC()
+	run.HeartbeatAt = &started
+	if _, err := a.rp.Create(ctx, run); err != nil {
+		return nil, err
+	}
+	// only heartbeat writes it from now on, saving the run must not move it back
+	run.HeartbeatAt = nil
+
+	if req.Async {
+		dto := toRunDTO(run)
+		bg := cctx.WithContext(context.Background())
+		cctx.SetUserID(bg, userID)
+		go a.execute(bg, workflow.Steps, run)
+		return dto, nil
+	}
+	a.execute(ctx, workflow.Steps, run)
+	return toRunDTO(run), nil
+}
+
+// execute runs the steps of a run wave by wave and records each of them. A
+// step is skipped when its condition does not hold or when all the steps it
+// depends on were skipped, the first failed step fails the run.
+func (a *app) execute(ctx context.Context, steps model.WorkflowSteps, run *model.WorkflowRun) {
+	// the run is recorded even when the caller goes away
+	saveCtx := context.WithoutCancel(ctx)
+	done := make(chan struct{})
+	go a.heartbeat(saveCtx, run.ID, done)
+	defer close(done)
+	records := make(map[string]*model.WorkflowStepRun, len(steps))
+	deps := make([][]string, len(steps))
+	for i := range steps {
+		records[steps[i].ID] = run.Steps[i]
+		deps[i] = stepDeps(&steps[i])
+	}
+
+	for run.Error == "" {
+		var wave []int
+		skippedAny := false
+		for i, rec := range run.Steps {
+			if rec.Status != model.WorkflowStepPending {
+				continue
+			}
+			ready, skipped := true, len(deps[i]) > 0
+			for _, dep := range deps[i] {
+				switch records[dep].Status {
+				case model.WorkflowStepSucceeded:
+					skipped = false
+				case model.WorkflowStepSkipped:
+				default:
+					ready = false
+				}
+			}
+			if !ready {
+				continue
+			}
+			now := time.Now()
+			if skipped || !conditionHolds(steps[i].Condition, records) {
+				rec.Status, rec.FinishedAt = model.WorkflowStepSkipped, &now
+				skippedAny = true
+				continue
+			}
+			rec.Status, rec.StartedAt = model.WorkflowStepRunning, &now
+			rec.Input = renderInput(steps[i].Input, run.Input, records)
+			wave = append(wave, i)
+		}
+		if len(wave) == 0 {
+			// skipping steps may have made others ready
+			if skippedAny {
+				continue
+			}
+			break
+		}
+		a.save(saveCtx, run)
+
+		var wg sync.WaitGroup
+		for _, i := range wave {
+			wg.Add(1)
+			go func(step *model.WorkflowStep, rec *model.WorkflowStepRun) {
+				defer wg.Done()
+				sessionID, output, err := a.runStep(ctx, step.RoleID, rec.Input)
+				now := time.Now()
+				rec.SessionID, rec.Output, rec.FinishedAt = sessionID, output, &now
+				rec.Status = model.WorkflowStepSucceeded
+				if err != nil {
+					rec.Status, rec.Error = model.WorkflowStepFailed, err.Error()
+				}
+			}(&steps[i], run.Steps[i])
+		}
+		wg.Wait()
+		for _, i := range wave {
+			if rec := run.Steps[i]; rec.Status == model.WorkflowStepFailed && run.Error == "" {
+				run.Error = fmt.Sprintf("step %s failed: %s", rec.StepID, rec.Error)
+			}
+		}
+	}
+
+	now := time.Now()
+	run.FinishedAt = &now
+	run.Status = model.WorkflowRunSucceeded
+	if run.Error != "" {
+		run.Status = model.WorkflowRunFailed
+	}
+	run.Output = runOutput(steps, deps, run.Steps)
+	a.save(saveCtx, run)
+}
+
+// StartRunMonitor fails the runs whose server stopped executing them, on
+// start and then every heartbeatInterval. The runs of the other servers
+// sharing the DB keep running.
+func (a *app) StartRunMonitor(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(heartbeatInterval)
+		defer ticker.Stop()
+		for {
+			a.closeInterrupted(ctx)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// closeInterrupted fails the running runs whose heartbeat is older than
+// runLease with the steps they were running. The heartbeats are written in UTC
+// and only compared with each other, the drivers store times with the offset
+// of the server writing them.
+func (a *app) closeInterrupted(ctx context.Context) {
+	now := time.Now().UTC()
+	// the runs recorded before heartbeats existed get one now, they are closed once it expires
+	err := a.rp.Update(ctx, &model.WorkflowRun{HeartbeatAt: &now}, func(db *gorm.DB) *gorm.DB {
+		return db.Where("status = ? AND heartbeat_at IS NULL", model.WorkflowRunRunning)
+	})
+	if err != nil {
+		slog.Error("Failed to start the heartbeat of workflow runs", "error", err)
+	}
+	expired := func(db *gorm.DB) *gorm.DB {
+		return db.Where("status = ? AND heartbeat_at < ?", model.WorkflowRunRunning, now.Add(-runLease))
+	}
+	runs, err := a.rp.GetList(ctx, expired)
+	if err != nil {
+		slog.Error("Failed to load interrupted workflow runs", "error", err)
+		return
+	}
+	for _, run := range runs {
+		for _, rec := range run.Steps {
+			if rec.Status == model.WorkflowStepRunning {
+				rec.Status, rec.Error, rec.FinishedAt = model.WorkflowStepFailed, "interrupted, the server running it stopped", &now
+			}
+		}
+		run.Status, run.Error, run.FinishedAt = model.WorkflowRunFailed, "interrupted, the server running it stopped", &now
+		// a run whose heartbeat came back in between is left alone
+		if err := a.rp.Update(ctx, run, expired); err != nil {
+			slog.Error("Failed to close an interrupted workflow run", "error", err, "run_id", run.ID)
+		}
+	}
+}
+
+// heartbeat refreshes the heartbeat of a run until done is closed
+func (a *app) heartbeat(ctx context.Context, runID string, done <-chan struct{}) {
+	ticker := time.NewTicker(heartbeatInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+		now := time.Now().UTC()
+		err := a.rp.Update(ctx, &model.WorkflowRun{ID: runID, HeartbeatAt: &now}, func(db *gorm.DB) *gorm.DB {
+			return db.Where("status = ?", model.WorkflowRunRunning)
+		})
+		if err != nil {
+			slog.Error("Failed to refresh the heartbeat of a workflow run", "error", err, "run_id", runID)
+		}
+	}
+}
+
+func (a *app) save(ctx context.Context, run *model.WorkflowRun) {
+	if err := a.rp.Update(ctx, run); err != nil {
+		slog.Error("Failed to save workflow run", "error", err, "run_id", run.ID)
+	}
+}
+
+// runStep sends the input of a step to its role in a new session and returns the reply
+func (a *app) runStep(ctx context.Context, roleID, input string) (string, string, error) {
+	req := &appdto.SendChatMessageReq{
+		Messages: []appdto.ChatMessageItem{
+			{Role: "user", Content: input},
+		},
+	}
+	sessionID, messages, err := a.chatApp.SendMessage(ctx, roleID, "", "", "", req)
+	if err != nil {
+		return sessionID, "", err
+	}
+	if len(messages) == 0 {
+		return sessionID, "", nil
+	}
+	return sessionID, messages[len(messages)-1].Content, nil
+}
+
+// renderInput fills the references of an input template, a skipped step has no output
+func renderInput(tmpl, input string, records map[string]*model.WorkflowStepRun) string {
+	return templateRef.ReplaceAllStringFunc(tmpl, func(ref string) string {
+		m := templateRef.FindStringSubmatch(ref)
+		if m[1] == "" {
+			return input
+		}
+		return records[m[1]].Output
+	})
+}
+
+func conditionHolds(c *model.WorkflowCondition, records map[string]*model.WorkflowStepRun) bool {
+	if c == nil {
+		return true
+	}
+	output := strings.TrimSpace(records[c.Step].Output)
+	value := strings.TrimSpace(c.Value)
+	switch c.Operator {
+	case "contains":
+		return strings.Contains(strings.ToLower(output), strings.ToLower(value))
+	case "not_contains":
+		return !strings.Contains(strings.ToLower(output), strings.ToLower(value))
+	case "equals":
+		return strings.EqualFold(output, value)
+	case "not_equals":
+		return !strings.EqualFold(output, value)
+	case "matches":
+		re, err := regexp.Compile(c.Value)
+		return err == nil && re.MatchString(output)
+	case "empty":
+		return output == ""
+	case "not_empty":
+		return output != ""
+	}
+	return false
+}
+
+// runOutput joins the outputs of the last steps, the ones no other step depends on
+func runOutput(steps model.WorkflowSteps, deps [][]string, records model.WorkflowStepRuns) string {
+	needed := make(map[string]bool, len(steps))
+	for _, d := range deps {
+		for _, dep := range d {
+			needed[dep] = true
+		}
+	}
+	var outputs []string
+	for i, step := range steps {
+		if !needed[step.ID] && records[i].Status == model.WorkflowStepSucceeded && records[i].Output != "" {
+			outputs = append(outputs, records[i].Output)
+		}
+	}
+	return strings.Join(outputs, "\n\n")
+}
+
+// ownedRun loads a run of a workflow started by the current user
+func (a *app) ownedRun(ctx context.Context, workflowID, runID string) (*model.WorkflowRun, error) {
+	run, err := a.rp.GetByID(ctx, runID)
+	if err != nil {
+		return nil, err
+	}
+	if run.WorkflowID != workflowID || run.UserID != cctx.GetUserID[string](ctx) {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return run, nil
+}
+
+func (a *app) GetRun(ctx context.Context, workflowID, runID string) (*appdto.WorkflowRun, error) {
+	run, err := a.ownedRun(ctx, workflowID, runID)
+	if err != nil {
+		return nil, err
+	}
+	return toRunDTO(run), nil
+}
+
+// GetRuns lists the runs of a workflow started by the current user, latest first
+func (a *app) GetRuns(ctx context.Context, workflowID string, req *appdto.GetWorkflowRunsReq) ([]*appdto.WorkflowRun, int64, error) {
+	userID := cctx.GetUserID[string](ctx)
+	opts := []func(*gorm.DB) *gorm.DB{
+		func(db *gorm.DB) *gorm.DB {
+			return db.Where("workflow_id = ? AND user_id = ?", workflowID, userID)
+		},
+	}
+	total, err := a.rp.Count(ctx, opts...)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	opts = append(opts, func(db *gorm.DB) *gorm.DB {
+		return db.Order("created_at DESC")
+	})
+	if req.Page > 0 && req.PageSize > 0 {
+		opts = append(opts, func(db *gorm.DB) *gorm.DB {
+			offset := (req.Page - 1) * req.PageSize
+			return db.Offset(offset).Limit(req.PageSize)
+		})
+	}
+
+	runs, err := a.rp.GetList(ctx, opts...)
+	if err != nil {
+		return nil, 0, err
+	}
+	dtos := make([]*appdto.WorkflowRun, len(runs))
+	for i, r := range runs {
+		dtos[i] = toRunDTO(r)
+	}
+	return dtos, total, nil
+}
+
+func toRunDTO(run *model.WorkflowRun) *appdto.WorkflowRun {
+	dto := &appdto.WorkflowRun{}
+	_ = copier.Copy(dto, run)
+	dto.Steps = make([]*appdto.WorkflowStepRun, len(run.Steps))
+	for i, step := range run.Steps {
+		dto.Steps[i] = &appdto.WorkflowStepRun{}
+		_ = copier.Copy(dto.Steps[i], step)
+	}
+	return dto
+}
@@ -0,0 +1,238 @@
+package workflow
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"github.com/xichan96/cortex-lab/internal/app/chat"
+	"github.com/xichan96/cortex-lab/internal/appdto"
+	"github.com/xichan96/cortex-lab/internal/infra/model"
+	"github.com/xichan96/cortex-lab/internal/infra/persist"
+	"github.com/xichan96/cortex-lab/pkg/sql"
+)
+
+func TestConditionHolds(t *testing.T) {
+	records := map[string]*model.WorkflowStepRun{
+		"a":     {Output: "  Approved: YES \n"},
+		"blank": {Output: " \n"},
+	}
+	tests := []struct {
+		operator string
+		step     string
+		value    string
+		want     bool
+	}{
+		{"contains", "a", "yes", true},
+		{"contains", "a", "no", false},
+		{"not_contains", "a", "no", true},
+		{"not_contains", "a", "approved", false},
+		{"equals", "a", "approved: yes", true},
+		{"equals", "a", "approved", false},
+		{"not_equals", "a", "approved", true},
+		{"matches", "a", `^Approved:\s+YES$`, true},
+		{"matches", "a", `^no`, false},
+		{"matches", "a", `(`, false},
+		{"empty", "blank", "", true},
+		{"empty", "a", "", false},
+		{"not_empty", "a", "", true},
+		{"not_empty", "blank", "", false},
+		{"starts_with", "a", "Approved", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.operator+" "+tt.value, func(t *testing.T) {
+			c := &model.WorkflowCondition{Step: tt.step, Operator: tt.operator, Value: tt.value}
+			if got := conditionHolds(c, records); got != tt.want {
+				t.Errorf("conditionHolds() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+	if !conditionHolds(nil, records) {
+		t.Errorf("conditionHolds(nil) = false, want true")
+	}
+}
+
+func TestRenderInput(t *testing.T) {
+	records := map[string]*model.WorkflowStepRun{
+		"draft":   {Output: "the draft"},
+		"skipped": {Status: model.WorkflowStepSkipped},
+	}
+	tests := []struct {
+		tmpl string
+		want string
+	}{
+		{"{{input}}", "the input"},
+		{"Review: {{steps.draft.output}}", "Review: the draft"},
+		{"{{ steps.draft.output }} for {{ input }}", "the draft for the input"},
+		{"[{{steps.skipped.output}}]", "[]"},
+		{"{{steps.draft.input}} {{other}}", "{{steps.draft.input}} {{other}}"},
+	}
+	for _, tt := range tests {
+		if got := renderInput(tt.tmpl, "the input", records); got != tt.want {
+			t.Errorf("renderInput(%q) = %q, want %q", tt.tmpl, got, tt.want)
+		}
+	}
+}
+
+// stubChat answers a step with the reply configured for its role, the role
+// "fail" fails
+type stubChat struct {
+	chat.AppIer
+	replies map[string]string
+}
+
+func (c stubChat) SendMessage(_ context.Context, roleID, _, _, _ string, _ *appdto.SendChatMessageReq) (string, []*appdto.ChatMessage, error) {
+	if roleID == "fail" {
+		return "session-" + roleID, nil, errors.New("model unavailable")
+	}
+	return "session-" + roleID, []*appdto.ChatMessage{{Role: "assistant", Content: c.replies[roleID]}}, nil
+}
+
+// memRuns drops the saves, the test reads the run it passes to execute
+type memRuns struct {
+	persist.WorkflowRunPersistIer
+}
+
+func (memRuns) Update(context.Context, *model.WorkflowRun, ...func(*gorm.DB) *gorm.DB) error {
+	return nil
+}
+
+func newRun(steps model.WorkflowSteps) *model.WorkflowRun {
+	run := &model.WorkflowRun{ID: "run1", Status: model.WorkflowRunRunning, Input: "in", Steps: make(model.WorkflowStepRuns, len(steps))}
+	for i, step := range steps {
+		run.Steps[i] = &model.WorkflowStepRun{StepID: step.ID, RoleID: step.RoleID, Status: model.WorkflowStepPending}
+	}
+	return run
+}
+
+func TestExecute_Skip(t *testing.T) {
+	steps := model.WorkflowSteps{
+		{ID: "a", RoleID: "a", Input: "{{input}}"},
+		{ID: "b", RoleID: "b", Input: "x", Condition: &model.WorkflowCondition{Step: "a", Operator: "contains", Value: "yes"}},
+		// all its dependencies were skipped
+		{ID: "c", RoleID: "c", Input: "{{steps.b.output}}"},
+		// one of its dependencies ran
+		{ID: "d", RoleID: "d", Input: "{{steps.b.output}}|{{steps.a.output}}|{{input}}", DependsOn: []string{"a"}},
+		{ID: "e", RoleID: "e", Input: "x", Condition: &model.WorkflowCondition{Step: "a", Operator: "not_contains", Value: "yes"}},
+	}
+	a := &app{rp: memRuns{}, chatApp: stubChat{replies: map[string]string{"a": "no", "d": "D", "e": "E"}}}
+	run := newRun(steps)
+	a.execute(context.Background(), steps, run)
+
+	tests := []struct {
+		step   int
+		status string
+		input  string
+	}{
+		{0, model.WorkflowStepSucceeded, "in"},
+		{1, model.WorkflowStepSkipped, ""},
+		{2, model.WorkflowStepSkipped, ""},
+		{3, model.WorkflowStepSucceeded, "|no|in"},
+		{4, model.WorkflowStepSucceeded, "x"},
+	}
+	for _, tt := range tests {
+		rec := run.Steps[tt.step]
+		if rec.Status != tt.status || rec.Input != tt.input {
+			t.Errorf("step %s = %s with input %q, want %s with %q", rec.StepID, rec.Status, rec.Input, tt.status, tt.input)
+		}
+	}
+	if run.Status != model.WorkflowRunSucceeded || run.Output != "D\n\nE" {
+		t.Errorf("run = %s with output %q, want succeeded with the outputs of d and e", run.Status, run.Output)
+	}
+}
+
+func TestExecute_Failure(t *testing.T) {
+	steps := model.WorkflowSteps{
+		{ID: "a", RoleID: "fail", Input: "x"},
+		{ID: "b", RoleID: "b", Input: "{{steps.a.output}}"},
+	}
+	a := &app{rp: memRuns{}, chatApp: stubChat{}}
+	run := newRun(steps)
+	a.execute(context.Background(), steps, run)
+
+	if run.Status != model.WorkflowRunFailed || run.Error == "" {
+		t.Errorf("run = %s with error %q, want failed", run.Status, run.Error)
+	}
+	if run.Steps[0].Status != model.WorkflowStepFailed || run.Steps[1].Status != model.WorkflowStepPending {
+		t.Errorf("steps = %s, %s, want failed, pending", run.Steps[0].Status, run.Steps[1].Status)
+	}
+}
+
+func TestCloseInterrupted(t *testing.T) {
+	for _, zone := range []*time.Location{time.FixedZone("UTC+8", 8*3600), time.FixedZone("UTC-5", -5*3600)} {
+		t.Run(zone.String(), func(t *testing.T) {
+			local := time.Local
+			time.Local = zone
+			t.Cleanup(func() { time.Local = local })
+
+			db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			// every connection to :memory: opens a new DB
+			if sqlDB, err := db.DB(); err == nil {
+				sqlDB.SetMaxOpenConns(1)
+			}
+			if err := db.AutoMigrate(&model.WorkflowRun{}); err != nil {
+				t.Fatal(err)
+			}
+			sql.SetDefaultDB(func() *gorm.DB { return db })
+			t.Cleanup(func() { sql.SetDefaultDB(nil) })
+
+			ctx := context.Background()
+			a := &app{rp: persist.NewWorkflowRunPersist()}
+			now := time.Now().UTC()
+			live, dead := now.Add(-heartbeatInterval), now.Add(-2*runLease)
+			runs := map[string]*model.WorkflowRun{
+				"live":   {HeartbeatAt: &live},
+				"dead":   {HeartbeatAt: &dead},
+				"legacy": {},
+			}
+			for id, run := range runs {
+				run.ID, run.WorkflowID, run.UserID, run.Status = id, "w1", "u1", model.WorkflowRunRunning
+				run.Steps = model.WorkflowStepRuns{
+					{StepID: "a", Status: model.WorkflowStepSucceeded},
+					{StepID: "b", Status: model.WorkflowStepRunning},
+					{StepID: "c", Status: model.WorkflowStepPending},
+				}
+				if _, err := a.rp.Create(ctx, run); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			a.closeInterrupted(ctx)
+
+			tests := []struct {
+				id    string
+				want  string
+				steps []string
+			}{
+				{"live", model.WorkflowRunRunning, []string{model.WorkflowStepSucceeded, model.WorkflowStepRunning, model.WorkflowStepPending}},
+				{"dead", model.WorkflowRunFailed, []string{model.WorkflowStepSucceeded, model.WorkflowStepFailed, model.WorkflowStepPending}},
+				// a run without a heartbeat gets a lease first
+				{"legacy", model.WorkflowRunRunning, []string{model.WorkflowStepSucceeded, model.WorkflowStepRunning, model.WorkflowStepPending}},
+			}
+			for _, tt := range tests {
+				run, err := a.rp.GetByID(ctx, tt.id)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if run.Status != tt.want {
+					t.Errorf("run %s status = %s, want %s", tt.id, run.Status, tt.want)
+				}
+				for i, rec := range run.Steps {
+					if rec.Status != tt.steps[i] {
+						t.Errorf("run %s step %s = %s, want %s", tt.id, rec.StepID, rec.Status, tt.steps[i])
+					}
+				}
+				if tt.id == "legacy" && run.HeartbeatAt == nil {
+					t.Errorf("run %s has no heartbeat", tt.id)
+				}
+			}
+		})
+	}
+}
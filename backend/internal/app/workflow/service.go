@@ -0,0 +1,298 @@
+package workflow
+
+import (
+	"context"
+	"regexp"
+	"slices"
+	"time"
+
+	"github.com/jinzhu/copier"
+	"github.com/xichan96/cortex-lab/internal/app/chat"
+	"github.com/xichan96/cortex-lab/internal/app/role"
+	"github.com/xichan96/cortex-lab/internal/appdto"
+	"github.com/xichan96/cortex-lab/internal/infra/model"
+	"github.com/xichan96/cortex-lab/internal/infra/persist"
+	"github.com/xichan96/cortex-lab/internal/pkg/errcode"
+	"github.com/xichan96/cortex-lab/pkg/ec"
+	"github.com/xichan96/cortex-lab/pkg/sql"
+	"github.com/xichan96/cortex-lab/pkg/web/cctx"
+	"gorm.io/gorm"
+)
+
+// maxWorkflowSteps caps the steps of a workflow
+const maxWorkflowSteps = 20
+
+var (
+	stepIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,32}$`)
+	// templateRef matches {{input}} and {{steps.<id>.output}} in the input of a step
+	templateRef = regexp.MustCompile(`\{\{\s*(?:input|steps\.([A-Za-z0-9_-]+)\.output)\s*\}\}`)
+)
+
+var conditionOperators = []string{"contains", "not_contains", "equals", "not_equals", "matches", "empty", "not_empty"}
+
+type AppIer interface {
+	CreateWorkflow(ctx context.Context, req *appdto.CreateWorkflowReq) (string, error)
+	UpdateWorkflow(ctx context.Context, req *appdto.UpdateWorkflowReq) error
+	DeleteWorkflow(ctx context.Context, id string) error
+	GetWorkflow(ctx context.Context, id string) (*appdto.Workflow, error)
+	GetWorkflows(ctx context.Context, req *appdto.GetWorkflowsReq) ([]*appdto.Workflow, int64, error)
+	RunWorkflow(ctx context.Context, id string, req *appdto.RunWorkflowReq) (*appdto.WorkflowRun, error)
+	GetRun(ctx context.Context, workflowID, runID string) (*appdto.WorkflowRun, error)
+	GetRuns(ctx context.Context, workflowID string, req *appdto.GetWorkflowRunsReq) ([]*appdto.WorkflowRun, int64, error)
+	// StartRunMonitor fails the runs interrupted by a stop of their server in the background until ctx is done
+	StartRunMonitor(ctx context.Context)
+}
+
+type app struct {
+	wp      persist.WorkflowPersistIer
+	rp      persist.WorkflowRunPersistIer
+	roleApp role.AppIer
+	chatApp chat.AppIer
+}
+
+func NewApp(wp persist.WorkflowPersistIer, rp persist.WorkflowRunPersistIer, roleApp role.AppIer, chatApp chat.AppIer) AppIer {
+	return &app{wp: wp, rp: rp, roleApp: roleApp, chatApp: chatApp}
+}
+
+// stepDeps returns the steps a step waits for: the ones it depends on and the
+// ones its input and condition reference
+func stepDeps(step *model.WorkflowStep) []string {
+	deps := slices.Clone(step.DependsOn)
+	for _, m := range templateRef.FindAllStringSubmatch(step.Input, -1) {
+		if m[1] != "" {
+			deps = append(deps, m[1])
+		}
+	}
+	if step.Condition != nil {
+		deps = append(deps, step.Condition.Step)
+	}
+	slices.Sort(deps)
+	return slices.Compact(deps)
+}
+
+// checkSteps validates the steps of a workflow, their roles must exist and
+// they must form a DAG
+func (a *app) checkSteps(ctx context.Context, steps []*appdto.WorkflowStep) (model.WorkflowSteps, error) {
+	if len(steps) == 0 || len(steps) > maxWorkflowSteps {
+		return nil, ec.Wrapf(errcode.WorkflowInvalid, "a workflow has 1 to %d steps", maxWorkflowSteps)
+	}
+	out := make(model.WorkflowSteps, len(steps))
+	ids := make(map[string]bool, len(steps))
+	for i, s := range steps {
+		if s == nil || !stepIDPattern.MatchString(s.ID) {
+			return nil, ec.Wrapf(errcode.WorkflowInvalid, "step %d needs an ID of at most 32 letters, digits, _ or -", i+1)
+		}
+		if ids[s.ID] {
+			return nil, ec.Wrapf(errcode.WorkflowInvalid, "step ID %q is used twice", s.ID)
+		}
+		ids[s.ID] = true
+		if s.RoleID == "" || s.Input == "" {
+			return nil, ec.Wrapf(errcode.WorkflowInvalid, "step %q needs a role and an input", s.ID)
+		}
+		if _, err := a.roleApp.GetRole(ctx, s.RoleID); err != nil {
+			return nil, err
+		}
+		step := model.WorkflowStep{ID: s.ID, RoleID: s.RoleID, Input: s.Input}
+		for _, dep := range s.DependsOn {
+			if !slices.Contains(step.DependsOn, dep) {
+				step.DependsOn = append(step.DependsOn, dep)
+			}
+		}
+		if c := s.Condition; c != nil {
+			if !slices.Contains(conditionOperators, c.Operator) {
+				return nil, ec.Wrapf(errcode.WorkflowInvalid, "step %q has an unknown condition operator %q", s.ID, c.Operator)
+			}
+			if c.Operator == "matches" {
+				if _, err := regexp.Compile(c.Value); err != nil {
+					return nil, ec.Wrapf(errcode.WorkflowInvalid, "step %q has an invalid condition pattern", s.ID)
+				}
+			}
+			step.Condition = &model.WorkflowCondition{Step: c.Step, Operator: c.Operator, Value: c.Value}
+		}
+		out[i] = step
+	}
+
+	// every step must be reachable in a topological order
+	pending := make(map[string][]string, len(out))
+	for i := range out {
+		deps := stepDeps(&out[i])
+		for _, dep := range deps {
+			if dep == out[i].ID || !ids[dep] {
+				return nil, ec.Wrapf(errcode.WorkflowInvalid, "step %q references unknown step %q", out[i].ID, dep)
+			}
+		}
+		pending[out[i].ID] = deps
+	}
+	for len(pending) > 0 {
+		progressed := false
+		for id, deps := range pending {
+			if !slices.ContainsFunc(deps, func(dep string) bool { _, ok := pending[dep]; return ok }) {
+				delete(pending, id)
+				progressed = true
+			}
+		}
+		if !progressed {
+			return nil, ec.Wrapf(errcode.WorkflowInvalid, "the steps depend on each other in a cycle")
+		}
+	}
+	return out, nil
+}
+
+// ownedWorkflow loads a workflow created by the current user
+func (a *app) ownedWorkflow(ctx context.Context, id string) (*model.Workflow, error) {
+	workflow, err := a.wp.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if workflow.CreatorID != cctx.GetUserID[string](ctx) {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return workflow, nil
+}
+
+// visibleWorkflow loads a workflow that is public or created by the current user
+func (a *app) visibleWorkflow(ctx context.Context, id string) (*model.Workflow, error) {
+	workflow, err := a.wp.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if workflow.IsPublic != 1 && workflow.CreatorID != cctx.GetUserID[string](ctx) {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return workflow, nil
+}
+
+func (a *app) CreateWorkflow(ctx context.Context, req *appdto.CreateWorkflowReq) (string, error) {
+	steps, err := a.checkSteps(ctx, req.Steps)
+	if err != nil {
+		return "", err
+	}
+
+	isPublic := 0
+	if req.IsPublic {
+		isPublic = 1
+	}
+
+	workflow := &model.Workflow{
+		Name:        req.Name,
+		Description: req.Description,
+		Steps:       steps,
+		CreatorID:   cctx.GetUserID[string](ctx),
+		IsPublic:    isPublic,
+	}
+	return a.wp.Create(ctx, workflow)
+}
+
+func (a *app) UpdateWorkflow(ctx context.Context, req *appdto.UpdateWorkflowReq) error {
+	workflow, err := a.ownedWorkflow(ctx, req.ID)
+	if err != nil {
+		return err
+	}
+
+	if req.Name != "" {
+		workflow.Name = req.Name
+	}
+	if req.Description != "" {
+		workflow.Description = req.Description
+	}
+	if req.IsPublic != nil {
+		if *req.IsPublic {
+			workflow.IsPublic = 1
+		} else {
+			workflow.IsPublic = 0
+		}
+	}
+	if req.Steps != nil {
+		if workflow.Steps, err = a.checkSteps(ctx, req.Steps); err != nil {
+			return err
+		}
+	}
+
+	workflow.UpdatedAt = time.Now()
+	return a.wp.Update(ctx, workflow)
+}
+
+// DeleteWorkflow deletes a workflow of the current user with its runs
+func (a *app) DeleteWorkflow(ctx context.Context, id string) error {
+	workflow, err := a.ownedWorkflow(ctx, id)
+	if err != nil {
+		return err
+	}
+	return sql.NewSqlTX().Execute(ctx, func(ctx context.Context) error {
+		if err := a.rp.DeleteByWorkflowID(ctx, workflow.ID); err != nil {
+			return err
+		}
+		return a.wp.Delete(ctx, workflow)
+	})
+}
+
+func (a *app) GetWorkflow(ctx context.Context, id string) (*appdto.Workflow, error) {
+	workflow, err := a.visibleWorkflow(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return toWorkflowDTO(workflow), nil
+}
+
+func (a *app) GetWorkflows(ctx context.Context, req *appdto.GetWorkflowsReq) ([]*appdto.Workflow, int64, error) {
+	userID := cctx.GetUserID[string](ctx)
+	opts := []func(*gorm.DB) *gorm.DB{}
+
+	if req.Keyword != "" {
+		opts = append(opts, func(db *gorm.DB) *gorm.DB {
+			return db.Where("name LIKE ? OR description LIKE ?", "%"+req.Keyword+"%", "%"+req.Keyword+"%")
+		})
+	}
+
+	switch req.Scope {
+	case "mine":
+		opts = append(opts, func(db *gorm.DB) *gorm.DB {
+			return db.Where("creator_id = ?", userID)
+		})
+	case "public":
+		opts = append(opts, func(db *gorm.DB) *gorm.DB {
+			return db.Where("is_public = ?", 1)
+		})
+	default:
+		opts = append(opts, func(db *gorm.DB) *gorm.DB {
+			return db.Where("is_public = ? OR creator_id = ?", 1, userID)
+		})
+	}
+
+	total, err := a.wp.Count(ctx, opts...)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	opts = append(opts, func(db *gorm.DB) *gorm.DB {
+		return db.Order("created_at DESC")
+	})
+	if req.Page > 0 && req.PageSize > 0 {
+		opts = append(opts, func(db *gorm.DB) *gorm.DB {
+			offset := (req.Page - 1) * req.PageSize
+			return db.Offset(offset).Limit(req.PageSize)
+		})
+	}
+
+	workflows, err := a.wp.GetList(ctx, opts...)
+	if err != nil {
+		return nil, 0, err
+	}
+	dtos := make([]*appdto.Workflow, len(workflows))
+	for i, w := range workflows {
+		dtos[i] = toWorkflowDTO(w)
+	}
+	return dtos, total, nil
+}
+
+func toWorkflowDTO(workflow *model.Workflow) *appdto.Workflow {
+	dto := &appdto.Workflow{}
+	_ = copier.Copy(dto, workflow)
+	dto.IsPublic = workflow.IsPublic == 1
+	dto.Steps = make([]*appdto.WorkflowStep, len(workflow.Steps))
+	for i, step := range workflow.Steps {
+		dto.Steps[i] = &appdto.WorkflowStep{}
+		_ = copier.CopyWithOption(dto.Steps[i], step, copier.Option{DeepCopy: true})
+	}
+	return dto
+}
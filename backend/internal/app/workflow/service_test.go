@@ -0,0 +1,69 @@
+package workflow
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/xichan96/cortex-lab/internal/app/role"
+	"github.com/xichan96/cortex-lab/internal/appdto"
+	"gorm.io/gorm"
+)
+
+// stubRoles knows every role except "missing"
+type stubRoles struct {
+	role.AppIer
+}
+
+func (stubRoles) GetRole(_ context.Context, id string) (*appdto.Role, error) {
+	if id == "missing" {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &appdto.Role{ID: id}, nil
+}
+
+func TestCheckSteps(t *testing.T) {
+	step := func(id, input string, dependsOn ...string) *appdto.WorkflowStep {
+		return &appdto.WorkflowStep{ID: id, RoleID: "r1", Input: input, DependsOn: dependsOn}
+	}
+	withCondition := func(s *appdto.WorkflowStep, c *appdto.WorkflowCondition) *appdto.WorkflowStep {
+		s.Condition = c
+		return s
+	}
+
+	tests := []struct {
+		name    string
+		steps   []*appdto.WorkflowStep
+		wantErr string
+	}{
+		{"single step", []*appdto.WorkflowStep{step("a", "{{input}}")}, ""},
+		{"chain through the input", []*appdto.WorkflowStep{step("a", "{{input}}"), step("b", "{{ steps.a.output }}")}, ""},
+		{"diamond", []*appdto.WorkflowStep{step("a", "x"), step("b", "x", "a"), step("c", "x", "a"), step("d", "{{steps.b.output}} {{steps.c.output}}")}, ""},
+		{"condition on an earlier step", []*appdto.WorkflowStep{step("a", "x"), withCondition(step("b", "x"), &appdto.WorkflowCondition{Step: "a", Operator: "contains", Value: "yes"})}, ""},
+		{"no steps", nil, "1 to"},
+		{"invalid ID", []*appdto.WorkflowStep{step("a b", "x")}, "needs an ID"},
+		{"duplicate ID", []*appdto.WorkflowStep{step("a", "x"), step("a", "y")}, "used twice"},
+		{"no input", []*appdto.WorkflowStep{step("a", "")}, "needs a role and an input"},
+		{"unknown role", []*appdto.WorkflowStep{{ID: "a", RoleID: "missing", Input: "x"}}, "record not found"},
+		{"unknown dependency", []*appdto.WorkflowStep{step("a", "x", "b")}, "unknown step \"b\""},
+		{"unknown step in the input", []*appdto.WorkflowStep{step("a", "{{steps.b.output}}")}, "unknown step \"b\""},
+		{"unknown step in the condition", []*appdto.WorkflowStep{withCondition(step("a", "x"), &appdto.WorkflowCondition{Step: "b", Operator: "empty"})}, "unknown step \"b\""},
+		{"depends on itself", []*appdto.WorkflowStep{step("a", "x", "a")}, "unknown step \"a\""},
+		{"cycle", []*appdto.WorkflowStep{step("a", "x", "c"), step("b", "x", "a"), step("c", "{{steps.b.output}}")}, "cycle"},
+		{"cycle through a condition", []*appdto.WorkflowStep{step("a", "x", "b"), withCondition(step("b", "x"), &appdto.WorkflowCondition{Step: "a", Operator: "empty"})}, "cycle"},
+		{"unknown operator", []*appdto.WorkflowStep{step("a", "x"), withCondition(step("b", "x"), &appdto.WorkflowCondition{Step: "a", Operator: "starts_with"})}, "unknown condition operator"},
+		{"invalid pattern", []*appdto.WorkflowStep{step("a", "x"), withCondition(step("b", "x"), &appdto.WorkflowCondition{Step: "a", Operator: "matches", Value: "("})}, "invalid condition pattern"},
+	}
+	a := &app{roleApp: stubRoles{}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := a.checkSteps(context.Background(), tt.steps)
+			if tt.wantErr == "" && err != nil {
+				t.Errorf("checkSteps() error = %v, want none", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("checkSteps() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -8,6 +8,8 @@ type CreateChatSessionReq struct {
 	Provider  string  `json:"provider" validate:"required"`
 	ModelName string  `json:"model_name" validate:"required"`
 	Title     *string `json:"title" validate:"omitempty"`
+	// ModelParams override the default parameters of the role for this session
+	ModelParams *ModelParams `json:"model_params" validate:"omitempty"`
 }
 
 type UpdateChatSessionTitleReq struct {
@@ -16,45 +18,241 @@ type UpdateChatSessionTitleReq struct {
 }
 
 type GetChatSessionsReq struct {
-	Page     int `form:"page" json:"page"`
-	PageSize int `form:"page_size" json:"page_size"`
+	Page      int    `form:"page" json:"page"`
+	PageSize  int    `form:"page_size" json:"page_size"`
+	RoleID    string `form:"role_id" json:"role_id"`
+	ModelName string `form:"model_name" json:"model_name"`
+	Tag       string `form:"tag" json:"tag"`
+	FolderID  string `form:"folder_id" json:"folder_id"`
+	Archived  bool   `form:"archived" json:"archived"` // list archived sessions instead of active ones
+	// IncludeChildren also lists the sessions created by calls between roles
+	IncludeChildren bool `form:"include_children" json:"include_children"`
+}
+
+// UpdateChatSessionOrganizeReq changes how a session is organized, nil fields are left unchanged
+type UpdateChatSessionOrganizeReq struct {
+	Pinned   *bool    `json:"pinned"`
+	Archived *bool    `json:"archived"`
+	FolderID *string  `json:"folder_id"` // empty to move the session out of its folder
+	Tags     []string `json:"tags"`      // replaces the tags of the session
 }
 
 type ChatSession struct {
-	ID        string    `json:"id"`
-	UserID    string    `json:"user_id"`
-	RoleID    string    `json:"role_id"`
-	RoleName  string    `json:"role_name"`
-	Provider  string    `json:"provider"`
-	ModelName string    `json:"model_name"`
-	Title     *string   `json:"title,omitempty"`
+	ID               string       `json:"id"`
+	UserID           string       `json:"user_id"`
+	RoleID           string       `json:"role_id"`
+	RoleName         string       `json:"role_name"`
+	Provider         string       `json:"provider"`
+	ModelName        string       `json:"model_name"`
+	Title            *string      `json:"title,omitempty"`
+	TitleSource      string       `json:"title_source"`
+	CurrentMessageID *string      `json:"current_message_id,omitempty"`
+	ShareToken       *string      `json:"share_token,omitempty"`
+	ShareExpiresAt   *time.Time   `json:"share_expires_at,omitempty"`
+	Pinned           bool         `json:"pinned"`
+	Archived         bool         `json:"archived"`
+	FolderID         *string      `json:"folder_id,omitempty"`
+	Tags             []string     `json:"tags"`
+	LastActiveAt     *time.Time   `json:"last_active_at,omitempty"`
+	ModelParams      *ModelParams `json:"model_params,omitempty"`
+	ParentSessionID  *string      `json:"parent_session_id,omitempty"`
+	ParentMessageID  *string      `json:"parent_message_id,omitempty"`
+	TeamID           *string      `json:"team_id,omitempty"` // set when chatting with a team, RoleID is then its supervisor
+	CreatedAt        time.Time    `json:"created_at"`
+	UpdatedAt        time.Time    `json:"updated_at"`
+}
+
+// ChatCallNode is a session of the call tree of a conversation, the children
+// are the sessions of the roles it called with the notify_role tool
+type ChatCallNode struct {
+	SessionID       string          `json:"session_id"`
+	ParentMessageID *string         `json:"parent_message_id,omitempty"` // message of the caller that made the call
+	RoleID          string          `json:"role_id"`
+	RoleName        string          `json:"role_name"`
+	Provider        string          `json:"provider"`
+	ModelName       string          `json:"model_name"`
+	Title           *string         `json:"title,omitempty"`
+	Input           string          `json:"input"`                // first user message
+	Output          string          `json:"output"`               // last reply of the active branch
+	Error           string          `json:"error,omitempty"`      // error of the last reply
+	LatencyMs       int64           `json:"latency_ms,omitempty"` // from the call to the last reply, not set on the root
+	CreatedAt       time.Time       `json:"created_at"`
+	Children        []*ChatCallNode `json:"children"`
+}
+
+type CreateChatFolderReq struct {
+	Name string `json:"name" validate:"required"`
+}
+
+type UpdateChatFolderReq struct {
+	ID   string `json:"id" validate:"required"`
+	Name string `json:"name" validate:"required"`
+}
+
+type ChatFolder struct {
+	ID           string    `json:"id"`
+	Name         string    `json:"name"`
+	SessionCount int64     `json:"session_count"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+type ChatTag struct {
+	Tag          string `json:"tag"`
+	SessionCount int64  `json:"session_count"`
+}
+
+type ShareChatSessionReq struct {
+	ExpiresAt *time.Time `json:"expires_at"` // empty for a link that does not expire
+}
+
+type ChatShare struct {
+	Token     string     `json:"token"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// SharedChatSession is the public read-only view of a shared session, it
+// holds the active branch with sensitive tool arguments redacted
+type SharedChatSession struct {
+	Title     *string        `json:"title,omitempty"`
+	RoleName  string         `json:"role_name"`
+	Provider  string         `json:"provider"`
+	ModelName string         `json:"model_name"`
+	CreatedAt time.Time      `json:"created_at"`
+	ExpiresAt *time.Time     `json:"expires_at,omitempty"`
+	Messages  []*ChatMessage `json:"messages"`
+}
+
+type ExportChatSessionReq struct {
+	Format string `form:"format" json:"format" validate:"omitempty,oneof=json markdown"`
+}
+
+// ChatRoleSnapshot is the role of an exported session at export time
+type ChatRoleSnapshot struct {
+	ID          string   `json:"id"`
+	Name        string   `json:"name"`
+	Description string   `json:"description,omitempty"`
+	Prompt      string   `json:"prompt,omitempty"`
+	Principle   string   `json:"principle,omitempty"`
+	Tools       []string `json:"tools,omitempty"`
+}
+
+// ChatSessionBundle is the JSON export of a session with all of its branches,
+// it can be imported as a new session
+type ChatSessionBundle struct {
+	Version    int               `json:"version"`
+	ExportedAt time.Time         `json:"exported_at"`
+	Session    *ChatSession      `json:"session" validate:"required"`
+	Role       *ChatRoleSnapshot `json:"role,omitempty"`
+	Messages   []*ChatMessage    `json:"messages"`
+}
+
+type SearchChatMessagesReq struct {
+	Keyword   string `form:"keyword" json:"keyword" validate:"required"`
+	RoleID    string `form:"role_id" json:"role_id"`
+	ModelName string `form:"model_name" json:"model_name"`
+	StartDate string `form:"start_date" json:"start_date" validate:"omitempty,datetime=2006-01-02"`
+	EndDate   string `form:"end_date" json:"end_date" validate:"omitempty,datetime=2006-01-02"`
+	Page      int    `form:"page" json:"page"`
+	PageSize  int    `form:"page_size" json:"page_size"`
+}
+
+// ChatSearchMatch is a message matching a search, Snippet marks the matches with <mark>
+type ChatSearchMatch struct {
+	MessageID string    `json:"message_id"`
+	Role      string    `json:"role"`
+	Snippet   string    `json:"snippet"`
 	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// ChatSearchResult is a session with its most recent matching messages
+type ChatSearchResult struct {
+	ChatSession
+	MatchCount int64              `json:"match_count"`
+	Matches    []*ChatSearchMatch `json:"matches"`
 }
 
 type SendChatMessageReq struct {
 	Messages []ChatMessageItem `json:"messages" validate:"required,min=1"`
 	Tools    []string          `json:"tools,omitempty"`
 	Stream   bool              `json:"stream,omitempty"`
+	// ModelParams replace the parameter overrides of the session, they are kept for the next messages
+	ModelParams *ModelParams `json:"model_params,omitempty"`
 }
 
 type ChatMessageItem struct {
 	Role    string `json:"role" validate:"required,oneof=user assistant system"`
-	Content string `json:"content" validate:"required"`
+	Content string `json:"content" validate:"required_without=Attachments"`
+	// Attachments are IDs returned by the upload endpoint
+	Attachments []string `json:"attachments,omitempty"`
 }
 
 type GetChatMessagesReq struct {
 	Page     int    `form:"page" json:"page"`
 	PageSize int    `form:"page_size" json:"page_size"`
 	Order    string `form:"order" json:"order"`
+	Branch   string `form:"branch" json:"branch"` // active: only messages of the active branch; empty: all messages
+}
+
+type EditChatMessageReq struct {
+	Content string `json:"content" validate:"required"`
+	// Attachments replaces the attachments of the edited message, nil keeps them
+	Attachments []string `json:"attachments"`
+}
+
+type SwitchChatBranchReq struct {
+	MessageID string `json:"message_id" validate:"required"`
 }
 
 type ChatMessage struct {
 	ID        string      `json:"id"`
 	SessionID string      `json:"session_id"`
+	ParentID  *string     `json:"parent_id"`
 	Role      string      `json:"role"`
 	Content   string      `json:"content"`
 	Meta      interface{} `json:"meta,omitempty"`
 	CreatedAt time.Time   `json:"created_at"`
 	UpdatedAt time.Time   `json:"updated_at"`
 }
+
+type CancelChatRunReq struct {
+	RunID string `json:"run_id"`
+}
+
+// ToolApproval is a tool call waiting for the user to approve it
+type ToolApproval struct {
+	ID        string                 `json:"id"`
+	SessionID string                 `json:"session_id"`
+	RunID     string                 `json:"run_id,omitempty"`
+	RoleID    string                 `json:"role_id"`
+	Tool      string                 `json:"tool"`
+	Arguments map[string]interface{} `json:"arguments"`
+	Sensitive []string               `json:"sensitive,omitempty"`
+	CreatedAt time.Time              `json:"created_at"`
+	ExpiresAt time.Time              `json:"expires_at"`
+}
+
+type GetToolApprovalsReq struct {
+	SessionID string `form:"session_id"`
+}
+
+type ApproveToolCallReq struct {
+	// Arguments replace the arguments of the call when set
+	Arguments map[string]interface{} `json:"arguments"`
+}
+
+type RejectToolCallReq struct {
+	Reason string `json:"reason"`
+}
+
+type EditToolCallReq struct {
+	Arguments map[string]interface{} `json:"arguments" validate:"required"`
+}
+
+type ChatAttachment struct {
+	ID        string    `json:"id"`
+	FileName  string    `json:"file_name"`
+	MimeType  string    `json:"mime_type"`
+	Size      int64     `json:"size"`
+	CreatedAt time.Time `json:"created_at"`
+}
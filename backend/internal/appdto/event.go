@@ -0,0 +1,64 @@
+package appdto
+
+import "time"
+
+type CreateEventSubscriptionReq struct {
+	Name string `json:"name" validate:"required,min=1,max=64"`
+	// URL receives the events as signed HTTP POSTs
+	URL string `json:"url" validate:"required,url"`
+	// Events are the names of the events to receive: session.created, message.completed,
+	// tool.failed, experience.created, experience.updated and role.updated
+	Events  []string `json:"events" validate:"required,min=1"`
+	Enabled *bool    `json:"enabled" validate:"omitempty"` // true by default
+}
+
+type UpdateEventSubscriptionReq struct {
+	ID      string   `json:"id" validate:"required"`
+	Name    string   `json:"name" validate:"omitempty,min=1,max=64"`
+	URL     string   `json:"url" validate:"omitempty,url"`
+	Events  []string `json:"events" validate:"omitempty"`
+	Enabled *bool    `json:"enabled" validate:"omitempty"`
+}
+
+type GetEventSubscriptionsReq struct {
+	Page     int `form:"page" json:"page"`
+	PageSize int `form:"page_size" json:"page_size"`
+}
+
+type EventSubscription struct {
+	ID        string    `json:"id"`
+	UserID    string    `json:"user_id"`
+	Name      string    `json:"name"`
+	URL       string    `json:"url"`
+	Events    []string  `json:"events"`
+	Enabled   bool      `json:"enabled"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// EventSubscriptionSecret is only returned when a subscription is created and when its secret is rotated
+type EventSubscriptionSecret struct {
+	ID     string `json:"id"`
+	Secret string `json:"secret"`
+}
+
+type GetEventDeliveriesReq struct {
+	Page     int    `form:"page" json:"page"`
+	PageSize int    `form:"page_size" json:"page_size"`
+	Status   string `form:"status" json:"status" validate:"omitempty,oneof=pending succeeded failed"`
+}
+
+type EventDelivery struct {
+	ID             string     `json:"id"`
+	SubscriptionID string     `json:"subscription_id"`
+	EventID        string     `json:"event_id"`
+	Event          string     `json:"event"`
+	Payload        string     `json:"payload"`
+	Status         string     `json:"status"` // pending, succeeded, failed
+	Attempts       int        `json:"attempts"`
+	ResponseCode   int        `json:"response_code"`
+	Error          string     `json:"error,omitempty"`
+	NextAttemptAt  *time.Time `json:"next_attempt_at"`
+	CreatedAt      time.Time  `json:"created_at"`
+	DeliveredAt    *time.Time `json:"delivered_at"`
+}
@@ -25,6 +25,7 @@ type GetExperienceReq struct {
 	Keyword  string `form:"keyword" json:"keyword"`
 	Q        string `form:"q" json:"q"`
 	RoleID   string `form:"role_id" json:"role_id"`
+	SourceID string `form:"source_id" json:"source_id"`
 }
 
 type Experience struct {
@@ -38,3 +39,37 @@ type Experience struct {
 	CreatedBy  string    `json:"created_by"`
 	CreatedAt  time.Time `json:"created_at"`
 }
+
+type IngestDocumentReq struct {
+	RoleID       string `form:"role_id" json:"role_id" validate:"omitempty"`
+	Title        string `form:"title" json:"title" validate:"omitempty"` // defaults to the file name
+	Tags         string `form:"tags" json:"tags" validate:"omitempty"`   // JSON string, copied to every fragment
+	ChunkSize    int    `form:"chunk_size" json:"chunk_size" validate:"omitempty"`
+	ChunkOverlap int    `form:"chunk_overlap" json:"chunk_overlap" validate:"omitempty"`
+}
+
+type GetExperienceDocumentsReq struct {
+	Page     int    `form:"page" json:"page"`
+	PageSize int    `form:"page_size" json:"page_size"`
+	RoleID   string `form:"role_id" json:"role_id"`
+	Keyword  string `form:"keyword" json:"keyword"`
+}
+
+type ExperienceDocument struct {
+	ID         string    `json:"id"`
+	Title      string    `json:"title"`
+	FileName   string    `json:"file_name"`
+	Format     string    `json:"format"`
+	Size       int64     `json:"size"`
+	ChunkCount int       `json:"chunk_count"`
+	RoleID     string    `json:"role_id"`
+	Tags       string    `json:"tags"`
+	CreatedBy  string    `json:"created_by"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+type ExperienceDocumentDetail struct {
+	ExperienceDocument
+	Fragments []*Experience `json:"fragments"`
+}
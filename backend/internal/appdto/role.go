@@ -28,6 +28,35 @@ type RoleToolConfig struct {
 	EmailConfig        *EmailConfig        `json:"email_config,omitempty"`
 	RoleNotifications  []RoleNotification  `json:"role_notifications,omitempty"`
 	HumanNotifications []HumanNotification `json:"human_notifications,omitempty"`
+	// RequireApproval lists the tools whose calls wait for the user to approve them
+	RequireApproval []string `json:"require_approval,omitempty"`
+	// Sandbox limits the command and file tools, they are unrestricted without it
+	Sandbox *SandboxPolicy `json:"sandbox,omitempty"`
+}
+
+// SandboxPolicy limits what the command and file tools of a role can do on the server
+type SandboxPolicy struct {
+	// Root is the directory the tools work in, paths outside of it are refused.
+	// It limits the paths given to the tools, not what a program does once it
+	// runs: a command only stays in the root if it runs programs that do not
+	// open other paths on their own, so the command tool requires AllowCommands
+	// with it. Do not allow interpreters or wrappers such as sh, env, python3,
+	// find or busybox, they run anything.
+	Root string `json:"root,omitempty"`
+	// AllowCommands are the only programs a command may run when set
+	AllowCommands []string `json:"allow_commands,omitempty"`
+	// DenyCommands are programs a command may never run
+	DenyCommands []string `json:"deny_commands,omitempty"`
+	// Env names the variables of the server passed to commands, the others are dropped
+	Env []string `json:"env,omitempty"`
+	// TimeoutSeconds caps how long a command runs, whatever timeout the model asks for
+	TimeoutSeconds int `json:"timeout_seconds,omitempty"`
+	// CPUSeconds caps the CPU time of a command
+	CPUSeconds int `json:"cpu_seconds,omitempty"`
+	// MaxOutputBytes caps the output of a command and the content of a file read
+	MaxOutputBytes int `json:"max_output_bytes,omitempty"`
+	// ReadOnly refuses the file operations that change the file system
+	ReadOnly bool `json:"read_only,omitempty"`
 }
 
 type MCPToolConfig struct {
@@ -35,6 +64,27 @@ type MCPToolConfig struct {
 	Tools []string `json:"tools,omitempty"`
 }
 
+// RoleModel is a provider and model a role can run on
+type RoleModel struct {
+	Provider string `json:"provider"`
+	Model    string `json:"model"`
+}
+
+// ModelParams are generation parameters, unset fields keep the role or engine defaults
+type ModelParams struct {
+	Temperature        *float32 `json:"temperature,omitempty"`
+	MaxTokens          *int     `json:"max_tokens,omitempty"`
+	TopP               *float32 `json:"top_p,omitempty"`
+	FrequencyPenalty   *float32 `json:"frequency_penalty,omitempty"`
+	PresencePenalty    *float32 `json:"presence_penalty,omitempty"`
+	StopSequences      []string `json:"stop_sequences,omitempty"`
+	MaxIterations      *int     `json:"max_iterations,omitempty"`
+	TimeoutSeconds     *int     `json:"timeout_seconds,omitempty"`
+	ToolTimeoutSeconds *int     `json:"tool_timeout_seconds,omitempty"`
+	RetryAttempts      *int     `json:"retry_attempts,omitempty"`
+	RetryDelayMs       *int     `json:"retry_delay_ms,omitempty"`
+}
+
 type CreateRoleReq struct {
 	Name        string          `json:"name" validate:"required,min=1,max=64"`
 	Description string          `json:"description" validate:"omitempty,max=255"`
@@ -45,6 +95,11 @@ type CreateRoleReq struct {
 	ToolConfig  *RoleToolConfig `json:"tool_config" validate:"omitempty"`
 	Permissions []string        `json:"permissions" validate:"omitempty"`
 	IsPublic    bool            `json:"is_public" validate:"omitempty"`
+	ModelParams *ModelParams    `json:"model_params" validate:"omitempty"`
+	// Provider and ModelName are the model the role runs on when a call does not choose one
+	Provider       string      `json:"provider" validate:"omitempty"`
+	ModelName      string      `json:"model_name" validate:"omitempty"`
+	FallbackModels []RoleModel `json:"fallback_models" validate:"omitempty"`
 }
 
 type UpdateRoleReq struct {
@@ -58,6 +113,11 @@ type UpdateRoleReq struct {
 	ToolConfig  *RoleToolConfig `json:"tool_config" validate:"omitempty"`
 	Permissions []string        `json:"permissions" validate:"omitempty"`
 	IsPublic    *bool           `json:"is_public" validate:"omitempty"`
+	ModelParams *ModelParams    `json:"model_params" validate:"omitempty"` // replaces the default parameters of the role
+	// Provider and ModelName replace the preferred model, set both empty to clear it
+	Provider       *string     `json:"provider" validate:"omitempty"`
+	ModelName      *string     `json:"model_name" validate:"omitempty"`
+	FallbackModels []RoleModel `json:"fallback_models" validate:"omitempty"`
 }
 
 type GetRolesReq struct {
@@ -79,6 +139,11 @@ type Role struct {
 	Permissions []string        `json:"permissions,omitempty"`
 	CreatorID   string          `json:"creator_id"`
 	IsPublic    bool            `json:"is_public"`
-	CreatedAt   time.Time       `json:"created_at"`
-	UpdatedAt   time.Time       `json:"updated_at"`
+	ModelParams *ModelParams    `json:"model_params,omitempty"`
+	// Provider and ModelName are the preferred model, FallbackModels are used in order when it is not configured
+	Provider       string      `json:"provider,omitempty"`
+	ModelName      string      `json:"model_name,omitempty"`
+	FallbackModels []RoleModel `json:"fallback_models,omitempty"`
+	CreatedAt      time.Time   `json:"created_at"`
+	UpdatedAt      time.Time   `json:"updated_at"`
 }
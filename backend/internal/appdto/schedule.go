@@ -0,0 +1,69 @@
+package appdto
+
+import "time"
+
+type CreateScheduleReq struct {
+	Name      string `json:"name" validate:"required,min=1,max=64"`
+	RoleID    string `json:"role_id" validate:"required"`
+	Provider  string `json:"provider" validate:"omitempty"`   // empty to resolve the model of the role
+	ModelName string `json:"model_name" validate:"omitempty"` // empty to resolve the model of the role
+	Cron      string `json:"cron" validate:"required"`        // 5-field cron expression or @daily, @hourly...
+	Timezone  string `json:"timezone" validate:"omitempty"`   // IANA name, UTC by default
+	Input     string `json:"input" validate:"required"`
+	// SessionID is a session of the current user every run posts to, empty to start a new session per run
+	SessionID string `json:"session_id" validate:"omitempty"`
+	Enabled   *bool  `json:"enabled" validate:"omitempty"` // true by default
+}
+
+type UpdateScheduleReq struct {
+	ID        string  `json:"id" validate:"required"`
+	Name      string  `json:"name" validate:"omitempty,min=1,max=64"`
+	RoleID    string  `json:"role_id" validate:"omitempty"`
+	Provider  *string `json:"provider" validate:"omitempty"`
+	ModelName *string `json:"model_name" validate:"omitempty"`
+	Cron      string  `json:"cron" validate:"omitempty"`
+	Timezone  string  `json:"timezone" validate:"omitempty"`
+	Input     string  `json:"input" validate:"omitempty"`
+	SessionID *string `json:"session_id" validate:"omitempty"` // empty string to start a new session per run
+	Enabled   *bool   `json:"enabled" validate:"omitempty"`
+}
+
+type GetSchedulesReq struct {
+	Page     int `form:"page" json:"page"`
+	PageSize int `form:"page_size" json:"page_size"`
+}
+
+type Schedule struct {
+	ID        string     `json:"id"`
+	UserID    string     `json:"user_id"`
+	Name      string     `json:"name"`
+	RoleID    string     `json:"role_id"`
+	Provider  string     `json:"provider"`
+	ModelName string     `json:"model_name"`
+	Cron      string     `json:"cron"`
+	Timezone  string     `json:"timezone"`
+	Input     string     `json:"input"`
+	SessionID *string    `json:"session_id"`
+	Enabled   bool       `json:"enabled"`
+	NextRunAt *time.Time `json:"next_run_at"`
+	LastRunAt *time.Time `json:"last_run_at"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+type GetScheduleRunsReq struct {
+	Page     int `form:"page" json:"page"`
+	PageSize int `form:"page_size" json:"page_size"`
+}
+
+type ScheduleRun struct {
+	ID          string     `json:"id"`
+	ScheduleID  string     `json:"schedule_id"`
+	SessionID   string     `json:"session_id"`
+	Status      string     `json:"status"` // running, succeeded, failed
+	Output      string     `json:"output"`
+	Error       string     `json:"error,omitempty"`
+	ScheduledAt time.Time  `json:"scheduled_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+	FinishedAt  *time.Time `json:"finished_at"`
+}
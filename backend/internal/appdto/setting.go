@@ -1,5 +1,7 @@
 package appdto
 
+import "slices"
+
 type Setting struct {
 	Group     string `json:"group"`
 	Key       string `json:"key"`
@@ -67,6 +69,45 @@ type ChatLLMConfig struct {
 	OpenAI   ChatOpenAIConfig   `yaml:"openai" json:"openai"`
 	DeepSeek ChatDeepSeekConfig `yaml:"deepseek" json:"deepseek"`
 	Volce    ChatVolceConfig    `yaml:"volce" json:"volce"`
+	Title    ChatTitleConfig    `yaml:"title" json:"title"`
+}
+
+// ChatTitleConfig controls the titles generated for new chat sessions
+type ChatTitleConfig struct {
+	Disabled bool   `yaml:"disabled" json:"disabled"` // keep the first user message as title
+	Provider string `yaml:"provider" json:"provider"` // provider of the title model, the session provider when empty
+	Model    string `yaml:"model" json:"model"`       // title model, the session model when empty
+}
+
+// Models returns the models configured for provider
+func (c *ChatLLMConfig) Models(provider string) []string {
+	if c == nil {
+		return nil
+	}
+	switch provider {
+	case "openai":
+		return c.OpenAI.Models
+	case "deepseek":
+		return c.DeepSeek.Models
+	case "volce":
+		return c.Volce.Models
+	}
+	return nil
+}
+
+// HasModel tells whether model is listed in the settings of provider
+func (c *ChatLLMConfig) HasModel(provider, model string) bool {
+	return model != "" && slices.Contains(c.Models(provider), model)
+}
+
+// FirstModel returns the first configured model, in the order openai, deepseek, volce
+func (c *ChatLLMConfig) FirstModel() (string, string) {
+	for _, provider := range []string{"openai", "deepseek", "volce"} {
+		if models := c.Models(provider); len(models) > 0 {
+			return provider, models[0]
+		}
+	}
+	return "", ""
 }
 
 type ChatOpenAIConfig struct {
@@ -148,13 +189,29 @@ type MySQLMemoryConfig struct {
 	MaxHistoryMessages int    `json:"max_history_messages" yaml:"max_history_messages"`
 }
 
+// MemoryCompressConfig controls the summarization of older turns in long chat sessions
+type MemoryCompressConfig struct {
+	Enabled   bool    `json:"enabled" yaml:"enabled"`
+	Threshold int     `json:"threshold" yaml:"threshold"` // history size in messages that triggers compression
+	Ratio     float32 `json:"ratio" yaml:"ratio"`         // share of the history summarized at once (0.0-1.0)
+}
+
+// ContextConfig controls how the chat history is fitted into the model context
+type ContextConfig struct {
+	Strategy      string `json:"strategy" yaml:"strategy"`             // sliding_window, summary_window (default)
+	ContextWindow int    `json:"context_window" yaml:"context_window"` // context window in tokens, 0 to use the known size of the model
+	ReserveTokens int    `json:"reserve_tokens" yaml:"reserve_tokens"` // extra tokens kept free for the user input
+}
+
 type MemoryConfig struct {
-	Provider string              `json:"provider" yaml:"provider"` // simple, mongodb, redis, sqlite, mysql
-	Simple   SimpleMemoryConfig  `json:"simple" yaml:"simple"`
-	MongoDB  MongoDBMemoryConfig `json:"mongodb" yaml:"mongodb"`
-	Redis    RedisMemoryConfig   `json:"redis" yaml:"redis"`
-	SQLite   SQLiteMemoryConfig  `json:"sqlite" yaml:"sqlite"`
-	MySQL    MySQLMemoryConfig   `json:"mysql" yaml:"mysql"`
+	Provider string               `json:"provider" yaml:"provider"` // simple, mongodb, redis, sqlite, mysql
+	Simple   SimpleMemoryConfig   `json:"simple" yaml:"simple"`
+	MongoDB  MongoDBMemoryConfig  `json:"mongodb" yaml:"mongodb"`
+	Redis    RedisMemoryConfig    `json:"redis" yaml:"redis"`
+	SQLite   SQLiteMemoryConfig   `json:"sqlite" yaml:"sqlite"`
+	MySQL    MySQLMemoryConfig    `json:"mysql" yaml:"mysql"`
+	Compress MemoryCompressConfig `json:"compress" yaml:"compress"`
+	Context  ContextConfig        `json:"context" yaml:"context"`
 }
 
 type MemorySetting struct {
@@ -165,8 +222,56 @@ type UpdateMemorySettingReq struct {
 	*MemoryConfig
 }
 
+// DelegationConfig limits the calls made between roles with the notify_role tool
+type DelegationConfig struct {
+	MaxDepth       int `json:"max_depth" yaml:"max_depth"`             // roles a call chain may reach after the first one, 3 when 0
+	TimeoutSeconds int `json:"timeout_seconds" yaml:"timeout_seconds"` // time allowed to a whole call tree, 300 when 0
+}
+
+type DelegationSetting struct {
+	*DelegationConfig
+}
+
+type UpdateDelegationSettingReq struct {
+	*DelegationConfig
+}
+
 type UpdateSettingReq struct {
 	Group string `json:"group" binding:"required"`
 	Key   string `json:"key" binding:"required"`
 	Value string `json:"value"`
 }
+
+// ModelPrice is the price of a model in Currency per one million tokens
+type ModelPrice struct {
+	Provider        string  `json:"provider" yaml:"provider"`
+	Model           string  `json:"model" yaml:"model"`
+	PromptPrice     float64 `json:"prompt_price" yaml:"prompt_price"`
+	CompletionPrice float64 `json:"completion_price" yaml:"completion_price"`
+}
+
+type PricingConfig struct {
+	Currency string       `json:"currency" yaml:"currency"`
+	Models   []ModelPrice `json:"models" yaml:"models"`
+}
+
+// Price returns the price of provider/model, nil when it is not configured
+func (c *PricingConfig) Price(provider, model string) *ModelPrice {
+	if c == nil {
+		return nil
+	}
+	for i := range c.Models {
+		if c.Models[i].Provider == provider && c.Models[i].Model == model {
+			return &c.Models[i]
+		}
+	}
+	return nil
+}
+
+type PricingSetting struct {
+	*PricingConfig
+}
+
+type UpdatePricingSettingReq struct {
+	*PricingConfig
+}
@@ -0,0 +1,60 @@
+package appdto
+
+import "time"
+
+// CreateSSHHostReq sets either Password or PrivateKey. The credentials are
+// stored encrypted and never returned.
+type CreateSSHHostReq struct {
+	// Name is what the model picks the host by, letters, digits, dots, dashes and underscores
+	Name        string `json:"name" validate:"required,min=1,max=64"`
+	Description string `json:"description" validate:"omitempty,max=255"`
+	Address     string `json:"address" validate:"required"`
+	Port        int    `json:"port" validate:"omitempty"` // 22 by default
+	Username    string `json:"username" validate:"required"`
+	Password    string `json:"password" validate:"omitempty"`
+	PrivateKey  string `json:"private_key" validate:"omitempty"` // PEM encoded
+	Passphrase  string `json:"passphrase" validate:"omitempty"`  // of the private key
+	// HostKey is the SHA256 fingerprint of the host key, empty to scan it once the host is created
+	HostKey string `json:"host_key" validate:"omitempty"`
+	// AllowCommands are the only programs the model may run on the host when set
+	AllowCommands []string `json:"allow_commands" validate:"omitempty"`
+	// RoleIDs are the roles whose ssh tool may connect to the host
+	RoleIDs []string `json:"role_ids" validate:"omitempty"`
+}
+
+// UpdateSSHHostReq leaves the fields that are not set unchanged. Setting
+// Password or PrivateKey replaces the credential.
+type UpdateSSHHostReq struct {
+	ID            string   `json:"id" validate:"required"`
+	Name          string   `json:"name" validate:"omitempty,min=1,max=64"`
+	Description   *string  `json:"description" validate:"omitempty"`
+	Address       string   `json:"address" validate:"omitempty"`
+	Port          int      `json:"port" validate:"omitempty"`
+	Username      string   `json:"username" validate:"omitempty"`
+	Password      string   `json:"password" validate:"omitempty"`
+	PrivateKey    string   `json:"private_key" validate:"omitempty"`
+	Passphrase    string   `json:"passphrase" validate:"omitempty"`
+	HostKey       *string  `json:"host_key" validate:"omitempty"`
+	AllowCommands []string `json:"allow_commands" validate:"omitempty"`
+	RoleIDs       []string `json:"role_ids" validate:"omitempty"`
+}
+
+type GetSSHHostsReq struct {
+	Page     int `form:"page" json:"page"`
+	PageSize int `form:"page_size" json:"page_size"`
+}
+
+type SSHHost struct {
+	ID            string    `json:"id"`
+	Name          string    `json:"name"`
+	Description   string    `json:"description"`
+	Address       string    `json:"address"`
+	Port          int       `json:"port"`
+	Username      string    `json:"username"`
+	AuthType      string    `json:"auth_type"`
+	HostKey       string    `json:"host_key"`
+	AllowCommands []string  `json:"allow_commands"`
+	RoleIDs       []string  `json:"role_ids"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
@@ -0,0 +1,53 @@
+package appdto
+
+import "time"
+
+type CreateTeamReq struct {
+	Name             string   `json:"name" validate:"required,min=1,max=64"`
+	Description      string   `json:"description" validate:"omitempty,max=255"`
+	Avatar           string   `json:"avatar" validate:"omitempty,max=255"`
+	SupervisorRoleID string   `json:"supervisor_role_id" validate:"required"`
+	MemberRoleIDs    []string `json:"member_role_ids" validate:"required,min=1"`
+	IsPublic         bool     `json:"is_public" validate:"omitempty"`
+}
+
+type UpdateTeamReq struct {
+	ID               string   `json:"id" validate:"required"`
+	Name             string   `json:"name" validate:"omitempty,min=1,max=64"`
+	Description      string   `json:"description" validate:"omitempty,max=255"`
+	Avatar           string   `json:"avatar" validate:"omitempty,max=255"`
+	SupervisorRoleID string   `json:"supervisor_role_id" validate:"omitempty"`
+	MemberRoleIDs    []string `json:"member_role_ids" validate:"omitempty"` // replaces the members of the team
+	IsPublic         *bool    `json:"is_public" validate:"omitempty"`
+}
+
+type GetTeamsReq struct {
+	Page     int    `form:"page" json:"page"`
+	PageSize int    `form:"page_size" json:"page_size"`
+	Keyword  string `form:"keyword" json:"keyword"`
+	Scope    string `form:"scope" json:"scope"` // mine, public, all
+}
+
+// TeamMember is a role of a team as the supervisor sees it
+type TeamMember struct {
+	RoleID      string `json:"role_id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Avatar      string `json:"avatar"`
+}
+
+type Team struct {
+	ID               string   `json:"id"`
+	Name             string   `json:"name"`
+	Description      string   `json:"description"`
+	Avatar           string   `json:"avatar"`
+	SupervisorRoleID string   `json:"supervisor_role_id"`
+	MemberRoleIDs    []string `json:"member_role_ids"`
+	// Supervisor and Members are only set on a single team, deleted roles are left out
+	Supervisor *TeamMember   `json:"supervisor,omitempty"`
+	Members    []*TeamMember `json:"members,omitempty"`
+	CreatorID  string        `json:"creator_id"`
+	IsPublic   bool          `json:"is_public"`
+	CreatedAt  time.Time     `json:"created_at"`
+	UpdatedAt  time.Time     `json:"updated_at"`
+}
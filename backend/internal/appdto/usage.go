@@ -0,0 +1,25 @@
+package appdto
+
+type GetUsageReq struct {
+	GroupBy   string `form:"group_by" json:"group_by" validate:"omitempty,oneof=session role user model day"`
+	SessionID string `form:"session_id" json:"session_id"`
+	RoleID    string `form:"role_id" json:"role_id"`
+	UserID    string `form:"user_id" json:"user_id"`
+	StartDate string `form:"start_date" json:"start_date" validate:"omitempty,datetime=2006-01-02"`
+	EndDate   string `form:"end_date" json:"end_date" validate:"omitempty,datetime=2006-01-02"`
+}
+
+type UsageSummary struct {
+	Key              string  `json:"key"`
+	Messages         int64   `json:"messages"`
+	PromptTokens     int64   `json:"prompt_tokens"`
+	CompletionTokens int64   `json:"completion_tokens"`
+	TotalTokens      int64   `json:"total_tokens"`
+	Cost             float64 `json:"cost"`
+}
+
+type Usage struct {
+	Currency string          `json:"currency"`
+	Total    *UsageSummary   `json:"total"`
+	List     []*UsageSummary `json:"list"`
+}
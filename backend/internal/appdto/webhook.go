@@ -0,0 +1,70 @@
+package appdto
+
+import "time"
+
+type CreateWebhookReq struct {
+	Name      string `json:"name" validate:"required,min=1,max=64"`
+	RoleID    string `json:"role_id" validate:"required"`
+	Provider  string `json:"provider" validate:"omitempty"`   // empty to resolve the model of the role
+	ModelName string `json:"model_name" validate:"omitempty"` // empty to resolve the model of the role
+	// PromptTemplate is a Go text/template. .Payload is the JSON payload, .Body
+	// the raw body, .Headers the request headers and .Name the name of the webhook.
+	// Empty to send the raw body.
+	PromptTemplate string `json:"prompt_template" validate:"omitempty"`
+	// Async answers with the invocation ID right away instead of waiting for the reply of the role
+	Async   bool  `json:"async" validate:"omitempty"`
+	Enabled *bool `json:"enabled" validate:"omitempty"` // true by default
+}
+
+type UpdateWebhookReq struct {
+	ID             string  `json:"id" validate:"required"`
+	Name           string  `json:"name" validate:"omitempty,min=1,max=64"`
+	RoleID         string  `json:"role_id" validate:"omitempty"`
+	Provider       *string `json:"provider" validate:"omitempty"`
+	ModelName      *string `json:"model_name" validate:"omitempty"`
+	PromptTemplate *string `json:"prompt_template" validate:"omitempty"`
+	Async          *bool   `json:"async" validate:"omitempty"`
+	Enabled        *bool   `json:"enabled" validate:"omitempty"`
+}
+
+type GetWebhooksReq struct {
+	Page     int `form:"page" json:"page"`
+	PageSize int `form:"page_size" json:"page_size"`
+}
+
+type Webhook struct {
+	ID              string     `json:"id"`
+	UserID          string     `json:"user_id"`
+	Name            string     `json:"name"`
+	RoleID          string     `json:"role_id"`
+	Provider        string     `json:"provider"`
+	ModelName       string     `json:"model_name"`
+	PromptTemplate  string     `json:"prompt_template"`
+	Async           bool       `json:"async"`
+	Enabled         bool       `json:"enabled"`
+	LastTriggeredAt *time.Time `json:"last_triggered_at"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+}
+
+// WebhookSecret is only returned when a webhook is created and when its secret is rotated
+type WebhookSecret struct {
+	ID     string `json:"id"`
+	Secret string `json:"secret"`
+}
+
+type GetWebhookInvocationsReq struct {
+	Page     int `form:"page" json:"page"`
+	PageSize int `form:"page_size" json:"page_size"`
+}
+
+type WebhookInvocation struct {
+	ID         string     `json:"id"`
+	WebhookID  string     `json:"webhook_id"`
+	SessionID  string     `json:"session_id"`
+	Status     string     `json:"status"` // running, succeeded, failed
+	Output     string     `json:"output"`
+	Error      string     `json:"error,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	FinishedAt *time.Time `json:"finished_at"`
+}
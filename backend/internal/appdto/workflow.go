@@ -0,0 +1,92 @@
+package appdto
+
+import "time"
+
+type CreateWorkflowReq struct {
+	Name        string          `json:"name" validate:"required,min=1,max=64"`
+	Description string          `json:"description" validate:"omitempty,max=255"`
+	Steps       []*WorkflowStep `json:"steps" validate:"required,min=1"`
+	IsPublic    bool            `json:"is_public" validate:"omitempty"`
+}
+
+type UpdateWorkflowReq struct {
+	ID          string          `json:"id" validate:"required"`
+	Name        string          `json:"name" validate:"omitempty,min=1,max=64"`
+	Description string          `json:"description" validate:"omitempty,max=255"`
+	Steps       []*WorkflowStep `json:"steps" validate:"omitempty"` // replaces the steps of the workflow
+	IsPublic    *bool           `json:"is_public" validate:"omitempty"`
+}
+
+type GetWorkflowsReq struct {
+	Page     int    `form:"page" json:"page"`
+	PageSize int    `form:"page_size" json:"page_size"`
+	Keyword  string `form:"keyword" json:"keyword"`
+	Scope    string `form:"scope" json:"scope"` // mine, public, all
+}
+
+// WorkflowStep is a step of a workflow run by a role.
+// Input is a template: {{input}} is the input of the run and
+// {{steps.<id>.output}} the output of another step, which then runs first.
+type WorkflowStep struct {
+	ID        string             `json:"id"` // letters, digits, _ and -, unique in the workflow
+	RoleID    string             `json:"role_id"`
+	Input     string             `json:"input"`
+	DependsOn []string           `json:"depends_on,omitempty"`
+	Condition *WorkflowCondition `json:"condition,omitempty"` // the step is skipped when it does not hold
+}
+
+// WorkflowCondition tests the output of another step. contains, not_contains,
+// equals and not_equals ignore case and surrounding spaces, matches takes a regular expression.
+type WorkflowCondition struct {
+	Step     string `json:"step"`
+	Operator string `json:"operator"` // contains, not_contains, equals, not_equals, matches, empty, not_empty
+	Value    string `json:"value,omitempty"`
+}
+
+type Workflow struct {
+	ID          string          `json:"id"`
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	Steps       []*WorkflowStep `json:"steps"`
+	CreatorID   string          `json:"creator_id"`
+	IsPublic    bool            `json:"is_public"`
+	CreatedAt   time.Time       `json:"created_at"`
+	UpdatedAt   time.Time       `json:"updated_at"`
+}
+
+type RunWorkflowReq struct {
+	Input string `json:"input"`
+	// Async returns the run as soon as it starts, poll it for its result
+	Async bool `json:"async"`
+}
+
+type GetWorkflowRunsReq struct {
+	Page     int `form:"page" json:"page"`
+	PageSize int `form:"page_size" json:"page_size"`
+}
+
+type WorkflowRun struct {
+	ID         string             `json:"id"`
+	WorkflowID string             `json:"workflow_id"`
+	UserID     string             `json:"user_id"`
+	Status     string             `json:"status"` // running, succeeded, failed
+	Input      string             `json:"input"`
+	Output     string             `json:"output"`
+	Error      string             `json:"error,omitempty"`
+	Steps      []*WorkflowStepRun `json:"steps"`
+	CreatedAt  time.Time          `json:"created_at"`
+	FinishedAt *time.Time         `json:"finished_at"`
+}
+
+// WorkflowStepRun is the record of a step of a run
+type WorkflowStepRun struct {
+	StepID     string     `json:"step_id"`
+	RoleID     string     `json:"role_id"`
+	Status     string     `json:"status"` // pending, running, succeeded, failed, skipped
+	SessionID  string     `json:"session_id,omitempty"`
+	Input      string     `json:"input,omitempty"`
+	Output     string     `json:"output,omitempty"`
+	Error      string     `json:"error,omitempty"`
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
@@ -17,9 +17,40 @@ var Config = &config{}
 var ConfigFile = "config.yaml"
 
 type config struct {
-	DBDriver string         `json:"db_driver" yaml:"db_driver"`
-	Mysql    *mysql.Config  `json:"mysql" yaml:"mysql"`
-	Sqlite   *sqlite.Config `json:"sqlite" yaml:"sqlite"`
+	DBDriver string          `json:"db_driver" yaml:"db_driver"`
+	Mysql    *mysql.Config   `json:"mysql" yaml:"mysql"`
+	Sqlite   *sqlite.Config  `json:"sqlite" yaml:"sqlite"`
+	Storage  *StorageConfig  `json:"storage" yaml:"storage"`
+	Security *SecurityConfig `json:"security" yaml:"security"`
+}
+
+// StorageConfig configures where uploaded files are kept
+type StorageConfig struct {
+	Path string `json:"path" yaml:"path"`
+}
+
+const defaultStoragePath = "data"
+
+// StoragePath returns the directory uploaded files are stored in
+func (c *config) StoragePath() string {
+	if c.Storage == nil || c.Storage.Path == "" {
+		return getEnv("STORAGE_PATH", defaultStoragePath)
+	}
+	return c.Storage.Path
+}
+
+// SecurityConfig holds the key the credentials stored in the DB are encrypted with
+type SecurityConfig struct {
+	SecretKey string `json:"secret_key" yaml:"secret_key"`
+}
+
+// SecretKey returns the key credentials stored in the DB are encrypted with,
+// empty when none is configured
+func (c *config) SecretKey() string {
+	if c.Security == nil || c.Security.SecretKey == "" {
+		return getEnv("SECRET_KEY", "")
+	}
+	return c.Security.SecretKey
 }
 
 func InitConfig() {
@@ -64,6 +95,16 @@ func InitConfig() {
 	Config.Sqlite = &sqlite.Config{
 		Path: getEnv("SQLITE_PATH", "cortex_lab.db"),
 	}
+
+	// Storage Config
+	Config.Storage = &StorageConfig{
+		Path: getEnv("STORAGE_PATH", defaultStoragePath),
+	}
+
+	// Security Config
+	Config.Security = &SecurityConfig{
+		SecretKey: getEnv("SECRET_KEY", ""),
+	}
 }
 
 func getEnv(key, fallback string) string {
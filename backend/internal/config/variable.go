@@ -12,6 +12,8 @@ var Var = variable{}
 
 type variable struct {
 	DB *gorm.DB
+	// FullTextSearch is set once the full-text index of chat messages is available
+	FullTextSearch bool
 }
 
 func ConnectDB() (*gorm.DB, error) {
@@ -7,10 +7,17 @@ import (
 	"github.com/google/wire"
 	"github.com/xichan96/cortex-lab/internal/app/agent"
 	"github.com/xichan96/cortex-lab/internal/app/chat"
+	"github.com/xichan96/cortex-lab/internal/app/event"
 	"github.com/xichan96/cortex-lab/internal/app/experience"
 	"github.com/xichan96/cortex-lab/internal/app/role"
+	"github.com/xichan96/cortex-lab/internal/app/schedule"
 	"github.com/xichan96/cortex-lab/internal/app/setting"
+	"github.com/xichan96/cortex-lab/internal/app/sshhost"
+	"github.com/xichan96/cortex-lab/internal/app/team"
+	"github.com/xichan96/cortex-lab/internal/app/usage"
 	"github.com/xichan96/cortex-lab/internal/app/user"
+	"github.com/xichan96/cortex-lab/internal/app/webhook"
+	"github.com/xichan96/cortex-lab/internal/app/workflow"
 	"github.com/xichan96/cortex-lab/internal/infra/persist"
 )
 
@@ -27,8 +34,24 @@ func NewUserApp() user.AppIer {
 
 var UserApp = NewUserApp()
 
+var EventAppSet = wire.NewSet(
+	persist.NewEventSubscriptionPersist,
+	persist.NewEventDeliveryPersist,
+)
+
+func NewEventApp() event.AppIer {
+	panic(wire.Build(
+		EventAppSet,
+		event.NewApp,
+	))
+}
+
+var EventApp = NewEventApp()
+
 var RoleAppSet = wire.NewSet(
 	persist.NewRolePersist,
+	NewSettingApp,
+	NewEventApp,
 )
 
 func NewRoleApp() role.AppIer {
@@ -40,9 +63,39 @@ func NewRoleApp() role.AppIer {
 
 var RoleApp = NewRoleApp()
 
+var TeamAppSet = wire.NewSet(
+	persist.NewTeamPersist,
+	NewRoleApp,
+)
+
+func NewTeamApp() team.AppIer {
+	panic(wire.Build(
+		TeamAppSet,
+		team.NewApp,
+	))
+}
+
+var TeamApp = NewTeamApp()
+
+var SSHHostAppSet = wire.NewSet(
+	persist.NewSSHHostPersist,
+	persist.NewSSHHostGrantPersist,
+	NewRoleApp,
+)
+
+func NewSSHHostApp() sshhost.AppIer {
+	panic(wire.Build(
+		SSHHostAppSet,
+		sshhost.NewApp,
+	))
+}
+
+var SSHHostApp = NewSSHHostApp()
+
 var ExperienceAppSet = wire.NewSet(
 	persist.NewExperiencePersist,
 	persist.NewRoleExperienceRelationPersist,
+	persist.NewExperienceDocumentPersist,
 )
 
 func NewExperienceApp() experience.AppIer {
@@ -70,6 +123,7 @@ var SettingApp = NewSettingApp()
 var AgentAppSet = wire.NewSet(
 	SettingAppSet,
 	setting.NewApp,
+	NewSSHHostApp,
 )
 
 func NewAgentApp() agent.AppIer {
@@ -84,9 +138,16 @@ var AgentApp = NewAgentApp()
 var ChatAppSet = wire.NewSet(
 	persist.NewChatSessionPersist,
 	persist.NewChatMessagePersist,
+	persist.NewUsageRecordPersist,
+	persist.NewChatAttachmentPersist,
+	persist.NewChatSessionTagPersist,
+	persist.NewChatFolderPersist,
 	NewRoleApp,
+	NewTeamApp,
 	NewSettingApp,
 	NewExperienceApp,
+	NewEventApp,
+	NewSSHHostApp,
 	chat.NewApp,
 )
 
@@ -97,3 +158,65 @@ func NewChatApp() chat.AppIer {
 }
 
 var ChatApp = NewChatApp()
+
+var WorkflowAppSet = wire.NewSet(
+	persist.NewWorkflowPersist,
+	persist.NewWorkflowRunPersist,
+	NewRoleApp,
+	NewChatApp,
+)
+
+func NewWorkflowApp() workflow.AppIer {
+	panic(wire.Build(
+		WorkflowAppSet,
+		workflow.NewApp,
+	))
+}
+
+var WorkflowApp = NewWorkflowApp()
+
+var ScheduleAppSet = wire.NewSet(
+	persist.NewSchedulePersist,
+	persist.NewScheduleRunPersist,
+	NewRoleApp,
+	NewChatApp,
+)
+
+func NewScheduleApp() schedule.AppIer {
+	panic(wire.Build(
+		ScheduleAppSet,
+		schedule.NewApp,
+	))
+}
+
+var ScheduleApp = NewScheduleApp()
+
+var WebhookAppSet = wire.NewSet(
+	persist.NewWebhookPersist,
+	persist.NewWebhookInvocationPersist,
+	NewRoleApp,
+	NewChatApp,
+)
+
+func NewWebhookApp() webhook.AppIer {
+	panic(wire.Build(
+		WebhookAppSet,
+		webhook.NewApp,
+	))
+}
+
+var WebhookApp = NewWebhookApp()
+
+var UsageAppSet = wire.NewSet(
+	persist.NewUsageRecordPersist,
+	NewSettingApp,
+	usage.NewApp,
+)
+
+func NewUsageApp() usage.AppIer {
+	panic(wire.Build(
+		UsageAppSet,
+	))
+}
+
+var UsageApp = NewUsageApp()
@@ -10,10 +10,17 @@ import (
 	"github.com/google/wire"
 	"github.com/xichan96/cortex-lab/internal/app/agent"
 	"github.com/xichan96/cortex-lab/internal/app/chat"
+	"github.com/xichan96/cortex-lab/internal/app/event"
 	"github.com/xichan96/cortex-lab/internal/app/experience"
 	"github.com/xichan96/cortex-lab/internal/app/role"
+	"github.com/xichan96/cortex-lab/internal/app/schedule"
 	"github.com/xichan96/cortex-lab/internal/app/setting"
+	"github.com/xichan96/cortex-lab/internal/app/sshhost"
+	"github.com/xichan96/cortex-lab/internal/app/team"
+	"github.com/xichan96/cortex-lab/internal/app/usage"
 	"github.com/xichan96/cortex-lab/internal/app/user"
+	"github.com/xichan96/cortex-lab/internal/app/webhook"
+	"github.com/xichan96/cortex-lab/internal/app/workflow"
 	"github.com/xichan96/cortex-lab/internal/infra/persist"
 )
 
@@ -25,16 +32,41 @@ func NewUserApp() user.AppIer {
 	return appIer
 }
 
+func NewEventApp() event.AppIer {
+	eventSubscriptionPersistIer := persist.NewEventSubscriptionPersist()
+	eventDeliveryPersistIer := persist.NewEventDeliveryPersist()
+	appIer := event.NewApp(eventSubscriptionPersistIer, eventDeliveryPersistIer)
+	return appIer
+}
+
 func NewRoleApp() role.AppIer {
 	rolePersistIer := persist.NewRolePersist()
-	appIer := role.NewApp(rolePersistIer)
-	return appIer
+	appIer := NewSettingApp()
+	eventAppIer := NewEventApp()
+	roleAppIer := role.NewApp(rolePersistIer, appIer, eventAppIer)
+	return roleAppIer
+}
+
+func NewTeamApp() team.AppIer {
+	teamPersistIer := persist.NewTeamPersist()
+	appIer := NewRoleApp()
+	teamAppIer := team.NewApp(teamPersistIer, appIer)
+	return teamAppIer
+}
+
+func NewSSHHostApp() sshhost.AppIer {
+	sshHostPersistIer := persist.NewSSHHostPersist()
+	sshHostGrantPersistIer := persist.NewSSHHostGrantPersist()
+	appIer := NewRoleApp()
+	sshhostAppIer := sshhost.NewApp(sshHostPersistIer, sshHostGrantPersistIer, appIer)
+	return sshhostAppIer
 }
 
 func NewExperienceApp() experience.AppIer {
 	experiencePersistIer := persist.NewExperiencePersist()
 	roleExperienceRelationPersistIer := persist.NewRoleExperienceRelationPersist()
-	appIer := experience.NewApp(experiencePersistIer, roleExperienceRelationPersistIer)
+	experienceDocumentPersistIer := persist.NewExperienceDocumentPersist()
+	appIer := experience.NewApp(experiencePersistIer, roleExperienceRelationPersistIer, experienceDocumentPersistIer)
 	return appIer
 }
 
@@ -47,31 +79,85 @@ func NewSettingApp() setting.AppIer {
 func NewAgentApp() agent.AppIer {
 	settingPersistIer := persist.NewSettingPersist()
 	appIer := setting.NewApp(settingPersistIer)
-	agentAppIer := agent.NewApp(appIer)
+	sshhostAppIer := NewSSHHostApp()
+	agentAppIer := agent.NewApp(appIer, sshhostAppIer)
 	return agentAppIer
 }
 
 func NewChatApp() chat.AppIer {
 	chatSessionPersistIer := persist.NewChatSessionPersist()
 	chatMessagePersistIer := persist.NewChatMessagePersist()
+	usageRecordPersistIer := persist.NewUsageRecordPersist()
+	chatAttachmentPersistIer := persist.NewChatAttachmentPersist()
+	chatSessionTagPersistIer := persist.NewChatSessionTagPersist()
+	chatFolderPersistIer := persist.NewChatFolderPersist()
 	appIer := NewRoleApp()
+	teamAppIer := NewTeamApp()
 	settingAppIer := NewSettingApp()
 	experienceAppIer := NewExperienceApp()
-	chatAppIer := chat.NewApp(chatSessionPersistIer, chatMessagePersistIer, appIer, settingAppIer, experienceAppIer)
+	eventAppIer := NewEventApp()
+	sshhostAppIer := NewSSHHostApp()
+	chatAppIer := chat.NewApp(chatSessionPersistIer, chatMessagePersistIer, usageRecordPersistIer, chatAttachmentPersistIer, chatSessionTagPersistIer, chatFolderPersistIer, appIer, teamAppIer, settingAppIer, experienceAppIer, eventAppIer, sshhostAppIer)
 	return chatAppIer
 }
 
+func NewWorkflowApp() workflow.AppIer {
+	workflowPersistIer := persist.NewWorkflowPersist()
+	workflowRunPersistIer := persist.NewWorkflowRunPersist()
+	appIer := NewRoleApp()
+	chatAppIer := NewChatApp()
+	workflowAppIer := workflow.NewApp(workflowPersistIer, workflowRunPersistIer, appIer, chatAppIer)
+	return workflowAppIer
+}
+
+func NewScheduleApp() schedule.AppIer {
+	schedulePersistIer := persist.NewSchedulePersist()
+	scheduleRunPersistIer := persist.NewScheduleRunPersist()
+	appIer := NewRoleApp()
+	chatAppIer := NewChatApp()
+	scheduleAppIer := schedule.NewApp(schedulePersistIer, scheduleRunPersistIer, appIer, chatAppIer)
+	return scheduleAppIer
+}
+
+func NewWebhookApp() webhook.AppIer {
+	webhookPersistIer := persist.NewWebhookPersist()
+	webhookInvocationPersistIer := persist.NewWebhookInvocationPersist()
+	appIer := NewRoleApp()
+	chatAppIer := NewChatApp()
+	webhookAppIer := webhook.NewApp(webhookPersistIer, webhookInvocationPersistIer, appIer, chatAppIer)
+	return webhookAppIer
+}
+
+func NewUsageApp() usage.AppIer {
+	usageRecordPersistIer := persist.NewUsageRecordPersist()
+	appIer := NewSettingApp()
+	usageAppIer := usage.NewApp(usageRecordPersistIer, appIer)
+	return usageAppIer
+}
+
 // wire.go:
 
 var UserAppSet = wire.NewSet(persist.NewUserPersist)
 
 var UserApp = NewUserApp()
 
-var RoleAppSet = wire.NewSet(persist.NewRolePersist)
+var EventAppSet = wire.NewSet(persist.NewEventSubscriptionPersist, persist.NewEventDeliveryPersist)
+
+var EventApp = NewEventApp()
+
+var RoleAppSet = wire.NewSet(persist.NewRolePersist, NewSettingApp, NewEventApp)
 
 var RoleApp = NewRoleApp()
 
-var ExperienceAppSet = wire.NewSet(persist.NewExperiencePersist, persist.NewRoleExperienceRelationPersist)
+var TeamAppSet = wire.NewSet(persist.NewTeamPersist, NewRoleApp)
+
+var TeamApp = NewTeamApp()
+
+var SSHHostAppSet = wire.NewSet(persist.NewSSHHostPersist, persist.NewSSHHostGrantPersist, NewRoleApp)
+
+var SSHHostApp = NewSSHHostApp()
+
+var ExperienceAppSet = wire.NewSet(persist.NewExperiencePersist, persist.NewRoleExperienceRelationPersist, persist.NewExperienceDocumentPersist)
 
 var ExperienceApp = NewExperienceApp()
 
@@ -81,13 +167,39 @@ var SettingApp = NewSettingApp()
 
 var AgentAppSet = wire.NewSet(
 	SettingAppSet, setting.NewApp,
+	NewSSHHostApp,
 )
 
 var AgentApp = NewAgentApp()
 
-var ChatAppSet = wire.NewSet(persist.NewChatSessionPersist, persist.NewChatMessagePersist, NewRoleApp,
+var ChatAppSet = wire.NewSet(persist.NewChatSessionPersist, persist.NewChatMessagePersist, persist.NewUsageRecordPersist, persist.NewChatAttachmentPersist, persist.NewChatSessionTagPersist, persist.NewChatFolderPersist, NewRoleApp,
+	NewTeamApp,
 	NewSettingApp,
-	NewExperienceApp, chat.NewApp,
+	NewExperienceApp,
+	NewEventApp,
+	NewSSHHostApp, chat.NewApp,
 )
 
 var ChatApp = NewChatApp()
+
+var WorkflowAppSet = wire.NewSet(persist.NewWorkflowPersist, persist.NewWorkflowRunPersist, NewRoleApp,
+	NewChatApp,
+)
+
+var WorkflowApp = NewWorkflowApp()
+
+var ScheduleAppSet = wire.NewSet(persist.NewSchedulePersist, persist.NewScheduleRunPersist, NewRoleApp,
+	NewChatApp,
+)
+
+var ScheduleApp = NewScheduleApp()
+
+var WebhookAppSet = wire.NewSet(persist.NewWebhookPersist, persist.NewWebhookInvocationPersist, NewRoleApp,
+	NewChatApp,
+)
+
+var WebhookApp = NewWebhookApp()
+
+var UsageAppSet = wire.NewSet(persist.NewUsageRecordPersist, NewSettingApp, usage.NewApp)
+
+var UsageApp = NewUsageApp()
@@ -0,0 +1,79 @@
+package migrate
+
+import (
+	"fmt"
+	"log/slog"
+
+	"gorm.io/gorm"
+
+	"github.com/xichan96/cortex-lab/internal/config"
+	"github.com/xichan96/cortex-lab/internal/infra/model"
+)
+
+const chatMessageContentIndex = "idx_chat_messages_content"
+
+// MigrateFullText creates the full-text index of chat messages: a FULLTEXT
+// index on MySQL, an FTS5 table kept in sync by triggers on SQLite. Search
+// falls back to LIKE when the database does not support it.
+func MigrateFullText() {
+	db := config.Var.DB
+	var err error
+	if config.Config.DBDriver == "sqlite" {
+		err = migrateSqliteFullText(db)
+	} else {
+		err = migrateMysqlFullText(db)
+	}
+	if err != nil {
+		slog.Warn("Full-text search of chat messages is not available, falling back to LIKE", "error", err)
+		return
+	}
+	config.Var.FullTextSearch = true
+}
+
+func migrateMysqlFullText(db *gorm.DB) error {
+	if db.Migrator().HasIndex(&model.ChatMessage{}, chatMessageContentIndex) {
+		return nil
+	}
+	// the ngram parser tokenizes CJK text, it is missing on MariaDB
+	addIndex := fmt.Sprintf("ALTER TABLE %s ADD FULLTEXT INDEX %s (content)", model.TableChatMessage, chatMessageContentIndex)
+	if err := db.Exec(addIndex + " WITH PARSER ngram").Error; err != nil {
+		return db.Exec(addIndex).Error
+	}
+	return nil
+}
+
+func migrateSqliteFullText(db *gorm.DB) error {
+	var triggers int64
+	err := db.Raw("SELECT COUNT(*) FROM sqlite_master WHERE type = 'trigger' AND name LIKE ?", model.TableChatMessageFTS+"_%").Scan(&triggers).Error
+	if err != nil {
+		return err
+	}
+	if triggers == 3 {
+		return nil
+	}
+	// the triggers are dropped together with chat_messages when a migration
+	// rebuilds the table, the index is then rebuilt as well
+	t, fts := model.TableChatMessage, model.TableChatMessageFTS
+	statements := []string{
+		fmt.Sprintf("CREATE VIRTUAL TABLE IF NOT EXISTS %s USING fts5(content, content='%s', content_rowid='rowid', tokenize='trigram')", fts, t),
+		fmt.Sprintf("DROP TRIGGER IF EXISTS %s_ai", fts),
+		fmt.Sprintf("DROP TRIGGER IF EXISTS %s_ad", fts),
+		fmt.Sprintf("DROP TRIGGER IF EXISTS %s_au", fts),
+		fmt.Sprintf("CREATE TRIGGER %[2]s_ai AFTER INSERT ON %[1]s BEGIN "+
+			"INSERT INTO %[2]s(rowid, content) VALUES (new.rowid, new.content); END", t, fts),
+		fmt.Sprintf("CREATE TRIGGER %[2]s_ad AFTER DELETE ON %[1]s BEGIN "+
+			"INSERT INTO %[2]s(%[2]s, rowid, content) VALUES ('delete', old.rowid, old.content); END", t, fts),
+		fmt.Sprintf("CREATE TRIGGER %[2]s_au AFTER UPDATE OF content ON %[1]s BEGIN "+
+			"INSERT INTO %[2]s(%[2]s, rowid, content) VALUES ('delete', old.rowid, old.content); "+
+			"INSERT INTO %[2]s(rowid, content) VALUES (new.rowid, new.content); END", t, fts),
+		fmt.Sprintf("INSERT INTO %[1]s(%[1]s) VALUES ('rebuild')", fts),
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, stmt := range statements {
+			if err := tx.Exec(stmt).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -10,6 +10,7 @@ import (
 
 	"github.com/xichan96/cortex-lab/internal/config"
 	"github.com/xichan96/cortex-lab/internal/infra/model"
+	"github.com/xichan96/cortex-lab/internal/pkg/secret"
 )
 
 func EnsureDatabase() error {
@@ -58,15 +59,69 @@ func MigrateTable() error {
 	if err := config.Var.DB.AutoMigrate(
 		&model.User{},
 		&model.Role{},
+		&model.Team{},
+		&model.Workflow{},
+		&model.WorkflowRun{},
+		&model.Schedule{},
+		&model.ScheduleRun{},
+		&model.Webhook{},
+		&model.WebhookInvocation{},
+		&model.EventSubscription{},
+		&model.EventDelivery{},
+		&model.SSHHost{},
+		&model.SSHHostGrant{},
 		&model.Experience{},
 		&model.RoleExperienceRelation{},
+		&model.ExperienceDocument{},
 		&model.Setting{},
 		&model.ChatSession{},
+		&model.ChatSessionTag{},
+		&model.ChatFolder{},
 		&model.ChatMessage{},
+		&model.UsageRecord{},
+		&model.ChatAttachment{},
 	); err != nil {
 		fmt.Printf("AutoMigrate failed: %v\n", err)
 		return err
 	}
+	// sessions created before last_active_at was added are ordered by their last update
+	if err := config.Var.DB.Table(model.TableChatSession).Where("last_active_at IS NULL").
+		Update("last_active_at", gorm.Expr("updated_at")).Error; err != nil {
+		return err
+	}
+	// secrets created before they were encrypted are encrypted in place
+	for _, table := range []string{model.TableWebhook, model.TableEventSubscription} {
+		if err := encryptSecrets(table); err != nil {
+			return err
+		}
+	}
+	MigrateFullText()
+	return nil
+}
+
+// encryptSecrets seals the secrets of a table stored before they were
+// encrypted
+func encryptSecrets(table string) error {
+	var rows []struct {
+		ID     string
+		Secret string
+	}
+	if err := config.Var.DB.Table(table).Select("id", "secret").Find(&rows).Error; err != nil {
+		return err
+	}
+	for _, row := range rows {
+		if secret.IsEncrypted(row.Secret) {
+			continue
+		}
+		sealed, err := secret.Encrypt(row.Secret)
+		if err != nil {
+			return err
+		}
+		if err := config.Var.DB.Table(table).Where("id = ?", row.ID).
+			Update("secret", sealed).Error; err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -0,0 +1,49 @@
+package model
+
+import (
+	"time"
+
+	"github.com/xichan96/cortex-lab/pkg/sql"
+	"gorm.io/gen/field"
+	"gorm.io/gorm"
+)
+
+const TableChatAttachment = "chat_attachments"
+
+var ChatAttachmentFM = sql.NewGlobalFieldMetaMapping(ChatAttachment{}, ChatAttachmentFieldMeta{})
+
+type ChatAttachment struct {
+	ID         string         `json:"id" gorm:"column:id;type:varchar(36);primaryKey;comment:附件ID"`
+	UserID     string         `json:"user_id" gorm:"column:user_id;type:varchar(36);not null;index;comment:上传用户ID"`
+	FileName   string         `json:"file_name" gorm:"column:file_name;type:varchar(255);not null;comment:原始文件名"`
+	MimeType   string         `json:"mime_type" gorm:"column:mime_type;type:varchar(128);not null;comment:文件类型"`
+	Size       int64          `json:"size" gorm:"column:size;type:bigint;not null;default:0;comment:文件大小 (字节)"`
+	StorageKey string         `json:"storage_key" gorm:"column:storage_key;type:varchar(255);not null;comment:本地存储路径 (相对存储目录)"`
+	CreatedAt  time.Time      `json:"created_at" gorm:"column:created_at;type:timestamp;not null;default:CURRENT_TIMESTAMP;autoCreateTime"`
+	DeletedAt  gorm.DeletedAt `json:"deleted_at,omitempty" gorm:"column:deleted_at;type:timestamp NULL;index;comment:软删除时间"`
+}
+
+func (ChatAttachment) TableName() string {
+	return TableChatAttachment
+}
+
+// AttachmentRef is the copy of an attachment kept on the message it was sent with
+type AttachmentRef struct {
+	ID       string `json:"id"`
+	FileName string `json:"file_name"`
+	MimeType string `json:"mime_type"`
+	Size     int64  `json:"size"`
+}
+
+type ChatAttachmentFieldMeta struct {
+	sql.CTable
+	ALL        field.Asterisk
+	ID         field.String
+	UserID     field.String
+	FileName   field.String
+	MimeType   field.String
+	Size       field.Int64
+	StorageKey field.String
+	CreatedAt  field.Time
+	DeletedAt  field.Field
+}
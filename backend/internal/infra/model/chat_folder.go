@@ -0,0 +1,34 @@
+package model
+
+import (
+	"time"
+
+	"github.com/xichan96/cortex-lab/pkg/sql"
+	"gorm.io/gen/field"
+)
+
+const TableChatFolder = "chat_folders"
+
+var ChatFolderFM = sql.NewGlobalFieldMetaMapping(ChatFolder{}, ChatFolderFieldMeta{})
+
+type ChatFolder struct {
+	ID        string    `json:"id" gorm:"column:id;type:varchar(36);primaryKey;comment:文件夹ID"`
+	UserID    string    `json:"user_id" gorm:"column:user_id;type:varchar(36);not null;index;comment:所属用户ID"`
+	Name      string    `json:"name" gorm:"column:name;type:varchar(64);not null;comment:文件夹名称"`
+	CreatedAt time.Time `json:"created_at" gorm:"column:created_at;type:timestamp;not null;default:CURRENT_TIMESTAMP;autoCreateTime"`
+	UpdatedAt time.Time `json:"updated_at" gorm:"column:updated_at;type:timestamp;not null;default:CURRENT_TIMESTAMP;autoUpdateTime"`
+}
+
+func (ChatFolder) TableName() string {
+	return TableChatFolder
+}
+
+type ChatFolderFieldMeta struct {
+	sql.CTable
+	ALL       field.Asterisk
+	ID        field.String
+	UserID    field.String
+	Name      field.String
+	CreatedAt field.Time
+	UpdatedAt field.Time
+}
@@ -12,11 +12,15 @@ import (
 
 const TableChatMessage = "chat_messages"
 
+// TableChatMessageFTS is the FTS5 index of chat message content on SQLite
+const TableChatMessageFTS = "chat_messages_fts"
+
 var ChatMessageFM = sql.NewGlobalFieldMetaMapping(ChatMessage{}, ChatMessageFieldMeta{})
 
 type ChatMessage struct {
 	ID        string         `json:"id" gorm:"column:id;type:varchar(36);primaryKey;comment:消息ID"`
-	SessionID string         `json:"session_id" gorm:"column:session_id;type:varchar(36);not null;index:idx_session_created_at;comment:所属会话ID"`
+	SessionID string         `json:"session_id" gorm:"column:session_id;type:varchar(36);not null;index:idx_session_created_at;index:idx_chat_message_session_parent,priority:1;comment:所属会话ID"`
+	ParentID  *string        `json:"parent_id" gorm:"column:parent_id;type:varchar(36);index;index:idx_chat_message_session_parent,priority:2;comment:父消息ID (分支对话, 根消息为空)"`
 	Role      string         `json:"role" gorm:"column:role;type:varchar(50);not null;comment:消息角色"`
 	Content   string         `json:"content" gorm:"column:content;type:text;not null;comment:消息内容 (Markdown/纯文本)"`
 	Meta      *MessageMeta   `json:"meta" gorm:"column:meta;type:text;comment:附加元信息 (如工具调用、token 统计等)"`
@@ -30,10 +34,79 @@ func (ChatMessage) TableName() string {
 }
 
 type MessageMeta struct {
-	ToolCalls     []interface{} `json:"tool_calls,omitempty"`
-	TokenCount    *int          `json:"token_count,omitempty"`
-	Error         *string       `json:"error,omitempty"`
-	ExperienceIDs []string      `json:"experience_ids,omitempty"`
+	ToolCalls     []ToolCallTrace `json:"tool_calls,omitempty"`
+	TokenCount    *int            `json:"token_count,omitempty"`
+	Error         *string         `json:"error,omitempty"`
+	ExperienceIDs []string        `json:"experience_ids,omitempty"`
+	Usage         *TokenUsage     `json:"usage,omitempty"`
+	// Cancelled is set when the run was cancelled by the client, Content then holds the partial output
+	Cancelled bool `json:"cancelled,omitempty"`
+	// Summary summarizes the conversation up to and including this message, it replaces those messages in the model context
+	Summary *MemorySummary `json:"summary,omitempty"`
+	// Attachments are the files sent with a user message
+	Attachments []AttachmentRef `json:"attachments,omitempty"`
+	// Params are the model parameters the reply was generated with
+	Params *ModelParams `json:"params,omitempty"`
+	// Delegation is set on the reply of another role delivered to the session that called it in async mode
+	Delegation *DelegationResult `json:"delegation,omitempty"`
+}
+
+// DelegationResult is the outcome of an async call to another role.
+type DelegationResult struct {
+	RoleID    string `json:"role_id"`
+	RoleName  string `json:"role_name,omitempty"`
+	SessionID string `json:"session_id,omitempty"` // session of the called role
+	Error     string `json:"error,omitempty"`
+}
+
+// MemorySummary is an LLM generated summary of the older turns of a session.
+type MemorySummary struct {
+	Content      string    `json:"content"`
+	MessageCount int       `json:"message_count"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// TokenUsage is the token consumption and cost of the run that produced a message.
+type TokenUsage struct {
+	PromptTokens     int     `json:"prompt_tokens"`
+	CompletionTokens int     `json:"completion_tokens"`
+	TotalTokens      int     `json:"total_tokens"`
+	Cost             float64 `json:"cost"`
+	Currency         string  `json:"currency,omitempty"`
+	// Estimated is set when the provider did not report usage and the counts were estimated locally
+	Estimated bool `json:"estimated,omitempty"`
+}
+
+// ToolCallTrace records a single tool invocation made while producing a message.
+type ToolCallTrace struct {
+	Name        string                 `json:"name"`
+	Arguments   map[string]interface{} `json:"arguments,omitempty"`
+	Observation string                 `json:"observation,omitempty"`
+	Error       string                 `json:"error,omitempty"`
+	DurationMs  int64                  `json:"duration_ms"`
+	StartedAt   time.Time              `json:"started_at"`
+	// Sensitive lists the arguments marked sensitive by the tool, they are redacted when the session is shared
+	Sensitive []string `json:"sensitive,omitempty"`
+	// Approval is the decision on a call the role requires approval for, nil for the other calls
+	Approval *ToolApproval `json:"approval,omitempty"`
+}
+
+const (
+	ToolApprovalApproved = "approved"
+	ToolApprovalRejected = "rejected"
+	ToolApprovalTimedOut = "timed_out"
+)
+
+// ToolApproval records how a tool call waiting for approval was decided.
+// Arguments of the call are the ones executed, OriginalArguments keeps the
+// ones of the model when the approver edited them.
+type ToolApproval struct {
+	Status            string                 `json:"status"`
+	DecidedBy         string                 `json:"decided_by,omitempty"`
+	DecidedAt         *time.Time             `json:"decided_at,omitempty"`
+	Reason            string                 `json:"reason,omitempty"`
+	OriginalArguments map[string]interface{} `json:"original_arguments,omitempty"`
+	WaitedMs          int64                  `json:"waited_ms"`
 }
 
 func (m *MessageMeta) Value() (driver.Value, error) {
@@ -59,6 +132,7 @@ type ChatMessageFieldMeta struct {
 	ALL       field.Asterisk
 	ID        field.String
 	SessionID field.String
+	ParentID  field.String
 	Role      field.String
 	Content   field.String
 	Meta      field.Field
@@ -7,20 +7,45 @@ import (
 	"gorm.io/gen/field"
 )
 
-const TableChatSession = "chat_sessions"
+const (
+	TableChatSession    = "chat_sessions"
+	TableChatSessionTag = "chat_session_tags"
+)
+
+var (
+	ChatSessionFM    = sql.NewGlobalFieldMetaMapping(ChatSession{}, ChatSessionFieldMeta{})
+	ChatSessionTagFM = sql.NewGlobalFieldMetaMapping(ChatSessionTag{}, ChatSessionTagFieldMeta{})
+)
 
-var ChatSessionFM = sql.NewGlobalFieldMetaMapping(ChatSession{}, ChatSessionFieldMeta{})
+// Title sources of a chat session
+const (
+	ChatTitleSourceAuto   = "auto"   // first user message, replaced by a generated title
+	ChatTitleSourceModel  = "model"  // generated by the model after the first exchange
+	ChatTitleSourceManual = "manual" // set by the user, never overwritten
+)
 
 type ChatSession struct {
-	ID        string    `json:"id" gorm:"column:id;type:varchar(36);primaryKey;comment:会话ID (session_id)"`
-	UserID    string    `json:"user_id" gorm:"column:user_id;type:varchar(36);not null;index;comment:发起用户ID"`
-	RoleID    string    `json:"role_id" gorm:"column:role_id;type:varchar(36);not null;index;comment:绑定的角色ID (不可变)"`
-	RoleName  string    `json:"role_name" gorm:"column:role_name;type:varchar(64);not null;comment:角色名称快照"`
-	Provider  string    `json:"provider" gorm:"column:provider;type:varchar(64);not null;comment:模型提供商 (不可变)"`
-	ModelName string    `json:"model_name" gorm:"column:model_name;type:varchar(128);not null;comment:模型名称 (不可变)"`
-	Title     *string   `json:"title" gorm:"column:title;type:varchar(255);comment:会话标题 (模型异步总结)"`
-	CreatedAt time.Time `json:"created_at" gorm:"column:created_at;type:timestamp;not null;default:CURRENT_TIMESTAMP;autoCreateTime"`
-	UpdatedAt time.Time `json:"updated_at" gorm:"column:updated_at;type:timestamp;not null;default:CURRENT_TIMESTAMP;autoUpdateTime"`
+	ID               string       `json:"id" gorm:"column:id;type:varchar(36);primaryKey;comment:会话ID (session_id)"`
+	UserID           string       `json:"user_id" gorm:"column:user_id;type:varchar(36);not null;index;comment:发起用户ID"`
+	RoleID           string       `json:"role_id" gorm:"column:role_id;type:varchar(36);not null;index;comment:绑定的角色ID (不可变)"`
+	RoleName         string       `json:"role_name" gorm:"column:role_name;type:varchar(64);not null;comment:角色名称快照"`
+	Provider         string       `json:"provider" gorm:"column:provider;type:varchar(64);not null;comment:模型提供商 (不可变)"`
+	ModelName        string       `json:"model_name" gorm:"column:model_name;type:varchar(128);not null;comment:模型名称 (不可变)"`
+	Title            *string      `json:"title" gorm:"column:title;type:varchar(255);comment:会话标题 (模型异步总结)"`
+	TitleSource      string       `json:"title_source" gorm:"column:title_source;type:varchar(16);not null;default:'';comment:标题来源 (auto, model, manual)"`
+	CurrentMessageID *string      `json:"current_message_id" gorm:"column:current_message_id;type:varchar(36);comment:当前分支的最后一条消息ID"`
+	ShareToken       *string      `json:"share_token" gorm:"column:share_token;type:varchar(64);uniqueIndex;comment:只读分享令牌 (为空表示未分享)"`
+	ShareExpiresAt   *time.Time   `json:"share_expires_at" gorm:"column:share_expires_at;type:timestamp NULL;comment:分享过期时间 (为空表示不过期)"`
+	Pinned           bool         `json:"pinned" gorm:"column:pinned;not null;default:false;comment:是否置顶"`
+	Archived         bool         `json:"archived" gorm:"column:archived;not null;default:false;index;comment:是否归档"`
+	FolderID         *string      `json:"folder_id" gorm:"column:folder_id;type:varchar(36);index;comment:所属文件夹ID"`
+	LastActiveAt     *time.Time   `json:"last_active_at" gorm:"column:last_active_at;type:timestamp NULL;index;comment:最后一条消息的时间"`
+	ModelParams      *ModelParams `json:"model_params" gorm:"column:model_params;type:text;comment:会话模型参数 (覆盖角色默认参数)"`
+	ParentSessionID  *string      `json:"parent_session_id" gorm:"column:parent_session_id;type:varchar(36);index;comment:调用方会话ID (角色间调用创建的子会话)"`
+	ParentMessageID  *string      `json:"parent_message_id" gorm:"column:parent_message_id;type:varchar(36);comment:调用方发起调用的消息ID"`
+	TeamID           *string      `json:"team_id" gorm:"column:team_id;type:varchar(36);index;comment:团队ID (与团队的会话, RoleID为主管角色)"`
+	CreatedAt        time.Time    `json:"created_at" gorm:"column:created_at;type:timestamp;not null;default:CURRENT_TIMESTAMP;autoCreateTime"`
+	UpdatedAt        time.Time    `json:"updated_at" gorm:"column:updated_at;type:timestamp;not null;default:CURRENT_TIMESTAMP;autoUpdateTime"`
 }
 
 func (ChatSession) TableName() string {
@@ -28,15 +53,48 @@ func (ChatSession) TableName() string {
 }
 
 type ChatSessionFieldMeta struct {
+	sql.CTable
+	ALL              field.Asterisk
+	ID               field.String
+	UserID           field.String
+	RoleID           field.String
+	RoleName         field.String
+	Provider         field.String
+	ModelName        field.String
+	Title            field.String
+	TitleSource      field.String
+	CurrentMessageID field.String
+	ShareToken       field.String
+	ShareExpiresAt   field.Field
+	Pinned           field.Bool
+	Archived         field.Bool
+	FolderID         field.String
+	LastActiveAt     field.Field
+	ModelParams      field.Field
+	ParentSessionID  field.String
+	ParentMessageID  field.String
+	TeamID           field.String
+	CreatedAt        field.Time
+	UpdatedAt        field.Time
+}
+
+// ChatSessionTag is a tag set by the user on a session
+type ChatSessionTag struct {
+	SessionID string    `json:"session_id" gorm:"column:session_id;type:varchar(36);primaryKey;comment:会话ID"`
+	Tag       string    `json:"tag" gorm:"column:tag;type:varchar(64);primaryKey;comment:标签"`
+	UserID    string    `json:"user_id" gorm:"column:user_id;type:varchar(36);not null;index;comment:会话所属用户ID"`
+	CreatedAt time.Time `json:"created_at" gorm:"column:created_at;type:timestamp;not null;default:CURRENT_TIMESTAMP;autoCreateTime"`
+}
+
+func (ChatSessionTag) TableName() string {
+	return TableChatSessionTag
+}
+
+type ChatSessionTagFieldMeta struct {
 	sql.CTable
 	ALL       field.Asterisk
-	ID        field.String
+	SessionID field.String
+	Tag       field.String
 	UserID    field.String
-	RoleID    field.String
-	RoleName  field.String
-	Provider  field.String
-	ModelName field.String
-	Title     field.String
 	CreatedAt field.Time
-	UpdatedAt field.Time
 }
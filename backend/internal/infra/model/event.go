@@ -0,0 +1,141 @@
+package model
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"time"
+
+	"github.com/xichan96/cortex-lab/pkg/sql"
+	"gorm.io/gen/field"
+)
+
+const (
+	TableEventSubscription = "event_subscriptions"
+	TableEventDelivery     = "event_deliveries"
+)
+
+// The events a subscription can receive
+const (
+	EventSessionCreated    = "session.created"
+	EventMessageCompleted  = "message.completed"
+	EventToolFailed        = "tool.failed"
+	EventExperienceCreated = "experience.created"
+	EventExperienceUpdated = "experience.updated"
+	EventRoleUpdated       = "role.updated"
+)
+
+// Events lists every event a subscription can receive
+var Events = []string{
+	EventSessionCreated,
+	EventMessageCompleted,
+	EventToolFailed,
+	EventExperienceCreated,
+	EventExperienceUpdated,
+	EventRoleUpdated,
+}
+
+const (
+	EventDeliveryPending   = "pending"
+	EventDeliverySucceeded = "succeeded"
+	EventDeliveryFailed    = "failed"
+)
+
+var EventSubscriptionFM = sql.NewGlobalFieldMetaMapping(EventSubscription{}, EventSubscriptionFieldMeta{})
+var EventDeliveryFM = sql.NewGlobalFieldMetaMapping(EventDelivery{}, EventDeliveryFieldMeta{})
+
+// EventSubscription posts the events of its owner to URL, signed with Secret,
+// which is stored encrypted
+type EventSubscription struct {
+	ID        string     `json:"id" gorm:"column:id;type:varchar(36);primaryKey;comment:订阅ID"`
+	UserID    string     `json:"user_id" gorm:"column:user_id;type:varchar(36);not null;index;comment:所属用户ID"`
+	Name      string     `json:"name" gorm:"column:name;type:varchar(64);not null;comment:订阅名称"`
+	URL       string     `json:"url" gorm:"column:url;type:varchar(1024);not null;comment:接收地址"`
+	Secret    string     `json:"-" gorm:"column:secret;type:varchar(255);not null;comment:HMAC 签名密钥"`
+	Events    EventNames `json:"events" gorm:"column:events;type:text;comment:订阅的事件 (JSON Array)"`
+	Enabled   int        `json:"enabled" gorm:"column:enabled;type:tinyint(1);not null;default:1;comment:是否启用 (0:停用, 1:启用)"`
+	CreatedAt time.Time  `json:"created_at" gorm:"column:created_at;type:timestamp;not null;default:CURRENT_TIMESTAMP;autoCreateTime;comment:创建时间"`
+	UpdatedAt time.Time  `json:"updated_at" gorm:"column:updated_at;type:timestamp;not null;default:CURRENT_TIMESTAMP;autoUpdateTime;comment:更新时间"`
+}
+
+func (EventSubscription) TableName() string {
+	return TableEventSubscription
+}
+
+type EventNames []string
+
+func (e EventNames) Value() (driver.Value, error) {
+	if e == nil {
+		return "[]", nil
+	}
+	data, err := json.Marshal(e)
+	return string(data), err
+}
+
+func (e *EventNames) Scan(value interface{}) error {
+	return scanJSON(value, e)
+}
+
+// Has reports whether event is one of the names
+func (e EventNames) Has(event string) bool {
+	for _, name := range e {
+		if name == event {
+			return true
+		}
+	}
+	return false
+}
+
+type EventSubscriptionFieldMeta struct {
+	sql.CTable
+	ALL       field.Asterisk
+	ID        field.String
+	UserID    field.String
+	Name      field.String
+	URL       field.String
+	Secret    field.String
+	Events    field.Field
+	Enabled   field.Int
+	CreatedAt field.Time
+	UpdatedAt field.Time
+}
+
+// EventDelivery is an event posted to a subscription. A pending delivery is
+// attempted again at NextAttemptAt until it succeeds or runs out of attempts.
+type EventDelivery struct {
+	ID             string `json:"id" gorm:"column:id;type:varchar(36);primaryKey;comment:投递ID"`
+	SubscriptionID string `json:"subscription_id" gorm:"column:subscription_id;type:varchar(36);not null;index;comment:订阅ID"`
+	UserID         string `json:"user_id" gorm:"column:user_id;type:varchar(36);not null;index;comment:所属用户ID"`
+	// EventID is shared by the deliveries of the same event, redeliveries included
+	EventID       string     `json:"event_id" gorm:"column:event_id;type:varchar(36);not null;index;comment:事件ID"`
+	Event         string     `json:"event" gorm:"column:event;type:varchar(64);not null;comment:事件名称"`
+	Payload       string     `json:"payload" gorm:"column:payload;type:text;comment:投递内容 (JSON)"`
+	Status        string     `json:"status" gorm:"column:status;type:varchar(16);not null;index:idx_event_delivery_due,priority:1;comment:状态 (pending, succeeded, failed)"`
+	Attempts      int        `json:"attempts" gorm:"column:attempts;type:int;not null;default:0;comment:已尝试次数"`
+	ResponseCode  int        `json:"response_code" gorm:"column:response_code;type:int;not null;default:0;comment:最近一次响应状态码"`
+	Error         string     `json:"error" gorm:"column:error;type:text;comment:最近一次错误信息"`
+	NextAttemptAt *time.Time `json:"next_attempt_at" gorm:"column:next_attempt_at;type:timestamp NULL;index:idx_event_delivery_due,priority:2;comment:下次尝试时间"`
+	CreatedAt     time.Time  `json:"created_at" gorm:"column:created_at;type:timestamp;not null;default:CURRENT_TIMESTAMP;autoCreateTime;index;comment:创建时间"`
+	DeliveredAt   *time.Time `json:"delivered_at" gorm:"column:delivered_at;type:timestamp NULL;comment:投递成功时间"`
+}
+
+func (EventDelivery) TableName() string {
+	return TableEventDelivery
+}
+
+type EventDeliveryFieldMeta struct {
+	sql.CTable
+	ALL            field.Asterisk
+	ID             field.String
+	SubscriptionID field.String
+	UserID         field.String
+	EventID        field.String
+	Event          field.String
+	Payload        field.String
+	Status         field.String
+	Attempts       field.Int
+	ResponseCode   field.Int
+	Error          field.String
+	NextAttemptAt  field.Field
+	CreatedAt      field.Time
+	DeliveredAt    field.Field
+}
@@ -11,11 +11,19 @@ import (
 const (
 	TableExperience             = "experiences"
 	TableRoleExperienceRelation = "role_experience_relations"
+	TableExperienceDocument     = "experience_documents"
+)
+
+const (
+	ExperienceTypeSnippet          = "snippet"
+	ExperienceTypeDocumentFragment = "document_fragment"
+	ExperienceTypeExternalLink     = "external_link"
 )
 
 var (
 	ExperienceFM             = sql.NewGlobalFieldMetaMapping(Experience{}, ExperienceFieldMeta{})
 	RoleExperienceRelationFM = sql.NewGlobalFieldMetaMapping(RoleExperienceRelation{}, RoleExperienceRelationFieldMeta{})
+	ExperienceDocumentFM     = sql.NewGlobalFieldMetaMapping(ExperienceDocument{}, ExperienceDocumentFieldMeta{})
 )
 
 type Experience struct {
@@ -71,3 +79,41 @@ type RoleExperienceRelationFieldMeta struct {
 	ExperienceID field.String
 	CreatedAt    field.Time
 }
+
+// ExperienceDocument is an uploaded document, its content is stored as
+// document_fragment experiences whose SourceID is the document ID.
+type ExperienceDocument struct {
+	ID         string         `json:"id" gorm:"column:id;type:varchar(36);primaryKey;comment:文档ID"`
+	Title      string         `json:"title" gorm:"column:title;type:varchar(255);not null;comment:文档标题"`
+	FileName   string         `json:"file_name" gorm:"column:file_name;type:varchar(255);not null;comment:原始文件名"`
+	Format     string         `json:"format" gorm:"column:format;type:varchar(16);not null;comment:文档格式 (markdown, text, html, pdf)"`
+	Size       int64          `json:"size" gorm:"column:size;type:bigint;not null;default:0;comment:文件大小 (字节)"`
+	ChunkCount int            `json:"chunk_count" gorm:"column:chunk_count;type:int;not null;default:0;comment:切分出的片段数"`
+	RoleID     string         `json:"role_id" gorm:"column:role_id;type:varchar(36);index;comment:关联角色ID"`
+	Tags       string         `json:"tags" gorm:"column:tags;type:json;comment:标签列表 (JSON Array, 同步到片段)"`
+	CreatedBy  string         `json:"created_by" gorm:"column:created_by;type:varchar(36);not null;comment:创建人ID"`
+	CreatedAt  time.Time      `json:"created_at" gorm:"column:created_at;type:timestamp;not null;default:CURRENT_TIMESTAMP;autoCreateTime"`
+	UpdatedAt  time.Time      `json:"updated_at" gorm:"column:updated_at;type:timestamp;not null;default:CURRENT_TIMESTAMP;autoUpdateTime"`
+	DeletedAt  gorm.DeletedAt `json:"deleted_at,omitempty" gorm:"column:deleted_at;type:timestamp NULL;index;comment:软删除时间"`
+}
+
+func (ExperienceDocument) TableName() string {
+	return TableExperienceDocument
+}
+
+type ExperienceDocumentFieldMeta struct {
+	sql.CTable
+	ALL        field.Asterisk
+	ID         field.String
+	Title      field.String
+	FileName   field.String
+	Format     field.String
+	Size       field.Int64
+	ChunkCount field.Int
+	RoleID     field.String
+	Tags       field.String
+	CreatedBy  field.String
+	CreatedAt  field.Time
+	UpdatedAt  field.Time
+	DeletedAt  field.Field
+}
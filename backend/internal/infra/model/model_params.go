@@ -0,0 +1,111 @@
+package model
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+)
+
+// ModelParams are the generation parameters of an agent run. Unset fields
+// keep the value of the level below: engine defaults, then role, then session.
+type ModelParams struct {
+	Temperature        *float32 `json:"temperature,omitempty"`
+	MaxTokens          *int     `json:"max_tokens,omitempty"`
+	TopP               *float32 `json:"top_p,omitempty"`
+	FrequencyPenalty   *float32 `json:"frequency_penalty,omitempty"`
+	PresencePenalty    *float32 `json:"presence_penalty,omitempty"`
+	StopSequences      []string `json:"stop_sequences,omitempty"`
+	MaxIterations      *int     `json:"max_iterations,omitempty"`
+	TimeoutSeconds     *int     `json:"timeout_seconds,omitempty"`
+	ToolTimeoutSeconds *int     `json:"tool_timeout_seconds,omitempty"`
+	RetryAttempts      *int     `json:"retry_attempts,omitempty"`
+	RetryDelayMs       *int     `json:"retry_delay_ms,omitempty"`
+}
+
+// Merge returns a copy of p with the fields set in over replacing its own
+func (p *ModelParams) Merge(over *ModelParams) *ModelParams {
+	out := &ModelParams{}
+	if p != nil {
+		*out = *p
+	}
+	if over == nil {
+		return out
+	}
+	if over.Temperature != nil {
+		out.Temperature = over.Temperature
+	}
+	if over.MaxTokens != nil {
+		out.MaxTokens = over.MaxTokens
+	}
+	if over.TopP != nil {
+		out.TopP = over.TopP
+	}
+	if over.FrequencyPenalty != nil {
+		out.FrequencyPenalty = over.FrequencyPenalty
+	}
+	if over.PresencePenalty != nil {
+		out.PresencePenalty = over.PresencePenalty
+	}
+	if over.StopSequences != nil {
+		out.StopSequences = over.StopSequences
+	}
+	if over.MaxIterations != nil {
+		out.MaxIterations = over.MaxIterations
+	}
+	if over.TimeoutSeconds != nil {
+		out.TimeoutSeconds = over.TimeoutSeconds
+	}
+	if over.ToolTimeoutSeconds != nil {
+		out.ToolTimeoutSeconds = over.ToolTimeoutSeconds
+	}
+	if over.RetryAttempts != nil {
+		out.RetryAttempts = over.RetryAttempts
+	}
+	if over.RetryDelayMs != nil {
+		out.RetryDelayMs = over.RetryDelayMs
+	}
+	return out
+}
+
+// Valid tells whether every set field is within the range accepted by the providers
+func (p *ModelParams) Valid() bool {
+	if p == nil {
+		return true
+	}
+	inRange := func(v *float32, low, high float32) bool {
+		return v == nil || (*v >= low && *v <= high)
+	}
+	between := func(v *int, low, high int) bool {
+		return v == nil || (*v >= low && *v <= high)
+	}
+	return inRange(p.Temperature, 0, 2) &&
+		inRange(p.TopP, 0, 1) &&
+		inRange(p.FrequencyPenalty, -2, 2) &&
+		inRange(p.PresencePenalty, -2, 2) &&
+		between(p.MaxTokens, 1, 1<<20) &&
+		between(p.MaxIterations, 1, 50) &&
+		between(p.TimeoutSeconds, 1, 3600) &&
+		between(p.ToolTimeoutSeconds, 1, 3600) &&
+		between(p.RetryAttempts, 0, 10) &&
+		between(p.RetryDelayMs, 0, 60000) &&
+		len(p.StopSequences) <= 4
+}
+
+func (p *ModelParams) Value() (driver.Value, error) {
+	if p == nil {
+		return nil, nil
+	}
+	return json.Marshal(p)
+}
+
+func (p *ModelParams) Scan(value interface{}) error {
+	if value == nil {
+		return nil
+	}
+	switch v := value.(type) {
+	case []byte:
+		return json.Unmarshal(v, p)
+	case string:
+		return json.Unmarshal([]byte(v), p)
+	}
+	return nil
+}
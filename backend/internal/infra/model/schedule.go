@@ -0,0 +1,103 @@
+package model
+
+import (
+	"time"
+
+	"github.com/xichan96/cortex-lab/pkg/sql"
+	"gorm.io/gen/field"
+)
+
+const (
+	TableSchedule    = "schedules"
+	TableScheduleRun = "schedule_runs"
+)
+
+const (
+	ScheduleRunRunning   = "running"
+	ScheduleRunSucceeded = "succeeded"
+	ScheduleRunFailed    = "failed"
+)
+
+var ScheduleFM = sql.NewGlobalFieldMetaMapping(Schedule{}, ScheduleFieldMeta{})
+var ScheduleRunFM = sql.NewGlobalFieldMetaMapping(ScheduleRun{}, ScheduleRunFieldMeta{})
+
+// Schedule sends an input to a role on a cron schedule. NextRunAt is kept in
+// the DB so the schedule survives restarts of the server.
+type Schedule struct {
+	ID        string  `json:"id" gorm:"column:id;type:varchar(36);primaryKey;comment:定时任务ID"`
+	UserID    string  `json:"user_id" gorm:"column:user_id;type:varchar(36);not null;index;comment:所属用户ID"`
+	Name      string  `json:"name" gorm:"column:name;type:varchar(64);not null;comment:定时任务名称"`
+	RoleID    string  `json:"role_id" gorm:"column:role_id;type:varchar(36);not null;index;comment:角色ID"`
+	Provider  string  `json:"provider" gorm:"column:provider;type:varchar(50);default:'';comment:模型提供方 (空则按角色解析)"`
+	ModelName string  `json:"model_name" gorm:"column:model_name;type:varchar(100);default:'';comment:模型名称 (空则按角色解析)"`
+	Cron      string  `json:"cron" gorm:"column:cron;type:varchar(100);not null;comment:cron 表达式"`
+	Timezone  string  `json:"timezone" gorm:"column:timezone;type:varchar(64);not null;default:'UTC';comment:时区"`
+	Input     string  `json:"input" gorm:"column:input;type:text;not null;comment:输入内容"`
+	SessionID *string `json:"session_id" gorm:"column:session_id;type:varchar(36);comment:目标会话ID (空则每次运行新建会话)"`
+	Enabled   int     `json:"enabled" gorm:"column:enabled;type:tinyint(1);not null;default:1;comment:是否启用 (0:停用, 1:启用)"`
+	// NextRunAt is nil when the expression never matches again
+	NextRunAt *time.Time `json:"next_run_at" gorm:"column:next_run_at;type:timestamp NULL;index;comment:下次运行时间"`
+	LastRunAt *time.Time `json:"last_run_at" gorm:"column:last_run_at;type:timestamp NULL;comment:上次运行时间"`
+	CreatedAt time.Time  `json:"created_at" gorm:"column:created_at;type:timestamp;not null;default:CURRENT_TIMESTAMP;autoCreateTime;comment:创建时间"`
+	UpdatedAt time.Time  `json:"updated_at" gorm:"column:updated_at;type:timestamp;not null;default:CURRENT_TIMESTAMP;autoUpdateTime;comment:更新时间"`
+}
+
+func (Schedule) TableName() string {
+	return TableSchedule
+}
+
+type ScheduleFieldMeta struct {
+	sql.CTable
+	ALL       field.Asterisk
+	ID        field.String
+	UserID    field.String
+	Name      field.String
+	RoleID    field.String
+	Provider  field.String
+	ModelName field.String
+	Cron      field.String
+	Timezone  field.String
+	Input     field.String
+	SessionID field.String
+	Enabled   field.Int
+	NextRunAt field.Field
+	LastRunAt field.Field
+	CreatedAt field.Time
+	UpdatedAt field.Time
+}
+
+// ScheduleRun is a run of a schedule with its outcome
+type ScheduleRun struct {
+	ID          string     `json:"id" gorm:"column:id;type:varchar(36);primaryKey;comment:运行ID"`
+	ScheduleID  string     `json:"schedule_id" gorm:"column:schedule_id;type:varchar(36);not null;index;comment:定时任务ID"`
+	UserID      string     `json:"user_id" gorm:"column:user_id;type:varchar(36);not null;index;comment:所属用户ID"`
+	SessionID   string     `json:"session_id" gorm:"column:session_id;type:varchar(36);default:'';comment:运行所在会话ID"`
+	Status      string     `json:"status" gorm:"column:status;type:varchar(16);not null;comment:状态 (running, succeeded, failed)"`
+	Output      string     `json:"output" gorm:"column:output;type:text;comment:运行输出"`
+	Error       string     `json:"error" gorm:"column:error;type:text;comment:错误信息"`
+	ScheduledAt time.Time  `json:"scheduled_at" gorm:"column:scheduled_at;type:timestamp;not null;comment:计划运行时间"`
+	CreatedAt   time.Time  `json:"created_at" gorm:"column:created_at;type:timestamp;not null;default:CURRENT_TIMESTAMP;autoCreateTime;index;comment:开始时间"`
+	FinishedAt  *time.Time `json:"finished_at" gorm:"column:finished_at;type:timestamp NULL;comment:结束时间"`
+	// HeartbeatAt is refreshed by the server executing the run, a running run whose heartbeat stopped was interrupted
+	HeartbeatAt *time.Time `json:"-" gorm:"column:heartbeat_at;type:timestamp NULL;comment:心跳时间"`
+}
+
+func (ScheduleRun) TableName() string {
+	return TableScheduleRun
+}
+
+type ScheduleRunFieldMeta struct {
+	sql.CTable
+	ALL         field.Asterisk
+	ID          field.String
+	ScheduleID  field.String
+	UserID      field.String
+	SessionID   field.String
+	Status      field.String
+	Output      field.String
+	Error       field.String
+	ScheduledAt field.Time
+	CreatedAt   field.Time
+	FinishedAt  field.Field
+	HeartbeatAt field.Field
+}
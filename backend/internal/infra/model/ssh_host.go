@@ -0,0 +1,101 @@
+package model
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"time"
+
+	"github.com/xichan96/cortex-lab/pkg/sql"
+	"gorm.io/gen/field"
+)
+
+const (
+	TableSSHHost      = "ssh_hosts"
+	TableSSHHostGrant = "ssh_host_grants"
+)
+
+const (
+	SSHAuthPassword = "password"
+	SSHAuthKey      = "key"
+)
+
+var SSHHostFM = sql.NewGlobalFieldMetaMapping(SSHHost{}, SSHHostFieldMeta{})
+var SSHHostGrantFM = sql.NewGlobalFieldMetaMapping(SSHHostGrant{}, SSHHostGrantFieldMeta{})
+
+// SSHHost is a server the ssh tool of the roles granted to it connects to.
+// The model only picks the host by name, the credential never leaves the
+// server and is stored encrypted.
+type SSHHost struct {
+	ID          string `json:"id" gorm:"column:id;type:varchar(36);primaryKey;comment:主机ID"`
+	UserID      string `json:"user_id" gorm:"column:user_id;type:varchar(36);not null;index;comment:所属用户ID"`
+	Name        string `json:"name" gorm:"column:name;type:varchar(64);not null;comment:主机名称 (模型按名称选择)"`
+	Description string `json:"description" gorm:"column:description;type:varchar(255);default:'';comment:主机描述"`
+	Address     string `json:"address" gorm:"column:address;type:varchar(255);not null;comment:主机地址"`
+	Port        int    `json:"port" gorm:"column:port;type:int;not null;default:22;comment:SSH 端口"`
+	Username    string `json:"username" gorm:"column:username;type:varchar(64);not null;comment:登录用户"`
+	AuthType    string `json:"auth_type" gorm:"column:auth_type;type:varchar(16);not null;comment:认证方式 (password, key)"`
+	// Credential is the encrypted password or private key, Passphrase the encrypted passphrase of the key
+	Credential string `json:"-" gorm:"column:credential;type:text;comment:加密的密码或私钥"`
+	Passphrase string `json:"-" gorm:"column:passphrase;type:text;comment:加密的私钥口令"`
+	// HostKey is the pinned SHA256 fingerprint of the host key, the host is not connected to without it
+	HostKey       string      `json:"host_key" gorm:"column:host_key;type:varchar(128);default:'';comment:固定的主机公钥指纹 (SHA256)"`
+	AllowCommands SSHCommands `json:"allow_commands" gorm:"column:allow_commands;type:text;comment:允许执行的命令 (JSON Array, 空则不限制)"`
+	CreatedAt     time.Time   `json:"created_at" gorm:"column:created_at;type:timestamp;not null;default:CURRENT_TIMESTAMP;autoCreateTime;comment:创建时间"`
+	UpdatedAt     time.Time   `json:"updated_at" gorm:"column:updated_at;type:timestamp;not null;default:CURRENT_TIMESTAMP;autoUpdateTime;comment:更新时间"`
+}
+
+func (SSHHost) TableName() string {
+	return TableSSHHost
+}
+
+type SSHCommands []string
+
+func (c SSHCommands) Value() (driver.Value, error) {
+	if c == nil {
+		return "[]", nil
+	}
+	data, err := json.Marshal(c)
+	return string(data), err
+}
+
+func (c *SSHCommands) Scan(value interface{}) error {
+	return scanJSON(value, c)
+}
+
+type SSHHostFieldMeta struct {
+	sql.CTable
+	ALL           field.Asterisk
+	ID            field.String
+	UserID        field.String
+	Name          field.String
+	Description   field.String
+	Address       field.String
+	Port          field.Int
+	Username      field.String
+	AuthType      field.String
+	Credential    field.String
+	Passphrase    field.String
+	HostKey       field.String
+	AllowCommands field.Field
+	CreatedAt     field.Time
+	UpdatedAt     field.Time
+}
+
+// SSHHostGrant lets the ssh tool of a role connect to a host
+type SSHHostGrant struct {
+	HostID    string    `json:"host_id" gorm:"column:host_id;type:varchar(36);primaryKey;comment:主机ID"`
+	RoleID    string    `json:"role_id" gorm:"column:role_id;type:varchar(36);primaryKey;index;comment:角色ID"`
+	CreatedAt time.Time `json:"created_at" gorm:"column:created_at;type:timestamp;not null;default:CURRENT_TIMESTAMP;autoCreateTime"`
+}
+
+func (SSHHostGrant) TableName() string {
+	return TableSSHHostGrant
+}
+
+type SSHHostGrantFieldMeta struct {
+	sql.CTable
+	ALL       field.Asterisk
+	HostID    field.String
+	RoleID    field.String
+	CreatedAt field.Time
+}
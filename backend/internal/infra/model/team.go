@@ -0,0 +1,45 @@
+package model
+
+import (
+	"time"
+
+	"github.com/xichan96/cortex-lab/pkg/sql"
+	"gorm.io/gen/field"
+)
+
+const TableTeam = "teams"
+
+var TeamFM = sql.NewGlobalFieldMetaMapping(Team{}, TeamFieldMeta{})
+
+// Team is a supervisor role that routes work to member roles
+type Team struct {
+	ID               string    `json:"id" gorm:"column:id;type:varchar(36);primaryKey;comment:团队ID"`
+	Name             string    `json:"name" gorm:"column:name;type:varchar(64);not null;comment:团队名称"`
+	Description      string    `json:"description" gorm:"column:description;type:varchar(255);default:'';comment:团队描述"`
+	Avatar           string    `json:"avatar" gorm:"column:avatar;type:varchar(255);default:'';comment:团队头像emoji"`
+	SupervisorRoleID string    `json:"supervisor_role_id" gorm:"column:supervisor_role_id;type:varchar(36);not null;index;comment:主管角色ID"`
+	MemberRoleIDs    string    `json:"member_role_ids" gorm:"column:member_role_ids;type:json;comment:成员角色ID列表 (JSON Array)"`
+	CreatorID        string    `json:"creator_id" gorm:"column:creator_id;type:varchar(36);not null;index;comment:创建者ID"`
+	IsPublic         int       `json:"is_public" gorm:"column:is_public;type:tinyint(1);not null;default:0;comment:是否公开 (0:私有, 1:公开)"`
+	CreatedAt        time.Time `json:"created_at" gorm:"column:created_at;type:timestamp;not null;default:CURRENT_TIMESTAMP;autoCreateTime;comment:创建时间"`
+	UpdatedAt        time.Time `json:"updated_at" gorm:"column:updated_at;type:timestamp;not null;default:CURRENT_TIMESTAMP;autoUpdateTime;comment:更新时间"`
+}
+
+func (Team) TableName() string {
+	return TableTeam
+}
+
+type TeamFieldMeta struct {
+	sql.CTable
+	ALL              field.Asterisk
+	ID               field.String
+	Name             field.String
+	Description      field.String
+	Avatar           field.String
+	SupervisorRoleID field.String
+	MemberRoleIDs    field.String
+	CreatorID        field.String
+	IsPublic         field.Int
+	CreatedAt        field.Time
+	UpdatedAt        field.Time
+}
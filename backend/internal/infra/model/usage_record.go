@@ -0,0 +1,50 @@
+package model
+
+import (
+	"time"
+
+	"github.com/xichan96/cortex-lab/pkg/sql"
+	"gorm.io/gen/field"
+)
+
+const TableUsageRecord = "usage_records"
+
+var UsageRecordFM = sql.NewGlobalFieldMetaMapping(UsageRecord{}, UsageRecordFieldMeta{})
+
+type UsageRecord struct {
+	ID               string    `json:"id" gorm:"column:id;type:varchar(36);primaryKey;comment:记录ID"`
+	UserID           string    `json:"user_id" gorm:"column:user_id;type:varchar(36);not null;index;comment:用户ID"`
+	SessionID        string    `json:"session_id" gorm:"column:session_id;type:varchar(36);not null;index;comment:会话ID"`
+	MessageID        string    `json:"message_id" gorm:"column:message_id;type:varchar(36);not null;index;comment:助手消息ID"`
+	RoleID           string    `json:"role_id" gorm:"column:role_id;type:varchar(36);not null;index;comment:角色ID"`
+	Provider         string    `json:"provider" gorm:"column:provider;type:varchar(64);not null;comment:模型提供商"`
+	ModelName        string    `json:"model_name" gorm:"column:model_name;type:varchar(128);not null;comment:模型名称"`
+	PromptTokens     int       `json:"prompt_tokens" gorm:"column:prompt_tokens;type:int;not null;default:0;comment:输入Token数"`
+	CompletionTokens int       `json:"completion_tokens" gorm:"column:completion_tokens;type:int;not null;default:0;comment:输出Token数"`
+	TotalTokens      int       `json:"total_tokens" gorm:"column:total_tokens;type:int;not null;default:0;comment:总Token数"`
+	Cost             float64   `json:"cost" gorm:"column:cost;type:decimal(20,8);not null;default:0;comment:费用 (按记录时的价格表计算)"`
+	Estimated        bool      `json:"estimated" gorm:"column:estimated;not null;default:false;comment:Token数是否为本地估算"`
+	CreatedAt        time.Time `json:"created_at" gorm:"column:created_at;type:timestamp;not null;default:CURRENT_TIMESTAMP;index;autoCreateTime"`
+}
+
+func (UsageRecord) TableName() string {
+	return TableUsageRecord
+}
+
+type UsageRecordFieldMeta struct {
+	sql.CTable
+	ALL              field.Asterisk
+	ID               field.String
+	UserID           field.String
+	SessionID        field.String
+	MessageID        field.String
+	RoleID           field.String
+	Provider         field.String
+	ModelName        field.String
+	PromptTokens     field.Int
+	CompletionTokens field.Int
+	TotalTokens      field.Int
+	Cost             field.Float64
+	Estimated        field.Bool
+	CreatedAt        field.Time
+}
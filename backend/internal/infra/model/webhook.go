@@ -0,0 +1,95 @@
+package model
+
+import (
+	"time"
+
+	"github.com/xichan96/cortex-lab/pkg/sql"
+	"gorm.io/gen/field"
+)
+
+const (
+	TableWebhook           = "webhooks"
+	TableWebhookInvocation = "webhook_invocations"
+)
+
+const (
+	WebhookInvocationRunning   = "running"
+	WebhookInvocationSucceeded = "succeeded"
+	WebhookInvocationFailed    = "failed"
+)
+
+var WebhookFM = sql.NewGlobalFieldMetaMapping(Webhook{}, WebhookFieldMeta{})
+var WebhookInvocationFM = sql.NewGlobalFieldMetaMapping(WebhookInvocation{}, WebhookInvocationFieldMeta{})
+
+// Webhook hands the events an external system posts to it to a role. The
+// posts are signed with Secret, which is stored encrypted, every invocation
+// runs in a new chat session of the owner of the webhook.
+type Webhook struct {
+	ID        string `json:"id" gorm:"column:id;type:varchar(36);primaryKey;comment:Webhook ID"`
+	UserID    string `json:"user_id" gorm:"column:user_id;type:varchar(36);not null;index;comment:所属用户ID"`
+	Name      string `json:"name" gorm:"column:name;type:varchar(64);not null;comment:Webhook 名称"`
+	RoleID    string `json:"role_id" gorm:"column:role_id;type:varchar(36);not null;index;comment:角色ID"`
+	Provider  string `json:"provider" gorm:"column:provider;type:varchar(50);default:'';comment:模型提供方 (空则按角色解析)"`
+	ModelName string `json:"model_name" gorm:"column:model_name;type:varchar(100);default:'';comment:模型名称 (空则按角色解析)"`
+	Secret    string `json:"-" gorm:"column:secret;type:varchar(255);not null;comment:HMAC 签名密钥"`
+	// PromptTemplate is a text/template turning the payload into the prompt sent to the role
+	PromptTemplate  string     `json:"prompt_template" gorm:"column:prompt_template;type:text;comment:负载转提示词模板"`
+	Async           int        `json:"async" gorm:"column:async;type:tinyint(1);not null;default:0;comment:是否异步 (0:同步返回回复, 1:返回调用ID)"`
+	Enabled         int        `json:"enabled" gorm:"column:enabled;type:tinyint(1);not null;default:1;comment:是否启用 (0:停用, 1:启用)"`
+	LastTriggeredAt *time.Time `json:"last_triggered_at" gorm:"column:last_triggered_at;type:timestamp NULL;comment:上次触发时间"`
+	CreatedAt       time.Time  `json:"created_at" gorm:"column:created_at;type:timestamp;not null;default:CURRENT_TIMESTAMP;autoCreateTime;comment:创建时间"`
+	UpdatedAt       time.Time  `json:"updated_at" gorm:"column:updated_at;type:timestamp;not null;default:CURRENT_TIMESTAMP;autoUpdateTime;comment:更新时间"`
+}
+
+func (Webhook) TableName() string {
+	return TableWebhook
+}
+
+type WebhookFieldMeta struct {
+	sql.CTable
+	ALL             field.Asterisk
+	ID              field.String
+	UserID          field.String
+	Name            field.String
+	RoleID          field.String
+	Provider        field.String
+	ModelName       field.String
+	Secret          field.String
+	PromptTemplate  field.String
+	Async           field.Int
+	Enabled         field.Int
+	LastTriggeredAt field.Field
+	CreatedAt       field.Time
+	UpdatedAt       field.Time
+}
+
+// WebhookInvocation is an event posted to a webhook with its outcome
+type WebhookInvocation struct {
+	ID         string     `json:"id" gorm:"column:id;type:varchar(36);primaryKey;comment:调用ID"`
+	WebhookID  string     `json:"webhook_id" gorm:"column:webhook_id;type:varchar(36);not null;index;comment:Webhook ID"`
+	UserID     string     `json:"user_id" gorm:"column:user_id;type:varchar(36);not null;index;comment:所属用户ID"`
+	SessionID  string     `json:"session_id" gorm:"column:session_id;type:varchar(36);default:'';comment:调用所在会话ID"`
+	Status     string     `json:"status" gorm:"column:status;type:varchar(16);not null;comment:状态 (running, succeeded, failed)"`
+	Output     string     `json:"output" gorm:"column:output;type:text;comment:角色回复"`
+	Error      string     `json:"error" gorm:"column:error;type:text;comment:错误信息"`
+	CreatedAt  time.Time  `json:"created_at" gorm:"column:created_at;type:timestamp;not null;default:CURRENT_TIMESTAMP;autoCreateTime;index;comment:触发时间"`
+	FinishedAt *time.Time `json:"finished_at" gorm:"column:finished_at;type:timestamp NULL;comment:结束时间"`
+}
+
+func (WebhookInvocation) TableName() string {
+	return TableWebhookInvocation
+}
+
+type WebhookInvocationFieldMeta struct {
+	sql.CTable
+	ALL        field.Asterisk
+	ID         field.String
+	WebhookID  field.String
+	UserID     field.String
+	SessionID  field.String
+	Status     field.String
+	Output     field.String
+	Error      field.String
+	CreatedAt  field.Time
+	FinishedAt field.Field
+}
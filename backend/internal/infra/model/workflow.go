@@ -0,0 +1,169 @@
+package model
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"time"
+
+	"github.com/xichan96/cortex-lab/pkg/sql"
+	"gorm.io/gen/field"
+)
+
+const (
+	TableWorkflow    = "workflows"
+	TableWorkflowRun = "workflow_runs"
+)
+
+const (
+	WorkflowRunRunning   = "running"
+	WorkflowRunSucceeded = "succeeded"
+	WorkflowRunFailed    = "failed"
+)
+
+const (
+	WorkflowStepPending   = "pending"
+	WorkflowStepRunning   = "running"
+	WorkflowStepSucceeded = "succeeded"
+	WorkflowStepFailed    = "failed"
+	WorkflowStepSkipped   = "skipped"
+)
+
+var WorkflowFM = sql.NewGlobalFieldMetaMapping(Workflow{}, WorkflowFieldMeta{})
+var WorkflowRunFM = sql.NewGlobalFieldMetaMapping(WorkflowRun{}, WorkflowRunFieldMeta{})
+
+// Workflow is a fixed pipeline of roles, its steps form a DAG
+type Workflow struct {
+	ID          string        `json:"id" gorm:"column:id;type:varchar(36);primaryKey;comment:工作流ID"`
+	Name        string        `json:"name" gorm:"column:name;type:varchar(64);not null;comment:工作流名称"`
+	Description string        `json:"description" gorm:"column:description;type:varchar(255);default:'';comment:工作流描述"`
+	Steps       WorkflowSteps `json:"steps" gorm:"column:steps;type:text;comment:步骤定义 (JSON Array)"`
+	CreatorID   string        `json:"creator_id" gorm:"column:creator_id;type:varchar(36);not null;index;comment:创建者ID"`
+	IsPublic    int           `json:"is_public" gorm:"column:is_public;type:tinyint(1);not null;default:0;comment:是否公开 (0:私有, 1:公开)"`
+	CreatedAt   time.Time     `json:"created_at" gorm:"column:created_at;type:timestamp;not null;default:CURRENT_TIMESTAMP;autoCreateTime;comment:创建时间"`
+	UpdatedAt   time.Time     `json:"updated_at" gorm:"column:updated_at;type:timestamp;not null;default:CURRENT_TIMESTAMP;autoUpdateTime;comment:更新时间"`
+}
+
+func (Workflow) TableName() string {
+	return TableWorkflow
+}
+
+// WorkflowStep is a step of a workflow run by a role. Input is a template,
+// {{input}} is the input of the run and {{steps.<id>.output}} the output of
+// an earlier step.
+type WorkflowStep struct {
+	ID     string `json:"id"`
+	RoleID string `json:"role_id"`
+	Input  string `json:"input"`
+	// DependsOn lists the steps that must end before this one, the steps
+	// referenced by Input and Condition are added to it
+	DependsOn []string `json:"depends_on,omitempty"`
+	// Condition skips the step when it does not hold
+	Condition *WorkflowCondition `json:"condition,omitempty"`
+}
+
+// WorkflowCondition tests the output of an earlier step
+type WorkflowCondition struct {
+	Step     string `json:"step"`
+	Operator string `json:"operator"` // contains, not_contains, equals, not_equals, matches, empty, not_empty
+	Value    string `json:"value,omitempty"`
+}
+
+type WorkflowSteps []WorkflowStep
+
+func (s WorkflowSteps) Value() (driver.Value, error) {
+	if s == nil {
+		return "[]", nil
+	}
+	data, err := json.Marshal(s)
+	return string(data), err
+}
+
+func (s *WorkflowSteps) Scan(value interface{}) error {
+	return scanJSON(value, s)
+}
+
+type WorkflowFieldMeta struct {
+	sql.CTable
+	ALL         field.Asterisk
+	ID          field.String
+	Name        field.String
+	Description field.String
+	Steps       field.Field
+	CreatorID   field.String
+	IsPublic    field.Int
+	CreatedAt   field.Time
+	UpdatedAt   field.Time
+}
+
+// WorkflowRun is a run of a workflow with the record of each of its steps
+type WorkflowRun struct {
+	ID         string           `json:"id" gorm:"column:id;type:varchar(36);primaryKey;comment:运行ID"`
+	WorkflowID string           `json:"workflow_id" gorm:"column:workflow_id;type:varchar(36);not null;index;comment:工作流ID"`
+	UserID     string           `json:"user_id" gorm:"column:user_id;type:varchar(36);not null;index;comment:运行者ID"`
+	Status     string           `json:"status" gorm:"column:status;type:varchar(16);not null;comment:状态 (running, succeeded, failed)"`
+	Input      string           `json:"input" gorm:"column:input;type:text;comment:运行输入"`
+	Output     string           `json:"output" gorm:"column:output;type:text;comment:运行输出"`
+	Error      string           `json:"error" gorm:"column:error;type:text;comment:错误信息"`
+	Steps      WorkflowStepRuns `json:"steps" gorm:"column:steps;type:text;comment:步骤记录 (JSON Array)"`
+	CreatedAt  time.Time        `json:"created_at" gorm:"column:created_at;type:timestamp;not null;default:CURRENT_TIMESTAMP;autoCreateTime;index;comment:创建时间"`
+	FinishedAt *time.Time       `json:"finished_at" gorm:"column:finished_at;type:timestamp NULL;comment:结束时间"`
+	// HeartbeatAt is refreshed by the server executing the run, a running run whose heartbeat stopped was interrupted
+	HeartbeatAt *time.Time `json:"-" gorm:"column:heartbeat_at;type:timestamp NULL;comment:心跳时间"`
+}
+
+func (WorkflowRun) TableName() string {
+	return TableWorkflowRun
+}
+
+// WorkflowStepRun records the run of a step
+type WorkflowStepRun struct {
+	StepID     string     `json:"step_id"`
+	RoleID     string     `json:"role_id"`
+	Status     string     `json:"status"`
+	SessionID  string     `json:"session_id,omitempty"` // session the role ran the step in
+	Input      string     `json:"input,omitempty"`
+	Output     string     `json:"output,omitempty"`
+	Error      string     `json:"error,omitempty"`
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+type WorkflowStepRuns []*WorkflowStepRun
+
+func (s WorkflowStepRuns) Value() (driver.Value, error) {
+	if s == nil {
+		return "[]", nil
+	}
+	data, err := json.Marshal(s)
+	return string(data), err
+}
+
+func (s *WorkflowStepRuns) Scan(value interface{}) error {
+	return scanJSON(value, s)
+}
+
+type WorkflowRunFieldMeta struct {
+	sql.CTable
+	ALL         field.Asterisk
+	ID          field.String
+	WorkflowID  field.String
+	UserID      field.String
+	Status      field.String
+	Input       field.String
+	Output      field.String
+	Error       field.String
+	Steps       field.Field
+	CreatedAt   field.Time
+	FinishedAt  field.Field
+	HeartbeatAt field.Field
+}
+
+func scanJSON(value interface{}, dest interface{}) error {
+	switch v := value.(type) {
+	case []byte:
+		return json.Unmarshal(v, dest)
+	case string:
+		return json.Unmarshal([]byte(v), dest)
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package persist
+
+import (
+	"context"
+
+	"github.com/xichan96/cortex-lab/internal/infra/model"
+	"github.com/xichan96/cortex-lab/pkg/sql"
+	"github.com/xichan96/cortex-lab/pkg/std/snowflake"
+	"gorm.io/gorm"
+)
+
+type ChatAttachmentPersistIer interface {
+	sql.Corm
+	Field() *model.ChatAttachmentFieldMeta
+	F() *model.ChatAttachmentFieldMeta
+	Create(ctx context.Context, attachment *model.ChatAttachment) (string, error)
+	GetByID(ctx context.Context, id string) (*model.ChatAttachment, error)
+	GetList(ctx context.Context, options ...func(*gorm.DB) *gorm.DB) ([]*model.ChatAttachment, error)
+	Delete(ctx context.Context, attachment *model.ChatAttachment) error
+}
+
+func NewChatAttachmentPersist() ChatAttachmentPersistIer {
+	return &ChatAttachmentPersist{
+		ChatAttachmentFieldMeta: model.ChatAttachmentFM,
+	}
+}
+
+type ChatAttachmentPersist struct {
+	*model.ChatAttachmentFieldMeta
+	sql.BaseOpr
+}
+
+func (p *ChatAttachmentPersist) Field() *model.ChatAttachmentFieldMeta {
+	return p.ChatAttachmentFieldMeta
+}
+func (p *ChatAttachmentPersist) F() *model.ChatAttachmentFieldMeta { return p.ChatAttachmentFieldMeta }
+
+func (p *ChatAttachmentPersist) Create(ctx context.Context, attachment *model.ChatAttachment) (string, error) {
+	if len(attachment.ID) == 0 {
+		attachment.ID = snowflake.NewUUID()
+	}
+	if err := p.DB(ctx).Table(p.Table()).Create(attachment).Error; err != nil {
+		return "", err
+	}
+	return attachment.ID, nil
+}
+
+func (p *ChatAttachmentPersist) GetByID(ctx context.Context, id string) (*model.ChatAttachment, error) {
+	var attachment model.ChatAttachment
+	if err := p.DB(ctx).Table(p.Table()).Where("id = ?", id).Take(&attachment).Error; err != nil {
+		return nil, err
+	}
+	return &attachment, nil
+}
+
+func (p *ChatAttachmentPersist) GetList(ctx context.Context, options ...func(*gorm.DB) *gorm.DB) ([]*model.ChatAttachment, error) {
+	var attachments []*model.ChatAttachment
+	if err := p.DB(ctx).Table(p.Table()).Scopes(options...).Find(&attachments).Error; err != nil {
+		return nil, err
+	}
+	return attachments, nil
+}
+
+func (p *ChatAttachmentPersist) Delete(ctx context.Context, attachment *model.ChatAttachment) error {
+	return p.DB(ctx).Table(p.Table()).Delete(attachment).Error
+}
@@ -0,0 +1,78 @@
+package persist
+
+import (
+	"context"
+
+	"github.com/xichan96/cortex-lab/internal/infra/model"
+	"github.com/xichan96/cortex-lab/pkg/sql"
+	"github.com/xichan96/cortex-lab/pkg/std/snowflake"
+	"gorm.io/gorm"
+)
+
+type ChatFolderPersistIer interface {
+	sql.Corm
+	Field() *model.ChatFolderFieldMeta
+	F() *model.ChatFolderFieldMeta
+	Create(ctx context.Context, folder *model.ChatFolder) (string, error)
+	Update(ctx context.Context, folder *model.ChatFolder) error
+	GetByID(ctx context.Context, id string) (*model.ChatFolder, error)
+	GetList(ctx context.Context, options ...func(*gorm.DB) *gorm.DB) ([]*model.ChatFolder, error)
+	Count(ctx context.Context, options ...func(*gorm.DB) *gorm.DB) (int64, error)
+	Delete(ctx context.Context, folder *model.ChatFolder) error
+}
+
+func NewChatFolderPersist() ChatFolderPersistIer {
+	return &ChatFolderPersist{
+		ChatFolderFieldMeta: model.ChatFolderFM,
+	}
+}
+
+type ChatFolderPersist struct {
+	*model.ChatFolderFieldMeta
+	sql.BaseOpr
+}
+
+func (p *ChatFolderPersist) Field() *model.ChatFolderFieldMeta { return p.ChatFolderFieldMeta }
+func (p *ChatFolderPersist) F() *model.ChatFolderFieldMeta     { return p.ChatFolderFieldMeta }
+
+func (p *ChatFolderPersist) Create(ctx context.Context, folder *model.ChatFolder) (string, error) {
+	if len(folder.ID) == 0 {
+		folder.ID = snowflake.NewUUID()
+	}
+	if err := p.DB(ctx).Table(p.Table()).Create(folder).Error; err != nil {
+		return "", err
+	}
+	return folder.ID, nil
+}
+
+func (p *ChatFolderPersist) Update(ctx context.Context, folder *model.ChatFolder) error {
+	return p.DB(ctx).Table(p.Table()).Updates(folder).Error
+}
+
+func (p *ChatFolderPersist) GetByID(ctx context.Context, id string) (*model.ChatFolder, error) {
+	var folder model.ChatFolder
+	if err := p.DB(ctx).Table(p.Table()).Where("id = ?", id).Take(&folder).Error; err != nil {
+		return nil, err
+	}
+	return &folder, nil
+}
+
+func (p *ChatFolderPersist) GetList(ctx context.Context, options ...func(*gorm.DB) *gorm.DB) ([]*model.ChatFolder, error) {
+	var folders []*model.ChatFolder
+	if err := p.DB(ctx).Table(p.Table()).Scopes(options...).Find(&folders).Error; err != nil {
+		return nil, err
+	}
+	return folders, nil
+}
+
+func (p *ChatFolderPersist) Count(ctx context.Context, options ...func(*gorm.DB) *gorm.DB) (int64, error) {
+	var count int64
+	if err := p.DB(ctx).Table(p.Table()).Scopes(options...).Count(&count).Error; err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+func (p *ChatFolderPersist) Delete(ctx context.Context, folder *model.ChatFolder) error {
+	return p.DB(ctx).Table(p.Table()).Delete(folder).Error
+}
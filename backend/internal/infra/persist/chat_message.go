@@ -15,9 +15,11 @@ type ChatMessagePersistIer interface {
 	F() *model.ChatMessageFieldMeta
 	Create(ctx context.Context, message *model.ChatMessage) (string, error)
 	CreateBatch(ctx context.Context, messages []*model.ChatMessage) error
+	Update(ctx context.Context, message *model.ChatMessage, options ...func(*gorm.DB) *gorm.DB) error
 	GetByID(ctx context.Context, id string) (*model.ChatMessage, error)
 	GetList(ctx context.Context, options ...func(*gorm.DB) *gorm.DB) ([]*model.ChatMessage, error)
 	Count(ctx context.Context, option ...func(db *gorm.DB) *gorm.DB) (int64, error)
+	Gets(ctx context.Context, data any, options ...func(*gorm.DB) *gorm.DB) error
 	DeleteBySessionID(ctx context.Context, sessionID string) error
 }
 
@@ -57,6 +59,10 @@ func (p *ChatMessagePersist) CreateBatch(ctx context.Context, messages []*model.
 	return nil
 }
 
+func (p *ChatMessagePersist) Update(ctx context.Context, message *model.ChatMessage, options ...func(*gorm.DB) *gorm.DB) error {
+	return p.DB(ctx).Table(p.Table()).Scopes(options...).Updates(message).Error
+}
+
 func (p *ChatMessagePersist) GetByID(ctx context.Context, id string) (*model.ChatMessage, error) {
 	var message model.ChatMessage
 	if err := p.DB(ctx).Table(p.Table()).Where("id = ?", id).Take(&message).Error; err != nil {
@@ -81,6 +87,11 @@ func (p *ChatMessagePersist) Count(ctx context.Context, option ...func(db *gorm.
 	return count, nil
 }
 
+// Gets scans the query result into data, used for joined and aggregated queries
+func (p *ChatMessagePersist) Gets(ctx context.Context, data any, options ...func(*gorm.DB) *gorm.DB) error {
+	return p.DB(ctx).Table(p.Table()).Scopes(options...).Find(data).Error
+}
+
 func (p *ChatMessagePersist) DeleteBySessionID(ctx context.Context, sessionID string) error {
 	return p.DB(ctx).Table(p.Table()).Where("session_id = ?", sessionID).Delete(&model.ChatMessage{}).Error
 }
@@ -2,6 +2,7 @@ package persist
 
 import (
 	"context"
+	"time"
 
 	"github.com/xichan96/cortex-lab/internal/infra/model"
 	"github.com/xichan96/cortex-lab/pkg/sql"
@@ -15,9 +16,14 @@ type ChatSessionPersistIer interface {
 	F() *model.ChatSessionFieldMeta
 	Create(ctx context.Context, session *model.ChatSession) (string, error)
 	Update(ctx context.Context, session *model.ChatSession, options ...func(*gorm.DB) *gorm.DB) error
+	UpdateTitle(ctx context.Context, id, title, source string, options ...func(*gorm.DB) *gorm.DB) (int64, error)
 	GetByID(ctx context.Context, id string) (*model.ChatSession, error)
+	GetByShareToken(ctx context.Context, token string) (*model.ChatSession, error)
+	UpdateShare(ctx context.Context, id string, token *string, expiresAt *time.Time) error
+	UpdateFields(ctx context.Context, fields map[string]interface{}, options ...func(*gorm.DB) *gorm.DB) error
 	GetList(ctx context.Context, options ...func(*gorm.DB) *gorm.DB) ([]*model.ChatSession, error)
 	Count(ctx context.Context, option ...func(db *gorm.DB) *gorm.DB) (int64, error)
+	Gets(ctx context.Context, data any, options ...func(*gorm.DB) *gorm.DB) error
 	Delete(ctx context.Context, session *model.ChatSession) error
 }
 
@@ -49,6 +55,14 @@ func (p *ChatSessionPersist) Update(ctx context.Context, session *model.ChatSess
 	return p.DB(ctx).Table(p.Table()).Scopes(options...).Updates(session).Error
 }
 
+// UpdateTitle sets the title of a session without touching its other columns,
+// options can restrict the update, it returns the number of updated rows
+func (p *ChatSessionPersist) UpdateTitle(ctx context.Context, id, title, source string, options ...func(*gorm.DB) *gorm.DB) (int64, error) {
+	result := p.DB(ctx).Table(p.Table()).Scopes(options...).Where("id = ?", id).
+		Updates(map[string]interface{}{"title": title, "title_source": source})
+	return result.RowsAffected, result.Error
+}
+
 func (p *ChatSessionPersist) GetByID(ctx context.Context, id string) (*model.ChatSession, error) {
 	var session model.ChatSession
 	if err := p.DB(ctx).Table(p.Table()).Where("id = ?", id).Take(&session).Error; err != nil {
@@ -57,6 +71,25 @@ func (p *ChatSessionPersist) GetByID(ctx context.Context, id string) (*model.Cha
 	return &session, nil
 }
 
+func (p *ChatSessionPersist) GetByShareToken(ctx context.Context, token string) (*model.ChatSession, error) {
+	var session model.ChatSession
+	if err := p.DB(ctx).Table(p.Table()).Where("share_token = ?", token).Take(&session).Error; err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// UpdateShare sets the share token of a session, a nil token revokes the share
+func (p *ChatSessionPersist) UpdateShare(ctx context.Context, id string, token *string, expiresAt *time.Time) error {
+	return p.DB(ctx).Table(p.Table()).Where("id = ?", id).
+		Updates(map[string]interface{}{"share_token": token, "share_expires_at": expiresAt}).Error
+}
+
+// UpdateFields updates the given columns, zero values included, of the sessions selected by options
+func (p *ChatSessionPersist) UpdateFields(ctx context.Context, fields map[string]interface{}, options ...func(*gorm.DB) *gorm.DB) error {
+	return p.DB(ctx).Table(p.Table()).Scopes(options...).Updates(fields).Error
+}
+
 func (p *ChatSessionPersist) GetList(ctx context.Context, options ...func(*gorm.DB) *gorm.DB) ([]*model.ChatSession, error) {
 	var sessions []*model.ChatSession
 	if err := p.DB(ctx).Table(p.Table()).Scopes(options...).Find(&sessions).Error; err != nil {
@@ -73,7 +106,57 @@ func (p *ChatSessionPersist) Count(ctx context.Context, option ...func(db *gorm.
 	return count, nil
 }
 
+// Gets scans the query result into data, used for aggregated queries
+func (p *ChatSessionPersist) Gets(ctx context.Context, data any, options ...func(*gorm.DB) *gorm.DB) error {
+	return p.DB(ctx).Table(p.Table()).Scopes(options...).Find(data).Error
+}
+
 func (p *ChatSessionPersist) Delete(ctx context.Context, session *model.ChatSession) error {
 	return p.DB(ctx).Table(p.Table()).Delete(session).Error
 }
 
+
+type ChatSessionTagPersistIer interface {
+	sql.Corm
+	Field() *model.ChatSessionTagFieldMeta
+	CreateBatch(ctx context.Context, list []*model.ChatSessionTag) error
+	GetList(ctx context.Context, options ...func(*gorm.DB) *gorm.DB) ([]*model.ChatSessionTag, error)
+	Gets(ctx context.Context, data any, options ...func(*gorm.DB) *gorm.DB) error
+	DeleteBySessionID(ctx context.Context, sessionID string) error
+}
+
+func NewChatSessionTagPersist() ChatSessionTagPersistIer {
+	return &ChatSessionTagPersist{
+		ChatSessionTagFieldMeta: model.ChatSessionTagFM,
+	}
+}
+
+type ChatSessionTagPersist struct {
+	*model.ChatSessionTagFieldMeta
+	sql.BaseOpr
+}
+
+func (p *ChatSessionTagPersist) Field() *model.ChatSessionTagFieldMeta {
+	return p.ChatSessionTagFieldMeta
+}
+
+func (p *ChatSessionTagPersist) CreateBatch(ctx context.Context, list []*model.ChatSessionTag) error {
+	return p.DB(ctx).Table(p.Table()).Create(&list).Error
+}
+
+func (p *ChatSessionTagPersist) GetList(ctx context.Context, options ...func(*gorm.DB) *gorm.DB) ([]*model.ChatSessionTag, error) {
+	var list []*model.ChatSessionTag
+	if err := p.DB(ctx).Table(p.Table()).Scopes(options...).Find(&list).Error; err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// Gets scans the query result into data, used for aggregated queries
+func (p *ChatSessionTagPersist) Gets(ctx context.Context, data any, options ...func(*gorm.DB) *gorm.DB) error {
+	return p.DB(ctx).Table(p.Table()).Scopes(options...).Find(data).Error
+}
+
+func (p *ChatSessionTagPersist) DeleteBySessionID(ctx context.Context, sessionID string) error {
+	return p.DB(ctx).Table(p.Table()).Where("session_id = ?", sessionID).Delete(&model.ChatSessionTag{}).Error
+}
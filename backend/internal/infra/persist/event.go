@@ -0,0 +1,181 @@
+package persist
+
+import (
+	"context"
+	"time"
+
+	"github.com/xichan96/cortex-lab/internal/infra/model"
+	"github.com/xichan96/cortex-lab/pkg/sql"
+	"github.com/xichan96/cortex-lab/pkg/std/snowflake"
+	"gorm.io/gorm"
+)
+
+type EventSubscriptionPersistIer interface {
+	sql.Corm
+	Field() *model.EventSubscriptionFieldMeta
+	F() *model.EventSubscriptionFieldMeta
+	Create(ctx context.Context, subscription *model.EventSubscription) (string, error)
+	Update(ctx context.Context, subscription *model.EventSubscription, options ...func(*gorm.DB) *gorm.DB) error
+	UpdateFields(ctx context.Context, fields map[string]interface{}, options ...func(*gorm.DB) *gorm.DB) error
+	GetByID(ctx context.Context, id string) (*model.EventSubscription, error)
+	GetList(ctx context.Context, options ...func(*gorm.DB) *gorm.DB) ([]*model.EventSubscription, error)
+	Count(ctx context.Context, option ...func(db *gorm.DB) *gorm.DB) (int64, error)
+	Delete(ctx context.Context, subscription *model.EventSubscription) error
+}
+
+func NewEventSubscriptionPersist() EventSubscriptionPersistIer {
+	return &EventSubscriptionPersist{
+		EventSubscriptionFieldMeta: model.EventSubscriptionFM,
+	}
+}
+
+type EventSubscriptionPersist struct {
+	*model.EventSubscriptionFieldMeta
+	sql.BaseOpr
+}
+
+func (p *EventSubscriptionPersist) Field() *model.EventSubscriptionFieldMeta {
+	return p.EventSubscriptionFieldMeta
+}
+func (p *EventSubscriptionPersist) F() *model.EventSubscriptionFieldMeta {
+	return p.EventSubscriptionFieldMeta
+}
+
+func (p *EventSubscriptionPersist) Create(ctx context.Context, subscription *model.EventSubscription) (string, error) {
+	if len(subscription.ID) == 0 {
+		subscription.ID = snowflake.NewUUID()
+	}
+	if err := p.DB(ctx).Table(p.Table()).Create(&subscription).Error; err != nil {
+		return "", err
+	}
+	return subscription.ID, nil
+}
+
+func (p *EventSubscriptionPersist) Update(ctx context.Context, subscription *model.EventSubscription, options ...func(*gorm.DB) *gorm.DB) error {
+	return p.DB(ctx).Table(p.Table()).Scopes(options...).Updates(subscription).Error
+}
+
+// UpdateFields updates the given columns, zero values included, of the subscriptions selected by options
+func (p *EventSubscriptionPersist) UpdateFields(ctx context.Context, fields map[string]interface{}, options ...func(*gorm.DB) *gorm.DB) error {
+	return p.DB(ctx).Table(p.Table()).Scopes(options...).Updates(fields).Error
+}
+
+func (p *EventSubscriptionPersist) GetByID(ctx context.Context, id string) (*model.EventSubscription, error) {
+	var subscription model.EventSubscription
+	if err := p.DB(ctx).Table(p.Table()).Where("id = ?", id).Take(&subscription).Error; err != nil {
+		return nil, err
+	}
+	return &subscription, nil
+}
+
+func (p *EventSubscriptionPersist) GetList(ctx context.Context, options ...func(*gorm.DB) *gorm.DB) ([]*model.EventSubscription, error) {
+	var subscriptions []*model.EventSubscription
+	if err := p.DB(ctx).Table(p.Table()).Scopes(options...).Find(&subscriptions).Error; err != nil {
+		return nil, err
+	}
+	return subscriptions, nil
+}
+
+func (p *EventSubscriptionPersist) Count(ctx context.Context, option ...func(db *gorm.DB) *gorm.DB) (int64, error) {
+	var count int64
+	if err := p.DB(ctx).Table(p.Table()).Scopes(option...).Count(&count).Error; err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+func (p *EventSubscriptionPersist) Delete(ctx context.Context, subscription *model.EventSubscription) error {
+	return p.DB(ctx).Table(p.Table()).Delete(subscription).Error
+}
+
+type EventDeliveryPersistIer interface {
+	sql.Corm
+	Field() *model.EventDeliveryFieldMeta
+	F() *model.EventDeliveryFieldMeta
+	Create(ctx context.Context, delivery *model.EventDelivery) (string, error)
+	Update(ctx context.Context, delivery *model.EventDelivery, options ...func(*gorm.DB) *gorm.DB) error
+	UpdateFields(ctx context.Context, fields map[string]interface{}, options ...func(*gorm.DB) *gorm.DB) error
+	Claim(ctx context.Context, id string, at, lease time.Time) (bool, error)
+	GetByID(ctx context.Context, id string) (*model.EventDelivery, error)
+	GetList(ctx context.Context, options ...func(*gorm.DB) *gorm.DB) ([]*model.EventDelivery, error)
+	Count(ctx context.Context, option ...func(db *gorm.DB) *gorm.DB) (int64, error)
+	DeleteBySubscriptionID(ctx context.Context, subscriptionID string) error
+}
+
+func NewEventDeliveryPersist() EventDeliveryPersistIer {
+	return &EventDeliveryPersist{
+		EventDeliveryFieldMeta: model.EventDeliveryFM,
+	}
+}
+
+type EventDeliveryPersist struct {
+	*model.EventDeliveryFieldMeta
+	sql.BaseOpr
+}
+
+func (p *EventDeliveryPersist) Field() *model.EventDeliveryFieldMeta {
+	return p.EventDeliveryFieldMeta
+}
+func (p *EventDeliveryPersist) F() *model.EventDeliveryFieldMeta {
+	return p.EventDeliveryFieldMeta
+}
+
+func (p *EventDeliveryPersist) Create(ctx context.Context, delivery *model.EventDelivery) (string, error) {
+	if len(delivery.ID) == 0 {
+		delivery.ID = snowflake.NewUUID()
+	}
+	if err := p.DB(ctx).Table(p.Table()).Create(&delivery).Error; err != nil {
+		return "", err
+	}
+	return delivery.ID, nil
+}
+
+func (p *EventDeliveryPersist) Update(ctx context.Context, delivery *model.EventDelivery, options ...func(*gorm.DB) *gorm.DB) error {
+	return p.DB(ctx).Table(p.Table()).Scopes(options...).Updates(delivery).Error
+}
+
+// UpdateFields updates the given columns, zero values included, of the deliveries selected by options
+func (p *EventDeliveryPersist) UpdateFields(ctx context.Context, fields map[string]interface{}, options ...func(*gorm.DB) *gorm.DB) error {
+	return p.DB(ctx).Table(p.Table()).Scopes(options...).Updates(fields).Error
+}
+
+// Claim moves the next attempt of a pending delivery due at at to lease, so
+// that it is attempted again if the attempt never records its outcome. It
+// reports false when another dispatcher claimed the attempt first.
+func (p *EventDeliveryPersist) Claim(ctx context.Context, id string, at, lease time.Time) (bool, error) {
+	result := p.DB(ctx).Table(p.Table()).
+		Where("id = ? AND status = ? AND next_attempt_at = ?", id, model.EventDeliveryPending, at).
+		Update("next_attempt_at", lease)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func (p *EventDeliveryPersist) GetByID(ctx context.Context, id string) (*model.EventDelivery, error) {
+	var delivery model.EventDelivery
+	if err := p.DB(ctx).Table(p.Table()).Where("id = ?", id).Take(&delivery).Error; err != nil {
+		return nil, err
+	}
+	return &delivery, nil
+}
+
+func (p *EventDeliveryPersist) GetList(ctx context.Context, options ...func(*gorm.DB) *gorm.DB) ([]*model.EventDelivery, error) {
+	var deliveries []*model.EventDelivery
+	if err := p.DB(ctx).Table(p.Table()).Scopes(options...).Find(&deliveries).Error; err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+func (p *EventDeliveryPersist) Count(ctx context.Context, option ...func(db *gorm.DB) *gorm.DB) (int64, error) {
+	var count int64
+	if err := p.DB(ctx).Table(p.Table()).Scopes(option...).Count(&count).Error; err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+func (p *EventDeliveryPersist) DeleteBySubscriptionID(ctx context.Context, subscriptionID string) error {
+	return p.DB(ctx).Table(p.Table()).Where("subscription_id = ?", subscriptionID).Delete(&model.EventDelivery{}).Error
+}
@@ -19,6 +19,8 @@ type ExperiencePersistIer interface {
 	GetList(ctx context.Context, options ...func(*gorm.DB) *gorm.DB) ([]*model.Experience, error)
 	Count(ctx context.Context, option ...func(db *gorm.DB) *gorm.DB) (int64, error)
 	Delete(ctx context.Context, k *model.Experience) error
+	CreateBatch(ctx context.Context, list []*model.Experience) error
+	DeleteBySourceID(ctx context.Context, sourceID string) error
 }
 
 func NewExperiencePersist() ExperiencePersistIer {
@@ -77,12 +79,27 @@ func (k *ExperiencePersist) Delete(ctx context.Context, experience *model.Experi
 	return k.DB(ctx).Table(k.Table()).Delete(experience).Error
 }
 
+func (k *ExperiencePersist) CreateBatch(ctx context.Context, list []*model.Experience) error {
+	for _, experience := range list {
+		if len(experience.ID) == 0 {
+			experience.ID = snowflake.NewUUID()
+		}
+	}
+	return k.DB(ctx).Table(k.Table()).Create(&list).Error
+}
+
+func (k *ExperiencePersist) DeleteBySourceID(ctx context.Context, sourceID string) error {
+	return k.DB(ctx).Table(k.Table()).Where("source_id = ?", sourceID).Delete(&model.Experience{}).Error
+}
+
 type RoleExperienceRelationPersistIer interface {
 	sql.Corm
 	Field() *model.RoleExperienceRelationFieldMeta
 	Create(ctx context.Context, rel *model.RoleExperienceRelation) error
 	Delete(ctx context.Context, rel *model.RoleExperienceRelation) error
 	GetList(ctx context.Context, options ...func(*gorm.DB) *gorm.DB) ([]*model.RoleExperienceRelation, error)
+	CreateBatch(ctx context.Context, list []*model.RoleExperienceRelation) error
+	DeleteBySourceID(ctx context.Context, sourceID string) error
 }
 
 func NewRoleExperienceRelationPersist() RoleExperienceRelationPersistIer {
@@ -115,3 +132,86 @@ func (r *RoleExperienceRelationPersist) GetList(ctx context.Context, options ...
 	}
 	return list, nil
 }
+
+func (r *RoleExperienceRelationPersist) CreateBatch(ctx context.Context, list []*model.RoleExperienceRelation) error {
+	return r.DB(ctx).Table(r.Table()).Create(&list).Error
+}
+
+// DeleteBySourceID removes the role relations of the experiences created from a source
+func (r *RoleExperienceRelationPersist) DeleteBySourceID(ctx context.Context, sourceID string) error {
+	db := r.DB(ctx)
+	subQuery := db.Session(&gorm.Session{NewDB: true}).Table(model.TableExperience).Select("id").Where("source_id = ?", sourceID)
+	return db.Table(r.Table()).Where("experience_id IN (?)", subQuery).Delete(&model.RoleExperienceRelation{}).Error
+}
+
+type ExperienceDocumentPersistIer interface {
+	sql.Corm
+	Field() *model.ExperienceDocumentFieldMeta
+	F() *model.ExperienceDocumentFieldMeta
+	Create(ctx context.Context, doc *model.ExperienceDocument) (string, error)
+	Update(ctx context.Context, doc *model.ExperienceDocument, options ...func(*gorm.DB) *gorm.DB) error
+	GetByID(ctx context.Context, id string) (*model.ExperienceDocument, error)
+	GetList(ctx context.Context, options ...func(*gorm.DB) *gorm.DB) ([]*model.ExperienceDocument, error)
+	Count(ctx context.Context, option ...func(db *gorm.DB) *gorm.DB) (int64, error)
+	Delete(ctx context.Context, doc *model.ExperienceDocument) error
+}
+
+func NewExperienceDocumentPersist() ExperienceDocumentPersistIer {
+	return &ExperienceDocumentPersist{
+		ExperienceDocumentFieldMeta: model.ExperienceDocumentFM,
+	}
+}
+
+type ExperienceDocumentPersist struct {
+	*model.ExperienceDocumentFieldMeta
+	sql.BaseOpr
+}
+
+func (d *ExperienceDocumentPersist) Field() *model.ExperienceDocumentFieldMeta {
+	return d.ExperienceDocumentFieldMeta
+}
+func (d *ExperienceDocumentPersist) F() *model.ExperienceDocumentFieldMeta {
+	return d.ExperienceDocumentFieldMeta
+}
+
+func (d *ExperienceDocumentPersist) Create(ctx context.Context, doc *model.ExperienceDocument) (string, error) {
+	if len(doc.ID) == 0 {
+		doc.ID = snowflake.NewUUID()
+	}
+	if err := d.DB(ctx).Table(d.Table()).Create(doc).Error; err != nil {
+		return "", err
+	}
+	return doc.ID, nil
+}
+
+func (d *ExperienceDocumentPersist) Update(ctx context.Context, doc *model.ExperienceDocument, options ...func(*gorm.DB) *gorm.DB) error {
+	return d.DB(ctx).Table(d.Table()).Scopes(options...).Updates(doc).Error
+}
+
+func (d *ExperienceDocumentPersist) GetByID(ctx context.Context, id string) (*model.ExperienceDocument, error) {
+	var doc model.ExperienceDocument
+	if err := d.DB(ctx).Table(d.Table()).Where("id = ?", id).Take(&doc).Error; err != nil {
+		return nil, err
+	}
+	return &doc, nil
+}
+
+func (d *ExperienceDocumentPersist) GetList(ctx context.Context, options ...func(*gorm.DB) *gorm.DB) ([]*model.ExperienceDocument, error) {
+	var list []*model.ExperienceDocument
+	if err := d.DB(ctx).Table(d.Table()).Scopes(options...).Find(&list).Error; err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (d *ExperienceDocumentPersist) Count(ctx context.Context, option ...func(db *gorm.DB) *gorm.DB) (int64, error) {
+	var count int64
+	if err := d.DB(ctx).Table(d.Table()).Scopes(option...).Count(&count).Error; err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+func (d *ExperienceDocumentPersist) Delete(ctx context.Context, doc *model.ExperienceDocument) error {
+	return d.DB(ctx).Table(d.Table()).Delete(doc).Error
+}
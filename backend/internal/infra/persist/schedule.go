@@ -0,0 +1,166 @@
+package persist
+
+import (
+	"context"
+	"time"
+
+	"github.com/xichan96/cortex-lab/internal/infra/model"
+	"github.com/xichan96/cortex-lab/pkg/sql"
+	"github.com/xichan96/cortex-lab/pkg/std/snowflake"
+	"gorm.io/gorm"
+)
+
+type SchedulePersistIer interface {
+	sql.Corm
+	Field() *model.ScheduleFieldMeta
+	F() *model.ScheduleFieldMeta
+	Create(ctx context.Context, schedule *model.Schedule) (string, error)
+	Update(ctx context.Context, schedule *model.Schedule, options ...func(*gorm.DB) *gorm.DB) error
+	UpdateFields(ctx context.Context, fields map[string]interface{}, options ...func(*gorm.DB) *gorm.DB) error
+	Claim(ctx context.Context, id string, at time.Time, next *time.Time) (bool, error)
+	GetByID(ctx context.Context, id string) (*model.Schedule, error)
+	GetList(ctx context.Context, options ...func(*gorm.DB) *gorm.DB) ([]*model.Schedule, error)
+	Count(ctx context.Context, option ...func(db *gorm.DB) *gorm.DB) (int64, error)
+	Delete(ctx context.Context, schedule *model.Schedule) error
+}
+
+func NewSchedulePersist() SchedulePersistIer {
+	return &SchedulePersist{
+		ScheduleFieldMeta: model.ScheduleFM,
+	}
+}
+
+type SchedulePersist struct {
+	*model.ScheduleFieldMeta
+	sql.BaseOpr
+}
+
+func (p *SchedulePersist) Field() *model.ScheduleFieldMeta { return p.ScheduleFieldMeta }
+func (p *SchedulePersist) F() *model.ScheduleFieldMeta     { return p.ScheduleFieldMeta }
+
+func (p *SchedulePersist) Create(ctx context.Context, schedule *model.Schedule) (string, error) {
+	if len(schedule.ID) == 0 {
+		schedule.ID = snowflake.NewUUID()
+	}
+	if err := p.DB(ctx).Table(p.Table()).Create(&schedule).Error; err != nil {
+		return "", err
+	}
+	return schedule.ID, nil
+}
+
+func (p *SchedulePersist) Update(ctx context.Context, schedule *model.Schedule, options ...func(*gorm.DB) *gorm.DB) error {
+	return p.DB(ctx).Table(p.Table()).Scopes(options...).Updates(schedule).Error
+}
+
+// UpdateFields updates the given columns, zero values included, of the schedules selected by options
+func (p *SchedulePersist) UpdateFields(ctx context.Context, fields map[string]interface{}, options ...func(*gorm.DB) *gorm.DB) error {
+	return p.DB(ctx).Table(p.Table()).Scopes(options...).Updates(fields).Error
+}
+
+// Claim moves the next run of a schedule due at at to next. It reports false
+// when another scheduler claimed the run first.
+func (p *SchedulePersist) Claim(ctx context.Context, id string, at time.Time, next *time.Time) (bool, error) {
+	result := p.DB(ctx).Table(p.Table()).
+		Where("id = ? AND enabled = ? AND next_run_at = ?", id, 1, at).
+		Updates(map[string]interface{}{"next_run_at": next, "last_run_at": at})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func (p *SchedulePersist) GetByID(ctx context.Context, id string) (*model.Schedule, error) {
+	var schedule model.Schedule
+	if err := p.DB(ctx).Table(p.Table()).Where("id = ?", id).Take(&schedule).Error; err != nil {
+		return nil, err
+	}
+	return &schedule, nil
+}
+
+func (p *SchedulePersist) GetList(ctx context.Context, options ...func(*gorm.DB) *gorm.DB) ([]*model.Schedule, error) {
+	var schedules []*model.Schedule
+	if err := p.DB(ctx).Table(p.Table()).Scopes(options...).Find(&schedules).Error; err != nil {
+		return nil, err
+	}
+	return schedules, nil
+}
+
+func (p *SchedulePersist) Count(ctx context.Context, option ...func(db *gorm.DB) *gorm.DB) (int64, error) {
+	var count int64
+	if err := p.DB(ctx).Table(p.Table()).Scopes(option...).Count(&count).Error; err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+func (p *SchedulePersist) Delete(ctx context.Context, schedule *model.Schedule) error {
+	return p.DB(ctx).Table(p.Table()).Delete(schedule).Error
+}
+
+type ScheduleRunPersistIer interface {
+	sql.Corm
+	Field() *model.ScheduleRunFieldMeta
+	F() *model.ScheduleRunFieldMeta
+	Create(ctx context.Context, run *model.ScheduleRun) (string, error)
+	Update(ctx context.Context, run *model.ScheduleRun, options ...func(*gorm.DB) *gorm.DB) error
+	GetByID(ctx context.Context, id string) (*model.ScheduleRun, error)
+	GetList(ctx context.Context, options ...func(*gorm.DB) *gorm.DB) ([]*model.ScheduleRun, error)
+	Count(ctx context.Context, option ...func(db *gorm.DB) *gorm.DB) (int64, error)
+	DeleteByScheduleID(ctx context.Context, scheduleID string) error
+}
+
+func NewScheduleRunPersist() ScheduleRunPersistIer {
+	return &ScheduleRunPersist{
+		ScheduleRunFieldMeta: model.ScheduleRunFM,
+	}
+}
+
+type ScheduleRunPersist struct {
+	*model.ScheduleRunFieldMeta
+	sql.BaseOpr
+}
+
+func (p *ScheduleRunPersist) Field() *model.ScheduleRunFieldMeta { return p.ScheduleRunFieldMeta }
+func (p *ScheduleRunPersist) F() *model.ScheduleRunFieldMeta     { return p.ScheduleRunFieldMeta }
+
+func (p *ScheduleRunPersist) Create(ctx context.Context, run *model.ScheduleRun) (string, error) {
+	if len(run.ID) == 0 {
+		run.ID = snowflake.NewUUID()
+	}
+	if err := p.DB(ctx).Table(p.Table()).Create(&run).Error; err != nil {
+		return "", err
+	}
+	return run.ID, nil
+}
+
+func (p *ScheduleRunPersist) Update(ctx context.Context, run *model.ScheduleRun, options ...func(*gorm.DB) *gorm.DB) error {
+	return p.DB(ctx).Table(p.Table()).Scopes(options...).Updates(run).Error
+}
+
+func (p *ScheduleRunPersist) GetByID(ctx context.Context, id string) (*model.ScheduleRun, error) {
+	var run model.ScheduleRun
+	if err := p.DB(ctx).Table(p.Table()).Where("id = ?", id).Take(&run).Error; err != nil {
+		return nil, err
+	}
+	return &run, nil
+}
+
+func (p *ScheduleRunPersist) GetList(ctx context.Context, options ...func(*gorm.DB) *gorm.DB) ([]*model.ScheduleRun, error) {
+	var runs []*model.ScheduleRun
+	if err := p.DB(ctx).Table(p.Table()).Scopes(options...).Find(&runs).Error; err != nil {
+		return nil, err
+	}
+	return runs, nil
+}
+
+func (p *ScheduleRunPersist) Count(ctx context.Context, option ...func(db *gorm.DB) *gorm.DB) (int64, error) {
+	var count int64
+	if err := p.DB(ctx).Table(p.Table()).Scopes(option...).Count(&count).Error; err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+func (p *ScheduleRunPersist) DeleteByScheduleID(ctx context.Context, scheduleID string) error {
+	return p.DB(ctx).Table(p.Table()).Where("schedule_id = ?", scheduleID).Delete(&model.ScheduleRun{}).Error
+}
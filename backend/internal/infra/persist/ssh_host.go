@@ -0,0 +1,123 @@
+package persist
+
+import (
+	"context"
+
+	"github.com/xichan96/cortex-lab/internal/infra/model"
+	"github.com/xichan96/cortex-lab/pkg/sql"
+	"github.com/xichan96/cortex-lab/pkg/std/snowflake"
+	"gorm.io/gorm"
+)
+
+type SSHHostPersistIer interface {
+	sql.Corm
+	Field() *model.SSHHostFieldMeta
+	F() *model.SSHHostFieldMeta
+	Create(ctx context.Context, host *model.SSHHost) (string, error)
+	Update(ctx context.Context, host *model.SSHHost, options ...func(*gorm.DB) *gorm.DB) error
+	UpdateFields(ctx context.Context, fields map[string]interface{}, options ...func(*gorm.DB) *gorm.DB) error
+	GetByID(ctx context.Context, id string) (*model.SSHHost, error)
+	GetList(ctx context.Context, options ...func(*gorm.DB) *gorm.DB) ([]*model.SSHHost, error)
+	Count(ctx context.Context, option ...func(db *gorm.DB) *gorm.DB) (int64, error)
+	Delete(ctx context.Context, host *model.SSHHost) error
+}
+
+func NewSSHHostPersist() SSHHostPersistIer {
+	return &SSHHostPersist{
+		SSHHostFieldMeta: model.SSHHostFM,
+	}
+}
+
+type SSHHostPersist struct {
+	*model.SSHHostFieldMeta
+	sql.BaseOpr
+}
+
+func (p *SSHHostPersist) Field() *model.SSHHostFieldMeta { return p.SSHHostFieldMeta }
+func (p *SSHHostPersist) F() *model.SSHHostFieldMeta     { return p.SSHHostFieldMeta }
+
+func (p *SSHHostPersist) Create(ctx context.Context, host *model.SSHHost) (string, error) {
+	if len(host.ID) == 0 {
+		host.ID = snowflake.NewUUID()
+	}
+	if err := p.DB(ctx).Table(p.Table()).Create(&host).Error; err != nil {
+		return "", err
+	}
+	return host.ID, nil
+}
+
+func (p *SSHHostPersist) Update(ctx context.Context, host *model.SSHHost, options ...func(*gorm.DB) *gorm.DB) error {
+	return p.DB(ctx).Table(p.Table()).Scopes(options...).Updates(host).Error
+}
+
+// UpdateFields updates the given columns, zero values included, of the hosts selected by options
+func (p *SSHHostPersist) UpdateFields(ctx context.Context, fields map[string]interface{}, options ...func(*gorm.DB) *gorm.DB) error {
+	return p.DB(ctx).Table(p.Table()).Scopes(options...).Updates(fields).Error
+}
+
+func (p *SSHHostPersist) GetByID(ctx context.Context, id string) (*model.SSHHost, error) {
+	var host model.SSHHost
+	if err := p.DB(ctx).Table(p.Table()).Where("id = ?", id).Take(&host).Error; err != nil {
+		return nil, err
+	}
+	return &host, nil
+}
+
+func (p *SSHHostPersist) GetList(ctx context.Context, options ...func(*gorm.DB) *gorm.DB) ([]*model.SSHHost, error) {
+	var hosts []*model.SSHHost
+	if err := p.DB(ctx).Table(p.Table()).Scopes(options...).Find(&hosts).Error; err != nil {
+		return nil, err
+	}
+	return hosts, nil
+}
+
+func (p *SSHHostPersist) Count(ctx context.Context, option ...func(db *gorm.DB) *gorm.DB) (int64, error) {
+	var count int64
+	if err := p.DB(ctx).Table(p.Table()).Scopes(option...).Count(&count).Error; err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+func (p *SSHHostPersist) Delete(ctx context.Context, host *model.SSHHost) error {
+	return p.DB(ctx).Table(p.Table()).Delete(host).Error
+}
+
+type SSHHostGrantPersistIer interface {
+	sql.Corm
+	Field() *model.SSHHostGrantFieldMeta
+	CreateBatch(ctx context.Context, list []*model.SSHHostGrant) error
+	GetList(ctx context.Context, options ...func(*gorm.DB) *gorm.DB) ([]*model.SSHHostGrant, error)
+	DeleteByHostID(ctx context.Context, hostID string) error
+}
+
+func NewSSHHostGrantPersist() SSHHostGrantPersistIer {
+	return &SSHHostGrantPersist{
+		SSHHostGrantFieldMeta: model.SSHHostGrantFM,
+	}
+}
+
+type SSHHostGrantPersist struct {
+	*model.SSHHostGrantFieldMeta
+	sql.BaseOpr
+}
+
+func (p *SSHHostGrantPersist) Field() *model.SSHHostGrantFieldMeta {
+	return p.SSHHostGrantFieldMeta
+}
+
+func (p *SSHHostGrantPersist) CreateBatch(ctx context.Context, list []*model.SSHHostGrant) error {
+	return p.DB(ctx).Table(p.Table()).Create(&list).Error
+}
+
+func (p *SSHHostGrantPersist) GetList(ctx context.Context, options ...func(*gorm.DB) *gorm.DB) ([]*model.SSHHostGrant, error) {
+	var list []*model.SSHHostGrant
+	if err := p.DB(ctx).Table(p.Table()).Scopes(options...).Find(&list).Error; err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (p *SSHHostGrantPersist) DeleteByHostID(ctx context.Context, hostID string) error {
+	return p.DB(ctx).Table(p.Table()).Where("host_id = ?", hostID).Delete(&model.SSHHostGrant{}).Error
+}
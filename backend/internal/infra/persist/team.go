@@ -0,0 +1,78 @@
+package persist
+
+import (
+	"context"
+
+	"github.com/xichan96/cortex-lab/internal/infra/model"
+	"github.com/xichan96/cortex-lab/pkg/sql"
+	"github.com/xichan96/cortex-lab/pkg/std/snowflake"
+	"gorm.io/gorm"
+)
+
+type TeamPersistIer interface {
+	sql.Corm
+	Field() *model.TeamFieldMeta
+	F() *model.TeamFieldMeta
+	Create(ctx context.Context, team *model.Team) (string, error)
+	Update(ctx context.Context, team *model.Team, options ...func(*gorm.DB) *gorm.DB) error
+	GetByID(ctx context.Context, id string) (*model.Team, error)
+	GetList(ctx context.Context, options ...func(*gorm.DB) *gorm.DB) ([]*model.Team, error)
+	Count(ctx context.Context, option ...func(db *gorm.DB) *gorm.DB) (int64, error)
+	Delete(ctx context.Context, team *model.Team) error
+}
+
+func NewTeamPersist() TeamPersistIer {
+	return &TeamPersist{
+		TeamFieldMeta: model.TeamFM,
+	}
+}
+
+type TeamPersist struct {
+	*model.TeamFieldMeta
+	sql.BaseOpr
+}
+
+func (p *TeamPersist) Field() *model.TeamFieldMeta { return p.TeamFieldMeta }
+func (p *TeamPersist) F() *model.TeamFieldMeta     { return p.TeamFieldMeta }
+
+func (p *TeamPersist) Create(ctx context.Context, team *model.Team) (string, error) {
+	if len(team.ID) == 0 {
+		team.ID = snowflake.NewUUID()
+	}
+	if err := p.DB(ctx).Table(p.Table()).Create(&team).Error; err != nil {
+		return "", err
+	}
+	return team.ID, nil
+}
+
+func (p *TeamPersist) Update(ctx context.Context, team *model.Team, options ...func(*gorm.DB) *gorm.DB) error {
+	return p.DB(ctx).Table(p.Table()).Scopes(options...).Updates(team).Error
+}
+
+func (p *TeamPersist) GetByID(ctx context.Context, id string) (*model.Team, error) {
+	var team model.Team
+	if err := p.DB(ctx).Table(p.Table()).Where("id = ?", id).Take(&team).Error; err != nil {
+		return nil, err
+	}
+	return &team, nil
+}
+
+func (p *TeamPersist) GetList(ctx context.Context, options ...func(*gorm.DB) *gorm.DB) ([]*model.Team, error) {
+	var teams []*model.Team
+	if err := p.DB(ctx).Table(p.Table()).Scopes(options...).Find(&teams).Error; err != nil {
+		return nil, err
+	}
+	return teams, nil
+}
+
+func (p *TeamPersist) Count(ctx context.Context, option ...func(db *gorm.DB) *gorm.DB) (int64, error) {
+	var count int64
+	if err := p.DB(ctx).Table(p.Table()).Scopes(option...).Count(&count).Error; err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+func (p *TeamPersist) Delete(ctx context.Context, team *model.Team) error {
+	return p.DB(ctx).Table(p.Table()).Delete(team).Error
+}
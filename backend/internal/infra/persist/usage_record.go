@@ -0,0 +1,65 @@
+package persist
+
+import (
+	"context"
+
+	"github.com/xichan96/cortex-lab/internal/infra/model"
+	"github.com/xichan96/cortex-lab/pkg/sql"
+	"github.com/xichan96/cortex-lab/pkg/std/snowflake"
+	"gorm.io/gorm"
+)
+
+type UsageRecordPersistIer interface {
+	sql.Corm
+	Field() *model.UsageRecordFieldMeta
+	F() *model.UsageRecordFieldMeta
+	Create(ctx context.Context, record *model.UsageRecord) (string, error)
+	Gets(ctx context.Context, data any, options ...func(*gorm.DB) *gorm.DB) error
+	GetList(ctx context.Context, options ...func(*gorm.DB) *gorm.DB) ([]*model.UsageRecord, error)
+	Count(ctx context.Context, option ...func(db *gorm.DB) *gorm.DB) (int64, error)
+}
+
+func NewUsageRecordPersist() UsageRecordPersistIer {
+	return &UsageRecordPersist{
+		UsageRecordFieldMeta: model.UsageRecordFM,
+	}
+}
+
+type UsageRecordPersist struct {
+	*model.UsageRecordFieldMeta
+	sql.BaseOpr
+}
+
+func (p *UsageRecordPersist) Field() *model.UsageRecordFieldMeta { return p.UsageRecordFieldMeta }
+func (p *UsageRecordPersist) F() *model.UsageRecordFieldMeta     { return p.UsageRecordFieldMeta }
+
+func (p *UsageRecordPersist) Create(ctx context.Context, record *model.UsageRecord) (string, error) {
+	if len(record.ID) == 0 {
+		record.ID = snowflake.NewUUID()
+	}
+	if err := p.DB(ctx).Table(p.Table()).Create(record).Error; err != nil {
+		return "", err
+	}
+	return record.ID, nil
+}
+
+// Gets scans the query result into data, used for aggregated queries
+func (p *UsageRecordPersist) Gets(ctx context.Context, data any, options ...func(*gorm.DB) *gorm.DB) error {
+	return p.DB(ctx).Table(p.Table()).Scopes(options...).Find(data).Error
+}
+
+func (p *UsageRecordPersist) GetList(ctx context.Context, options ...func(*gorm.DB) *gorm.DB) ([]*model.UsageRecord, error) {
+	var records []*model.UsageRecord
+	if err := p.DB(ctx).Table(p.Table()).Scopes(options...).Find(&records).Error; err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+func (p *UsageRecordPersist) Count(ctx context.Context, option ...func(db *gorm.DB) *gorm.DB) (int64, error) {
+	var count int64
+	if err := p.DB(ctx).Table(p.Table()).Scopes(option...).Count(&count).Error; err != nil {
+		return 0, err
+	}
+	return count, nil
+}
@@ -0,0 +1,156 @@
+package persist
+
+import (
+	"context"
+
+	"github.com/xichan96/cortex-lab/internal/infra/model"
+	"github.com/xichan96/cortex-lab/pkg/sql"
+	"github.com/xichan96/cortex-lab/pkg/std/snowflake"
+	"gorm.io/gorm"
+)
+
+type WebhookPersistIer interface {
+	sql.Corm
+	Field() *model.WebhookFieldMeta
+	F() *model.WebhookFieldMeta
+	Create(ctx context.Context, webhook *model.Webhook) (string, error)
+	Update(ctx context.Context, webhook *model.Webhook, options ...func(*gorm.DB) *gorm.DB) error
+	UpdateFields(ctx context.Context, fields map[string]interface{}, options ...func(*gorm.DB) *gorm.DB) error
+	GetByID(ctx context.Context, id string) (*model.Webhook, error)
+	GetList(ctx context.Context, options ...func(*gorm.DB) *gorm.DB) ([]*model.Webhook, error)
+	Count(ctx context.Context, option ...func(db *gorm.DB) *gorm.DB) (int64, error)
+	Delete(ctx context.Context, webhook *model.Webhook) error
+}
+
+func NewWebhookPersist() WebhookPersistIer {
+	return &WebhookPersist{
+		WebhookFieldMeta: model.WebhookFM,
+	}
+}
+
+type WebhookPersist struct {
+	*model.WebhookFieldMeta
+	sql.BaseOpr
+}
+
+func (p *WebhookPersist) Field() *model.WebhookFieldMeta { return p.WebhookFieldMeta }
+func (p *WebhookPersist) F() *model.WebhookFieldMeta     { return p.WebhookFieldMeta }
+
+func (p *WebhookPersist) Create(ctx context.Context, webhook *model.Webhook) (string, error) {
+	if len(webhook.ID) == 0 {
+		webhook.ID = snowflake.NewUUID()
+	}
+	if err := p.DB(ctx).Table(p.Table()).Create(&webhook).Error; err != nil {
+		return "", err
+	}
+	return webhook.ID, nil
+}
+
+func (p *WebhookPersist) Update(ctx context.Context, webhook *model.Webhook, options ...func(*gorm.DB) *gorm.DB) error {
+	return p.DB(ctx).Table(p.Table()).Scopes(options...).Updates(webhook).Error
+}
+
+// UpdateFields updates the given columns, zero values included, of the webhooks selected by options
+func (p *WebhookPersist) UpdateFields(ctx context.Context, fields map[string]interface{}, options ...func(*gorm.DB) *gorm.DB) error {
+	return p.DB(ctx).Table(p.Table()).Scopes(options...).Updates(fields).Error
+}
+
+func (p *WebhookPersist) GetByID(ctx context.Context, id string) (*model.Webhook, error) {
+	var webhook model.Webhook
+	if err := p.DB(ctx).Table(p.Table()).Where("id = ?", id).Take(&webhook).Error; err != nil {
+		return nil, err
+	}
+	return &webhook, nil
+}
+
+func (p *WebhookPersist) GetList(ctx context.Context, options ...func(*gorm.DB) *gorm.DB) ([]*model.Webhook, error) {
+	var webhooks []*model.Webhook
+	if err := p.DB(ctx).Table(p.Table()).Scopes(options...).Find(&webhooks).Error; err != nil {
+		return nil, err
+	}
+	return webhooks, nil
+}
+
+func (p *WebhookPersist) Count(ctx context.Context, option ...func(db *gorm.DB) *gorm.DB) (int64, error) {
+	var count int64
+	if err := p.DB(ctx).Table(p.Table()).Scopes(option...).Count(&count).Error; err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+func (p *WebhookPersist) Delete(ctx context.Context, webhook *model.Webhook) error {
+	return p.DB(ctx).Table(p.Table()).Delete(webhook).Error
+}
+
+type WebhookInvocationPersistIer interface {
+	sql.Corm
+	Field() *model.WebhookInvocationFieldMeta
+	F() *model.WebhookInvocationFieldMeta
+	Create(ctx context.Context, invocation *model.WebhookInvocation) (string, error)
+	Update(ctx context.Context, invocation *model.WebhookInvocation, options ...func(*gorm.DB) *gorm.DB) error
+	GetByID(ctx context.Context, id string) (*model.WebhookInvocation, error)
+	GetList(ctx context.Context, options ...func(*gorm.DB) *gorm.DB) ([]*model.WebhookInvocation, error)
+	Count(ctx context.Context, option ...func(db *gorm.DB) *gorm.DB) (int64, error)
+	DeleteByWebhookID(ctx context.Context, webhookID string) error
+}
+
+func NewWebhookInvocationPersist() WebhookInvocationPersistIer {
+	return &WebhookInvocationPersist{
+		WebhookInvocationFieldMeta: model.WebhookInvocationFM,
+	}
+}
+
+type WebhookInvocationPersist struct {
+	*model.WebhookInvocationFieldMeta
+	sql.BaseOpr
+}
+
+func (p *WebhookInvocationPersist) Field() *model.WebhookInvocationFieldMeta {
+	return p.WebhookInvocationFieldMeta
+}
+func (p *WebhookInvocationPersist) F() *model.WebhookInvocationFieldMeta {
+	return p.WebhookInvocationFieldMeta
+}
+
+func (p *WebhookInvocationPersist) Create(ctx context.Context, invocation *model.WebhookInvocation) (string, error) {
+	if len(invocation.ID) == 0 {
+		invocation.ID = snowflake.NewUUID()
+	}
+	if err := p.DB(ctx).Table(p.Table()).Create(&invocation).Error; err != nil {
+		return "", err
+	}
+	return invocation.ID, nil
+}
+
+func (p *WebhookInvocationPersist) Update(ctx context.Context, invocation *model.WebhookInvocation, options ...func(*gorm.DB) *gorm.DB) error {
+	return p.DB(ctx).Table(p.Table()).Scopes(options...).Updates(invocation).Error
+}
+
+func (p *WebhookInvocationPersist) GetByID(ctx context.Context, id string) (*model.WebhookInvocation, error) {
+	var invocation model.WebhookInvocation
+	if err := p.DB(ctx).Table(p.Table()).Where("id = ?", id).Take(&invocation).Error; err != nil {
+		return nil, err
+	}
+	return &invocation, nil
+}
+
+func (p *WebhookInvocationPersist) GetList(ctx context.Context, options ...func(*gorm.DB) *gorm.DB) ([]*model.WebhookInvocation, error) {
+	var invocations []*model.WebhookInvocation
+	if err := p.DB(ctx).Table(p.Table()).Scopes(options...).Find(&invocations).Error; err != nil {
+		return nil, err
+	}
+	return invocations, nil
+}
+
+func (p *WebhookInvocationPersist) Count(ctx context.Context, option ...func(db *gorm.DB) *gorm.DB) (int64, error) {
+	var count int64
+	if err := p.DB(ctx).Table(p.Table()).Scopes(option...).Count(&count).Error; err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+func (p *WebhookInvocationPersist) DeleteByWebhookID(ctx context.Context, webhookID string) error {
+	return p.DB(ctx).Table(p.Table()).Where("webhook_id = ?", webhookID).Delete(&model.WebhookInvocation{}).Error
+}
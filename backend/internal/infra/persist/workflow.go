@@ -0,0 +1,146 @@
+package persist
+
+import (
+	"context"
+
+	"github.com/xichan96/cortex-lab/internal/infra/model"
+	"github.com/xichan96/cortex-lab/pkg/sql"
+	"github.com/xichan96/cortex-lab/pkg/std/snowflake"
+	"gorm.io/gorm"
+)
+
+type WorkflowPersistIer interface {
+	sql.Corm
+	Field() *model.WorkflowFieldMeta
+	F() *model.WorkflowFieldMeta
+	Create(ctx context.Context, workflow *model.Workflow) (string, error)
+	Update(ctx context.Context, workflow *model.Workflow, options ...func(*gorm.DB) *gorm.DB) error
+	GetByID(ctx context.Context, id string) (*model.Workflow, error)
+	GetList(ctx context.Context, options ...func(*gorm.DB) *gorm.DB) ([]*model.Workflow, error)
+	Count(ctx context.Context, option ...func(db *gorm.DB) *gorm.DB) (int64, error)
+	Delete(ctx context.Context, workflow *model.Workflow) error
+}
+
+func NewWorkflowPersist() WorkflowPersistIer {
+	return &WorkflowPersist{
+		WorkflowFieldMeta: model.WorkflowFM,
+	}
+}
+
+type WorkflowPersist struct {
+	*model.WorkflowFieldMeta
+	sql.BaseOpr
+}
+
+func (p *WorkflowPersist) Field() *model.WorkflowFieldMeta { return p.WorkflowFieldMeta }
+func (p *WorkflowPersist) F() *model.WorkflowFieldMeta     { return p.WorkflowFieldMeta }
+
+func (p *WorkflowPersist) Create(ctx context.Context, workflow *model.Workflow) (string, error) {
+	if len(workflow.ID) == 0 {
+		workflow.ID = snowflake.NewUUID()
+	}
+	if err := p.DB(ctx).Table(p.Table()).Create(&workflow).Error; err != nil {
+		return "", err
+	}
+	return workflow.ID, nil
+}
+
+func (p *WorkflowPersist) Update(ctx context.Context, workflow *model.Workflow, options ...func(*gorm.DB) *gorm.DB) error {
+	return p.DB(ctx).Table(p.Table()).Scopes(options...).Updates(workflow).Error
+}
+
+func (p *WorkflowPersist) GetByID(ctx context.Context, id string) (*model.Workflow, error) {
+	var workflow model.Workflow
+	if err := p.DB(ctx).Table(p.Table()).Where("id = ?", id).Take(&workflow).Error; err != nil {
+		return nil, err
+	}
+	return &workflow, nil
+}
+
+func (p *WorkflowPersist) GetList(ctx context.Context, options ...func(*gorm.DB) *gorm.DB) ([]*model.Workflow, error) {
+	var workflows []*model.Workflow
+	if err := p.DB(ctx).Table(p.Table()).Scopes(options...).Find(&workflows).Error; err != nil {
+		return nil, err
+	}
+	return workflows, nil
+}
+
+func (p *WorkflowPersist) Count(ctx context.Context, option ...func(db *gorm.DB) *gorm.DB) (int64, error) {
+	var count int64
+	if err := p.DB(ctx).Table(p.Table()).Scopes(option...).Count(&count).Error; err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+func (p *WorkflowPersist) Delete(ctx context.Context, workflow *model.Workflow) error {
+	return p.DB(ctx).Table(p.Table()).Delete(workflow).Error
+}
+
+type WorkflowRunPersistIer interface {
+	sql.Corm
+	Field() *model.WorkflowRunFieldMeta
+	F() *model.WorkflowRunFieldMeta
+	Create(ctx context.Context, run *model.WorkflowRun) (string, error)
+	Update(ctx context.Context, run *model.WorkflowRun, options ...func(*gorm.DB) *gorm.DB) error
+	GetByID(ctx context.Context, id string) (*model.WorkflowRun, error)
+	GetList(ctx context.Context, options ...func(*gorm.DB) *gorm.DB) ([]*model.WorkflowRun, error)
+	Count(ctx context.Context, option ...func(db *gorm.DB) *gorm.DB) (int64, error)
+	DeleteByWorkflowID(ctx context.Context, workflowID string) error
+}
+
+func NewWorkflowRunPersist() WorkflowRunPersistIer {
+	return &WorkflowRunPersist{
+		WorkflowRunFieldMeta: model.WorkflowRunFM,
+	}
+}
+
+type WorkflowRunPersist struct {
+	*model.WorkflowRunFieldMeta
+	sql.BaseOpr
+}
+
+func (p *WorkflowRunPersist) Field() *model.WorkflowRunFieldMeta { return p.WorkflowRunFieldMeta }
+func (p *WorkflowRunPersist) F() *model.WorkflowRunFieldMeta     { return p.WorkflowRunFieldMeta }
+
+func (p *WorkflowRunPersist) Create(ctx context.Context, run *model.WorkflowRun) (string, error) {
+	if len(run.ID) == 0 {
+		run.ID = snowflake.NewUUID()
+	}
+	if err := p.DB(ctx).Table(p.Table()).Create(&run).Error; err != nil {
+		return "", err
+	}
+	return run.ID, nil
+}
+
+func (p *WorkflowRunPersist) Update(ctx context.Context, run *model.WorkflowRun, options ...func(*gorm.DB) *gorm.DB) error {
+	return p.DB(ctx).Table(p.Table()).Scopes(options...).Updates(run).Error
+}
+
+func (p *WorkflowRunPersist) GetByID(ctx context.Context, id string) (*model.WorkflowRun, error) {
+	var run model.WorkflowRun
+	if err := p.DB(ctx).Table(p.Table()).Where("id = ?", id).Take(&run).Error; err != nil {
+		return nil, err
+	}
+	return &run, nil
+}
+
+func (p *WorkflowRunPersist) GetList(ctx context.Context, options ...func(*gorm.DB) *gorm.DB) ([]*model.WorkflowRun, error) {
+	var runs []*model.WorkflowRun
+	if err := p.DB(ctx).Table(p.Table()).Scopes(options...).Find(&runs).Error; err != nil {
+		return nil, err
+	}
+	return runs, nil
+}
+
+func (p *WorkflowRunPersist) Count(ctx context.Context, option ...func(db *gorm.DB) *gorm.DB) (int64, error) {
+	var count int64
+	if err := p.DB(ctx).Table(p.Table()).Scopes(option...).Count(&count).Error; err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+func (p *WorkflowRunPersist) DeleteByWorkflowID(ctx context.Context, workflowID string) error {
+	return p.DB(ctx).Table(p.Table()).Where("workflow_id = ?", workflowID).Delete(&model.WorkflowRun{}).Error
+}
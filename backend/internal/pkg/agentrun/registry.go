@@ -0,0 +1,146 @@
+package agentrun
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+
+	"github.com/xichan96/cortex-lab/pkg/std/snowflake"
+	"github.com/xichan96/cortex/agent/engine"
+)
+
+// Run is an agent run in flight. Its context is cancelled when the run is
+// cancelled so LLM calls and tools bound to it stop early.
+type Run struct {
+	ID        string
+	SessionID string
+	UserID    string
+
+	ctx       context.Context
+	cancel    context.CancelFunc
+	cancelled atomic.Bool
+
+	mu     sync.Mutex
+	engine *engine.AgentEngine
+}
+
+// Context is cancelled when the run is cancelled or finished
+func (r *Run) Context() context.Context {
+	return r.ctx
+}
+
+// Cancelled reports whether the run was cancelled by the client
+func (r *Run) Cancelled() bool {
+	return r.cancelled.Load()
+}
+
+// SetEngine attaches the engine executing the run so it is stopped on cancel
+func (r *Run) SetEngine(e *engine.AgentEngine) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.engine = e
+}
+
+func (r *Run) stop() {
+	r.cancelled.Store(true)
+	r.cancel()
+	r.mu.Lock()
+	e := r.engine
+	r.mu.Unlock()
+	if e != nil {
+		e.Stop()
+	}
+}
+
+// Registry tracks the runs in flight, at most one per session
+type Registry struct {
+	mu   sync.Mutex
+	runs map[string]*Run
+}
+
+func NewRegistry() *Registry {
+	return &Registry{runs: make(map[string]*Run)}
+}
+
+// Default is the registry shared by the chat and agent apps
+var Default = NewRegistry()
+
+// Start registers a new run of a session, ok is false when the session already has one
+func (g *Registry) Start(sessionID, userID string) (*Run, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if _, exists := g.runs[sessionID]; exists {
+		return nil, false
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	run := &Run{
+		ID:        snowflake.NewUUID(),
+		SessionID: sessionID,
+		UserID:    userID,
+		ctx:       ctx,
+		cancel:    cancel,
+	}
+	g.runs[sessionID] = run
+	return run, true
+}
+
+// Finish removes the run from the registry and releases its context
+func (g *Registry) Finish(run *Run) {
+	g.mu.Lock()
+	if g.runs[run.SessionID] == run {
+		delete(g.runs, run.SessionID)
+	}
+	g.mu.Unlock()
+	run.cancel()
+}
+
+// Get returns the run in flight of a session
+func (g *Registry) Get(sessionID string) (*Run, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	run, ok := g.runs[sessionID]
+	return run, ok
+}
+
+// Cancel stops the run of a session started by userID. When runID is not
+// empty only that run is cancelled, so a stale request cannot stop a newer run.
+func (g *Registry) Cancel(sessionID, userID, runID string) bool {
+	g.mu.Lock()
+	run, ok := g.runs[sessionID]
+	g.mu.Unlock()
+	if !ok || run.UserID != userID || (runID != "" && run.ID != runID) {
+		return false
+	}
+	run.stop()
+	return true
+}
+
+// Watch relays the results of stream until it ends or the run is cancelled.
+// It is used for engines whose LLM calls are not bound to the run context:
+// on cancel the stream ends right away with a "cancelled" result while the
+// engine winds down in the background.
+func (r *Run) Watch(stream <-chan engine.StreamResult) <-chan engine.StreamResult {
+	out := make(chan engine.StreamResult, engine.DefaultChannelBuffer)
+	go func() {
+		defer close(out)
+		for {
+			select {
+			case result, ok := <-stream:
+				if !ok {
+					return
+				}
+				out <- result
+			case <-r.ctx.Done():
+				if r.Cancelled() {
+					out <- engine.StreamResult{Type: "cancelled"}
+				}
+				go func() {
+					for range stream {
+					}
+				}()
+				return
+			}
+		}
+	}()
+	return out
+}
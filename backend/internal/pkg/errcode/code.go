@@ -11,3 +11,34 @@ var UsernameExisted = ec.NewErrorCode(1005, "username already exists")
 var EmailExisted = ec.NewErrorCode(1006, "email already exists")
 var UserNotFound = ec.NewErrorCode(1007, "user not found")
 var SkillNameExisted = ec.NewErrorCode(1008, "skill name already exists")
+var ChatMessageNotEditable = ec.NewErrorCode(1009, "only user messages can be edited")
+var ChatMessageNotRegenerable = ec.NewErrorCode(1010, "message cannot be regenerated")
+var ChatSessionBusy = ec.NewErrorCode(1011, "session already has a run in progress")
+var ChatAttachmentNotFound = ec.NewErrorCode(1012, "attachment not found")
+var ChatAttachmentTooLarge = ec.NewErrorCode(1013, "attachment exceeds the size limit")
+var DocumentFormatUnsupported = ec.NewErrorCode(1014, "unsupported document format, use Markdown, plain text, HTML or PDF")
+var DocumentEmpty = ec.NewErrorCode(1015, "document has no text content")
+var DocumentTooLarge = ec.NewErrorCode(1016, "document exceeds the size limit")
+var ChatBundleInvalid = ec.NewErrorCode(1017, "invalid session export, expected a JSON export of a chat session")
+var ChatShareNotFound = ec.NewErrorCode(1018, "share link not found or expired")
+var ChatShareExpiresInPast = ec.NewErrorCode(1019, "share expiry must be in the future")
+var ChatFolderNameExisted = ec.NewErrorCode(1020, "folder name already exists")
+var ChatTagInvalid = ec.NewErrorCode(1021, "tags must be at most 32 characters, with at most 20 tags per session")
+var ModelParamsInvalid = ec.NewErrorCode(1022, "invalid model parameters: temperature 0-2, top_p 0-1, penalties -2 to 2, max_iterations 1-50, timeouts 1-3600 seconds, retry_attempts 0-10, at most 4 stop sequences")
+var RoleModelNotConfigured = ec.NewErrorCode(1023, "the model of the role is not configured in the chat LLM settings")
+var ChatModelUnavailable = ec.NewErrorCode(1024, "no chat model available, choose a model, set one on the role or configure one in the chat LLM settings")
+var DelegationSettingInvalid = ec.NewErrorCode(1025, "max_depth and timeout_seconds must not be negative")
+var DelegationCycle = ec.NewErrorCode(1026, "the role is already in the delegation chain, calling it again would loop")
+var DelegationTooDeep = ec.NewErrorCode(1027, "the delegation chain is too deep")
+var DelegationTimeout = ec.NewErrorCode(1028, "the delegation timed out")
+var TeamInvalid = ec.NewErrorCode(1029, "a team needs a supervisor role and at least one member role other than the supervisor")
+var WorkflowInvalid = ec.NewErrorCode(1030, "invalid workflow steps")
+var ScheduleInvalid = ec.NewErrorCode(1031, "invalid schedule")
+var WebhookInvalid = ec.NewErrorCode(1032, "invalid webhook")
+var WebhookNotFound = ec.NewErrorCode(1033, "webhook not found or disabled")
+var WebhookSignatureInvalid = ec.NewErrorCode(1034, "invalid webhook signature, sign the raw body with HMAC-SHA256 and the secret of the webhook")
+var EventSubscriptionInvalid = ec.NewErrorCode(1035, "invalid event subscription")
+var ToolApprovalNotFound = ec.NewErrorCode(1036, "tool call approval not found or already decided")
+var SandboxPolicyInvalid = ec.NewErrorCode(1037, "invalid sandbox policy")
+var SSHHostInvalid = ec.NewErrorCode(1038, "invalid SSH host")
+var SSHHostUnreachable = ec.NewErrorCode(1039, "failed to reach the SSH host")
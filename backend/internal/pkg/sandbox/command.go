@@ -0,0 +1,189 @@
+package sandbox
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/xichan96/cortex/agent/tools/builtin"
+	"github.com/xichan96/cortex/agent/types"
+	"github.com/xichan96/cortex/pkg/errors"
+)
+
+// CommandTool is the builtin command tool run under a policy, with the
+// process killed when the context of the call is cancelled
+type CommandTool struct {
+	types.Tool
+	policy *Policy
+}
+
+func NewCommandTool(policy *Policy) *CommandTool {
+	return &CommandTool{Tool: builtin.NewCommandTool(), policy: policy}
+}
+
+func (t *CommandTool) Description() string {
+	if t.policy != nil && t.policy.Root != "" {
+		return t.Tool.Description() + fmt.Sprintf(" Commands run in %s and cannot use paths outside of it.", t.policy.Root)
+	}
+	return t.Tool.Description()
+}
+
+func (t *CommandTool) Execute(input map[string]interface{}) (interface{}, error) {
+	return t.ExecuteContext(context.Background(), input)
+}
+
+func (t *CommandTool) ExecuteContext(ctx context.Context, input map[string]interface{}) (interface{}, error) {
+	command, ok := input["command"].(string)
+	if !ok {
+		return nil, errors.EC_TOOL_PARAMETER_INVALID.Wrap(fmt.Errorf("invalid 'command' parameter: must be a string"))
+	}
+	if command == "" {
+		return nil, errors.EC_PARAMETER_MISSING.Wrap(fmt.Errorf("'command' parameter cannot be empty"))
+	}
+
+	timeout := 30 * time.Second
+	if timeoutVal, ok := input["timeout"].(float64); ok {
+		timeout = time.Duration(timeoutVal) * time.Second
+	} else if timeoutVal, ok := input["timeout"].(int); ok {
+		timeout = time.Duration(timeoutVal) * time.Second
+	}
+	if t.policy != nil && t.policy.TimeoutSeconds > 0 {
+		timeout = min(timeout, time.Duration(t.policy.TimeoutSeconds)*time.Second)
+	}
+
+	parts := strings.Fields(command)
+	if len(parts) == 0 {
+		return nil, errors.EC_TOOL_PARAMETER_INVALID.Wrap(fmt.Errorf("invalid 'command' parameter: command cannot be empty"))
+	}
+	root, err := t.policy.root()
+	if err != nil {
+		return nil, errors.EC_TOOL_EXECUTION_FAILED.Wrap(err)
+	}
+	if err := t.allow(root, parts); err != nil {
+		return nil, errors.EC_PERMISSION_DENIED.Wrap(err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, parts[0], parts[1:]...)
+	if t.policy != nil && t.policy.CPUSeconds > 0 {
+		// the shell sets the limit on itself before it becomes the command
+		script := "ulimit -t " + strconv.Itoa(t.policy.CPUSeconds) + ` && exec "$0" "$@"`
+		cmd = exec.CommandContext(ctx, "/bin/sh", append([]string{"-c", script}, parts...)...)
+	}
+	cmd.Dir = root
+	cmd.Env = t.policy.env(root)
+	// a child left holding the output open does not keep the call waiting
+	cmd.WaitDelay = time.Second
+	var maxOutput int
+	if t.policy != nil {
+		maxOutput = t.policy.MaxOutputBytes
+	}
+	stdout, stderr := &limitedBuffer{max: maxOutput}, &limitedBuffer{max: maxOutput}
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+
+	err = cmd.Run()
+	if ctx.Err() == context.DeadlineExceeded {
+		return nil, errors.EC_TOOL_EXECUTION_TIMEOUT.Wrap(fmt.Errorf("command execution timeout after %v", timeout))
+	}
+
+	output := map[string]interface{}{
+		"command":   command,
+		"exit_code": 0,
+		"stdout":    stdout.String(),
+		"stderr":    stderr.String(),
+	}
+	if stdout.truncated || stderr.truncated {
+		output["truncated"] = true
+	}
+	if err != nil {
+		output["exit_code"] = -1
+		if cmd.ProcessState != nil {
+			output["exit_code"] = cmd.ProcessState.ExitCode()
+		}
+		output["error"] = err.Error()
+	}
+	return output, nil
+}
+
+// allow checks the program against the allow and deny lists, and the
+// arguments that look like paths against the root
+func (t *CommandTool) allow(root string, parts []string) error {
+	if t.policy == nil {
+		return nil
+	}
+	program := filepath.Base(parts[0])
+	if slices.Contains(t.policy.DenyCommands, program) {
+		return fmt.Errorf("%s is not allowed to run", program)
+	}
+	if root != "" && len(t.policy.AllowCommands) == 0 {
+		// any program could open paths outside of the root on its own
+		return fmt.Errorf("commands cannot run in the sandbox root %s without a list of allowed commands", t.policy.Root)
+	}
+	if len(t.policy.AllowCommands) > 0 {
+		if !slices.Contains(t.policy.AllowCommands, program) {
+			return fmt.Errorf("%s is not allowed to run, allowed commands: %s", program, strings.Join(t.policy.AllowCommands, ", "))
+		}
+		// the allowed programs are looked up in the PATH, a path could run any file of that name
+		if strings.ContainsRune(parts[0], filepath.Separator) {
+			return fmt.Errorf("run %s by its name, not by a path", program)
+		}
+	}
+	if root == "" {
+		return nil
+	}
+	// any argument may be a path, a relative one too since it may be a symlink
+	// leading out of the root
+	for _, arg := range parts[1:] {
+		paths := []string{arg}
+		switch {
+		case strings.HasPrefix(arg, "--"):
+			// --flag=path carries a path as well
+			i := strings.IndexByte(arg, '=')
+			if i < 0 {
+				continue
+			}
+			paths = []string{arg[i+1:]}
+		case strings.HasPrefix(arg, "-"):
+			// a short flag may carry its value attached, as in -f/etc/passwd
+			// or -la, any tail of the flags may be that value
+			paths = paths[:0]
+			for i := 1; i < len(arg); i++ {
+				paths = append(paths, arg[i:])
+			}
+		}
+		for _, path := range paths {
+			if path == "" {
+				continue
+			}
+			if _, err := t.policy.resolve(path); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// limitedBuffer keeps the first max bytes written to it, all of them when
+// max is 0
+type limitedBuffer struct {
+	strings.Builder
+	max       int
+	truncated bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if b.max > 0 && b.Len()+len(p) > b.max {
+		b.truncated = true
+		b.Builder.Write(p[:max(b.max-b.Len(), 0)])
+		return len(p), nil
+	}
+	return b.Builder.Write(p)
+}
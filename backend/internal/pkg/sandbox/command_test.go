@@ -0,0 +1,114 @@
+package sandbox
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCommandTool_Allow(t *testing.T) {
+	root, outside := newRoot(t)
+	confined := &Policy{Root: root, AllowCommands: []string{"cat", "ls", "grep", "sort", "tar", "head"}, DenyCommands: []string{"rm"}}
+
+	tests := []struct {
+		name    string
+		policy  *Policy
+		command string
+		wantErr bool
+	}{
+		{"allowed", confined, "cat file", false},
+		{"flags", confined, "ls -la", false},
+		{"symlink inside", confined, "cat link-in", false},
+		{"symlink outside", confined, "cat link-out", true},
+		{"under a symlinked directory", confined, "ls dir-out", true},
+		{"absolute outside", confined, "cat " + filepath.Join(outside, "secret"), true},
+		{"dot dot", confined, "cat ../secret", true},
+		{"flag value outside", confined, "ls --directory=" + outside, true},
+		{"flag value symlink outside", confined, "cat --file=link-out", true},
+		{"short flags", confined, "head -n5 file", false},
+		{"attached value inside", confined, "grep -ffile x", false},
+		{"attached value outside", confined, "grep -f" + filepath.Join(outside, "secret") + " x", true},
+		{"attached output outside", confined, "sort -o/etc/cron.d/x file", true},
+		{"attached directory root", confined, "tar -C/ -cf out.tar etc", true},
+		{"attached value dot dot", confined, "grep -f../secret x", true},
+		{"attached value symlink outside", confined, "grep -flink-out x", true},
+		{"attached value after flags", confined, "sort -ro/etc/cron.d/x file", true},
+		{"not allowed", confined, "sh -c id", true},
+		{"denied", confined, "rm file", true},
+		{"allowed name run by a path", confined, "/tmp/cat file", true},
+		{"root without allowed commands", &Policy{Root: root}, "cat file", true},
+		{"deny list only", &Policy{DenyCommands: []string{"rm"}}, "cat " + filepath.Join(outside, "secret"), false},
+		{"deny list only denied", &Policy{DenyCommands: []string{"rm"}}, "/bin/rm file", true},
+		{"no policy", nil, "rm -rf /", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tool := NewCommandTool(tt.policy)
+			realRoot, err := tool.policy.root()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := tool.allow(realRoot, strings.Fields(tt.command)); (err != nil) != tt.wantErr {
+				t.Errorf("allow(%q) error = %v, wantErr %v", tt.command, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLimitedBuffer(t *testing.T) {
+	tests := []struct {
+		name          string
+		max           int
+		writes        []string
+		want          string
+		wantTruncated bool
+	}{
+		{"unlimited", 0, []string{"hello", " world"}, "hello world", false},
+		{"under the limit", 16, []string{"hello", " world"}, "hello world", false},
+		{"exactly the limit", 11, []string{"hello", " world"}, "hello world", false},
+		{"cut in a write", 8, []string{"hello", " world"}, "hello wo", true},
+		{"writes past the limit", 5, []string{"hello", " world", "!"}, "hello", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := &limitedBuffer{max: tt.max}
+			for _, w := range tt.writes {
+				if n, err := b.Write([]byte(w)); n != len(w) || err != nil {
+					t.Fatalf("Write() = %d, %v, want the whole write accepted", n, err)
+				}
+			}
+			if b.String() != tt.want || b.truncated != tt.wantTruncated {
+				t.Errorf("buffer = %q truncated %v, want %q truncated %v", b.String(), b.truncated, tt.want, tt.wantTruncated)
+			}
+		})
+	}
+}
+
+func TestCommandTool_ExecuteContext(t *testing.T) {
+	root, _ := newRoot(t)
+	t.Setenv("SANDBOX_TEST_SECRET", "leaked")
+	tool := NewCommandTool(&Policy{Root: root, AllowCommands: []string{"cat", "env"}, MaxOutputBytes: 2})
+
+	out, err := tool.ExecuteContext(context.Background(), map[string]interface{}{"command": "cat file"})
+	if err != nil {
+		t.Fatalf("ExecuteContext() error = %v", err)
+	}
+	result := out.(map[string]interface{})
+	if result["stdout"] != "da" || result["truncated"] != true {
+		t.Errorf("stdout = %q truncated %v, want the output cut to 2 bytes", result["stdout"], result["truncated"])
+	}
+
+	tool.policy.MaxOutputBytes = 0
+	out, err = tool.ExecuteContext(context.Background(), map[string]interface{}{"command": "env"})
+	if err != nil {
+		t.Fatalf("ExecuteContext() error = %v", err)
+	}
+	if stdout := out.(map[string]interface{})["stdout"].(string); strings.Contains(stdout, "SANDBOX_TEST_SECRET") {
+		t.Errorf("the command saw a variable of the server that is not listed: %s", stdout)
+	}
+
+	if _, err := tool.ExecuteContext(context.Background(), map[string]interface{}{"command": "cat link-out"}); err == nil {
+		t.Error("ExecuteContext() followed a symlink out of the root")
+	}
+}
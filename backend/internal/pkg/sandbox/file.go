@@ -0,0 +1,95 @@
+package sandbox
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"slices"
+
+	"github.com/xichan96/cortex/agent/tools/builtin"
+	"github.com/xichan96/cortex/agent/types"
+	"github.com/xichan96/cortex/pkg/errors"
+)
+
+// writeOperations are the operations of the file tool refused in read-only mode
+var writeOperations = []string{"write_file", "append_file", "create_dir", "delete_file", "delete_dir", "copy", "move"}
+
+// FileTool is the builtin file tool run under a policy
+type FileTool struct {
+	types.Tool
+	policy *Policy
+}
+
+// NewFileTool returns the builtin file tool as is when policy is nil
+func NewFileTool(policy *Policy) types.Tool {
+	if policy == nil {
+		return builtin.NewFileTool()
+	}
+	return &FileTool{Tool: builtin.NewFileTool(), policy: policy}
+}
+
+func (t *FileTool) Description() string {
+	desc := t.Tool.Description()
+	if t.policy.Root != "" {
+		desc += fmt.Sprintf(" Paths are relative to %s and cannot leave it.", t.policy.Root)
+	}
+	if t.policy.ReadOnly {
+		desc += " The file system is read-only, only read_file, list_dir, exists, is_file and is_dir are available."
+	}
+	return desc
+}
+
+func (t *FileTool) Execute(input map[string]interface{}) (interface{}, error) {
+	operation, _ := input["operation"].(string)
+	if t.policy.ReadOnly && slices.Contains(writeOperations, operation) {
+		return nil, errors.EC_PERMISSION_DENIED.Wrap(fmt.Errorf("%s is not allowed, the file system is read-only", operation))
+	}
+
+	args := make(map[string]interface{}, len(input))
+	for k, v := range input {
+		args[k] = v
+	}
+	for _, key := range []string{"path", "target_path"} {
+		path, ok := args[key].(string)
+		if !ok || path == "" {
+			continue
+		}
+		resolved, err := t.policy.resolve(path)
+		if err != nil {
+			return nil, errors.EC_PERMISSION_DENIED.Wrap(err)
+		}
+		args[key] = resolved
+	}
+
+	if operation == "read_file" && t.policy.MaxOutputBytes > 0 {
+		if path, ok := args["path"].(string); ok && path != "" {
+			return readFile(path, t.policy.MaxOutputBytes)
+		}
+	}
+	return t.Tool.Execute(args)
+}
+
+// readFile reads at most limit bytes of a file
+func readFile(path string, limit int) (interface{}, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, errors.EC_TOOL_EXECUTION_FAILED.Wrap(fmt.Errorf("failed to read file: %w", err))
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, errors.EC_TOOL_EXECUTION_FAILED.Wrap(fmt.Errorf("failed to read file: %w", err))
+	}
+	data, err := io.ReadAll(io.LimitReader(f, int64(limit)))
+	if err != nil {
+		return nil, errors.EC_TOOL_EXECUTION_FAILED.Wrap(fmt.Errorf("failed to read file: %w", err))
+	}
+	result := map[string]interface{}{
+		"content": string(data),
+		"size":    info.Size(),
+	}
+	if info.Size() > int64(len(data)) {
+		result["truncated"] = true
+	}
+	return result, nil
+}
@@ -0,0 +1,140 @@
+package sandbox
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Policy limits what the command and file tools of a role can do on the
+// server. A nil policy leaves the tools unrestricted.
+type Policy struct {
+	// Root is the directory the tools work in, paths outside of it are refused.
+	// It limits the paths given to the tools, not what a program does once it
+	// runs: a command only stays in the root if it runs programs that do not
+	// open other paths on their own, so the command tool requires AllowCommands
+	// with it. Do not allow interpreters or wrappers such as sh, env, python3,
+	// find or busybox, they run anything.
+	Root string `json:"root,omitempty"`
+	// AllowCommands are the only programs a command may run when set
+	AllowCommands []string `json:"allow_commands,omitempty"`
+	// DenyCommands are programs a command may never run
+	DenyCommands []string `json:"deny_commands,omitempty"`
+	// Env names the variables of the server passed to commands, the others are dropped
+	Env []string `json:"env,omitempty"`
+	// TimeoutSeconds caps how long a command runs, whatever timeout the model asks for
+	TimeoutSeconds int `json:"timeout_seconds,omitempty"`
+	// CPUSeconds caps the CPU time of a command
+	CPUSeconds int `json:"cpu_seconds,omitempty"`
+	// MaxOutputBytes caps the output of a command and the content of a file read
+	MaxOutputBytes int `json:"max_output_bytes,omitempty"`
+	// ReadOnly refuses the file operations that change the file system
+	ReadOnly bool `json:"read_only,omitempty"`
+}
+
+// Check validates a policy set on a role, command tells whether the command
+// tool of the role runs under it
+func (p *Policy) Check(command bool) error {
+	if p == nil {
+		return nil
+	}
+	if command && p.Root != "" && len(p.AllowCommands) == 0 {
+		return fmt.Errorf("list the allowed commands, a root alone does not keep programs in it")
+	}
+	if p.Root != "" && !filepath.IsAbs(p.Root) {
+		return fmt.Errorf("root must be an absolute path")
+	}
+	if p.TimeoutSeconds < 0 || p.CPUSeconds < 0 || p.MaxOutputBytes < 0 {
+		return fmt.Errorf("limits must not be negative")
+	}
+	for _, name := range slices.Concat(p.AllowCommands, p.DenyCommands) {
+		if name == "" || strings.ContainsAny(name, `/\ `) {
+			return fmt.Errorf("invalid command name %q, list program names without a path", name)
+		}
+	}
+	for _, name := range p.Env {
+		if name == "" || strings.Contains(name, "=") {
+			return fmt.Errorf("invalid environment variable name %q", name)
+		}
+	}
+	return nil
+}
+
+// root returns the root with its symlinks resolved, empty when the tools may
+// work anywhere
+func (p *Policy) root() (string, error) {
+	if p == nil || p.Root == "" {
+		return "", nil
+	}
+	root, err := filepath.EvalSymlinks(p.Root)
+	if err != nil {
+		return "", fmt.Errorf("sandbox root unavailable: %w", err)
+	}
+	return root, nil
+}
+
+// resolve returns path made absolute against the root, refusing the paths
+// that lead outside of it, symlinks included
+func (p *Policy) resolve(path string) (string, error) {
+	root, err := p.root()
+	if err != nil || root == "" {
+		return path, err
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(root, path)
+	}
+	real, err := realPath(filepath.Clean(path))
+	if err != nil {
+		return "", err
+	}
+	if !within(root, real) {
+		return "", fmt.Errorf("%s is outside of the sandbox root %s", path, p.Root)
+	}
+	return real, nil
+}
+
+// realPath resolves the symlinks of the part of path that exists
+func realPath(path string) (string, error) {
+	rest := ""
+	for {
+		real, err := filepath.EvalSymlinks(path)
+		if err == nil {
+			return filepath.Join(real, rest), nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return "", err
+		}
+		parent := filepath.Dir(path)
+		if parent == path {
+			return filepath.Join(path, rest), nil
+		}
+		rest = filepath.Join(filepath.Base(path), rest)
+		path = parent
+	}
+}
+
+func within(root, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// env returns the environment of a command, nil to inherit the one of the server
+func (p *Policy) env(root string) []string {
+	if p == nil {
+		return nil
+	}
+	env := []string{"PATH=" + os.Getenv("PATH")}
+	if root != "" {
+		env = append(env, "HOME="+root)
+	}
+	for _, name := range p.Env {
+		if value, ok := os.LookupEnv(name); ok {
+			env = append(env, name+"="+value)
+		}
+	}
+	return env
+}
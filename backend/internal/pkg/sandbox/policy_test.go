@@ -0,0 +1,113 @@
+package sandbox
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// newRoot creates a root with a file, a symlink to it and a symlink to a file
+// outside of the root, and returns the root and the outside directory
+func newRoot(t *testing.T) (string, string) {
+	t.Helper()
+	root, outside := t.TempDir(), t.TempDir()
+	for _, p := range []string{filepath.Join(root, "file"), filepath.Join(outside, "secret")} {
+		if err := os.WriteFile(p, []byte("data"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink(filepath.Join(root, "file"), filepath.Join(root, "link-in")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(outside, "secret"), filepath.Join(root, "link-out")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(outside, filepath.Join(root, "dir-out")); err != nil {
+		t.Fatal(err)
+	}
+	return root, outside
+}
+
+func TestPolicy_Resolve(t *testing.T) {
+	root, outside := newRoot(t)
+	p := &Policy{Root: root}
+
+	tests := []struct {
+		name    string
+		path    string
+		wantErr bool
+	}{
+		{"relative file", "file", false},
+		{"absolute file", filepath.Join(root, "file"), false},
+		{"missing file", "new/file", false},
+		{"symlink inside", "link-in", false},
+		{"symlink outside", "link-out", true},
+		{"under a symlinked directory", "dir-out/secret", true},
+		{"missing file under a symlinked directory", "dir-out/new", true},
+		{"dot dot", "../secret", true},
+		{"dot dot back in", "sub/../file", false},
+		{"absolute outside", filepath.Join(outside, "secret"), true},
+		{"root itself", root, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := p.resolve(tt.path)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("resolve(%q) error = %v, wantErr %v", tt.path, err, tt.wantErr)
+			}
+		})
+	}
+
+	if got, err := (*Policy)(nil).resolve("../anything"); err != nil || got != "../anything" {
+		t.Errorf("resolve() without a policy = %q, %v, want the path unchanged", got, err)
+	}
+}
+
+func TestPolicy_Env(t *testing.T) {
+	t.Setenv("SANDBOX_TEST_KEEP", "kept")
+	t.Setenv("SANDBOX_TEST_DROP", "dropped")
+
+	if env := (*Policy)(nil).env("/srv"); env != nil {
+		t.Errorf("env() without a policy = %v, want nil to inherit the environment", env)
+	}
+
+	env := (&Policy{Env: []string{"SANDBOX_TEST_KEEP", "SANDBOX_TEST_UNSET"}}).env("/srv")
+	for _, want := range []string{"PATH=" + os.Getenv("PATH"), "HOME=/srv", "SANDBOX_TEST_KEEP=kept"} {
+		if !slices.Contains(env, want) {
+			t.Errorf("env() = %v, missing %q", env, want)
+		}
+	}
+	if len(env) != 3 {
+		t.Errorf("env() = %v, want only PATH, HOME and the listed variables", env)
+	}
+
+	if env := (&Policy{}).env(""); len(env) != 1 {
+		t.Errorf("env() without a root = %v, want only PATH", env)
+	}
+}
+
+func TestPolicy_Check(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  *Policy
+		command bool
+		wantErr bool
+	}{
+		{"nil", nil, true, false},
+		{"root with commands", &Policy{Root: "/srv", AllowCommands: []string{"ls"}}, true, false},
+		{"root without commands", &Policy{Root: "/srv"}, true, true},
+		{"root without commands for the file tool", &Policy{Root: "/srv"}, false, false},
+		{"relative root", &Policy{Root: "srv"}, false, true},
+		{"negative limit", &Policy{TimeoutSeconds: -1}, false, true},
+		{"command with a path", &Policy{AllowCommands: []string{"/bin/ls"}}, true, true},
+		{"invalid variable", &Policy{Env: []string{"A=B"}}, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.policy.Check(tt.command); (err != nil) != tt.wantErr {
+				t.Errorf("Check() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
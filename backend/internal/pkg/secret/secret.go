@@ -0,0 +1,132 @@
+package secret
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/xichan96/cortex-lab/internal/config"
+)
+
+// keyFile holds the key generated under the storage path when none is configured
+const keyFile = "secret.key"
+
+// prefix marks the values encrypted by Encrypt, it is bumped if the scheme changes
+const prefix = "v1:"
+
+var (
+	mu  sync.Mutex
+	gcm cipher.AEAD
+)
+
+// aead returns the cipher of the configured key. Without one a random key is
+// generated once and kept next to the uploaded files, so the values survive a
+// restart as long as the storage does.
+func aead() (cipher.AEAD, error) {
+	mu.Lock()
+	defer mu.Unlock()
+	if gcm != nil {
+		return gcm, nil
+	}
+	key := config.Config.SecretKey()
+	if key == "" {
+		var err error
+		if key, err = fileKey(filepath.Join(config.Config.StoragePath(), keyFile)); err != nil {
+			return nil, fmt.Errorf("failed to load the secret key: %w", err)
+		}
+	}
+	sum := sha256.Sum256([]byte(key))
+	block, err := aes.NewCipher(sum[:])
+	if err != nil {
+		return nil, err
+	}
+	if gcm, err = cipher.NewGCM(block); err != nil {
+		return nil, err
+	}
+	return gcm, nil
+}
+
+func fileKey(path string) (string, error) {
+	if data, err := os.ReadFile(path); err == nil {
+		return strings.TrimSpace(string(data)), nil
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return "", err
+	}
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if errors.Is(err, fs.ErrExist) {
+		// another process generated it first
+		return fileKey(path)
+	}
+	if err != nil {
+		return "", err
+	}
+	key := hex.EncodeToString(b)
+	_, err = f.WriteString(key)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return key, err
+}
+
+// Encrypt seals a value to store it in the DB, an empty value stays empty
+func Encrypt(plain string) (string, error) {
+	if plain == "" {
+		return "", nil
+	}
+	gcm, err := aead()
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := gcm.Seal(nonce, nonce, []byte(plain), nil)
+	return prefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// IsEncrypted reports whether a value was sealed by Encrypt, the values
+// stored before a column was encrypted are still plain
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, prefix)
+}
+
+// Decrypt opens a value sealed by Encrypt
+func Decrypt(value string) (string, error) {
+	if value == "" {
+		return "", nil
+	}
+	data, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(value, prefix))
+	if err != nil || !strings.HasPrefix(value, prefix) {
+		return "", fmt.Errorf("the value is not encrypted")
+	}
+	gcm, err := aead()
+	if err != nil {
+		return "", err
+	}
+	if len(data) < gcm.NonceSize() {
+		return "", fmt.Errorf("the encrypted value is truncated")
+	}
+	plain, err := gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt the value, was the secret key changed? %w", err)
+	}
+	return string(plain), nil
+}
@@ -0,0 +1,51 @@
+package cron
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSchedule_Next(t *testing.T) {
+	shanghai, err := time.LoadLocation("Asia/Shanghai")
+	if err != nil {
+		t.Skip(err)
+	}
+	from := time.Date(2024, 1, 31, 8, 30, 15, 0, shanghai) // a Wednesday
+
+	tests := []struct {
+		name string
+		expr string
+		want time.Time
+	}{
+		{"every minute", "* * * * *", time.Date(2024, 1, 31, 8, 31, 0, 0, shanghai)},
+		{"daily at 9:00", "0 9 * * *", time.Date(2024, 1, 31, 9, 0, 0, 0, shanghai)},
+		{"daily at 8:00 is tomorrow", "0 8 * * *", time.Date(2024, 2, 1, 8, 0, 0, 0, shanghai)},
+		{"step", "*/20 * * * *", time.Date(2024, 1, 31, 8, 40, 0, 0, shanghai)},
+		{"weekdays by name", "0 9 * * mon-fri", time.Date(2024, 1, 31, 9, 0, 0, 0, shanghai)},
+		{"sunday as 7", "0 0 * * 7", time.Date(2024, 2, 4, 0, 0, 0, 0, shanghai)},
+		{"leap day", "0 0 29 feb *", time.Date(2024, 2, 29, 0, 0, 0, 0, shanghai)},
+		{"day of month or day of week", "0 0 15 * fri", time.Date(2024, 2, 2, 0, 0, 0, 0, shanghai)},
+		{"macro", "@monthly", time.Date(2024, 2, 1, 0, 0, 0, 0, shanghai)},
+		{"never", "0 0 30 2 *", time.Time{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := Parse(tt.expr)
+			if err != nil {
+				t.Fatalf("Parse(%q) error = %v", tt.expr, err)
+			}
+			if got := s.Next(from); !got.Equal(tt.want) {
+				t.Errorf("Next() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParse_Invalid(t *testing.T) {
+	for _, expr := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "* * * 13 *", "5-1 * * * *", "*/0 * * * *", "* * * * funday"} {
+		if _, err := Parse(expr); err == nil {
+			t.Errorf("Parse(%q) expected an error", expr)
+		}
+	}
+}